@@ -30,6 +30,7 @@ func DoInit(cmd *cobra.Command) {
 	_ = cmd.MarkFlagRequired(options.DBNAME)
 	utils.InitializeSignalHandler(DoCleanup, "backup process", &wasTerminated)
 	objectCounts = make(map[string]int)
+	startTime = time.Now()
 }
 
 func DoFlagValidation(cmd *cobra.Command) {
@@ -41,9 +42,15 @@ func DoFlagValidation(cmd *cobra.Command) {
 func DoSetup() {
 	SetLoggerVerbosity()
 	gplog.Verbose("Backup Command: %s", os.Args)
+	if MustGetFlagString(options.CONFIG) != "" {
+		gplog.Info("Effective flags after applying configuration file %s: %s", MustGetFlagString(options.CONFIG), strings.Join(options.GetEffectiveFlags(cmdFlags), " "))
+	}
 
 	utils.CheckGpexpandRunning(utils.BackupPreventedByGpexpandMessage)
 	timestamp := history.CurrentTimestamp()
+	if MustGetFlagString(options.RESUME) != "" {
+		timestamp = MustGetFlagString(options.RESUME)
+	}
 	createBackupLockFile(timestamp)
 	initializeConnectionPool()
 
@@ -51,7 +58,19 @@ func DoSetup() {
 	opts, err := options.NewOptions(cmdFlags)
 	gplog.FatalOnError(err)
 
+	expandFilterPatterns(opts)
 	validateFilterLists(opts)
+	validateTablePredicates(opts)
+	tablePredicates = opts.TablePredicates
+	validateObjectTypeFilters(opts)
+	includedObjectTypes = opts.IncludedObjectTypes
+	excludedObjectTypes = opts.ExcludedObjectTypes
+	if MustGetFlagBool(options.INCREMENTAL_HEAP) {
+		ValidateTrackCounts(connectionPool)
+	}
+	if maskingPolicyFile := MustGetFlagString(options.MASKING_POLICY_FILE); maskingPolicyFile != "" {
+		maskingPolicy = readMaskingPolicy(maskingPolicyFile)
+	}
 
 	err = opts.ExpandIncludesForPartitions(connectionPool, cmdFlags)
 	gplog.FatalOnError(err)
@@ -68,7 +87,14 @@ func DoSetup() {
 	}
 	globalTOC = &toc.TOC{}
 	globalTOC.InitializeMetadataEntryMap()
-	utils.InitializePipeThroughParameters(!MustGetFlagBool(options.NO_COMPRESSION), MustGetFlagInt(options.COMPRESSION_LEVEL))
+	err = utils.InitializePipeThroughParameters(!MustGetFlagBool(options.NO_COMPRESSION), MustGetFlagString(options.COMPRESSION_TYPE), MustGetFlagInt(options.COMPRESSION_LEVEL))
+	gplog.FatalOnError(err)
+	if !MustGetFlagBool(options.NO_COMPRESSION) && !MustGetFlagBool(options.METADATA_ONLY) {
+		utils.CheckCompressionProgramOnAllHosts(globalCluster, MustGetFlagString(options.COMPRESSION_TYPE))
+	}
+	if MustGetFlagBool(options.ENCRYPT) || MustGetFlagString(options.ENCRYPTION_KEY_FILE) != "" {
+		initializeEncryption()
+	}
 	getQuotedRoleNames(connectionPool)
 
 	pluginConfigFlag := MustGetFlagString(options.PLUGIN_CONFIG)
@@ -84,6 +110,9 @@ func DoSetup() {
 	}
 
 	initializeBackupReport(*opts)
+	if MustGetFlagString(options.RESUME) != "" {
+		prepareToResumeBackup()
+	}
 
 	if pluginConfigFlag != "" {
 		backupReport.PluginVersion = pluginConfig.CheckPluginExistsOnAllHosts(globalCluster)
@@ -111,14 +140,17 @@ func DoBackup() {
 			pluginConfig.MustRestoreFile(targetBackupFPInfo.GetTOCFilePath())
 			pluginConfig.MustRestoreFile(targetBackupFPInfo.GetPluginConfigPath())
 		}
+		ValidateIncrementalChain(targetBackupFPInfo)
 	}
 
 	gplog.Info("Gathering table state information")
 	metadataTables, dataTables := RetrieveAndProcessTables()
 	if !(MustGetFlagBool(options.METADATA_ONLY) || MustGetFlagBool(options.DATA_ONLY)) {
-		backupIncrementalMetadata()
+		backupIncrementalMetadata(dataTables)
 	}
 	CheckTablesContainData(dataTables)
+	ValidateTablePredicatesInBackupSet(dataTables)
+	maskingPolicy.ValidateColumns(connectionPool, dataTables)
 	metadataFilename := globalFPInfo.GetMetadataFilePath()
 	gplog.Info("Metadata will be written to %s", metadataFilename)
 	metadataFile := utils.NewFileWithByteCountFromFile(metadataFilename)
@@ -153,6 +185,9 @@ func DoBackup() {
 		}
 
 		backupReport.RestorePlan = PopulateRestorePlan(backupSetTables, targetBackupRestorePlan, dataTables)
+		if MustGetFlagString(options.RESUME) != "" {
+			backupSetTables = resumeBackupData(backupSetTables)
+		}
 		backupData(backupSetTables)
 	}
 
@@ -165,21 +200,26 @@ func DoBackup() {
 		connectionPool.MustCommit(connNum)
 	}
 	metadataFile.Close()
+	err := utils.EncryptBackupFile(metadataFilename)
+	gplog.FatalOnError(err)
+	writeChecksumManifest()
 	if pluginConfigFlag != "" {
 		pluginConfig.MustBackupFile(metadataFilename)
 		pluginConfig.MustBackupFile(globalFPInfo.GetTOCFilePath())
 		if MustGetFlagBool(options.WITH_STATS) {
 			pluginConfig.MustBackupFile(globalFPInfo.GetStatisticsFilePath())
 		}
+		pluginConfig.MustBackupFile(globalFPInfo.GetChecksumManifestFilePath())
 		_ = utils.CopyFile(pluginConfigFlag, globalFPInfo.GetPluginConfigPath())
 		pluginConfig.MustBackupFile(globalFPInfo.GetPluginConfigPath())
 	}
 
-	err := history.WriteBackupHistory(globalFPInfo.GetBackupHistoryFilePath(), &backupReport.BackupConfig)
+	err = history.WriteBackupHistory(globalFPInfo.GetBackupHistoryFilePath(), &backupReport.BackupConfig)
 	gplog.FatalOnError(err)
 }
 
 func backupGlobals(metadataFile *utils.FileWithByteCount) {
+	defer phaseTimings.Record("globals", time.Now())
 	gplog.Info("Writing global database metadata")
 
 	backupResourceQueues(metadataFile)
@@ -195,6 +235,7 @@ func backupGlobals(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupPredata(metadataFile *utils.FileWithByteCount, tables []Table, tableOnly bool) {
+	defer phaseTimings.Record("predata", time.Now())
 	if wasTerminated {
 		return
 	}
@@ -239,6 +280,7 @@ func backupPredata(metadataFile *utils.FileWithByteCount, tables []Table, tableO
 }
 
 func backupData(tables []Table) {
+	defer phaseTimings.Record("data", time.Now())
 	if len(tables) == 0 {
 		// No incremental data changes to backup
 		gplog.Info("No tables to backup")
@@ -257,10 +299,13 @@ func backupData(tables []Table) {
 		}
 		utils.WriteOidListToSegments(oidList, globalCluster, globalFPInfo)
 		utils.CreateFirstSegmentPipeOnAllHosts(oidList[0], globalCluster, globalFPInfo)
-		compressStr := fmt.Sprintf(" --compression-level %d", MustGetFlagInt(options.COMPRESSION_LEVEL))
+		compressStr := fmt.Sprintf(" --compression-level %d --compression-type %s", MustGetFlagInt(options.COMPRESSION_LEVEL), MustGetFlagString(options.COMPRESSION_TYPE))
 		if MustGetFlagBool(options.NO_COMPRESSION) {
 			compressStr = " --compression-level 0"
 		}
+		if encryptionKeyFile != "" {
+			compressStr += fmt.Sprintf(" --encryption-key-file %s", encryptionKeyFile)
+		}
 		// Do not pass through the --on-error-continue flag because it does not apply to gpbackup
 		utils.StartGpbackupHelpers(globalCluster, globalFPInfo, "--backup-agent",
 			MustGetFlagString(options.PLUGIN_CONFIG), compressStr, false)
@@ -268,14 +313,20 @@ func backupData(tables []Table) {
 	gplog.Info("Writing data to file")
 	rowsCopiedMaps := backupDataForAllTables(tables)
 	AddTableDataEntriesToTOC(tables, rowsCopiedMaps)
-	if MustGetFlagBool(options.SINGLE_DATA_FILE) && MustGetFlagString(options.PLUGIN_CONFIG) != "" {
-		pluginConfig.BackupSegmentTOCs(globalCluster, globalFPInfo)
+	if MustGetFlagBool(options.SINGLE_DATA_FILE) {
+		if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
+			pluginConfig.BackupSegmentTOCs(globalCluster, globalFPInfo)
+		} else {
+			utils.WaitForSegmentTOCs(globalCluster, globalFPInfo, "Waiting for gpbackup_helper to finish writing data files",
+				"See gpAdminLog for gpbackup_helper on segment host for details: Error occurred in gpbackup_helper")
+		}
 	}
 
 	logCompletionMessage("Data backup")
 }
 
 func backupPostdata(metadataFile *utils.FileWithByteCount) {
+	defer phaseTimings.Record("postdata", time.Now())
 	if wasTerminated {
 		return
 	}
@@ -295,21 +346,34 @@ func backupPostdata(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupStatistics(tables []Table) {
+	defer phaseTimings.Record("statistics", time.Now())
 	if wasTerminated {
 		return
 	}
 	statisticsFilename := globalFPInfo.GetStatisticsFilePath()
 	gplog.Info("Writing query planner statistics to %s", statisticsFilename)
 	statisticsFile := utils.NewFileWithByteCountFromFile(statisticsFilename)
-	defer statisticsFile.Close()
 	backupTableStatistics(statisticsFile, tables)
+	statisticsFile.Close()
+	err := utils.EncryptBackupFile(statisticsFilename)
+	gplog.FatalOnError(err)
 
 	logCompletionMessage("Query planner statistics backup")
 }
 
+func writeChecksumManifest() {
+	gplog.Info("Writing checksum manifest")
+	masterFiles := []string{globalFPInfo.GetMetadataFilePath(), globalFPInfo.GetTOCFilePath()}
+	if MustGetFlagBool(options.WITH_STATS) {
+		masterFiles = append(masterFiles, globalFPInfo.GetStatisticsFilePath())
+	}
+	utils.WriteChecksumManifest(globalCluster, globalFPInfo, masterFiles, !MustGetFlagBool(options.METADATA_ONLY))
+}
+
 func DoTeardown() {
 	backupFailed := false
 	defer func() {
+		writeMetricsFile(backupFailed)
 		DoCleanup(backupFailed)
 
 		errorCode := gplog.GetErrorCode()
@@ -356,6 +420,7 @@ func DoTeardown() {
 			return
 		}
 		reportFilename := globalFPInfo.GetBackupReportFilePath()
+		jsonReportFilename := globalFPInfo.GetBackupJSONReportFilePath()
 		configFilename := globalFPInfo.GetConfigFilePath()
 
 		time.Sleep(time.Second) // We sleep for 1 second to ensure multiple backups do not start within the same second.
@@ -368,7 +433,8 @@ func DoTeardown() {
 			}
 			endtime, _ := time.ParseInLocation("20060102150405", backupReport.BackupConfig.EndTime, operating.System.Local)
 			backupReport.WriteBackupReportFile(reportFilename, globalFPInfo.Timestamp, endtime, objectCounts, errMsg)
-			report.EmailReport(globalCluster, globalFPInfo.Timestamp, reportFilename, "gpbackup")
+			backupReport.WriteBackupJSONReportFile(jsonReportFilename, globalFPInfo.Timestamp, endtime, objectCounts, tableResults.Sorted(), errMsg)
+			report.SendReportNotifications(globalCluster, globalFPInfo.Timestamp, reportFilename, "gpbackup")
 			if pluginConfig != nil {
 				err := pluginConfig.BackupFile(configFilename)
 				if err != nil {
@@ -380,6 +446,11 @@ func DoTeardown() {
 					gplog.Error(fmt.Sprintf("%v", err))
 					return
 				}
+				err = pluginConfig.BackupFile(jsonReportFilename)
+				if err != nil {
+					gplog.Error(fmt.Sprintf("%v", err))
+					return
+				}
 			}
 		}
 		if pluginConfig != nil {
@@ -400,6 +471,9 @@ func DoCleanup(backupFailed bool) {
 
 	gplog.Verbose("Beginning cleanup")
 	if globalFPInfo.Timestamp != "" {
+		if backupFailed {
+			writePartialBackupFiles()
+		}
 		if MustGetFlagBool(options.SINGLE_DATA_FILE) {
 			if backupFailed {
 				// Cleanup only if terminated or fataled
@@ -412,6 +486,9 @@ func DoCleanup(backupFailed bool) {
 			utils.CleanUpHelperFilesOnAllHosts(globalCluster, globalFPInfo)
 		}
 	}
+	if encryptionKeyFile != "" {
+		utils.DeleteEncryptionKeyFromAllHosts(globalCluster, encryptionKeyFile)
+	}
 	err := backupLockFile.Unlock()
 	if err != nil && backupLockFile != "" {
 		gplog.Warn("Failed to remove lock file %s.", backupLockFile)
@@ -2,6 +2,8 @@ package backup
 
 import (
 	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/spf13/pflag"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -22,4 +24,104 @@ var _ = Describe("backup internal tests", func() {
 			Expect(string(log.Contents())).To(ContainSubstring("Data backup complete"))
 		})
 	})
+	Describe("parseSegmentDataBytes", func() {
+		It("sums the sizes of the data files of a segment", func() {
+			numBytes, err := parseSegmentDataBytes("100\n250\n\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(numBytes).To(Equal(uint64(350)))
+		})
+		It("returns an error for an invalid file size", func() {
+			_, err := parseSegmentDataBytes("find: permission denied\n")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Describe("shouldBackupObjectType", func() {
+		AfterEach(func() {
+			SetObjectTypeFilters(nil, nil)
+		})
+		It("backs up every object type when no object types are filtered", func() {
+			Expect(shouldBackupObjectType("TRIGGER")).To(BeTrue())
+		})
+		It("does not back up excluded object types", func() {
+			SetObjectTypeFilters(nil, []string{"TRIGGER", "RULE"})
+
+			Expect(shouldBackupObjectType("TRIGGER")).To(BeFalse())
+			Expect(shouldBackupObjectType("INDEX")).To(BeTrue())
+		})
+		It("backs up only included object types", func() {
+			SetObjectTypeFilters([]string{"FUNCTION", "VIEW"}, nil)
+
+			Expect(shouldBackupObjectType("VIEW")).To(BeTrue())
+			Expect(shouldBackupObjectType("INDEX")).To(BeFalse())
+		})
+		It("backs up the object types that tables depend on when other object types are included", func() {
+			SetObjectTypeFilters([]string{"FUNCTION"}, nil)
+
+			Expect(shouldBackupObjectType("SCHEMA")).To(BeTrue())
+			Expect(shouldBackupObjectType("SEQUENCE")).To(BeTrue())
+			Expect(shouldBackupObjectType("TYPE")).To(BeTrue())
+			Expect(shouldBackupObjectType("DOMAIN")).To(BeTrue())
+		})
+	})
+	Describe("validateObjectTypeFilters", func() {
+		It("does not allow excluding an object type that tables depend on", func() {
+			defer testhelper.ShouldPanicWithMessage("Cannot exclude object type SEQUENCE during backup, as the tables in the backup may depend on it.")
+			validateObjectTypeFilters(&options.Options{ExcludedObjectTypes: []string{"TRIGGER", "SEQUENCE"}})
+		})
+	})
+	Describe("breakCircularDependencies", func() {
+		It("removes the dependencies of functions on the type that depends on them and returns them", func() {
+			function := UniqueID{ClassID: PG_PROC_OID, Oid: 1}
+			baseType := UniqueID{ClassID: PG_TYPE_OID, Oid: 2}
+			table := UniqueID{ClassID: PG_CLASS_OID, Oid: 3}
+			depMap := DependencyMap{
+				function: {baseType: true},
+				baseType: {function: true},
+				table:    {baseType: true},
+			}
+
+			brokenDeps := breakCircularDependencies(depMap)
+
+			Expect(brokenDeps).To(Equal([]BrokenDependency{{Object: function, Reference: baseType}}))
+			Expect(depMap).To(Equal(DependencyMap{
+				baseType: {function: true},
+				table:    {baseType: true},
+			}))
+		})
+	})
+	Describe("validateFlagCombinations", func() {
+		var flags *pflag.FlagSet
+		BeforeEach(func() {
+			flags = pflag.NewFlagSet("gpbackup", pflag.ExitOnError)
+			SetCmdFlags(flags)
+		})
+		It("allows an incremental backup with a single data file", func() {
+			_ = flags.Set(options.INCREMENTAL, "true")
+			_ = flags.Set(options.SINGLE_DATA_FILE, "true")
+			_ = flags.Set(options.LEAF_PARTITION_DATA, "true")
+
+			validateFlagCombinations(flags)
+		})
+		It("does not allow a resumed incremental backup", func() {
+			_ = flags.Set(options.RESUME, "20190101010101")
+			_ = flags.Set(options.INCREMENTAL, "true")
+			_ = flags.Set(options.LEAF_PARTITION_DATA, "true")
+
+			defer testhelper.ShouldPanicWithMessage("The following flags may not be specified together: resume, incremental")
+			validateFlagCombinations(flags)
+		})
+		It("allows a metadata-only backup with statistics", func() {
+			_ = flags.Set(options.METADATA_ONLY, "true")
+			_ = flags.Set(options.WITH_STATS, "true")
+
+			validateFlagCombinations(flags)
+		})
+		It("does not allow a masked backup with statistics", func() {
+			_ = flags.Set(options.MASKING_POLICY_FILE, "/tmp/masking_policy")
+			_ = flags.Set(options.WITH_STATS, "true")
+
+			defer testhelper.ShouldPanicWithMessage("The following flags may not be specified together: masking-policy-file, with-stats")
+			validateFlagCombinations(flags)
+		})
+	})
 })
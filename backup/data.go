@@ -9,6 +9,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
@@ -43,7 +44,7 @@ func AddTableDataEntriesToTOC(tables []Table, rowsCopiedMaps []map[uint32]int64)
 				}
 			}
 			attributes := ConstructTableAttributesList(table.ColumnDefs)
-			globalTOC.AddMasterDataEntry(table.Schema, table.Name, table.Oid, attributes, rowsCopied, table.PartitionLevelInfo.RootName)
+			globalTOC.AddMasterDataEntry(table.Schema, table.Name, table.Oid, attributes, rowsCopied, table.PartitionLevelInfo.RootName, tablePredicates[table.FQN()])
 		}
 	}
 }
@@ -67,13 +68,28 @@ func CopyTableOut(connectionPool *dbconn.DBConn, table Table, destinationToWrite
 		 */
 		checkPipeExistsCommand = fmt.Sprintf("(test -p \"%s\" || (echo \"Pipe not found %s\">&2; exit 1)) && ", destinationToWrite, destinationToWrite)
 		customPipeThroughCommand = "cat -"
-	} else if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
-		sendToDestinationCommand = fmt.Sprintf("| %s backup_data %s", pluginConfig.ExecutablePath, pluginConfig.ConfigPath)
+	} else {
+		/*
+		 * The checksum of each data file is computed as it is written and stored
+		 * next to where the file is written, where it will be collected into the
+		 * checksum manifest.  The data sent to a plugin is never written to the
+		 * segment, and the checksum of a local file is used to check that the
+		 * file is complete if the backup is resumed.
+		 */
+		customPipeThroughCommand = fmt.Sprintf("{ %s | tee /dev/fd/3 | sha256sum > %s%s; }", customPipeThroughCommand, destinationToWrite, utils.ChecksumFileSuffix)
+		sendToDestinationCommand = "3>"
+		if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
+			customPipeThroughCommand += " 3>&1"
+			sendToDestinationCommand = fmt.Sprintf("| %s backup_data %s", pluginConfig.ExecutablePath, pluginConfig.ConfigPath)
+		}
 	}
 
 	copyCommand := fmt.Sprintf("PROGRAM '%s%s %s %s'", checkPipeExistsCommand, customPipeThroughCommand, sendToDestinationCommand, destinationToWrite)
 
 	query := fmt.Sprintf("COPY %s TO %s WITH CSV DELIMITER '%s' ON SEGMENT IGNORE EXTERNAL PARTITIONS;", table.FQN(), copyCommand, tableDelim)
+	if predicate, ok := tablePredicates[table.FQN()]; ok || maskingPolicy.IsMasked(table) {
+		query = fmt.Sprintf("COPY (%s) TO %s WITH CSV DELIMITER '%s' ON SEGMENT;", getTableSelectQuery(table, predicate), copyCommand, tableDelim)
+	}
 	gplog.Verbose(query)
 	result, err := connectionPool.Exec(query, connNum)
 	if err != nil {
@@ -83,9 +99,31 @@ func CopyTableOut(connectionPool *dbconn.DBConn, table Table, destinationToWrite
 	return numRows, nil
 }
 
+/*
+ * Tables with a predicate or masked columns are backed up with a query, whose
+ * columns are selected in the order of the attribute list that the data is
+ * restored with.
+ */
+func getTableSelectQuery(table Table, predicate string) string {
+	columns := make([]string, 0, len(table.ColumnDefs))
+	for _, col := range table.ColumnDefs {
+		columns = append(columns, maskingPolicy.GetColumnExpression(table, col.Name))
+	}
+	columnStr := "*"
+	if len(columns) > 0 {
+		columnStr = strings.Join(columns, ",")
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", columnStr, table.FQN())
+	if predicate != "" {
+		query += fmt.Sprintf(" WHERE %s", predicate)
+	}
+	return query
+}
+
 func BackupSingleTableData(table Table, rowsCopiedMap map[uint32]int64, counters *BackupProgressCounters, whichConn int) error {
 	if table.SkipDataBackup() {
 		gplog.Verbose("Skipping data backup of table %s because it is either an external or foreign table.", table.FQN())
+		tableResults.AddSkipped(table.FQN())
 	} else {
 
 		atomic.AddInt64(&counters.NumRegTables, 1)
@@ -103,11 +141,14 @@ func BackupSingleTableData(table Table, rowsCopiedMap map[uint32]int64, counters
 		} else {
 			destinationToWrite = globalFPInfo.GetTableBackupFilePathForCopyCommand(table.Oid, utils.GetPipeThroughProgram().Extension, false)
 		}
+		start := time.Now()
 		rowsCopied, err := CopyTableOut(connectionPool, table, destinationToWrite, whichConn)
+		tableResults.Add(table.FQN(), rowsCopied, time.Since(start), err)
 		if err != nil {
 			return err
 		}
 		rowsCopiedMap[table.Oid] = rowsCopied
+		recordCompletedTable(table, rowsCopied)
 		counters.ProgressBar.Increment()
 	}
 	return nil
@@ -2,6 +2,7 @@ package backup
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
@@ -145,6 +146,12 @@ type UniqueID struct {
 
 // This function only returns dependencies that are referenced in the backup set
 func GetDependencies(connectionPool *dbconn.DBConn, backupSet map[UniqueID]bool) DependencyMap {
+	dependencyMap := getCatalogDependencies(connectionPool, backupSet)
+	breakCircularDependencies(dependencyMap)
+	return dependencyMap
+}
+
+func getCatalogDependencies(connectionPool *dbconn.DBConn, backupSet map[UniqueID]bool) DependencyMap {
 	query := fmt.Sprintf(`SELECT
 	coalesce(id1.refclassid, d.classid) AS classid,
 	coalesce(id1.refobjid, d.objid) AS objid,
@@ -204,12 +211,22 @@ AND typelem != 0`)
 		dependencyMap[object][referenceObject] = true
 	}
 
-	breakCircularDependencies(dependencyMap)
-
 	return dependencyMap
 }
 
-func breakCircularDependencies(depMap DependencyMap) {
+// A dependency that was removed from the dependency map to break a cycle
+type BrokenDependency struct {
+	Object    UniqueID
+	Reference UniqueID
+}
+
+/*
+ * A base type and its input and output functions depend on each other, so
+ * the dependencies of the functions on the type are removed; the functions
+ * are created first using a shell type, which the type then replaces.
+ */
+func breakCircularDependencies(depMap DependencyMap) []BrokenDependency {
+	brokenDeps := make([]BrokenDependency, 0)
 	for entry, deps := range depMap {
 		for dep := range deps {
 			if _, ok := depMap[dep]; ok && entry.ClassID == PG_TYPE_OID && dep.ClassID == PG_PROC_OID {
@@ -219,18 +236,26 @@ func breakCircularDependencies(depMap DependencyMap) {
 					} else {
 						delete(depMap[dep], entry)
 					}
+					brokenDeps = append(brokenDeps, BrokenDependency{Object: dep, Reference: entry})
 				}
 			}
 		}
 	}
+	return brokenDeps
 }
 
-func PrintDependentObjectStatements(metadataFile *utils.FileWithByteCount, toc *toc.TOC, objects []Sortable, metadataMap MetadataMap, constraints []Constraint, funcInfoMap map[uint32]FunctionInfo) {
+/*
+ * Returns the indices of the pre-data TOC entries printed for each object, so
+ * that the dependencies between objects can be recorded between their entries.
+ */
+func PrintDependentObjectStatements(metadataFile *utils.FileWithByteCount, toc *toc.TOC, objects []Sortable, metadataMap MetadataMap, constraints []Constraint, funcInfoMap map[uint32]FunctionInfo) map[UniqueID][]int {
 	conMap := make(map[string][]Constraint)
 	for _, constraint := range constraints {
 		conMap[constraint.OwningObject] = append(conMap[constraint.OwningObject], constraint)
 	}
+	objectEntries := make(map[UniqueID][]int, len(objects))
 	for _, object := range objects {
+		firstEntry := len(toc.PredataEntries)
 		objMetadata := metadataMap[object.GetUniqueID()]
 		switch obj := object.(type) {
 		case BaseType:
@@ -274,9 +299,42 @@ func PrintDependentObjectStatements(metadataFile *utils.FileWithByteCount, toc *
 		case MaterializedView:
 			PrintCreateMaterializedViewStatement(metadataFile, toc, obj, objMetadata)
 		}
+		for i := firstEntry; i < len(toc.PredataEntries); i++ {
+			objectEntries[object.GetUniqueID()] = append(objectEntries[object.GetUniqueID()], i)
+		}
 		// Remove ACLs from metadataMap for the current object since they have been processed
 		delete(metadataMap, object.GetUniqueID())
 	}
 	//  Process ACLs for left over objects in the metadata map
 	printExtensionFunctionACLs(metadataFile, toc, metadataMap, funcInfoMap)
+	return objectEntries
+}
+
+/*
+ * Each pre-data entry of a dependent object is given an ID, so that gprestore
+ * can create objects in parallel once the objects they depend on exist.  The
+ * first entry of an object depends on the last entry of each object that the
+ * object depends on, and each other entry, such as a COMMENT or an ALTER ...
+ * OWNER statement, depends on the entry before it.  Entries without an ID are
+ * restored only after all entries before them.
+ */
+func AddPredataEntryDependencies(toc *toc.TOC, objectEntries map[UniqueID][]int, dependencies DependencyMap) {
+	for object, entries := range objectEntries {
+		for i, entryIndex := range entries {
+			entry := &toc.PredataEntries[entryIndex]
+			entry.ID = uint64(entryIndex + 1)
+			if i > 0 {
+				entry.DependsOn = []uint64{uint64(entries[i-1] + 1)}
+				continue
+			}
+			for reference := range dependencies[object] {
+				if referenceEntries := objectEntries[reference]; len(referenceEntries) > 0 {
+					entry.DependsOn = append(entry.DependsOn, uint64(referenceEntries[len(referenceEntries)-1]+1))
+				}
+			}
+			sort.Slice(entry.DependsOn, func(i, j int) bool {
+				return entry.DependsOn[i] < entry.DependsOn[j]
+			})
+		}
+	}
 }
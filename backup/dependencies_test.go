@@ -4,6 +4,7 @@ import (
 	"github.com/greenplum-db/gp-common-go-libs/testhelper"
 	"github.com/greenplum-db/gpbackup/backup"
 	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/greenplum-db/gpbackup/toc"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -223,5 +224,50 @@ CREATE TRUSTED PROTOCOL ext_protocol (readfunc = public.read_from_s3, writefunc
 COMMENT ON PROTOCOL ext_protocol IS 'protocol';
 `)
 		})
+		It("returns the indices of the pre-data TOC entries of each object", func() {
+			objectEntries := backup.PrintDependentObjectStatements(backupfile, tocfile, objects[:2], metadataMap, []backup.Constraint{}, funcInfoMap)
+
+			Expect(objectEntries).To(Equal(map[backup.UniqueID][]int{
+				{ClassID: backup.PG_PROC_OID, Oid: 1}: {0, 1},
+				{ClassID: backup.PG_TYPE_OID, Oid: 2}: {2, 3},
+			}))
+			Expect(tocfile.PredataEntries[1].ObjectType).To(Equal("FUNCTION"))
+			Expect(tocfile.PredataEntries[2].ObjectType).To(Equal("TYPE"))
+		})
+	})
+	Describe("AddPredataEntryDependencies", func() {
+		It("makes the first entry of each object depend on the last entry of the objects it depends on", func() {
+			tocfile.PredataEntries = []toc.MetadataEntry{
+				{Schema: "public", Name: "schema_entry", ObjectType: "SCHEMA"},
+				{Schema: "public", Name: "base", ObjectType: "TYPE"},
+				{Schema: "public", Name: "base", ObjectType: "TYPE"},
+				{Schema: "public", Name: "composite", ObjectType: "TYPE"},
+				{Schema: "public", Name: "relation", ObjectType: "TABLE"},
+				{Schema: "public", Name: "relation", ObjectType: "TABLE"},
+			}
+			baseType := backup.UniqueID{ClassID: backup.PG_TYPE_OID, Oid: 2}
+			compositeType := backup.UniqueID{ClassID: backup.PG_TYPE_OID, Oid: 3}
+			table := backup.UniqueID{ClassID: backup.PG_CLASS_OID, Oid: 5}
+			objectEntries := map[backup.UniqueID][]int{
+				baseType:      {1, 2},
+				compositeType: {3},
+				table:         {4, 5},
+			}
+			depMap = backup.DependencyMap{
+				compositeType: {baseType: true},
+				table:         {baseType: true, compositeType: true},
+			}
+
+			backup.AddPredataEntryDependencies(tocfile, objectEntries, depMap)
+
+			Expect(tocfile.PredataEntries).To(Equal([]toc.MetadataEntry{
+				{Schema: "public", Name: "schema_entry", ObjectType: "SCHEMA"},
+				{Schema: "public", Name: "base", ObjectType: "TYPE", ID: 2},
+				{Schema: "public", Name: "base", ObjectType: "TYPE", ID: 3, DependsOn: []uint64{2}},
+				{Schema: "public", Name: "composite", ObjectType: "TYPE", ID: 4, DependsOn: []uint64{3}},
+				{Schema: "public", Name: "relation", ObjectType: "TABLE", ID: 5, DependsOn: []uint64{3, 4}},
+				{Schema: "public", Name: "relation", ObjectType: "TABLE", ID: 6, DependsOn: []uint64{5}},
+			}))
+		})
 	})
 })
@@ -0,0 +1,148 @@
+package backup
+
+/*
+ * This file contains functions for writing the dependency graph used to sort
+ * dependent objects, so that the order in which objects are created can be
+ * diagnosed when a restore fails because an object does not exist yet.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/toc"
+)
+
+type DependencyGraphNode struct {
+	ID         string   `json:"id"`
+	ClassID    uint32   `json:"class_id"`
+	Oid        uint32   `json:"oid"`
+	ObjectType string   `json:"object_type"`
+	FQN        string   `json:"fqn"`
+	DependsOn  []string `json:"depends_on"`
+}
+
+type DependencyGraphEdge struct {
+	Object    string `json:"object"`
+	Reference string `json:"reference"`
+}
+
+type DependencyGraph struct {
+	Nodes []DependencyGraphNode `json:"nodes"`
+	// Dependencies that were removed to break cycles, so they are not in DependsOn
+	BrokenDependencies []DependencyGraphEdge `json:"broken_dependencies"`
+}
+
+// Nodes are identified by the class ID and OID of their UniqueID
+func getGraphNodeID(uniqueID UniqueID) string {
+	return fmt.Sprintf("%d:%d", uniqueID.ClassID, uniqueID.Oid)
+}
+
+/*
+ * Nodes are listed in the order in which the objects were retrieved, and each
+ * node lists the objects it depends on, which must be created before it.
+ */
+func NewDependencyGraph(objects []Sortable, dependencies DependencyMap, brokenDeps []BrokenDependency) DependencyGraph {
+	graph := DependencyGraph{
+		Nodes:              make([]DependencyGraphNode, 0, len(objects)),
+		BrokenDependencies: make([]DependencyGraphEdge, 0, len(brokenDeps)),
+	}
+	for _, object := range objects {
+		uniqueID := object.GetUniqueID()
+		dependsOn := make([]string, 0, len(dependencies[uniqueID]))
+		for reference := range dependencies[uniqueID] {
+			dependsOn = append(dependsOn, getGraphNodeID(reference))
+		}
+		sort.Strings(dependsOn)
+		graph.Nodes = append(graph.Nodes, DependencyGraphNode{
+			ID:         getGraphNodeID(uniqueID),
+			ClassID:    uniqueID.ClassID,
+			Oid:        uniqueID.Oid,
+			ObjectType: getSortableObjectType(object),
+			FQN:        object.FQN(),
+			DependsOn:  dependsOn,
+		})
+	}
+	for _, brokenDep := range brokenDeps {
+		graph.BrokenDependencies = append(graph.BrokenDependencies,
+			DependencyGraphEdge{Object: getGraphNodeID(brokenDep.Object), Reference: getGraphNodeID(brokenDep.Reference)})
+	}
+	sort.Slice(graph.BrokenDependencies, func(i, j int) bool {
+		if graph.BrokenDependencies[i].Object != graph.BrokenDependencies[j].Object {
+			return graph.BrokenDependencies[i].Object < graph.BrokenDependencies[j].Object
+		}
+		return graph.BrokenDependencies[i].Reference < graph.BrokenDependencies[j].Reference
+	})
+	return graph
+}
+
+func getSortableObjectType(object Sortable) string {
+	if tocObject, ok := object.(toc.TOCObject); ok {
+		_, entry := tocObject.GetMetadataEntry()
+		return entry.ObjectType
+	}
+	return ""
+}
+
+// Edges point from each object to the objects it depends on, and broken dependencies are dashed
+func (graph DependencyGraph) FormatDOT() string {
+	var builder strings.Builder
+	builder.WriteString("digraph dependencies {\n")
+	for _, node := range graph.Nodes {
+		builder.WriteString(fmt.Sprintf("\t\"%s\" [label=\"%s\\n%s\"];\n", node.ID, escapeDOTString(node.ObjectType), escapeDOTString(node.FQN)))
+	}
+	for _, node := range graph.Nodes {
+		for _, reference := range node.DependsOn {
+			builder.WriteString(fmt.Sprintf("\t\"%s\" -> \"%s\";\n", node.ID, reference))
+		}
+	}
+	for _, edge := range graph.BrokenDependencies {
+		builder.WriteString(fmt.Sprintf("\t\"%s\" -> \"%s\" [style=dashed, color=red, label=\"broken\"];\n", edge.Object, edge.Reference))
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+func escapeDOTString(str string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(str)
+}
+
+func (graph DependencyGraph) FormatCycleReport() string {
+	if len(graph.BrokenDependencies) == 0 {
+		return "No dependency cycles were broken.\n"
+	}
+	nodes := make(map[string]DependencyGraphNode, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		nodes[node.ID] = node
+	}
+	describe := func(id string) string {
+		if node, ok := nodes[id]; ok {
+			return fmt.Sprintf("%s %s (%s)", node.ObjectType, node.FQN, id)
+		}
+		return id
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("%d dependency cycle(s) were broken:\n", len(graph.BrokenDependencies)))
+	for _, edge := range graph.BrokenDependencies {
+		builder.WriteString(fmt.Sprintf("\n%s and %s depend on each other.\n", describe(edge.Object), describe(edge.Reference)))
+		builder.WriteString("The dependency of the first on the second was removed, so the first is created before the second, using a shell type in its place.\n")
+	}
+	return builder.String()
+}
+
+func WriteDependencyGraph(graphPath string, objects []Sortable, dependencies DependencyMap, brokenDeps []BrokenDependency) {
+	graph := NewDependencyGraph(objects, dependencies, brokenDeps)
+	gplog.Info("Writing dependency graph of %d objects to %s.dot and %s.json", len(graph.Nodes), graphPath, graphPath)
+	err := ioutil.WriteFile(graphPath+".dot", []byte(graph.FormatDOT()), 0644)
+	gplog.FatalOnError(err)
+	contents, err := json.MarshalIndent(graph, "", "  ")
+	gplog.FatalOnError(err)
+	err = ioutil.WriteFile(graphPath+".json", append(contents, '\n'), 0644)
+	gplog.FatalOnError(err)
+	err = ioutil.WriteFile(graphPath+"_cycles.txt", []byte(graph.FormatCycleReport()), 0644)
+	gplog.FatalOnError(err)
+}
@@ -0,0 +1,76 @@
+package backup_test
+
+import (
+	"github.com/greenplum-db/gpbackup/backup"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backup/dependency_graph tests", func() {
+	var (
+		function   backup.Function
+		baseType   backup.BaseType
+		objects    []backup.Sortable
+		depMap     backup.DependencyMap
+		brokenDeps []backup.BrokenDependency
+	)
+
+	BeforeEach(func() {
+		function = backup.Function{Oid: 1, Schema: "public", Name: "type_in", IdentArgs: "cstring"}
+		baseType = backup.BaseType{Oid: 2, Schema: "public", Name: "base_type"}
+		objects = []backup.Sortable{function, baseType}
+		depMap = backup.DependencyMap{
+			backup.UniqueID{ClassID: backup.PG_TYPE_OID, Oid: 2}: {backup.UniqueID{ClassID: backup.PG_PROC_OID, Oid: 1}: true},
+		}
+		brokenDeps = []backup.BrokenDependency{
+			{Object: backup.UniqueID{ClassID: backup.PG_PROC_OID, Oid: 1}, Reference: backup.UniqueID{ClassID: backup.PG_TYPE_OID, Oid: 2}},
+		}
+	})
+	Describe("NewDependencyGraph", func() {
+		It("creates a node for each object with the objects it depends on", func() {
+			graph := backup.NewDependencyGraph(objects, depMap, brokenDeps)
+
+			Expect(graph.Nodes).To(Equal([]backup.DependencyGraphNode{
+				{ID: "1255:1", ClassID: backup.PG_PROC_OID, Oid: 1, ObjectType: "FUNCTION", FQN: "public.type_in(cstring)", DependsOn: []string{}},
+				{ID: "1247:2", ClassID: backup.PG_TYPE_OID, Oid: 2, ObjectType: "TYPE", FQN: "public.base_type", DependsOn: []string{"1255:1"}},
+			}))
+			Expect(graph.BrokenDependencies).To(Equal([]backup.DependencyGraphEdge{{Object: "1255:1", Reference: "1247:2"}}))
+		})
+	})
+	Describe("FormatDOT", func() {
+		It("writes a node for each object and an edge for each dependency", func() {
+			graph := backup.NewDependencyGraph(objects, depMap, brokenDeps)
+
+			Expect(graph.FormatDOT()).To(Equal(`digraph dependencies {
+	"1255:1" [label="FUNCTION\npublic.type_in(cstring)"];
+	"1247:2" [label="TYPE\npublic.base_type"];
+	"1247:2" -> "1255:1";
+	"1255:1" -> "1247:2" [style=dashed, color=red, label="broken"];
+}
+`))
+		})
+		It("escapes quotes in object names", func() {
+			quotedType := backup.BaseType{Oid: 2, Schema: "public", Name: `"Base Type"`}
+			graph := backup.NewDependencyGraph([]backup.Sortable{quotedType}, backup.DependencyMap{}, []backup.BrokenDependency{})
+
+			Expect(graph.FormatDOT()).To(ContainSubstring(`"1247:2" [label="TYPE\npublic.\"Base Type\""];`))
+		})
+	})
+	Describe("FormatCycleReport", func() {
+		It("describes each dependency that was removed to break a cycle", func() {
+			graph := backup.NewDependencyGraph(objects, depMap, brokenDeps)
+
+			Expect(graph.FormatCycleReport()).To(Equal(`1 dependency cycle(s) were broken:
+
+FUNCTION public.type_in(cstring) (1255:1) and TYPE public.base_type (1247:2) depend on each other.
+The dependency of the first on the second was removed, so the first is created before the second, using a shell type in its place.
+`))
+		})
+		It("reports that no cycles were broken", func() {
+			graph := backup.NewDependencyGraph(objects, depMap, []backup.BrokenDependency{})
+
+			Expect(graph.FormatCycleReport()).To(Equal("No dependency cycles were broken.\n"))
+		})
+	})
+})
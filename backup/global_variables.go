@@ -3,6 +3,7 @@ package backup
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/greenplum-db/gp-common-go-libs/cluster"
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
@@ -38,6 +39,22 @@ var (
 	backupLockFile       lockfile.Lockfile
 	filterRelationClause string
 	quotedRoleNames      map[string]string
+	resumeTOC            *toc.TOC
+	encryptionKeyFile    string
+	tablePredicates      map[string]string
+	includedObjectTypes  []string
+	excludedObjectTypes  []string
+	maskingPolicy        *MaskingPolicy
+	tableResults         report.TableResults
+	phaseTimings         report.PhaseTimings
+	startTime            time.Time
+
+	// Heap table change counters, read before the backup's snapshot is taken
+	heapIncrementalMetadata map[string]toc.HeapEntry
+
+	// Data entries of the tables whose data has been completely backed up
+	completedDataTOC     = &toc.TOC{}
+	completedDataTOCLock sync.Mutex
 	/*
 	 * Used for synchronizing DoCleanup.  In DoInit() we increment the group
 	 * and then wait for at least one DoCleanup to finish, either in DoTeardown
@@ -100,6 +117,19 @@ func SetQuotedRoleNames(quotedRoles map[string]string) {
 	quotedRoleNames = quotedRoles
 }
 
+func SetTablePredicates(predicates map[string]string) {
+	tablePredicates = predicates
+}
+
+func SetObjectTypeFilters(included []string, excluded []string) {
+	includedObjectTypes = included
+	excludedObjectTypes = excluded
+}
+
+func SetMaskingPolicy(policy *MaskingPolicy) {
+	maskingPolicy = policy
+}
+
 // Util functions to enable ease of access to global flag values
 
 func MustGetFlagString(flagName string) string {
@@ -3,6 +3,7 @@ package backup
 import (
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gpbackup/filepath"
 	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/toc"
@@ -10,9 +11,20 @@ import (
 	"github.com/pkg/errors"
 )
 
+/*
+ * Heap tables are always backed up unless change counters were recorded for
+ * them in both the current and the last backup.
+ */
 func FilterTablesForIncremental(lastBackupTOC, currentTOC *toc.TOC, tables []Table) []Table {
 	var filteredTables []Table
 	for _, table := range tables {
+		if currentHeapEntry, isHeapTable := currentTOC.IncrementalMetadata.Heap[table.FQN()]; isHeapTable {
+			previousHeapEntry, hasPreviousEntry := lastBackupTOC.IncrementalMetadata.Heap[table.FQN()]
+			if !hasPreviousEntry || previousHeapEntry != currentHeapEntry {
+				filteredTables = append(filteredTables, table)
+			}
+			continue
+		}
 		currentAOEntry, isAOTable := currentTOC.IncrementalMetadata.AO[table.FQN()]
 		if !isAOTable {
 			filteredTables = append(filteredTables, table)
@@ -28,6 +40,16 @@ func FilterTablesForIncremental(lastBackupTOC, currentTOC *toc.TOC, tables []Tab
 	return filteredTables
 }
 
+func FilterHeapIncrementalMetadata(heapEntries map[string]toc.HeapEntry, tables []Table) map[string]toc.HeapEntry {
+	filteredEntries := make(map[string]toc.HeapEntry)
+	for _, table := range tables {
+		if heapEntry, ok := heapEntries[table.FQN()]; ok {
+			filteredEntries[table.FQN()] = heapEntry
+		}
+	}
+	return filteredEntries
+}
+
 func GetTargetBackupTimestamp() string {
 	targetTimestamp := ""
 	if fromTimestamp := MustGetFlagString(options.FROM_TIMESTAMP); fromTimestamp != "" {
@@ -76,7 +98,11 @@ func matchesIncrementalFlags(backupConfig *history.BackupConfig, currentBackupCo
 		backupConfig.LeafPartitionData == MustGetFlagBool(options.LEAF_PARTITION_DATA) &&
 		backupConfig.Plugin == currentBackupConfig.Plugin &&
 		backupConfig.SingleDataFile == MustGetFlagBool(options.SINGLE_DATA_FILE) &&
-		backupConfig.Compressed == currentBackupConfig.Compressed &&
+		backupConfig.GetCompressionType() == currentBackupConfig.GetCompressionType() &&
+		backupConfig.EncryptionKeyFingerprint == currentBackupConfig.EncryptionKeyFingerprint &&
+		backupConfig.Masked == currentBackupConfig.Masked &&
+		// Backups with table predicates have only some rows of their tables
+		backupConfig.Predicated == currentBackupConfig.Predicated &&
 		// Expanding of the include list happens before this now so we must compare again current backup config
 		utils.NewIncludeSet(backupConfig.IncludeRelations).Equals(utils.NewIncludeSet(currentBackupConfig.IncludeRelations)) &&
 		utils.NewIncludeSet(backupConfig.IncludeSchemas).Equals(utils.NewIncludeSet(MustGetFlagStringArray(options.INCLUDE_SCHEMA))) &&
@@ -84,6 +110,24 @@ func matchesIncrementalFlags(backupConfig *history.BackupConfig, currentBackupCo
 		utils.NewIncludeSet(backupConfig.ExcludeSchemas).Equals(utils.NewIncludeSet(MustGetFlagStringArray(options.EXCLUDE_SCHEMA)))
 }
 
+/*
+ * The new backup's restore plan extends that of the backup it is based on, so
+ * every backup in that plan must still be available for the new backup to be
+ * restorable.
+ */
+func ValidateIncrementalChain(targetBackupFPInfo filepath.FilePathInfo) {
+	targetBackupConfig := history.ReadConfigFile(targetBackupFPInfo.GetConfigFilePath())
+	problems := history.ValidateIncrementalChain(globalCluster, pluginConfig, targetBackupConfig, targetBackupConfig.RestorePlan,
+		history.TableFilters{}, globalFPInfo.UserSpecifiedBackupDir, globalFPInfo.UserSpecifiedSegPrefix, true)
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			gplog.Error(problem.String())
+		}
+		gplog.Fatal(errors.Errorf("Found %d problem(s) in the restore plan of backup %s, on which this incremental backup would be based.  "+
+			"Please take a full backup.", len(problems), targetBackupFPInfo.Timestamp), "")
+	}
+}
+
 func PopulateRestorePlan(changedTables []Table,
 	restorePlan []history.RestorePlanEntry, allTables []Table) []history.RestorePlanEntry {
 	currBackupRestorePlanEntry := history.RestorePlanEntry{
@@ -1,12 +1,14 @@
 package backup_test
 
 import (
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/greenplum-db/gp-common-go-libs/operating"
 	"github.com/greenplum-db/gp-common-go-libs/structmatcher"
 	"github.com/greenplum-db/gp-common-go-libs/testhelper"
 	"github.com/greenplum-db/gpbackup/backup"
 	"github.com/greenplum-db/gpbackup/filepath"
 	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/report"
 	"github.com/greenplum-db/gpbackup/testutils"
 	"github.com/greenplum-db/gpbackup/toc"
@@ -78,6 +80,58 @@ var _ = Describe("backup/incremental tests", func() {
 		})
 	})
 
+	Describe("FilterTablesForIncremental with heap change counters", func() {
+		defaultEntry := toc.HeapEntry{Relfilenode: 16384, TuplesInserted: 10, LastDDLTimestamp: "00000"}
+		prevTOC := toc.TOC{
+			IncrementalMetadata: toc.IncrementalEntries{
+				Heap: map[string]toc.HeapEntry{
+					"public.heap_changed_counters":    defaultEntry,
+					"public.heap_changed_relfilenode": defaultEntry,
+					"public.heap_unchanged":           defaultEntry,
+				},
+			},
+		}
+		currTOC := toc.TOC{
+			IncrementalMetadata: toc.IncrementalEntries{
+				Heap: map[string]toc.HeapEntry{
+					"public.heap_changed_counters":    {Relfilenode: 16384, TuplesInserted: 10, TuplesDeleted: 1, LastDDLTimestamp: "00000"},
+					"public.heap_changed_relfilenode": {Relfilenode: 16390, TuplesInserted: 10, LastDDLTimestamp: "00000"},
+					"public.heap_unchanged":           defaultEntry,
+					"public.heap_new":                 defaultEntry,
+				},
+			},
+		}
+
+		tblHeapChangedCounters := backup.Table{Relation: backup.Relation{Schema: "public", Name: "heap_changed_counters"}}
+		tblHeapChangedRelfilenode := backup.Table{Relation: backup.Relation{Schema: "public", Name: "heap_changed_relfilenode"}}
+		tblHeapUnchanged := backup.Table{Relation: backup.Relation{Schema: "public", Name: "heap_unchanged"}}
+		tblHeapNew := backup.Table{Relation: backup.Relation{Schema: "public", Name: "heap_new"}}
+		tables := []backup.Table{
+			tblHeapChangedCounters,
+			tblHeapChangedRelfilenode,
+			tblHeapUnchanged,
+			tblHeapNew,
+		}
+
+		filteredTables := backup.FilterTablesForIncremental(&prevTOC, &currTOC, tables)
+
+		It("Should include the heap table having modified tuple counters", func() {
+			Expect(filteredTables).To(ContainElement(tblHeapChangedCounters))
+		})
+
+		It("Should include the heap table having a modified relfilenode", func() {
+			Expect(filteredTables).To(ContainElement(tblHeapChangedRelfilenode))
+		})
+
+		It("Should include the heap table without counters in the last backup", func() {
+			Expect(filteredTables).To(ContainElement(tblHeapNew))
+		})
+
+		It("Should NOT include the unmodified heap table", func() {
+			Expect(filteredTables).To(Not(ContainElement(tblHeapUnchanged)))
+		})
+	})
+
 	Describe("GetLatestMatchingBackupConfig", func() {
 		contents := history.History{BackupConfigs: []history.BackupConfig{
 			{DatabaseName: "test2", Timestamp: "timestamp4"},
@@ -92,6 +146,28 @@ var _ = Describe("backup/incremental tests", func() {
 
 			structmatcher.ExpectStructsToMatch(contents.BackupConfigs[1], latestBackupHistoryEntry)
 		})
+		It("should not return a masked backup", func() {
+			maskedContents := history.History{BackupConfigs: []history.BackupConfig{
+				{DatabaseName: "test1", Timestamp: "timestamp2", Masked: true},
+				{DatabaseName: "test1", Timestamp: "timestamp1"},
+			}}
+			currentBackupConfig := history.BackupConfig{DatabaseName: "test1"}
+
+			latestBackupHistoryEntry := backup.GetLatestMatchingBackupConfig(&maskedContents, &currentBackupConfig)
+
+			structmatcher.ExpectStructsToMatch(maskedContents.BackupConfigs[1], latestBackupHistoryEntry)
+		})
+		It("should not return a backup taken with table predicates", func() {
+			predicatedContents := history.History{BackupConfigs: []history.BackupConfig{
+				{DatabaseName: "test1", Timestamp: "timestamp2", Predicated: true},
+				{DatabaseName: "test1", Timestamp: "timestamp1"},
+			}}
+			currentBackupConfig := history.BackupConfig{DatabaseName: "test1"}
+
+			latestBackupHistoryEntry := backup.GetLatestMatchingBackupConfig(&predicatedContents, &currentBackupConfig)
+
+			structmatcher.ExpectStructsToMatch(predicatedContents.BackupConfigs[1], latestBackupHistoryEntry)
+		})
 		It("should return nil with no matching Dbname", func() {
 			currentBackupConfig := history.BackupConfig{DatabaseName: "test3"}
 
@@ -214,4 +290,50 @@ var _ = Describe("backup/incremental tests", func() {
 
 		})
 	})
+	Describe("FilterHeapIncrementalMetadata", func() {
+		It("keeps the change counters of the tables in the backup", func() {
+			heapEntries := map[string]toc.HeapEntry{
+				"public.foo": {Relfilenode: 1},
+				"public.bar": {Relfilenode: 2},
+			}
+			tables := []backup.Table{
+				{Relation: backup.Relation{Schema: "public", Name: "foo"}},
+				{Relation: backup.Relation{Schema: "public", Name: "baz"}},
+			}
+
+			Expect(backup.FilterHeapIncrementalMetadata(heapEntries, tables)).To(Equal(map[string]toc.HeapEntry{
+				"public.foo": {Relfilenode: 1},
+			}))
+		})
+	})
+	Describe("BeginBackupTransactions", func() {
+		expectSessionSetup := func() {
+			mock.ExpectExec("SET application_name").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectBegin()
+			for i := 0; i < 8; i++ {
+				mock.ExpectExec(".*").WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+		}
+
+		BeforeEach(func() {
+			testhelper.SetDBVersion(connectionPool, "5.1.0")
+		})
+		It("reads the heap table change counters before the backup's snapshot is taken", func() {
+			_ = cmdFlags.Set(options.INCREMENTAL_HEAP, "true")
+			heapHeader := []string{"tablefqn", "relfilenode", "tuplesinserted", "tuplesupdated", "tuplesdeleted", "lastddltimestamp"}
+			mock.ExpectQuery("pg_stat_get_tuples_inserted").WillReturnRows(sqlmock.NewRows(heapHeader).AddRow("public.foo", 1, 10, 0, 0, ""))
+			expectSessionSetup()
+
+			backup.BeginBackupTransactions()
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not read the heap table change counters without --incremental-heap", func() {
+			expectSessionSetup()
+
+			backup.BeginBackupTransactions()
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
 })
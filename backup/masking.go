@@ -0,0 +1,298 @@
+package backup
+
+/*
+ * This file contains structs and functions related to masking column data
+ * during backup with --masking-policy-file.
+ */
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	MASK_NULL     = "null"
+	MASK_CONSTANT = "constant"
+	MASK_HASH     = "hash"
+	MASK_REDACT   = "redact"
+	MASK_FAKE     = "fake"
+
+	fakeCharacterClasses = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+var textTypeRegex = regexp.MustCompile(`^(text|character varying|character)(\((\d+)\))?$`)
+
+type MaskingRule struct {
+	Transform string
+	Argument  string
+}
+
+/*
+ * A masking policy maps the quoted FQN of a table to the masking rules of its
+ * quoted column names.  The hash salt and fake character substitution are
+ * chosen randomly for each backup, so that masked values are consistent
+ * within a backup, and can be joined on, but cannot be matched against
+ * values hashed or faked elsewhere.  As the same substitution is applied to
+ * every value in the backup, faked values keep the length, format, and
+ * character frequencies of the original values, so fake only disguises them
+ * and should not be relied on to anonymize sensitive data.
+ */
+type MaskingPolicy struct {
+	Tables         map[string]map[string]MaskingRule
+	hashSalt       string
+	fakeCharacters string
+}
+
+/*
+ * Each line of the masking policy file maps a column to a transform, in the
+ * form "schema.table.column: transform [argument]", where transform is one of
+ *   null              replaces every value with NULL
+ *   constant <value>  replaces every value with <value>
+ *   hash              replaces each value with a salted MD5 hash of it
+ *   redact [n]        replaces all but the last n characters of each value with *
+ *   fake              replaces each letter and digit with another of the same kind,
+ *                     using one substitution for the whole backup
+ */
+func ParseMaskingPolicy(reader io.Reader) (*MaskingPolicy, error) {
+	policy := &MaskingPolicy{Tables: make(map[string]map[string]MaskingRule)}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf(`Invalid line in masking policy file: %s.  Lines must be in the form "schema.table.column: transform".`, line)
+		}
+		columnParts := strings.Split(strings.TrimSpace(parts[0]), ".")
+		if len(columnParts) != 3 || columnParts[0] == "" || columnParts[1] == "" || columnParts[2] == "" {
+			return nil, errors.Errorf("Invalid column %s in masking policy file.  Columns must be in the form schema.table.column.", strings.TrimSpace(parts[0]))
+		}
+		rule, err := parseMaskingRule(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+		tableFQN := fmt.Sprintf("%s.%s", columnParts[0], columnParts[1])
+		if policy.Tables[tableFQN] == nil {
+			policy.Tables[tableFQN] = make(map[string]MaskingRule)
+		}
+		if _, ok := policy.Tables[tableFQN][columnParts[2]]; ok {
+			return nil, errors.Errorf("Column %s has more than one masking rule", strings.TrimSpace(parts[0]))
+		}
+		policy.Tables[tableFQN][columnParts[2]] = rule
+	}
+	return policy, scanner.Err()
+}
+
+func parseMaskingRule(ruleStr string) (MaskingRule, error) {
+	fields := strings.SplitN(ruleStr, " ", 2)
+	rule := MaskingRule{Transform: strings.ToLower(fields[0])}
+	if len(fields) == 2 {
+		rule.Argument = strings.TrimSpace(fields[1])
+	}
+	switch rule.Transform {
+	case MASK_NULL, MASK_HASH, MASK_FAKE:
+		if rule.Argument != "" {
+			return MaskingRule{}, errors.Errorf("Masking transform %s does not take an argument", rule.Transform)
+		}
+	case MASK_CONSTANT:
+		if rule.Argument == "" {
+			return MaskingRule{}, errors.Errorf("Masking transform constant requires a value")
+		}
+	case MASK_REDACT:
+		if rule.Argument != "" {
+			if numKept, err := strconv.Atoi(rule.Argument); err != nil || numKept < 0 {
+				return MaskingRule{}, errors.Errorf("Masking transform redact requires a non-negative number of characters to keep, not %s", rule.Argument)
+			}
+		}
+	default:
+		return MaskingRule{}, errors.Errorf("Invalid masking transform %s.  Valid transforms are null, constant, hash, redact, and fake.", rule.Transform)
+	}
+	return rule, nil
+}
+
+/*
+ * Quotes the table and column names in the policy so that they match the
+ * quoted names of the tables and columns retrieved from the database, and
+ * chooses the hash salt and fake character substitution for this backup.
+ */
+func (policy *MaskingPolicy) Initialize(conn *dbconn.DBConn) error {
+	quotedTables := make(map[string]map[string]MaskingRule, len(policy.Tables))
+	for table, columns := range policy.Tables {
+		quotedTableNames, err := options.QuoteTableNames(conn, []string{table})
+		if err != nil {
+			return err
+		}
+		quotedColumns := make(map[string]MaskingRule, len(columns))
+		for column, rule := range columns {
+			quotedColumns[utils.QuoteIdent(conn, column)] = rule
+		}
+		quotedTables[quotedTableNames[0]] = quotedColumns
+	}
+	policy.Tables = quotedTables
+
+	salt := make([]byte, 16)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return err
+	}
+	policy.hashSalt = hex.EncodeToString(salt)
+	policy.fakeCharacters, err = shuffleFakeCharacterClasses()
+	return err
+}
+
+// Each digit is replaced by a digit and each lowercase or uppercase letter by a letter of the same case
+func shuffleFakeCharacterClasses() (string, error) {
+	shuffled := []byte(fakeCharacterClasses)
+	for _, class := range [][2]int{{0, 10}, {10, 36}, {36, 62}} {
+		for i := class[1] - 1; i > class[0]; i-- {
+			j, err := rand.Int(rand.Reader, big.NewInt(int64(i-class[0]+1)))
+			if err != nil {
+				return "", err
+			}
+			k := class[0] + int(j.Int64())
+			shuffled[i], shuffled[k] = shuffled[k], shuffled[i]
+		}
+	}
+	return string(shuffled), nil
+}
+
+func (policy *MaskingPolicy) IsMasked(table Table) bool {
+	return policy != nil && len(policy.Tables[table.FQN()]) > 0
+}
+
+/*
+ * Returns the expression that the column is selected with when backing up the
+ * table, which is the column itself if it is not masked.
+ */
+func (policy *MaskingPolicy) GetColumnExpression(table Table, column string) string {
+	if policy == nil {
+		return column
+	}
+	rule, ok := policy.Tables[table.FQN()][column]
+	if !ok {
+		return column
+	}
+	switch rule.Transform {
+	case MASK_NULL:
+		return "NULL"
+	case MASK_CONSTANT:
+		return fmt.Sprintf("'%s'", utils.EscapeSingleQuotes(rule.Argument))
+	case MASK_HASH:
+		return fmt.Sprintf("md5('%s' || %s::text)", policy.hashSalt, column)
+	case MASK_REDACT:
+		numKept := 0
+		if rule.Argument != "" {
+			numKept, _ = strconv.Atoi(rule.Argument)
+		}
+		return fmt.Sprintf("repeat('*', greatest(length(%[1]s::text) - %[2]d, 0)) || substr(%[1]s::text, greatest(length(%[1]s::text) - %[2]d + 1, 1))", column, numKept)
+	case MASK_FAKE:
+		return fmt.Sprintf("translate(%s::text, '%s', '%s')", column, fakeCharacterClasses, policy.fakeCharacters)
+	}
+	return column
+}
+
+/*
+ * Masked values are restored into the original columns, so each transform
+ * must produce values that the column can hold: the transforms that produce
+ * text can only be applied to text columns, NULL cannot be put in a NOT NULL
+ * column, and a constant must be a valid value of the column's type.
+ */
+func (policy *MaskingPolicy) ValidateColumns(conn *dbconn.DBConn, tables []Table) {
+	if policy == nil {
+		return
+	}
+	dataTables := make(map[string]Table, len(tables))
+	for _, table := range tables {
+		if !table.SkipDataBackup() {
+			dataTables[table.FQN()] = table
+		}
+	}
+	for tableFQN, columns := range policy.Tables {
+		table, ok := dataTables[tableFQN]
+		if !ok {
+			gplog.Fatal(errors.Errorf("Table %s in the masking policy file is not in the set of tables whose data is being backed up", tableFQN), "")
+		}
+		columnDefs := make(map[string]ColumnDefinition, len(table.ColumnDefs))
+		for _, columnDef := range table.ColumnDefs {
+			columnDefs[columnDef.Name] = columnDef
+		}
+		for column, rule := range columns {
+			columnDef, ok := columnDefs[column]
+			if !ok {
+				gplog.Fatal(errors.Errorf("Column %s.%s in the masking policy file does not exist", tableFQN, column), "")
+			}
+			switch rule.Transform {
+			case MASK_NULL:
+				if columnDef.NotNull {
+					gplog.Fatal(errors.Errorf("Cannot apply masking transform null to column %s.%s, as it is NOT NULL", tableFQN, column), "")
+				}
+			case MASK_CONSTANT:
+				validateMaskingConstant(conn, tableFQN, column, columnDef.Type, rule.Argument)
+			default:
+				matches := textTypeRegex.FindStringSubmatch(columnDef.Type)
+				if matches == nil {
+					gplog.Fatal(errors.Errorf("Cannot apply masking transform %s to column %s.%s of type %s.  It can only be applied to text columns.", rule.Transform, tableFQN, column, columnDef.Type), "")
+				}
+				// An MD5 hash is 32 hexadecimal characters
+				if length, err := strconv.Atoi(matches[3]); err == nil && rule.Transform == MASK_HASH && length < 32 {
+					gplog.Fatal(errors.Errorf("Cannot apply masking transform hash to column %s.%s of type %s, as a hash is 32 characters long", tableFQN, column, columnDef.Type), "")
+				}
+			}
+		}
+	}
+}
+
+/*
+ * An explicit cast to a text type of limited length truncates the value
+ * instead of failing as restoring it would, so the length of text constants
+ * is checked here and other constants are cast to the column's type.
+ */
+func validateMaskingConstant(conn *dbconn.DBConn, tableFQN string, column string, columnType string, value string) {
+	if matches := textTypeRegex.FindStringSubmatch(columnType); matches != nil {
+		if length, err := strconv.Atoi(matches[3]); err == nil && utf8.RuneCountInString(value) > length {
+			gplog.Fatal(errors.Errorf("Cannot apply masking transform constant to column %s.%s of type %s, as %s is longer than %d characters", tableFQN, column, columnType, value, length), "")
+		}
+		return
+	}
+	_, err := conn.Exec(fmt.Sprintf("SELECT CAST('%s' AS %s)", utils.EscapeSingleQuotes(value), columnType))
+	if err != nil {
+		gplog.Fatal(errors.Errorf("Cannot apply masking transform constant to column %s.%s of type %s, as %s is not a valid value of that type: %v", tableFQN, column, columnType, value, err), "")
+	}
+}
+
+func readMaskingPolicy(filename string) *MaskingPolicy {
+	contents, err := operating.System.ReadFile(filename)
+	gplog.FatalOnError(err)
+	policy, err := ParseMaskingPolicy(strings.NewReader(string(contents)))
+	gplog.FatalOnError(err, fmt.Sprintf("Unable to read masking policy file %s", filename))
+	tableList := make([]string, 0, len(policy.Tables))
+	for table := range policy.Tables {
+		tableList = append(tableList, table)
+	}
+	DBValidate(connectionPool, tableList, false)
+	err = policy.Initialize(connectionPool)
+	gplog.FatalOnError(err)
+	quotedTableList := make([]string, 0, len(policy.Tables))
+	for table := range policy.Tables {
+		quotedTableList = append(quotedTableList, table)
+	}
+	validateTablesForCopyQuery(quotedTableList, options.MASKING_POLICY_FILE)
+	return policy
+}
@@ -0,0 +1,150 @@
+package backup_test
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backup/masking tests", func() {
+	fooTable := backup.Table{
+		Relation: backup.Relation{Schema: "public", Name: "foo"},
+		TableDefinition: backup.TableDefinition{ColumnDefs: []backup.ColumnDefinition{
+			{Name: "id", Type: "integer"},
+			{Name: "email", Type: "text"},
+			{Name: "code", Type: "character varying(8)"},
+			{Name: "created", Type: "date", NotNull: true},
+		}},
+	}
+	Describe("ParseMaskingPolicy", func() {
+		It("parses a rule for each column", func() {
+			policy, err := backup.ParseMaskingPolicy(strings.NewReader("public.foo.email: hash\n\npublic.foo.id: constant 0\npublic.bar.card: redact 4\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policy.Tables).To(Equal(map[string]map[string]backup.MaskingRule{
+				"public.foo": {
+					"email": {Transform: backup.MASK_HASH},
+					"id":    {Transform: backup.MASK_CONSTANT, Argument: "0"},
+				},
+				"public.bar": {
+					"card": {Transform: backup.MASK_REDACT, Argument: "4"},
+				},
+			}))
+		})
+		It("returns an error for a column that is not qualified", func() {
+			_, err := backup.ParseMaskingPolicy(strings.NewReader("foo.email: hash\n"))
+			Expect(err).To(MatchError("Invalid column foo.email in masking policy file.  Columns must be in the form schema.table.column."))
+		})
+		It("returns an error for a line without a transform", func() {
+			_, err := backup.ParseMaskingPolicy(strings.NewReader("public.foo.email\n"))
+			Expect(err).To(MatchError(`Invalid line in masking policy file: public.foo.email.  Lines must be in the form "schema.table.column: transform".`))
+		})
+		It("returns an error for an invalid transform", func() {
+			_, err := backup.ParseMaskingPolicy(strings.NewReader("public.foo.email: scramble\n"))
+			Expect(err).To(MatchError("Invalid masking transform scramble.  Valid transforms are null, constant, hash, redact, and fake."))
+		})
+		It("returns an error for a constant without a value", func() {
+			_, err := backup.ParseMaskingPolicy(strings.NewReader("public.foo.email: constant\n"))
+			Expect(err).To(MatchError("Masking transform constant requires a value"))
+		})
+		It("returns an error for a redaction of an invalid number of characters", func() {
+			_, err := backup.ParseMaskingPolicy(strings.NewReader("public.foo.email: redact -1\n"))
+			Expect(err).To(MatchError("Masking transform redact requires a non-negative number of characters to keep, not -1"))
+		})
+		It("returns an error for a column with more than one rule", func() {
+			_, err := backup.ParseMaskingPolicy(strings.NewReader("public.foo.email: hash\npublic.foo.email: null\n"))
+			Expect(err).To(MatchError("Column public.foo.email has more than one masking rule"))
+		})
+	})
+	Describe("GetColumnExpression", func() {
+		var policy *backup.MaskingPolicy
+		BeforeEach(func() {
+			policy, _ = backup.ParseMaskingPolicy(strings.NewReader("public.foo.id: null\npublic.foo.email: constant o'brien@example.com\npublic.foo.code: redact 2\n"))
+		})
+		It("returns the column of a table without a masking policy", func() {
+			var noPolicy *backup.MaskingPolicy
+			Expect(noPolicy.IsMasked(fooTable)).To(BeFalse())
+			Expect(noPolicy.GetColumnExpression(fooTable, "id")).To(Equal("id"))
+		})
+		It("returns the column if it is not masked", func() {
+			policy, _ = backup.ParseMaskingPolicy(strings.NewReader("public.foo.email: null\n"))
+			Expect(policy.GetColumnExpression(fooTable, "id")).To(Equal("id"))
+		})
+		It("returns the transform of each masked column", func() {
+			Expect(policy.IsMasked(fooTable)).To(BeTrue())
+			Expect(policy.GetColumnExpression(fooTable, "id")).To(Equal("NULL"))
+			Expect(policy.GetColumnExpression(fooTable, "email")).To(Equal("'o''brien@example.com'"))
+			Expect(policy.GetColumnExpression(fooTable, "code")).To(Equal("repeat('*', greatest(length(code::text) - 2, 0)) || substr(code::text, greatest(length(code::text) - 2 + 1, 1))"))
+		})
+		It("hashes with a random salt and fakes with a random substitution", func() {
+			policy, _ = backup.ParseMaskingPolicy(strings.NewReader("public.foo.email: hash\npublic.foo.code: fake\n"))
+			mock.ExpectQuery(`SELECT quote_ident\('public'\)`).WillReturnRows(sqlmock.NewRows([]string{"schemaname", "tablename"}).AddRow("public", "foo"))
+			mock.ExpectQuery(`SELECT quote_ident\('email'\)`).WillReturnRows(sqlmock.NewRows([]string{"string"}).AddRow("email"))
+			mock.ExpectQuery(`SELECT quote_ident\('code'\)`).WillReturnRows(sqlmock.NewRows([]string{"string"}).AddRow("code"))
+			mock.MatchExpectationsInOrder(false)
+			defer mock.MatchExpectationsInOrder(true)
+
+			err := policy.Initialize(connectionPool)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policy.GetColumnExpression(fooTable, "email")).To(MatchRegexp(`^md5\('[0-9a-f]{32}' \|\| email::text\)$`))
+			Expect(policy.GetColumnExpression(fooTable, "code")).To(MatchRegexp(`^translate\(code::text, '0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ', '[0-9]{10}[a-z]{26}[A-Z]{26}'\)$`))
+		})
+	})
+	Describe("ValidateColumns", func() {
+		It("passes for transforms that can be applied to their columns", func() {
+			policy, _ := backup.ParseMaskingPolicy(strings.NewReader("public.foo.id: null\npublic.foo.email: hash\npublic.foo.code: fake\n"))
+			policy.ValidateColumns(connectionPool, []backup.Table{fooTable})
+		})
+		It("panics if the table's data is not being backed up", func() {
+			policy, _ := backup.ParseMaskingPolicy(strings.NewReader("public.bar.id: null\n"))
+			defer testhelper.ShouldPanicWithMessage("Table public.bar in the masking policy file is not in the set of tables whose data is being backed up")
+			policy.ValidateColumns(connectionPool, []backup.Table{fooTable})
+		})
+		It("panics if the column does not exist", func() {
+			policy, _ := backup.ParseMaskingPolicy(strings.NewReader("public.foo.name: null\n"))
+			defer testhelper.ShouldPanicWithMessage("Column public.foo.name in the masking policy file does not exist")
+			policy.ValidateColumns(connectionPool, []backup.Table{fooTable})
+		})
+		It("panics if a text transform is applied to a column that is not text", func() {
+			policy, _ := backup.ParseMaskingPolicy(strings.NewReader("public.foo.id: redact\n"))
+			defer testhelper.ShouldPanicWithMessage("Cannot apply masking transform redact to column public.foo.id of type integer.  It can only be applied to text columns.")
+			policy.ValidateColumns(connectionPool, []backup.Table{fooTable})
+		})
+		It("panics if a hash is applied to a column too short to hold it", func() {
+			policy, _ := backup.ParseMaskingPolicy(strings.NewReader("public.foo.code: hash\n"))
+			defer testhelper.ShouldPanicWithMessage("Cannot apply masking transform hash to column public.foo.code of type character varying(8), as a hash is 32 characters long")
+			policy.ValidateColumns(connectionPool, []backup.Table{fooTable})
+		})
+		It("panics if null is applied to a NOT NULL column", func() {
+			policy, _ := backup.ParseMaskingPolicy(strings.NewReader("public.foo.created: null\n"))
+			defer testhelper.ShouldPanicWithMessage("Cannot apply masking transform null to column public.foo.created, as it is NOT NULL")
+			policy.ValidateColumns(connectionPool, []backup.Table{fooTable})
+		})
+		It("passes for constants that are valid values of their columns", func() {
+			policy, _ := backup.ParseMaskingPolicy(strings.NewReader("public.foo.created: constant 2000-01-01\npublic.foo.code: constant 12345678\n"))
+			mock.ExpectExec(regexp.QuoteMeta("SELECT CAST('2000-01-01' AS date)")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+			policy.ValidateColumns(connectionPool, []backup.Table{fooTable})
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("panics if a constant is too long for its column", func() {
+			policy, _ := backup.ParseMaskingPolicy(strings.NewReader("public.foo.code: constant 123456789\n"))
+			defer testhelper.ShouldPanicWithMessage("Cannot apply masking transform constant to column public.foo.code of type character varying(8), as 123456789 is longer than 8 characters")
+			policy.ValidateColumns(connectionPool, []backup.Table{fooTable})
+		})
+		It("panics if a constant is not a valid value of its column's type", func() {
+			policy, _ := backup.ParseMaskingPolicy(strings.NewReader("public.foo.id: constant none\n"))
+			mock.ExpectExec(regexp.QuoteMeta("SELECT CAST('none' AS integer)")).WillReturnError(errors.New(`invalid input syntax for integer: "none"`))
+			defer testhelper.ShouldPanicWithMessage(`Cannot apply masking transform constant to column public.foo.id of type integer, as none is not a valid value of that type: invalid input syntax for integer: "none"`)
+			policy.ValidateColumns(connectionPool, []backup.Table{fooTable})
+		})
+	})
+})
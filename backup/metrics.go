@@ -0,0 +1,91 @@
+package backup
+
+/*
+ * This file contains functions related to writing the Prometheus metrics
+ * file of a backup with --metrics-dir.
+ */
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/utils"
+)
+
+func writeMetricsFile(backupFailed bool) {
+	metricsDir := MustGetFlagString(options.METRICS_DIR)
+	if metricsDir == "" {
+		return
+	}
+	success := !backupFailed && gplog.GetErrorCode() == 0
+	completed, failed, skipped := tableResults.Counts()
+	metrics := report.Metrics{
+		Utility:         "gpbackup",
+		Database:        MustGetFlagString(options.DBNAME),
+		Success:         success,
+		StartTime:       startTime,
+		EndTime:         time.Now(),
+		TablesCompleted: completed,
+		TablesFailed:    failed,
+		TablesSkipped:   skipped,
+		PhaseDurations:  phaseTimings.Durations(),
+	}
+	if success {
+		metrics.SegmentBytes = getSegmentDataBytes()
+	}
+	err := report.WriteMetricsFile(metricsDir, metrics)
+	if err != nil {
+		gplog.Warn("Unable to write metrics file to %s: %v", metricsDir, err)
+	}
+}
+
+/*
+ * The bytes written by each segment are the sizes of its data files as they
+ * are stored, after compression, whether the backup has a single data file
+ * per segment or one per table.  Files sent to a plugin are not on the
+ * segments, so no bytes are reported for them.
+ */
+func getSegmentDataBytes() map[int]uint64 {
+	if globalCluster == nil || backupReport == nil || backupReport.MetadataOnly || MustGetFlagString(options.PLUGIN_CONFIG) != "" {
+		return nil
+	}
+	remoteOutput := globalCluster.GenerateAndExecuteCommand("Reading sizes of data files", func(contentID int) string {
+		return fmt.Sprintf("find %s -maxdepth 1 -name 'gpbackup_%d_%s*' ! -name '*_toc.yaml' ! -name '*%s' -printf '%%s\\n'",
+			globalFPInfo.GetDirForContent(contentID), contentID, globalFPInfo.Timestamp, utils.ChecksumFileSuffix)
+	}, cluster.ON_SEGMENTS)
+	if remoteOutput.NumErrors > 0 {
+		gplog.Warn("Unable to read sizes of data files on %d segment(s), so no segment bytes will be reported", remoteOutput.NumErrors)
+		return nil
+	}
+	segmentBytes := make(map[int]uint64, len(remoteOutput.Stdouts))
+	for contentID, stdout := range remoteOutput.Stdouts {
+		numBytes, err := parseSegmentDataBytes(stdout)
+		if err != nil {
+			gplog.Warn("Unable to read sizes of data files on segment %d: %v", contentID, err)
+			return nil
+		}
+		segmentBytes[contentID] = numBytes
+	}
+	return segmentBytes
+}
+
+func parseSegmentDataBytes(output string) (uint64, error) {
+	var numBytes uint64
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		fileBytes, err := strconv.ParseUint(line, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		numBytes += fileBytes
+	}
+	return numBytes, nil
+}
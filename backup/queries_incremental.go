@@ -117,3 +117,69 @@ func getLastDDLTimestamps(connectionPool *dbconn.DBConn) map[string]string {
 	}
 	return resultMap
 }
+
+/*
+ * The tuple counters are summed from the statistics of each segment, where
+ * the rows of a table are inserted, updated, and deleted.  The counters are
+ * reset if the statistics are reset, which causes the table to be backed up
+ * again.  This is queried before the table filters are expanded, so the
+ * counters of every user heap table are returned, and those of the tables in
+ * the backup are kept by FilterHeapIncrementalMetadata.
+ */
+func GetHeapIncrementalMetadata(connectionPool *dbconn.DBConn) map[string]toc.HeapEntry {
+	gplog.Verbose("Querying heap table change counters")
+	heapClause := "c.relstorage = 'h'"
+	if connectionPool.Version.AtLeast("7") {
+		heapClause = "c.relam = (SELECT oid FROM pg_am WHERE amname = 'heap')"
+	}
+	query := fmt.Sprintf(`
+	SELECT quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS tablefqn,
+		c.relfilenode,
+		coalesce(stats.tuplesinserted, 0) AS tuplesinserted,
+		coalesce(stats.tuplesupdated, 0) AS tuplesupdated,
+		coalesce(stats.tuplesdeleted, 0) AS tuplesdeleted,
+		coalesce(lastop.lastddltimestamp::text, '') AS lastddltimestamp
+	FROM pg_class c
+		JOIN pg_namespace n ON c.relnamespace = n.oid
+		LEFT JOIN ( SELECT segc.oid,
+				pg_catalog.sum(pg_stat_get_tuples_inserted(segc.oid))::bigint AS tuplesinserted,
+				pg_catalog.sum(pg_stat_get_tuples_updated(segc.oid))::bigint AS tuplesupdated,
+				pg_catalog.sum(pg_stat_get_tuples_deleted(segc.oid))::bigint AS tuplesdeleted
+			FROM gp_dist_random('pg_class') segc
+			WHERE segc.relkind = 'r'
+			GROUP BY segc.oid
+		) stats ON c.oid = stats.oid
+		LEFT JOIN ( SELECT lo.objid,
+				MAX(lo.statime) AS lastddltimestamp
+			FROM pg_stat_last_operation lo
+			WHERE lo.staactionname IN ('CREATE', 'ALTER', 'TRUNCATE')
+			GROUP BY lo.objid
+		) lastop ON c.oid = lastop.objid
+	WHERE c.relkind = 'r'
+		AND %s
+		AND n.nspname NOT LIKE 'pg_temp_%%'
+		AND n.nspname NOT LIKE 'pg_toast%%'
+		AND n.nspname NOT IN ('gp_toolkit', 'information_schema', 'pg_aoseg', 'pg_bitmapindex', 'pg_catalog')`, heapClause)
+
+	results := make([]struct {
+		TableFQN         string
+		Relfilenode      uint32
+		TuplesInserted   int64
+		TuplesUpdated    int64
+		TuplesDeleted    int64
+		LastDDLTimestamp string
+	}, 0)
+	err := connectionPool.Select(&results, query)
+	gplog.FatalOnError(err)
+	heapTableEntries := make(map[string]toc.HeapEntry, len(results))
+	for _, result := range results {
+		heapTableEntries[result.TableFQN] = toc.HeapEntry{
+			Relfilenode:      result.Relfilenode,
+			TuplesInserted:   result.TuplesInserted,
+			TuplesUpdated:    result.TuplesUpdated,
+			TuplesDeleted:    result.TuplesDeleted,
+			LastDDLTimestamp: result.LastDDLTimestamp,
+		}
+	}
+	return heapTableEntries
+}
@@ -0,0 +1,264 @@
+package backup
+
+/*
+ * This file contains functions related to resuming a backup whose data
+ * backup failed or was interrupted.
+ */
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Records a table whose data has been completely backed up, so that a partial
+ * table of contents can be written if the backup does not finish.
+ */
+func recordCompletedTable(table Table, rowsCopied int64) {
+	completedDataTOCLock.Lock()
+	defer completedDataTOCLock.Unlock()
+	completedDataTOC.AddMasterDataEntry(table.Schema, table.Name, table.Oid, ConstructTableAttributesList(table.ColumnDefs), rowsCopied, table.PartitionLevelInfo.RootName, tablePredicates[table.FQN()])
+}
+
+/*
+ * A single data file cannot be appended to once its helper exits, so only
+ * backups with one data file per table are resumable.
+ */
+func writePartialBackupFiles() {
+	completedDataTOCLock.Lock()
+	defer completedDataTOCLock.Unlock()
+	if MustGetFlagBool(options.SINGLE_DATA_FILE) || len(completedDataTOC.DataEntries) == 0 {
+		return
+	}
+	tocFilename := globalFPInfo.GetTOCFilePath()
+	if iohelper.FileExistsAndIsReadable(tocFilename) {
+		// The backup failed after its table of contents was complete
+		return
+	}
+	completedDataTOC.WriteToFileAndMakeReadOnly(tocFilename)
+	configFilename := globalFPInfo.GetConfigFilePath()
+	if backupReport != nil && !iohelper.FileExistsAndIsReadable(configFilename) {
+		history.WriteConfigFile(&backupReport.BackupConfig, configFilename)
+	}
+	gplog.Info("Data for %d table(s) was backed up completely.  Use --resume %s to back up the remaining tables.",
+		len(completedDataTOC.DataEntries), globalFPInfo.Timestamp)
+}
+
+func prepareToResumeBackup() {
+	timestamp := globalFPInfo.Timestamp
+	backupHistory, err := history.NewHistory(globalFPInfo.GetBackupHistoryFilePath())
+	if err == nil && backupHistory.FindBackupConfig(timestamp) != nil {
+		gplog.Fatal(errors.Errorf("Backup %s completed successfully and cannot be resumed.", timestamp), "")
+	}
+	configFilename := globalFPInfo.GetConfigFilePath()
+	if !iohelper.FileExistsAndIsReadable(configFilename) {
+		gplog.Fatal(errors.Errorf("Cannot resume backup %s: config file %s does not exist.", timestamp, configFilename), "")
+	}
+	resumedConfig := history.ReadConfigFile(configFilename)
+	if resumedConfig.SingleDataFile {
+		gplog.Fatal(errors.Errorf("Backups with a single data file per segment cannot be resumed."), "")
+	}
+	if !matchesIncrementalFlags(resumedConfig, &backupReport.BackupConfig) {
+		gplog.Fatal(errors.Errorf("The flags of the backup with timestamp = %s do not match "+
+			"that of the current one. Please refer to the report to view the flags supplied for the "+
+			"previous backup.", timestamp), "")
+	}
+
+	resumeTOC = &toc.TOC{}
+	tocFilename := globalFPInfo.GetTOCFilePath()
+	if iohelper.FileExistsAndIsReadable(tocFilename) {
+		resumeTOC = toc.NewTOC(tocFilename)
+		// Keep the recorded tables in case this attempt also fails before they are checked
+		completedDataTOC.DataEntries = append([]toc.MasterDataEntry{}, resumeTOC.DataEntries...)
+	} else {
+		gplog.Warn("No completed tables were recorded for backup %s.  The data of all tables will be backed up again.", timestamp)
+	}
+	removeMasterBackupFiles()
+	backupReport.Resumed = true
+}
+
+// The metadata of a resumed backup is written again under the new snapshot
+func removeMasterBackupFiles() {
+	for _, filename := range []string{
+		globalFPInfo.GetConfigFilePath(),
+		globalFPInfo.GetMetadataFilePath(),
+		globalFPInfo.GetStatisticsFilePath(),
+		globalFPInfo.GetTOCFilePath(),
+		globalFPInfo.GetChecksumManifestFilePath(),
+		globalFPInfo.GetBackupReportFilePath(),
+		globalFPInfo.GetBackupJSONReportFilePath(),
+	} {
+		err := os.Remove(filename)
+		if err != nil && !os.IsNotExist(err) {
+			gplog.Fatal(err, "Unable to remove file %s", filename)
+		}
+	}
+}
+
+/*
+ * Carries over the tables whose data was completely backed up before the
+ * backup was resumed and returns the tables whose data must be backed up.
+ */
+func resumeBackupData(tables []Table) []Table {
+	segmentFiles := getDataFilesOnSegments()
+	extension := utils.GetPipeThroughProgram().Extension
+	isFileComplete := func(oid uint32) bool {
+		if segmentFiles == nil {
+			// Files sent to a plugin cannot be read back, so the recorded COPY results are trusted
+			return true
+		}
+		for contentID, files := range segmentFiles {
+			filename := path.Base(globalFPInfo.GetTableBackupFilePath(contentID, oid, extension, false))
+			if !files[filename] {
+				return false
+			}
+		}
+		return true
+	}
+	remainingTables, completedEntries := GetTablesToResume(resumeTOC, tables, isFileComplete)
+	completedDataTOC.DataEntries = nil
+	for _, entry := range completedEntries {
+		globalTOC.AddMasterDataEntry(entry.Schema, entry.Name, entry.Oid, entry.AttributeString, entry.RowsCopied, entry.PartitionRoot, entry.Predicate)
+		completedDataTOC.AddMasterDataEntry(entry.Schema, entry.Name, entry.Oid, entry.AttributeString, entry.RowsCopied, entry.PartitionRoot, entry.Predicate)
+	}
+	removeStaleDataFiles(segmentFiles, tables)
+	gplog.Info("Resuming backup %s: data for %d table(s) was already backed up", globalFPInfo.Timestamp, len(completedEntries))
+	return remainingTables
+}
+
+/*
+ * A table is carried over only if it has not been altered since it was backed
+ * up and its data file is complete on every segment.
+ */
+func GetTablesToResume(resumeTOC *toc.TOC, tables []Table, isFileComplete func(oid uint32) bool) ([]Table, []toc.MasterDataEntry) {
+	completedEntryMap := make(map[uint32]toc.MasterDataEntry, len(resumeTOC.DataEntries))
+	for _, entry := range resumeTOC.DataEntries {
+		completedEntryMap[entry.Oid] = entry
+	}
+	remainingTables := make([]Table, 0)
+	completedEntries := make([]toc.MasterDataEntry, 0)
+	for _, table := range tables {
+		entry, ok := completedEntryMap[table.Oid]
+		if !table.SkipDataBackup() && ok && entry.Schema == table.Schema && entry.Name == table.Name &&
+			entry.AttributeString == ConstructTableAttributesList(table.ColumnDefs) && isFileComplete(table.Oid) {
+			completedEntries = append(completedEntries, entry)
+		} else {
+			remainingTables = append(remainingTables, table)
+		}
+	}
+	return remainingTables, completedEntries
+}
+
+/*
+ * Returns a map from content ID to a map from the name of each file in the
+ * segment backup directory to whether it is a data file that still matches
+ * the checksum computed as it was written.
+ */
+func getDataFilesOnSegments() map[int]map[string]bool {
+	if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
+		return nil
+	}
+	remoteOutput := globalCluster.GenerateAndExecuteCommand("Checking data files of backup being resumed", func(contentID int) string {
+		return GetCheckDataFilesCommand(globalFPInfo.GetDirForContent(contentID))
+	}, cluster.ON_SEGMENTS)
+	globalCluster.CheckClusterError(remoteOutput, "Unable to check data files of backup being resumed", func(contentID int) string {
+		return fmt.Sprintf("Unable to check files in directory %s", globalFPInfo.GetDirForContent(contentID))
+	})
+	segmentFiles := make(map[int]map[string]bool)
+	for contentID, stdout := range remoteOutput.Stdouts {
+		segmentFiles[contentID] = ParseCheckDataFilesOutput(stdout)
+	}
+	return segmentFiles
+}
+
+/*
+ * Prints the name of each file in the directory, followed by "complete" for
+ * each data file whose checksum matches its checksum file.  A data file that
+ * was truncated or only partly written does not match, and neither does one
+ * written before checksums were stored for local files.
+ */
+func GetCheckDataFilesCommand(backupDir string) string {
+	return fmt.Sprintf(`cd %s && for f in gpbackup_*; do
+[ -e "$f" ] || continue
+case "$f" in
+*%[2]s) echo "$f";;
+*) if [ -f "$f%[2]s" ] && [ "$(sha256sum < "$f" | cut -c1-64)" = "$(cut -c1-64 < "$f%[2]s")" ]; then echo "$f complete"; else echo "$f"; fi;;
+esac
+done`, backupDir, utils.ChecksumFileSuffix)
+}
+
+func ParseCheckDataFilesOutput(output string) map[string]bool {
+	files := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		files[fields[0]] = len(fields) == 2 && fields[1] == "complete"
+	}
+	return files
+}
+
+// Removes the data files of tables that are no longer part of the backup set
+func removeStaleDataFiles(segmentFiles map[int]map[string]bool, tables []Table) {
+	if segmentFiles == nil {
+		return
+	}
+	oids := make(map[uint32]bool, len(tables))
+	for _, table := range tables {
+		oids[table.Oid] = true
+	}
+	staleFiles := make(map[int][]string)
+	for contentID, files := range segmentFiles {
+		filenames := make([]string, 0, len(files))
+		for filename := range files {
+			filenames = append(filenames, filename)
+		}
+		if stale := GetStaleDataFiles(filenames, contentID, globalFPInfo.Timestamp, oids); len(stale) > 0 {
+			staleFiles[contentID] = stale
+		}
+	}
+	if len(staleFiles) == 0 {
+		return
+	}
+	remoteOutput := globalCluster.GenerateAndExecuteCommand("Removing data files of tables no longer in backup set", func(contentID int) string {
+		if len(staleFiles[contentID]) == 0 {
+			return "true"
+		}
+		return fmt.Sprintf("cd %s && rm -f %s", globalFPInfo.GetDirForContent(contentID), strings.Join(staleFiles[contentID], " "))
+	}, cluster.ON_SEGMENTS)
+	globalCluster.CheckClusterError(remoteOutput, "Unable to remove data files of tables no longer in backup set", func(contentID int) string {
+		return fmt.Sprintf("Unable to remove data files in directory %s", globalFPInfo.GetDirForContent(contentID))
+	})
+}
+
+func GetStaleDataFiles(filenames []string, contentID int, timestamp string, oids map[uint32]bool) []string {
+	dataFileRegex := regexp.MustCompile(fmt.Sprintf(`^gpbackup_%d_%s_(\d+)`, contentID, timestamp))
+	staleFiles := make([]string, 0)
+	for _, filename := range filenames {
+		matches := dataFileRegex.FindStringSubmatch(filename)
+		if matches == nil {
+			continue
+		}
+		oid, err := strconv.ParseUint(matches[1], 10, 32)
+		if err == nil && !oids[uint32(oid)] {
+			staleFiles = append(staleFiles, filename)
+		}
+	}
+	sort.Strings(staleFiles)
+	return staleFiles
+}
@@ -0,0 +1,124 @@
+package backup_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backup/resume tests", func() {
+	Describe("GetTablesToResume", func() {
+		var (
+			resumeTOC      *toc.TOC
+			tableFoo       backup.Table
+			tableBar       backup.Table
+			allFilesExist  func(oid uint32) bool
+			completedEntry toc.MasterDataEntry
+		)
+		BeforeEach(func() {
+			tableFoo = backup.Table{
+				Relation:        backup.Relation{Oid: 1, Schema: "public", Name: "foo"},
+				TableDefinition: backup.TableDefinition{ColumnDefs: []backup.ColumnDefinition{{Oid: 1, Name: "a"}}},
+			}
+			tableBar = backup.Table{
+				Relation:        backup.Relation{Oid: 2, Schema: "public", Name: "bar"},
+				TableDefinition: backup.TableDefinition{ColumnDefs: []backup.ColumnDefinition{{Oid: 2, Name: "b"}}},
+			}
+			completedEntry = toc.MasterDataEntry{Schema: "public", Name: "foo", Oid: 1, AttributeString: "(a)", RowsCopied: 10}
+			resumeTOC = &toc.TOC{DataEntries: []toc.MasterDataEntry{completedEntry}}
+			allFilesExist = func(oid uint32) bool { return true }
+		})
+		It("carries over completed tables and returns the remaining tables", func() {
+			remainingTables, completedEntries := backup.GetTablesToResume(resumeTOC, []backup.Table{tableFoo, tableBar}, allFilesExist)
+
+			Expect(remainingTables).To(Equal([]backup.Table{tableBar}))
+			Expect(completedEntries).To(Equal([]toc.MasterDataEntry{completedEntry}))
+		})
+		It("backs up a completed table again if its data file is missing", func() {
+			remainingTables, completedEntries := backup.GetTablesToResume(resumeTOC, []backup.Table{tableFoo, tableBar}, func(oid uint32) bool { return false })
+
+			Expect(remainingTables).To(Equal([]backup.Table{tableFoo, tableBar}))
+			Expect(completedEntries).To(BeEmpty())
+		})
+		It("backs up a completed table again if its columns have changed", func() {
+			tableFoo.ColumnDefs = append(tableFoo.ColumnDefs, backup.ColumnDefinition{Oid: 1, Name: "c"})
+
+			remainingTables, completedEntries := backup.GetTablesToResume(resumeTOC, []backup.Table{tableFoo, tableBar}, allFilesExist)
+
+			Expect(remainingTables).To(Equal([]backup.Table{tableFoo, tableBar}))
+			Expect(completedEntries).To(BeEmpty())
+		})
+		It("backs up a table again if its oid now belongs to a different table", func() {
+			tableFoo.Name = "baz"
+
+			remainingTables, completedEntries := backup.GetTablesToResume(resumeTOC, []backup.Table{tableFoo}, allFilesExist)
+
+			Expect(remainingTables).To(Equal([]backup.Table{tableFoo}))
+			Expect(completedEntries).To(BeEmpty())
+		})
+	})
+	Describe("GetStaleDataFiles", func() {
+		It("returns the data files of tables that are not in the backup set", func() {
+			filenames := []string{
+				"gpbackup_0_20170101010101_1.gz",
+				"gpbackup_0_20170101010101_2.gz",
+				"gpbackup_0_20170101010101_2.gz.sha256",
+				"gpbackup_0_20170101010101_toc.yaml",
+				"gpbackup_0_20170101010101_10.gz",
+			}
+
+			staleFiles := backup.GetStaleDataFiles(filenames, 0, "20170101010101", map[uint32]bool{1: true, 10: true})
+
+			Expect(staleFiles).To(Equal([]string{"gpbackup_0_20170101010101_2.gz", "gpbackup_0_20170101010101_2.gz.sha256"}))
+		})
+	})
+	Describe("GetCheckDataFilesCommand", func() {
+		var tempDir string
+		writeDataFile := func(filename string, contents string) {
+			Expect(ioutil.WriteFile(tempDir+"/"+filename, []byte(contents), 0644)).To(Succeed())
+			checksum := sha256.Sum256([]byte(contents))
+			Expect(ioutil.WriteFile(tempDir+"/"+filename+".sha256", []byte(hex.EncodeToString(checksum[:])+"  -\n"), 0644)).To(Succeed())
+		}
+		checkDataFiles := func() map[string]bool {
+			output, err := exec.Command("bash", "-c", backup.GetCheckDataFilesCommand(tempDir)).CombinedOutput()
+			Expect(err).ToNot(HaveOccurred(), string(output))
+			return backup.ParseCheckDataFilesOutput(string(output))
+		}
+		BeforeEach(func() {
+			tempDir, _ = ioutil.TempDir("", "resume")
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(tempDir)
+		})
+		It("finds data files that match their checksums", func() {
+			writeDataFile("gpbackup_0_20190101010101_1", "1,a\n2,b\n")
+
+			Expect(checkDataFiles()).To(Equal(map[string]bool{
+				"gpbackup_0_20190101010101_1":        true,
+				"gpbackup_0_20190101010101_1.sha256": false,
+			}))
+		})
+		It("finds a data file that was truncated after it was written", func() {
+			writeDataFile("gpbackup_0_20190101010101_1", "1,a\n2,b\n")
+			Expect(os.Truncate(tempDir+"/gpbackup_0_20190101010101_1", 4)).To(Succeed())
+
+			Expect(checkDataFiles()).To(HaveKeyWithValue("gpbackup_0_20190101010101_1", false))
+		})
+		It("finds a data file without a checksum", func() {
+			Expect(ioutil.WriteFile(tempDir+"/gpbackup_0_20190101010101_1", []byte("1,a\n"), 0644)).To(Succeed())
+
+			Expect(checkDataFiles()).To(Equal(map[string]bool{"gpbackup_0_20190101010101_1": false}))
+		})
+		It("finds nothing in an empty directory", func() {
+			Expect(checkDataFiles()).To(BeEmpty())
+		})
+	})
+})
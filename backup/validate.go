@@ -2,6 +2,7 @@ package backup
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
@@ -25,6 +26,131 @@ func validateFilterLists(opts *options.Options) {
 	ValidateFilterSchemas(connectionPool, opts.GetExcludedSchemas(), true)
 }
 
+/*
+ * Table and schema patterns are expanded against the catalog before the
+ * filters are validated, so that the rest of the backup, and the backup
+ * config, see only the names of the tables and schemas that they match.
+ */
+func expandFilterPatterns(opts *options.Options) {
+	if !opts.HasFilterPatterns() {
+		return
+	}
+	gplog.Verbose("Expanding filter patterns")
+	relations, schemas := getFilterPatternCandidates(connectionPool)
+	err := opts.ExpandFilterPatterns(cmdFlags, relations, schemas)
+	gplog.FatalOnError(err)
+}
+
+/*
+ * Patterns may match any user table, sequence, view, materialized view, or
+ * schema, as they do during a restore.  Intermediate partition tables cannot
+ * be filtered on, so patterns do not match them.
+ */
+func getFilterPatternCandidates(conn *dbconn.DBConn) (map[string]string, map[string]string) {
+	systemSchemaFilter := `n.nspname NOT LIKE 'pg_temp_%' AND n.nspname NOT LIKE 'pg_toast%' AND n.nspname NOT IN ('gp_toolkit', 'information_schema', 'pg_aoseg', 'pg_bitmapindex', 'pg_catalog')`
+	relationQuery := fmt.Sprintf(`
+	SELECT
+		c.oid,
+		n.nspname || '.' || c.relname AS name
+	FROM pg_namespace n
+	JOIN pg_class c ON n.oid = c.relnamespace
+	WHERE c.relkind IN ('r', 'f', 'S', 'v', 'm')
+	AND %s
+	AND %s`, systemSchemaFilter, ExtensionFilterClause("c"))
+	resultTables := make([]struct {
+		Oid  uint32
+		Name string
+	}, 0)
+	err := conn.Select(&resultTables, relationQuery)
+	gplog.FatalOnError(err, fmt.Sprintf("Query was: %s", relationQuery))
+	partTableMap := GetPartitionTableMap(conn)
+	relations := make(map[string]string, len(resultTables))
+	for _, table := range resultTables {
+		if partTableMap[table.Oid].Level != "i" {
+			relations[table.Name] = table.Name
+		}
+	}
+
+	schemaQuery := fmt.Sprintf(`SELECT nspname AS string FROM pg_namespace n WHERE %s`, systemSchemaFilter)
+	schemas := make(map[string]string)
+	for _, schema := range dbconn.MustSelectStringSlice(conn, schemaQuery) {
+		schemas[schema] = schema
+	}
+	return relations, schemas
+}
+
+func validateTablePredicates(opts *options.Options) {
+	if len(opts.TablePredicates) == 0 {
+		return
+	}
+	tableList := make([]string, 0, len(opts.TablePredicates))
+	for table := range opts.TablePredicates {
+		tableList = append(tableList, table)
+	}
+	DBValidate(connectionPool, tableList, false)
+	err := opts.QuoteTablePredicates(connectionPool)
+	gplog.FatalOnError(err)
+	quotedTableList := make([]string, 0, len(opts.TablePredicates))
+	for table := range opts.TablePredicates {
+		quotedTableList = append(quotedTableList, table)
+	}
+	validateTablesForCopyQuery(quotedTableList, options.TABLE_PREDICATE_FILE)
+}
+
+/*
+ * The data of every table in a backup needs the table's definition in order to
+ * be restored, so tables can only be filtered by the table and schema filters,
+ * and the objects that table definitions refer to cannot be excluded.
+ */
+func validateObjectTypeFilters(opts *options.Options) {
+	for _, objectType := range append(opts.IncludedObjectTypes, opts.ExcludedObjectTypes...) {
+		if objectType == "TABLE" || objectType == "FOREIGN TABLE" {
+			gplog.Fatal(errors.Errorf("Cannot filter on object type %s during backup.  Use the table and schema filters to filter tables.", objectType), "")
+		}
+	}
+	for _, objectType := range opts.ExcludedObjectTypes {
+		if utils.Exists(tableDependencyObjectTypes, objectType) {
+			gplog.Fatal(errors.Errorf("Cannot exclude object type %s during backup, as the tables in the backup may depend on it.", objectType), "")
+		}
+	}
+}
+
+/*
+ * Tables whose rows are filtered or whose columns are masked are backed up
+ * with COPY of a query, which cannot write to segments before GPDB 6 and,
+ * unlike COPY of a table, cannot skip external partitions.
+ */
+func validateTablesForCopyQuery(quotedTableList []string, flagName string) {
+	if connectionPool.Version.Before("6") {
+		gplog.Fatal(errors.Errorf("--%s requires GPDB 6 or later", flagName), "")
+	}
+	extPartitions, _ := GetExternalPartitionInfo(connectionPool)
+	for _, partition := range extPartitions {
+		parentFQN := utils.MakeFQN(partition.ParentSchema, partition.ParentRelationName)
+		if utils.Exists(quotedTableList, parentFQN) {
+			gplog.Fatal(errors.Errorf("Cannot use --%s with table %s, as it has external partitions.  Use --leaf-partition-data and specify its leaf partitions instead.", flagName, parentFQN), "")
+		}
+	}
+}
+
+/*
+ * A predicate for a table whose data is not backed up on its own, such as a
+ * leaf partition without --leaf-partition-data, would otherwise be ignored.
+ */
+func ValidateTablePredicatesInBackupSet(tables []Table) {
+	dataTableSet := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		if !table.SkipDataBackup() {
+			dataTableSet[table.FQN()] = true
+		}
+	}
+	for table := range tablePredicates {
+		if !dataTableSet[table] {
+			gplog.Fatal(errors.Errorf("Table %s in the table predicate file is not in the set of tables whose data is being backed up", table), "")
+		}
+	}
+}
+
 func ValidateFilterSchemas(connectionPool *dbconn.DBConn, schemaList []string, excludeSet bool) {
 	if len(schemaList) == 0 {
 		return
@@ -99,6 +225,28 @@ func DBValidate(conn *dbconn.DBConn, tableList []string, excludeSet bool) {
 	}
 }
 
+/*
+ * The heap table change counters only move while the statistics collector
+ * counts tuple changes, so with track_counts off on the master or any segment,
+ * changed heap tables would look unchanged and be left out of incremental
+ * backups.
+ */
+func ValidateTrackCounts(conn *dbconn.DBConn) {
+	query := `
+	SELECT CASE WHEN content = -1 THEN 'master' ELSE 'segment ' || content END AS string
+	FROM (
+		SELECT -1 AS content, current_setting('track_counts') AS setting
+		UNION ALL
+		SELECT gp_segment_id AS content, current_setting('track_counts') AS setting FROM gp_dist_random('gp_id')
+	) AS settings
+	WHERE setting <> 'on'
+	ORDER BY content`
+	disabled := dbconn.MustSelectStringSlice(conn, query)
+	if len(disabled) > 0 {
+		gplog.Fatal(errors.Errorf("Cannot use --%s, as track_counts is not on for the following: %s", options.INCREMENTAL_HEAP, strings.Join(disabled, ", ")), "")
+	}
+}
+
 func validateFlagCombinations(flags *pflag.FlagSet) {
 	options.CheckExclusiveFlags(flags, options.DEBUG, options.QUIET, options.VERBOSE)
 	options.CheckExclusiveFlags(flags, options.DATA_ONLY, options.METADATA_ONLY, options.INCREMENTAL)
@@ -106,10 +254,23 @@ func validateFlagCombinations(flags *pflag.FlagSet) {
 	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE, options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_FILE)
 	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE, options.EXCLUDE_RELATION, options.INCLUDE_RELATION, options.EXCLUDE_RELATION_FILE, options.INCLUDE_RELATION_FILE)
 	options.CheckExclusiveFlags(flags, options.EXCLUDE_RELATION, options.EXCLUDE_RELATION_FILE, options.LEAF_PARTITION_DATA)
+	options.CheckExclusiveFlags(flags, options.INCLUDE_OBJECT_TYPE, options.EXCLUDE_OBJECT_TYPE)
+	options.CheckExclusiveFlags(flags, options.INCLUDE_OBJECT_TYPE, options.DATA_ONLY)
+	options.CheckExclusiveFlags(flags, options.EXCLUDE_OBJECT_TYPE, options.DATA_ONLY)
+	options.CheckExclusiveFlags(flags, options.DEPENDENCY_GRAPH, options.DATA_ONLY)
+	options.CheckExclusiveFlags(flags, options.INCREMENTAL_HEAP, options.DATA_ONLY, options.METADATA_ONLY)
 	options.CheckExclusiveFlags(flags, options.JOBS, options.METADATA_ONLY, options.SINGLE_DATA_FILE)
 	options.CheckExclusiveFlags(flags, options.METADATA_ONLY, options.LEAF_PARTITION_DATA)
 	options.CheckExclusiveFlags(flags, options.NO_COMPRESSION, options.COMPRESSION_LEVEL)
+	options.CheckExclusiveFlags(flags, options.NO_COMPRESSION, options.COMPRESSION_TYPE)
 	options.CheckExclusiveFlags(flags, options.PLUGIN_CONFIG, options.BACKUP_DIR)
+	for _, flagName := range []string{options.INCREMENTAL, options.METADATA_ONLY, options.SINGLE_DATA_FILE} {
+		options.CheckExclusiveFlags(flags, options.RESUME, flagName)
+	}
+	options.CheckExclusiveFlags(flags, options.TABLE_PREDICATE_FILE, options.INCREMENTAL, options.METADATA_ONLY)
+	for _, flagName := range []string{options.INCREMENTAL, options.METADATA_ONLY, options.RESUME, options.WITH_STATS} {
+		options.CheckExclusiveFlags(flags, options.MASKING_POLICY_FILE, flagName)
+	}
 	if MustGetFlagString(options.FROM_TIMESTAMP) != "" && !MustGetFlagBool(options.INCREMENTAL) {
 		gplog.Fatal(errors.Errorf("--from-timestamp must be specified with --incremental"), "")
 	}
@@ -123,12 +284,18 @@ func validateFlagValues() {
 	gplog.FatalOnError(err)
 	err = utils.ValidateFullPath(MustGetFlagString(options.PLUGIN_CONFIG))
 	gplog.FatalOnError(err)
-	err = utils.ValidateCompressionLevel(MustGetFlagInt(options.COMPRESSION_LEVEL))
+	err = utils.ValidateFullPath(MustGetFlagString(options.METRICS_DIR))
+	gplog.FatalOnError(err)
+	err = utils.ValidateCompressionTypeAndLevel(MustGetFlagString(options.COMPRESSION_TYPE), MustGetFlagInt(options.COMPRESSION_LEVEL))
 	gplog.FatalOnError(err)
 	if MustGetFlagString(options.FROM_TIMESTAMP) != "" && !filepath.IsValidTimestamp(MustGetFlagString(options.FROM_TIMESTAMP)) {
 		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.",
 			MustGetFlagString(options.FROM_TIMESTAMP)), "")
 	}
+	if MustGetFlagString(options.RESUME) != "" && !filepath.IsValidTimestamp(MustGetFlagString(options.RESUME)) {
+		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.",
+			MustGetFlagString(options.RESUME)), "")
+	}
 }
 
 func validateFromTimestamp(fromTimestamp string) {
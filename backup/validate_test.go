@@ -177,4 +177,34 @@ var _ = Describe("backup/validate tests", func() {
 			})
 		})
 	})
+	Describe("ValidateTablePredicatesInBackupSet", func() {
+		tables := []backup.Table{
+			{Relation: backup.Relation{Schema: "sales", Name: "events"}},
+			{Relation: backup.Relation{Schema: "sales", Name: "ext_events"}, TableDefinition: backup.TableDefinition{IsExternal: true}},
+		}
+		AfterEach(func() {
+			backup.SetTablePredicates(nil)
+		})
+		It("passes if every table with a predicate has its data backed up", func() {
+			backup.SetTablePredicates(map[string]string{"sales.events": "id > 1"})
+			backup.ValidateTablePredicatesInBackupSet(tables)
+		})
+		It("panics if a table with a predicate does not have its data backed up", func() {
+			backup.SetTablePredicates(map[string]string{"sales.ext_events": "id > 1"})
+			defer testhelper.ShouldPanicWithMessage("Table sales.ext_events in the table predicate file is not in the set of tables whose data is being backed up")
+			backup.ValidateTablePredicatesInBackupSet(tables)
+		})
+	})
+	Describe("ValidateTrackCounts", func() {
+		It("passes if track_counts is on everywhere", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"string"}))
+			backup.ValidateTrackCounts(connectionPool)
+		})
+		It("panics if track_counts is off on the master or a segment", func() {
+			disabledRows := sqlmock.NewRows([]string{"string"}).AddRow("master").AddRow("segment 1")
+			mock.ExpectQuery(`SELECT (.*)current_setting\('track_counts'\)(.*)gp_dist_random\('gp_id'\)`).WillReturnRows(disabledRows)
+			defer testhelper.ShouldPanicWithMessage("Cannot use --incremental-heap, as track_counts is not on for the following: master, segment 1")
+			backup.ValidateTrackCounts(connectionPool)
+		})
+	})
 })
@@ -12,6 +12,7 @@ import (
 	"github.com/nightlyone/lockfile"
 	"github.com/pkg/errors"
 	"reflect"
+	"strings"
 )
 
 /*
@@ -40,6 +41,21 @@ func initializeConnectionPool() {
 	connectionPool.MustConnect(MustGetFlagInt(options.JOBS))
 	utils.ValidateGPDBVersionCompatibility(connectionPool)
 	InitializeMetadataParams(connectionPool)
+	BeginBackupTransactions()
+}
+
+/*
+ * The heap table change counters are not transactional, so they are read
+ * before the snapshot of the backup is taken.  A change committed between
+ * the two is then in the backup without having been counted, which only
+ * causes the next incremental backup to back up the table again.  Were the
+ * counters read after the snapshot, a change could be counted without being
+ * in the backup, and every later incremental backup would skip it.
+ */
+func BeginBackupTransactions() {
+	if MustGetFlagBool(options.INCREMENTAL_HEAP) {
+		heapIncrementalMetadata = GetHeapIncrementalMetadata(connectionPool)
+	}
 	for connNum := 0; connNum < connectionPool.NumConns; connNum++ {
 		connectionPool.MustExec("SET application_name TO 'gpbackup'", connNum)
 		connectionPool.MustBegin(connNum)
@@ -73,17 +89,24 @@ func SetSessionGUCs(connNum int) {
 }
 
 func NewBackupConfig(dbName string, dbVersion string, backupVersion string, plugin string, timestamp string, opts options.Options) *history.BackupConfig {
+	compressionType := ""
+	if !MustGetFlagBool(options.NO_COMPRESSION) {
+		compressionType = MustGetFlagString(options.COMPRESSION_TYPE)
+	}
 	backupConfig := history.BackupConfig{
 		BackupDir:             MustGetFlagString(options.BACKUP_DIR),
 		BackupVersion:         backupVersion,
 		Compressed:            !MustGetFlagBool(options.NO_COMPRESSION),
+		CompressionType:       compressionType,
 		DatabaseName:          dbName,
 		DatabaseVersion:       dbVersion,
 		DataOnly:              MustGetFlagBool(options.DATA_ONLY),
+		ExcludeObjectTypes:    opts.ExcludedObjectTypes,
 		ExcludeRelations:      MustGetFlagStringArray(options.EXCLUDE_RELATION),
 		ExcludeSchemaFiltered: len(MustGetFlagStringArray(options.EXCLUDE_SCHEMA)) > 0,
 		ExcludeSchemas:        MustGetFlagStringArray(options.EXCLUDE_SCHEMA),
 		ExcludeTableFiltered:  len(MustGetFlagStringArray(options.EXCLUDE_RELATION)) > 0,
+		IncludeObjectTypes:    opts.IncludedObjectTypes,
 		IncludeRelations:      opts.GetOriginalIncludedTables(),
 		IncludeSchemaFiltered: len(MustGetFlagStringArray(options.INCLUDE_SCHEMA)) > 0,
 		IncludeSchemas:        MustGetFlagStringArray(options.INCLUDE_SCHEMA),
@@ -109,6 +132,13 @@ func initializeBackupReport(opts options.Options) {
 	}
 	config := NewBackupConfig(escapedDBName, connectionPool.Version.VersionString, version,
 		plugin, globalFPInfo.Timestamp, opts)
+	config.SegmentCount = utils.GetSegmentCount(globalCluster)
+	if key := utils.GetEncryptionKey(); key != nil {
+		config.Encrypted = true
+		config.EncryptionKeyFingerprint = utils.GetEncryptionKeyFingerprint(key)
+	}
+	config.Masked = maskingPolicy != nil
+	config.Predicated = len(tablePredicates) > 0
 
 	isFilteredBackup := config.IncludeTableFiltered || config.IncludeSchemaFiltered ||
 		config.ExcludeTableFiltered || config.ExcludeSchemaFiltered
@@ -123,9 +153,30 @@ func initializeBackupReport(opts options.Options) {
 		DatabaseSize: dbSize,
 		BackupConfig: *config,
 	}
+	if MustGetFlagString(options.CONFIG) != "" {
+		backupReport.EffectiveFlags = strings.Join(options.GetEffectiveFlags(cmdFlags), " ")
+	}
 	backupReport.ConstructBackupParamsString()
 }
 
+/*
+ * Master files are encrypted by gpbackup itself, while data is encrypted on
+ * the segments by gpbackup_helper with a copy of the key that is removed
+ * when the backup finishes.
+ */
+func initializeEncryption() {
+	key, err := utils.ReadEncryptionKey(MustGetFlagString(options.ENCRYPTION_KEY_FILE))
+	gplog.FatalOnError(err)
+	utils.SetEncryptionKey(key)
+	if MustGetFlagBool(options.METADATA_ONLY) {
+		return
+	}
+	utils.VerifyHelperVersionOnSegments(version, globalCluster)
+	encryptionKeyFile = utils.NewEncryptionKeyFilePath(fmt.Sprintf("gpbackup_%s", globalFPInfo.Timestamp))
+	utils.CopyEncryptionKeyToAllHosts(globalCluster, key, encryptionKeyFile)
+	utils.AddEncryptionToPipeThroughProgram(encryptionKeyFile)
+}
+
 func createBackupLockFile(timestamp string) {
 	var err error
 	timestampLockFile := fmt.Sprintf("/tmp/%s.lck", timestamp)
@@ -172,10 +223,13 @@ func RetrieveAndProcessTables() ([]Table, []Table) {
 
 func retrieveFunctions(sortables *[]Sortable, metadataMap MetadataMap) ([]Function, map[uint32]FunctionInfo) {
 	gplog.Verbose("Retrieving function information")
+	funcInfoMap := GetFunctionOidToInfoMap(connectionPool)
+	if !shouldBackupObjectType("FUNCTION") {
+		return []Function{}, funcInfoMap
+	}
 	functionMetadata := GetMetadataForObjectType(connectionPool, TYPE_FUNCTION)
 	addToMetadataMap(functionMetadata, metadataMap)
 	functions := GetFunctionsAllVersions(connectionPool)
-	funcInfoMap := GetFunctionOidToInfoMap(connectionPool)
 	objectCounts["Functions"] = len(functions)
 	*sortables = append(*sortables, convertToSortableSlice(functions)...)
 
@@ -184,18 +238,26 @@ func retrieveFunctions(sortables *[]Sortable, metadataMap MetadataMap) ([]Functi
 
 func retrieveAndBackupTypes(metadataFile *utils.FileWithByteCount, sortables *[]Sortable, metadataMap MetadataMap) {
 	gplog.Verbose("Retrieving type information")
-	shells := GetShellTypes(connectionPool)
-	bases := GetBaseTypes(connectionPool)
-	composites := GetCompositeTypes(connectionPool)
-	domains := GetDomainTypes(connectionPool)
+	shells := make([]ShellType, 0)
+	bases := make([]BaseType, 0)
+	composites := make([]CompositeType, 0)
+	domains := make([]Domain, 0)
 	rangeTypes := make([]RangeType, 0)
-	if connectionPool.Version.AtLeast("6") {
-		rangeTypes = GetRangeTypes(connectionPool)
+	if shouldBackupObjectType("TYPE") {
+		shells = GetShellTypes(connectionPool)
+		bases = GetBaseTypes(connectionPool)
+		composites = GetCompositeTypes(connectionPool)
+		if connectionPool.Version.AtLeast("6") {
+			rangeTypes = GetRangeTypes(connectionPool)
+		}
+	}
+	if shouldBackupObjectType("DOMAIN") {
+		domains = GetDomainTypes(connectionPool)
 	}
 	typeMetadata := GetMetadataForObjectType(connectionPool, TYPE_TYPE)
 
 	backupShellTypes(metadataFile, shells, bases, rangeTypes)
-	if connectionPool.Version.AtLeast("5") {
+	if connectionPool.Version.AtLeast("5") && shouldBackupObjectType("TYPE") {
 		backupEnumTypes(metadataFile, typeMetadata)
 	}
 
@@ -220,6 +282,9 @@ func retrieveConstraints(tables ...Relation) ([]Constraint, MetadataMap) {
 
 func retrieveAndBackupSequences(metadataFile *utils.FileWithByteCount,
 	relationMetadata MetadataMap) []Sequence {
+	if !shouldBackupObjectType("SEQUENCE") {
+		return []Sequence{}
+	}
 	gplog.Verbose("Writing CREATE SEQUENCE statements to metadata file")
 	sequences := GetAllSequences(connectionPool)
 	objectCounts["Sequences"] = len(sequences)
@@ -228,6 +293,9 @@ func retrieveAndBackupSequences(metadataFile *utils.FileWithByteCount,
 }
 
 func retrieveProtocols(sortables *[]Sortable, metadataMap MetadataMap) []ExternalProtocol {
+	if !shouldBackupObjectType("PROTOCOL") {
+		return []ExternalProtocol{}
+	}
 	gplog.Verbose("Retrieving protocols")
 	protocols := GetExternalProtocols(connectionPool)
 	objectCounts["Protocols"] = len(protocols)
@@ -240,8 +308,17 @@ func retrieveProtocols(sortables *[]Sortable, metadataMap MetadataMap) []Externa
 }
 
 func retrieveViews(sortables *[]Sortable) {
+	if !shouldBackupObjectType("VIEW") && !shouldBackupObjectType("MATERIALIZED VIEW") {
+		return
+	}
 	gplog.Verbose("Retrieving views")
 	views, materializedViews := GetAllViews(connectionPool)
+	if !shouldBackupObjectType("VIEW") {
+		views = []View{}
+	}
+	if !shouldBackupObjectType("MATERIALIZED VIEW") {
+		materializedViews = []MaterializedView{}
+	}
 	objectCounts["Views"] = len(views)
 
 	*sortables = append(*sortables, convertToSortableSlice(views)...)
@@ -260,6 +337,9 @@ func retrieveTSObjects(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveTSParsers(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("TEXT SEARCH PARSER") {
+		return
+	}
 	gplog.Verbose("Retrieving Text Search Parsers")
 	parsers := GetTextSearchParsers(connectionPool)
 	objectCounts["Text Search Parsers"] = len(parsers)
@@ -270,6 +350,9 @@ func retrieveTSParsers(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveTSTemplates(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("TEXT SEARCH TEMPLATE") {
+		return
+	}
 	gplog.Verbose("Retrieving TEXT SEARCH TEMPLATE information")
 	templates := GetTextSearchTemplates(connectionPool)
 	objectCounts["Text Search Templates"] = len(templates)
@@ -280,6 +363,9 @@ func retrieveTSTemplates(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveTSDictionaries(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("TEXT SEARCH DICTIONARY") {
+		return
+	}
 	gplog.Verbose("Retrieving TEXT SEARCH DICTIONARY information")
 	dictionaries := GetTextSearchDictionaries(connectionPool)
 	objectCounts["Text Search Dictionaries"] = len(dictionaries)
@@ -290,6 +376,9 @@ func retrieveTSDictionaries(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveTSConfigurations(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("TEXT SEARCH CONFIGURATION") {
+		return
+	}
 	gplog.Verbose("Retrieving TEXT SEARCH CONFIGURATION information")
 	configurations := GetTextSearchConfigurations(connectionPool)
 	objectCounts["Text Search Configurations"] = len(configurations)
@@ -305,6 +394,9 @@ func retrieveOperatorObjects(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveOperators(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("OPERATOR") {
+		return
+	}
 	gplog.Verbose("Retrieving OPERATOR information")
 	operators := GetOperators(connectionPool)
 	objectCounts["Operators"] = len(operators)
@@ -315,6 +407,9 @@ func retrieveOperators(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveOperatorClasses(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("OPERATOR CLASS") {
+		return
+	}
 	gplog.Verbose("Retrieving OPERATOR CLASS information")
 	operatorClasses := GetOperatorClasses(connectionPool)
 	objectCounts["Operator Classes"] = len(operatorClasses)
@@ -325,6 +420,9 @@ func retrieveOperatorClasses(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveAggregates(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("AGGREGATE") {
+		return
+	}
 	gplog.Verbose("Retrieving AGGREGATE information")
 	aggregates := GetAggregates(connectionPool)
 	objectCounts["Aggregates"] = len(aggregates)
@@ -335,6 +433,9 @@ func retrieveAggregates(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveCasts(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("CAST") {
+		return
+	}
 	gplog.Verbose("Retrieving CAST information")
 	casts := GetCasts(connectionPool)
 	objectCounts["Casts"] = len(casts)
@@ -354,6 +455,9 @@ func retrieveFDWObjects(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveForeignDataWrappers(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("FOREIGN DATA WRAPPER") {
+		return
+	}
 	gplog.Verbose("Writing CREATE FOREIGN DATA WRAPPER statements to metadata file")
 	wrappers := GetForeignDataWrappers(connectionPool)
 	objectCounts["Foreign Data Wrappers"] = len(wrappers)
@@ -364,6 +468,9 @@ func retrieveForeignDataWrappers(sortables *[]Sortable, metadataMap MetadataMap)
 }
 
 func retrieveForeignServers(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("FOREIGN SERVER") {
+		return
+	}
 	gplog.Verbose("Writing CREATE SERVER statements to metadata file")
 	servers := GetForeignServers(connectionPool)
 	objectCounts["Foreign Servers"] = len(servers)
@@ -374,6 +481,9 @@ func retrieveForeignServers(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveUserMappings(sortables *[]Sortable) {
+	if !shouldBackupObjectType("USER MAPPING") {
+		return
+	}
 	gplog.Verbose("Writing CREATE USER MAPPING statements to metadata file")
 	mappings := GetUserMappings(connectionPool)
 	objectCounts["User Mappings"] = len(mappings)
@@ -393,6 +503,9 @@ func backupSessionGUC(metadataFile *utils.FileWithByteCount) {
  */
 
 func backupTablespaces(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("TABLESPACE") {
+		return
+	}
 	gplog.Verbose("Writing CREATE TABLESPACE statements to metadata file")
 	tablespaces := GetTablespaces(connectionPool)
 	objectCounts["Tablespaces"] = len(tablespaces)
@@ -416,6 +529,9 @@ func backupDatabaseGUCs(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupResourceQueues(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("RESOURCE QUEUE") {
+		return
+	}
 	gplog.Verbose("Writing CREATE RESOURCE QUEUE statements to metadata file")
 	resQueues := GetResourceQueues(connectionPool)
 	objectCounts["Resource Queues"] = len(resQueues)
@@ -424,7 +540,7 @@ func backupResourceQueues(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupResourceGroups(metadataFile *utils.FileWithByteCount) {
-	if !connectionPool.Version.AtLeast("5") {
+	if !connectionPool.Version.AtLeast("5") || !shouldBackupObjectType("RESOURCE GROUP") {
 		return
 	}
 	gplog.Verbose("Writing CREATE RESOURCE GROUP statements to metadata file")
@@ -436,6 +552,9 @@ func backupResourceGroups(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupRoles(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("ROLE") {
+		return
+	}
 	gplog.Verbose("Writing CREATE ROLE statements to metadata file")
 	roles := GetRoles(connectionPool)
 	objectCounts["Roles"] = len(roles)
@@ -444,12 +563,18 @@ func backupRoles(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupRoleGUCs(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("ROLE GUCS") {
+		return
+	}
 	gplog.Verbose("Writing ROLE Configuration Parameter to meadata file")
 	roleGUCs := GetRoleGUCs(connectionPool)
 	PrintRoleGUCStatements(metadataFile, globalTOC, roleGUCs)
 }
 
 func backupRoleGrants(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("ROLE GRANT") {
+		return
+	}
 	gplog.Verbose("Writing GRANT ROLE statements to metadata file")
 	roleMembers := GetRoleMembers(connectionPool)
 	PrintRoleMembershipStatements(metadataFile, globalTOC, roleMembers)
@@ -460,6 +585,9 @@ func backupRoleGrants(metadataFile *utils.FileWithByteCount) {
  */
 
 func backupSchemas(metadataFile *utils.FileWithByteCount, partitionAlteredSchemas map[string]bool) {
+	if !shouldBackupObjectType("SCHEMA") {
+		return
+	}
 	gplog.Verbose("Writing CREATE SCHEMA statements to metadata file")
 	schemas := GetAllUserSchemas(connectionPool, partitionAlteredSchemas)
 	objectCounts["Schemas"] = len(schemas)
@@ -469,6 +597,9 @@ func backupSchemas(metadataFile *utils.FileWithByteCount, partitionAlteredSchema
 
 func backupProceduralLanguages(metadataFile *utils.FileWithByteCount,
 	functions []Function, funcInfoMap map[uint32]FunctionInfo, functionMetadata MetadataMap) {
+	if !shouldBackupObjectType("LANGUAGE") {
+		return
+	}
 	gplog.Verbose("Writing CREATE PROCEDURAL LANGUAGE statements to metadata file")
 	procLangs := GetProceduralLanguages(connectionPool)
 	objectCounts["Procedural Languages"] = len(procLangs)
@@ -492,6 +623,21 @@ func backupEnumTypes(metadataFile *utils.FileWithByteCount, typeMetadata Metadat
 	PrintCreateEnumTypeStatements(metadataFile, globalTOC, enums, typeMetadata)
 }
 
+/*
+ * Tables are always backed up, as they are filtered by the table and schema
+ * filters instead, and so are the schemas, sequences, and types that table
+ * definitions refer to.  Objects whose type is not recorded in the table of
+ * contents, such as the database itself, always pass the filter.
+ */
+var tableDependencyObjectTypes = []string{"SCHEMA", "SEQUENCE", "TYPE", "DOMAIN"}
+
+func shouldBackupObjectType(objectType string) bool {
+	if utils.Exists(tableDependencyObjectTypes, objectType) {
+		return true
+	}
+	return options.ObjectTypeMatchesFilter(includedObjectTypes, excludedObjectTypes, objectType)
+}
+
 func createBackupSet(objSlice []Sortable) (backupSet map[UniqueID]bool) {
 	backupSet = make(map[UniqueID]bool)
 	for _, obj := range objSlice {
@@ -533,13 +679,19 @@ func backupDependentObjects(metadataFile *utils.FileWithByteCount, tables []Tabl
 	gplog.Verbose("Writing CREATE statements for dependent objects to metadata file")
 
 	backupSet := createBackupSet(sortables)
-	relevantDeps := GetDependencies(connectionPool, backupSet)
+	relevantDeps := getCatalogDependencies(connectionPool, backupSet)
+	brokenDeps := breakCircularDependencies(relevantDeps)
 	if connectionPool.Version.Is("4") && !tableOnly {
 		AddProtocolDependenciesForGPDB4(relevantDeps, tables, protocols)
 	}
+	// The graph is written before sorting so that it is available if sorting fails
+	if graphPath := MustGetFlagString(options.DEPENDENCY_GRAPH); graphPath != "" {
+		WriteDependencyGraph(graphPath, sortables, relevantDeps, brokenDeps)
+	}
 	sortedSlice := TopologicalSort(sortables, relevantDeps)
 
-	PrintDependentObjectStatements(metadataFile, globalTOC, sortedSlice, filteredMetadata, constraints, funcInfoMap)
+	objectEntries := PrintDependentObjectStatements(metadataFile, globalTOC, sortedSlice, filteredMetadata, constraints, funcInfoMap)
+	AddPredataEntryDependencies(globalTOC, objectEntries, relevantDeps)
 	PrintAlterSequenceStatements(metadataFile, globalTOC, sequences)
 	extPartInfo, partInfoMap := GetExternalPartitionInfo(connectionPool)
 	if len(extPartInfo) > 0 {
@@ -549,6 +701,9 @@ func backupDependentObjects(metadataFile *utils.FileWithByteCount, tables []Tabl
 }
 
 func backupConversions(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("CONVERSION") {
+		return
+	}
 	gplog.Verbose("Writing CREATE CONVERSION statements to metadata file")
 	conversions := GetConversions(connectionPool)
 	objectCounts["Conversions"] = len(conversions)
@@ -557,7 +712,7 @@ func backupConversions(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupOperatorFamilies(metadataFile *utils.FileWithByteCount) {
-	if !connectionPool.Version.AtLeast("5") {
+	if !connectionPool.Version.AtLeast("5") || !shouldBackupObjectType("OPERATOR FAMILY") {
 		return
 	}
 	gplog.Verbose("Writing CREATE OPERATOR FAMILY statements to metadata file")
@@ -568,7 +723,7 @@ func backupOperatorFamilies(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupCollations(metadataFile *utils.FileWithByteCount) {
-	if !connectionPool.Version.AtLeast("6") {
+	if !connectionPool.Version.AtLeast("6") || !shouldBackupObjectType("COLLATION") {
 		return
 	}
 	gplog.Verbose("Writing CREATE COLLATION statements to metadata file")
@@ -580,7 +735,7 @@ func backupCollations(metadataFile *utils.FileWithByteCount) {
 
 func backupExtensions(metadataFile *utils.FileWithByteCount) {
 	if !(len(MustGetFlagStringArray(options.INCLUDE_SCHEMA)) == 0 &&
-		connectionPool.Version.AtLeast("5")) {
+		connectionPool.Version.AtLeast("5")) || !shouldBackupObjectType("EXTENSION") {
 		return
 	}
 	gplog.Verbose("Writing CREATE EXTENSION statements to metadata file")
@@ -591,6 +746,9 @@ func backupExtensions(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupConstraints(metadataFile *utils.FileWithByteCount, constraints []Constraint, conMetadata MetadataMap) {
+	if !shouldBackupObjectType("CONSTRAINT") {
+		return
+	}
 	gplog.Verbose("Writing ADD CONSTRAINT statements to metadata file")
 	objectCounts["Constraints"] = len(constraints)
 	PrintConstraintStatements(metadataFile, globalTOC, constraints, conMetadata)
@@ -601,6 +759,9 @@ func backupConstraints(metadataFile *utils.FileWithByteCount, constraints []Cons
  */
 
 func backupIndexes(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("INDEX") {
+		return
+	}
 	gplog.Verbose("Writing CREATE INDEX statements to metadata file")
 	indexes := GetIndexes(connectionPool)
 	objectCounts["Indexes"] = len(indexes)
@@ -609,6 +770,9 @@ func backupIndexes(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupRules(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("RULE") {
+		return
+	}
 	gplog.Verbose("Writing CREATE RULE statements to metadata file")
 	rules := GetRules(connectionPool)
 	objectCounts["Rules"] = len(rules)
@@ -617,6 +781,9 @@ func backupRules(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupTriggers(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("TRIGGER") {
+		return
+	}
 	gplog.Verbose("Writing CREATE TRIGGER statements to metadata file")
 	triggers := GetTriggers(connectionPool)
 	objectCounts["Triggers"] = len(triggers)
@@ -625,6 +792,9 @@ func backupTriggers(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupEventTriggers(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("EVENT TRIGGER") {
+		return
+	}
 	gplog.Verbose("Writing CREATE EVENT TRIGGER statements to metadata file")
 	eventTriggers := GetEventTriggers(connectionPool)
 	objectCounts["Event Triggers"] = len(eventTriggers)
@@ -633,6 +803,9 @@ func backupEventTriggers(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupDefaultPrivileges(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("DEFAULT PRIVILEGES") {
+		return
+	}
 	gplog.Verbose("Writing ALTER DEFAULT PRIVILEGES statements to metadata file")
 	defaultPrivileges := GetDefaultPrivileges(connectionPool)
 	objectCounts["DEFAULT PRIVILEGES"] = len(defaultPrivileges)
@@ -651,7 +824,10 @@ func backupTableStatistics(statisticsFile *utils.FileWithByteCount, tables []Tab
 	PrintStatisticsStatements(statisticsFile, globalTOC, tables, attStats, tupleStats)
 }
 
-func backupIncrementalMetadata() {
+func backupIncrementalMetadata(tables []Table) {
 	aoTableEntries := GetAOIncrementalMetadata(connectionPool)
 	globalTOC.IncrementalMetadata.AO = aoTableEntries
+	if MustGetFlagBool(options.INCREMENTAL_HEAP) {
+		globalTOC.IncrementalMetadata.Heap = FilterHeapIncrementalMetadata(heapIncrementalMetadata, tables)
+	}
 }
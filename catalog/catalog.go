@@ -0,0 +1,226 @@
+package catalog
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+/*
+ * This file contains the command definitions for gpbackup_catalog, which
+ * manages the backups recorded in the gpbackup_history.yaml file.
+ */
+
+// This function handles setup that can be done before parsing flags.
+func DoInit(cmd *cobra.Command) {
+	gplog.InitializeLogging("gpbackup_catalog", "")
+	SetCmdFlags(cmd.PersistentFlags())
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List the backups recorded in the backup history file",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				defer DoTeardown()
+				DoSetup()
+				ListBackups(readHistory(), MustGetFlagString(options.DBNAME), MustGetFlagBool(options.SHOW_DELETED))
+			},
+		},
+		&cobra.Command{
+			Use:   "show <timestamp>",
+			Short: "Display the configuration and restore plan of a backup",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				defer DoTeardown()
+				validateTimestamp(args[0])
+				DoSetup()
+				ShowBackup(readHistory(), args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "delete <timestamp>",
+			Short: "Delete a backup from all hosts, or from the plugin destination, and mark it deleted in the backup history file",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				defer DoTeardown()
+				validateTimestamp(args[0])
+				DoSetup()
+				lock := history.LockHistoryFile()
+				defer func() {
+					_ = lock.Unlock()
+				}()
+				DeleteBackup(readHistory(), getHistoryFilePath(), args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "diff <old timestamp> <new timestamp>",
+			Short: "Compare the metadata and table row counts of two backups",
+			Args:  cobra.ExactArgs(2),
+			Run: func(cmd *cobra.Command, args []string) {
+				defer DoTeardown()
+				validateTimestamp(args[0])
+				validateTimestamp(args[1])
+				DoSetup()
+				DiffBackups(readHistory(), args[0], args[1], MustGetFlagString(options.FORMAT))
+			},
+		},
+		&cobra.Command{
+			Use:   "consolidate <timestamp>",
+			Short: "Create a full backup from an incremental backup and the backups it depends on",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				defer DoTeardown()
+				validateTimestamp(args[0])
+				DoSetup()
+				lock := history.LockHistoryFile()
+				defer func() {
+					_ = lock.Unlock()
+				}()
+				ConsolidateBackup(readHistory(), getHistoryFilePath(), args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "prune",
+			Short: "Delete the backups that have expired under the given retention policy",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				defer DoTeardown()
+				policy := NewRetentionPolicyFromFlags()
+				DoSetup()
+				lock := history.LockHistoryFile()
+				defer func() {
+					_ = lock.Unlock()
+				}()
+				PruneBackups(readHistory(), getHistoryFilePath(), MustGetFlagString(options.DBNAME), policy, MustGetFlagBool(options.DRY_RUN))
+			},
+		},
+	)
+}
+
+func validateTimestamp(timestamp string) {
+	if !filepath.IsValidTimestamp(timestamp) {
+		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.", timestamp), "")
+	}
+}
+
+// This function handles setup that must be done after parsing flags.
+func DoSetup() {
+	SetLoggerVerbosity()
+	gplog.Verbose("Catalog Command: %s", os.Args)
+
+	connectionPool = dbconn.NewDBConnFromEnvironment("postgres")
+	connectionPool.MustConnect(1)
+	segConfig := cluster.MustGetSegmentConfiguration(connectionPool)
+	globalCluster = cluster.NewCluster(segConfig)
+	segPrefix = filepath.GetSegPrefix(connectionPool)
+}
+
+func SetLoggerVerbosity() {
+	if MustGetFlagBool(options.QUIET) {
+		gplog.SetVerbosity(gplog.LOGERROR)
+	} else if MustGetFlagBool(options.DEBUG) {
+		gplog.SetVerbosity(gplog.LOGDEBUG)
+	} else if MustGetFlagBool(options.VERBOSE) {
+		gplog.SetVerbosity(gplog.LOGVERBOSE)
+	}
+}
+
+func getHistoryFilePath() string {
+	fpInfo := filepath.NewFilePathInfo(globalCluster, "", "", "")
+	return fpInfo.GetBackupHistoryFilePath()
+}
+
+func readHistory() *history.History {
+	historyFilePath := getHistoryFilePath()
+	if !iohelper.FileExistsAndIsReadable(historyFilePath) {
+		gplog.Verbose("No backup history file found at %s", historyFilePath)
+		return &history.History{BackupConfigs: make([]history.BackupConfig, 0)}
+	}
+	backupHistory, err := history.NewHistory(historyFilePath)
+	gplog.FatalOnError(err)
+	return backupHistory
+}
+
+// All of the backups must have been encrypted with the same key, if they were encrypted at all
+func initializeEncryption(backupConfigs ...*history.BackupConfig) {
+	key, err := utils.ReadEncryptionKey(MustGetFlagString(options.ENCRYPTION_KEY_FILE))
+	gplog.FatalOnError(err)
+	for _, backupConfig := range backupConfigs {
+		if backupConfig.Encrypted && utils.GetEncryptionKeyFingerprint(key) != backupConfig.EncryptionKeyFingerprint {
+			gplog.Fatal(errors.Errorf("The encryption key provided is not the key that was used to encrypt backup %s", backupConfig.Timestamp), "")
+		}
+	}
+	utils.SetEncryptionKey(key)
+}
+
+// The data entries of the tables whose data is in the backup with the timestamp
+type restorePlanDataEntries struct {
+	timestamp   string
+	dataEntries []toc.MasterDataEntry
+}
+
+/*
+ * An incremental backup holds the data of only some of its tables, so the
+ * data entries of the other tables are read from the TOCs of the backups in
+ * its restore plan, which are expected to be in the same backup directory.
+ * The entries are returned for each backup in the order of the restore plan.
+ */
+func readRestorePlanDataEntries(backupConfig *history.BackupConfig, backupTOC *toc.TOC) []restorePlanDataEntries {
+	if len(backupConfig.RestorePlan) == 0 {
+		return []restorePlanDataEntries{{timestamp: backupConfig.Timestamp, dataEntries: backupTOC.DataEntries}}
+	}
+	planDataEntries := make([]restorePlanDataEntries, 0, len(backupConfig.RestorePlan))
+	for _, restorePlanEntry := range backupConfig.RestorePlan {
+		planTOC := backupTOC
+		if restorePlanEntry.Timestamp != backupConfig.Timestamp {
+			planFPInfo := filepath.NewFilePathInfo(globalCluster, backupConfig.BackupDir, restorePlanEntry.Timestamp, segPrefix)
+			planTOC = toc.NewTOC(planFPInfo.GetTOCFilePath())
+		}
+		entries := restorePlanDataEntries{timestamp: restorePlanEntry.Timestamp, dataEntries: make([]toc.MasterDataEntry, 0)}
+		planTables := utils.NewSet(restorePlanEntry.TableFQNs)
+		for _, entry := range planTOC.DataEntries {
+			if planTables.MatchesFilter(utils.MakeFQN(entry.Schema, entry.Name)) {
+				entries.dataEntries = append(entries.dataEntries, entry)
+			}
+		}
+		planDataEntries = append(planDataEntries, entries)
+	}
+	return planDataEntries
+}
+
+func DoTeardown() {
+	errStr := ""
+	if err := recover(); err != nil {
+		// gplog's Fatal will cause a panic with error code 2
+		if gplog.GetErrorCode() != 2 {
+			gplog.Error(fmt.Sprintf("%v: %s", err, debug.Stack()))
+			gplog.SetErrorCode(2)
+		} else {
+			errStr = fmt.Sprintf("%v", err)
+		}
+	}
+	if errStr != "" {
+		fmt.Fprintln(operating.System.Stdout, errStr)
+	}
+	if connectionPool != nil {
+		connectionPool.Close()
+	}
+	os.Exit(gplog.GetErrorCode())
+}
+
+func GetVersion() string {
+	return version
+}
@@ -0,0 +1,40 @@
+package catalog_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/catalog"
+	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/spf13/pflag"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var (
+	connectionPool *dbconn.DBConn
+	mock           sqlmock.Sqlmock
+	stdout         *Buffer
+	logfile        *Buffer
+	buffer         *Buffer
+	cmdFlags       *pflag.FlagSet
+)
+
+func TestCatalog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "catalog tests")
+}
+
+var _ = BeforeEach(func() {
+	connectionPool, mock, stdout, _, logfile = testutils.SetupTestEnvironment()
+	catalog.SetConnection(connectionPool)
+	cmdFlags = pflag.NewFlagSet("gpbackup_catalog", pflag.ExitOnError)
+	catalog.SetCmdFlags(cmdFlags)
+	buffer = NewBuffer()
+	operating.InitializeSystemFunctions()
+	operating.System.Stdout = buffer
+})
@@ -0,0 +1,211 @@
+package catalog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+/*
+ * This file contains functions for consolidating an incremental backup and
+ * the backups in its restore plan into a new full backup.
+ */
+
+// The caller holds the history file lock from reading backupHistory until this returns
+func ConsolidateBackup(backupHistory *history.History, historyFilePath string, timestamp string) {
+	backupConfig := findConsolidateBackupConfig(backupHistory, timestamp)
+	newTimestamp := history.CurrentTimestamp()
+	if backupHistory.FindBackupConfig(newTimestamp) != nil {
+		gplog.Fatal(errors.Errorf("A backup with timestamp %s already exists", newTimestamp), "")
+	}
+	if backupConfig.Encrypted {
+		initializeEncryption(backupConfig)
+	}
+
+	gplog.Info("Consolidating incremental backup %s and the %d backup(s) it depends on into full backup %s",
+		timestamp, len(backupConfig.RestorePlan)-1, newTimestamp)
+	fpInfo := filepath.NewFilePathInfo(globalCluster, backupConfig.BackupDir, timestamp, segPrefix)
+	newFPInfo := filepath.NewFilePathInfo(globalCluster, backupConfig.BackupDir, newTimestamp, segPrefix)
+	backupTOC := toc.NewTOC(fpInfo.GetTOCFilePath())
+	planDataEntries := readRestorePlanDataEntries(backupConfig, backupTOC)
+	consolidatedTOC := newConsolidatedTOC(backupTOC, planDataEntries)
+
+	createConsolidatedDirectories(newFPInfo)
+	defer func() {
+		if err := recover(); err != nil {
+			removeConsolidatedDirectories(newFPInfo)
+			panic(err)
+		}
+	}()
+	utils.InitializePipeThroughParameters(backupConfig.Compressed, backupConfig.CompressionType, 0)
+	for _, entries := range planDataEntries {
+		linkDataFiles(backupConfig, entries, newFPInfo)
+	}
+
+	masterFiles := []string{newFPInfo.GetMetadataFilePath(), newFPInfo.GetTOCFilePath()}
+	err := utils.CopyFile(fpInfo.GetMetadataFilePath(), newFPInfo.GetMetadataFilePath())
+	gplog.FatalOnError(err)
+	if backupConfig.WithStatistics {
+		err = utils.CopyFile(fpInfo.GetStatisticsFilePath(), newFPInfo.GetStatisticsFilePath())
+		gplog.FatalOnError(err)
+		masterFiles = append(masterFiles, newFPInfo.GetStatisticsFilePath())
+	}
+	consolidatedTOC.WriteToFileAndMakeReadOnly(newFPInfo.GetTOCFilePath())
+	newConfig := NewConsolidatedConfig(backupConfig, newTimestamp)
+	history.WriteConfigFile(newConfig, newFPInfo.GetConfigFilePath())
+	utils.WriteChecksumManifest(globalCluster, newFPInfo, masterFiles, true)
+
+	newConfig.EndTime = history.CurrentTimestamp()
+	backupHistory.AddBackupConfig(newConfig)
+	err = backupHistory.WriteToFileAndMakeReadOnly(historyFilePath)
+	gplog.FatalOnError(err)
+	gplog.Info("Full backup %s created from incremental backup %s", newTimestamp, timestamp)
+}
+
+/*
+ * The data files of every backup in the restore plan must be on the cluster,
+ * and each table must have its own data file so that it can be linked into
+ * the new backup.
+ */
+func findConsolidateBackupConfig(backupHistory *history.History, timestamp string) *history.BackupConfig {
+	backupConfig := backupHistory.FindBackupConfig(timestamp)
+	if backupConfig == nil {
+		gplog.Fatal(errors.Errorf("Backup %s was not found in the backup history file", timestamp), "")
+	}
+	if backupConfig.DateDeleted != "" {
+		gplog.Fatal(errors.Errorf("Backup %s was deleted on %s", timestamp, backupConfig.DateDeleted), "")
+	}
+	if !backupConfig.Incremental {
+		gplog.Fatal(errors.Errorf("Backup %s is not an incremental backup", timestamp), "")
+	}
+	if backupConfig.Plugin != "" {
+		gplog.Fatal(errors.Errorf("Backup %s was taken with plugin %s, so its files are not available to consolidate", timestamp, backupConfig.Plugin), "")
+	}
+	if backupConfig.SingleDataFile {
+		gplog.Fatal(errors.Errorf("Backup %s was taken with a single data file per segment, so its tables cannot be consolidated", timestamp), "")
+	}
+	for _, restorePlanEntry := range backupConfig.RestorePlan {
+		planConfig := backupHistory.FindBackupConfig(restorePlanEntry.Timestamp)
+		if planConfig == nil {
+			gplog.Fatal(errors.Errorf("Backup %s in the restore plan of backup %s was not found in the backup history file", restorePlanEntry.Timestamp, timestamp), "")
+		}
+		if planConfig.DateDeleted != "" {
+			gplog.Fatal(errors.Errorf("Backup %s in the restore plan of backup %s was deleted on %s", restorePlanEntry.Timestamp, timestamp, planConfig.DateDeleted), "")
+		}
+	}
+	return backupConfig
+}
+
+/*
+ * The metadata of an incremental backup describes the whole database, so the
+ * metadata entries and the incremental metadata used to base later backups
+ * on are taken from its TOC, while the data entries are gathered from every
+ * backup in its restore plan.  Data files are named by table OID, so two
+ * tables with the same OID cannot be consolidated into one backup.
+ */
+func newConsolidatedTOC(backupTOC *toc.TOC, planDataEntries []restorePlanDataEntries) *toc.TOC {
+	consolidatedTOC := &toc.TOC{
+		GlobalEntries:       backupTOC.GlobalEntries,
+		PredataEntries:      backupTOC.PredataEntries,
+		PostdataEntries:     backupTOC.PostdataEntries,
+		StatisticsEntries:   backupTOC.StatisticsEntries,
+		DataEntries:         make([]toc.MasterDataEntry, 0),
+		IncrementalMetadata: backupTOC.IncrementalMetadata,
+	}
+	tableForOid := make(map[uint32]string)
+	for _, entries := range planDataEntries {
+		for _, entry := range entries.dataEntries {
+			tableFQN := utils.MakeFQN(entry.Schema, entry.Name)
+			if otherTableFQN, ok := tableForOid[entry.Oid]; ok {
+				gplog.Fatal(errors.Errorf("Tables %s and %s both have OID %d, so their data files cannot be consolidated", otherTableFQN, tableFQN, entry.Oid), "")
+			}
+			tableForOid[entry.Oid] = tableFQN
+			consolidatedTOC.DataEntries = append(consolidatedTOC.DataEntries, entry)
+		}
+	}
+	return consolidatedTOC
+}
+
+/*
+ * The new backup has the same settings as the incremental backup, so that
+ * later incremental backups can be based on it, and a restore plan that
+ * restores all of its tables from its own data files.
+ */
+func NewConsolidatedConfig(backupConfig *history.BackupConfig, newTimestamp string) *history.BackupConfig {
+	newConfig := *backupConfig
+	newConfig.Timestamp = newTimestamp
+	newConfig.Incremental = false
+	newConfig.Resumed = false
+	newConfig.EndTime = ""
+	newConfig.ConsolidatedFrom = backupConfig.Timestamp
+	tableFQNs := make([]string, 0)
+	for _, restorePlanEntry := range backupConfig.RestorePlan {
+		tableFQNs = append(tableFQNs, restorePlanEntry.TableFQNs...)
+	}
+	newConfig.RestorePlan = []history.RestorePlanEntry{{Timestamp: newTimestamp, TableFQNs: tableFQNs}}
+	return &newConfig
+}
+
+func createConsolidatedDirectories(fpInfo filepath.FilePathInfo) {
+	remoteOutput := globalCluster.GenerateAndExecuteCommand(
+		fmt.Sprintf("Creating backup directories for timestamp %s", fpInfo.Timestamp),
+		func(contentID int) string {
+			return fmt.Sprintf("mkdir -p %s", fpInfo.GetDirForContent(contentID))
+		}, cluster.ON_SEGMENTS_AND_MASTER)
+	globalCluster.CheckClusterError(remoteOutput, "Unable to create backup directories", func(contentID int) string {
+		return fmt.Sprintf("Unable to create backup directory %s", fpInfo.GetDirForContent(contentID))
+	})
+}
+
+func removeConsolidatedDirectories(fpInfo filepath.FilePathInfo) {
+	gplog.Warn("Removing the directories of incomplete backup %s", fpInfo.Timestamp)
+	remoteOutput := globalCluster.GenerateAndExecuteCommand(
+		fmt.Sprintf("Removing backup directories for timestamp %s", fpInfo.Timestamp),
+		func(contentID int) string {
+			return fmt.Sprintf("rm -rf %s", fpInfo.GetDirForContent(contentID))
+		}, cluster.ON_SEGMENTS_AND_MASTER)
+	if remoteOutput.NumErrors > 0 {
+		gplog.Warn("Unable to remove the directories of incomplete backup %s", fpInfo.Timestamp)
+	}
+}
+
+/*
+ * Each data file is hard-linked into the new backup directory, so that no
+ * data is copied, or copied if the file cannot be linked, as when the backup
+ * directories are on different file systems.
+ */
+func linkDataFiles(backupConfig *history.BackupConfig, entries restorePlanDataEntries, newFPInfo filepath.FilePathInfo) {
+	if len(entries.dataEntries) == 0 {
+		return
+	}
+	gplog.Verbose("Linking the data files of %d table(s) from backup %s", len(entries.dataEntries), entries.timestamp)
+	fpInfo := filepath.NewFilePathInfo(globalCluster, backupConfig.BackupDir, entries.timestamp, segPrefix)
+	oids := make([]string, 0, len(entries.dataEntries))
+	for _, entry := range entries.dataEntries {
+		oids = append(oids, fmt.Sprintf("%d", entry.Oid))
+	}
+	extension := utils.GetPipeThroughProgram().Extension
+	remoteOutput := globalCluster.GenerateAndExecuteCommand(
+		fmt.Sprintf("Linking data files from backup %s", entries.timestamp),
+		func(contentID int) string {
+			return GetLinkDataFilesCommand(fpInfo, newFPInfo, contentID, oids, extension)
+		}, cluster.ON_SEGMENTS)
+	globalCluster.CheckClusterError(remoteOutput, fmt.Sprintf("Unable to link data files from backup %s", entries.timestamp), func(contentID int) string {
+		return fmt.Sprintf("Unable to link data files from %s to %s", fpInfo.GetDirForContent(contentID), newFPInfo.GetDirForContent(contentID))
+	})
+}
+
+// Data files are named as in FilePathInfo.GetTableBackupFilePath
+func GetLinkDataFilesCommand(fpInfo filepath.FilePathInfo, newFPInfo filepath.FilePathInfo, contentID int, oids []string, extension string) string {
+	source := fmt.Sprintf("%s/gpbackup_%d_%s_${oid}%s", fpInfo.GetDirForContent(contentID), contentID, fpInfo.Timestamp, extension)
+	destination := fmt.Sprintf("%s/gpbackup_%d_%s_${oid}%s", newFPInfo.GetDirForContent(contentID), contentID, newFPInfo.Timestamp, extension)
+	return fmt.Sprintf(`set -e; for oid in %s; do ln "%s" "%s" 2>/dev/null || cp -p "%s" "%s"; done`,
+		strings.Join(oids, " "), source, destination, source, destination)
+}
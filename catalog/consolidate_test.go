@@ -0,0 +1,113 @@
+package catalog_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/catalog"
+	gpfilepath "github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/testutils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("catalog/consolidate tests", func() {
+	Describe("NewConsolidatedConfig", func() {
+		It("creates a full backup config that restores every table in the restore plan from the new backup", func() {
+			backupConfig := history.BackupConfig{
+				Timestamp:   "20190103010101",
+				EndTime:     "20190103010202",
+				Compressed:  true,
+				Incremental: true,
+				Resumed:     true,
+				RestorePlan: []history.RestorePlanEntry{
+					{Timestamp: "20190101010101", TableFQNs: []string{"public.foo", "public.bar"}},
+					{Timestamp: "20190103010101", TableFQNs: []string{"public.baz"}},
+				},
+			}
+
+			newConfig := catalog.NewConsolidatedConfig(&backupConfig, "20190104010101")
+
+			Expect(newConfig.Timestamp).To(Equal("20190104010101"))
+			Expect(newConfig.EndTime).To(Equal(""))
+			Expect(newConfig.Compressed).To(BeTrue())
+			Expect(newConfig.Incremental).To(BeFalse())
+			Expect(newConfig.Resumed).To(BeFalse())
+			Expect(newConfig.ConsolidatedFrom).To(Equal("20190103010101"))
+			Expect(newConfig.RestorePlan).To(Equal([]history.RestorePlanEntry{
+				{Timestamp: "20190104010101", TableFQNs: []string{"public.foo", "public.bar", "public.baz"}},
+			}))
+			Expect(backupConfig.Timestamp).To(Equal("20190103010101"))
+			Expect(backupConfig.RestorePlan).To(HaveLen(2))
+		})
+	})
+	Describe("GetLinkDataFilesCommand", func() {
+		It("links or copies the data file of each table into the new backup directory", func() {
+			testCluster := testutils.SetDefaultSegmentConfiguration()
+			fpInfo := gpfilepath.NewFilePathInfo(testCluster, "", "20190101010101", "gpseg")
+			newFPInfo := gpfilepath.NewFilePathInfo(testCluster, "", "20190104010101", "gpseg")
+
+			command := catalog.GetLinkDataFilesCommand(fpInfo, newFPInfo, 0, []string{"1234", "2345"}, ".gz")
+
+			source := "gpseg0/backups/20190101/20190101010101/gpbackup_0_20190101010101_${oid}.gz"
+			destination := "gpseg0/backups/20190104/20190104010101/gpbackup_0_20190104010101_${oid}.gz"
+			Expect(command).To(Equal(`set -e; for oid in 1234 2345; do ln "` + source + `" "` + destination + `" 2>/dev/null || cp -p "` + source + `" "` + destination + `"; done`))
+		})
+	})
+	Describe("ConsolidateBackup", func() {
+		var (
+			backupHistory   *history.History
+			historyFilePath string
+			tempDir         string
+		)
+
+		BeforeEach(func() {
+			tempDir, _ = ioutil.TempDir("", "catalog")
+			historyFilePath = filepath.Join(tempDir, "gpbackup_history.yaml")
+			fullPlan := []history.RestorePlanEntry{{Timestamp: "20190101010101"}}
+			incrementalPlan := []history.RestorePlanEntry{{Timestamp: "20190101010101"}, {Timestamp: "20190102010101"}}
+			backupHistory = &history.History{BackupConfigs: []history.BackupConfig{
+				{Timestamp: "20190106010101", Incremental: true, RestorePlan: []history.RestorePlanEntry{{Timestamp: "20190105010101"}, {Timestamp: "20190106010101"}}},
+				{Timestamp: "20190105010101", DateDeleted: "20190107000000", RestorePlan: []history.RestorePlanEntry{{Timestamp: "20190105010101"}}},
+				{Timestamp: "20190104010101", Incremental: true, SingleDataFile: true, RestorePlan: incrementalPlan},
+				{Timestamp: "20190103010101", Incremental: true, Plugin: "gpbackup_s3_plugin", RestorePlan: incrementalPlan},
+				{Timestamp: "20190102010101", Incremental: true, RestorePlan: incrementalPlan},
+				{Timestamp: "20190101010101", RestorePlan: fullPlan},
+			}}
+			catalog.SetCluster(testutils.SetDefaultSegmentConfiguration())
+			catalog.SetSegPrefix("gpseg")
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(tempDir)
+		})
+
+		It("fails if the backup is not in the history", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20000101010101 was not found in the backup history file")
+			catalog.ConsolidateBackup(backupHistory, historyFilePath, "20000101010101")
+		})
+		It("fails if the backup was deleted", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20190105010101 was deleted on 20190107000000")
+			catalog.ConsolidateBackup(backupHistory, historyFilePath, "20190105010101")
+		})
+		It("fails if the backup is not incremental", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20190101010101 is not an incremental backup")
+			catalog.ConsolidateBackup(backupHistory, historyFilePath, "20190101010101")
+		})
+		It("fails if the backup was taken with a plugin", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20190103010101 was taken with plugin gpbackup_s3_plugin, so its files are not available to consolidate")
+			catalog.ConsolidateBackup(backupHistory, historyFilePath, "20190103010101")
+		})
+		It("fails if the backup was taken with a single data file", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20190104010101 was taken with a single data file per segment, so its tables cannot be consolidated")
+			catalog.ConsolidateBackup(backupHistory, historyFilePath, "20190104010101")
+		})
+		It("fails if a backup in the restore plan was deleted", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20190105010101 in the restore plan of backup 20190106010101 was deleted on 20190107000000")
+			catalog.ConsolidateBackup(backupHistory, historyFilePath, "20190106010101")
+		})
+	})
+})
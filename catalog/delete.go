@@ -0,0 +1,78 @@
+package catalog
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+/*
+ * This file contains functions for deleting backups from disk or from a
+ * plugin destination and recording the deletion in the backup history file.
+ */
+
+// The caller holds the history file lock from reading backupHistory until this returns
+func DeleteBackup(backupHistory *history.History, historyFilePath string, timestamp string) {
+	backupConfig := backupHistory.FindBackupConfig(timestamp)
+	if backupConfig == nil {
+		gplog.Fatal(errors.Errorf("Backup %s was not found in the backup history file", timestamp), "")
+	}
+	if backupConfig.DateDeleted != "" {
+		gplog.Fatal(errors.Errorf("Backup %s was already deleted on %s", timestamp, backupConfig.DateDeleted), "")
+	}
+	if dependent := GetDependentBackup(backupHistory, timestamp); dependent != nil {
+		gplog.Fatal(errors.Errorf("Backup %s cannot be deleted because incremental backup %s depends on it", timestamp, dependent.Timestamp), "")
+	}
+
+	gplog.Info("Deleting backup %s of database %s", timestamp, backupConfig.DatabaseName)
+	if backupConfig.Plugin != "" {
+		deleteBackupFromPlugin(backupConfig)
+	}
+	deleteBackupDirectories(backupConfig)
+
+	backupConfig.DateDeleted = history.CurrentTimestamp()
+	err := backupHistory.WriteToFileAndMakeReadOnly(historyFilePath)
+	gplog.FatalOnError(err)
+	gplog.Info("Backup %s deleted", timestamp)
+}
+
+func deleteBackupFromPlugin(backupConfig *history.BackupConfig) {
+	pluginConfigFile := MustGetFlagString(options.PLUGIN_CONFIG)
+	if pluginConfigFile == "" {
+		gplog.Fatal(errors.Errorf("Backup %s was taken with plugin %s; --%s must be specified to delete it",
+			backupConfig.Timestamp, backupConfig.Plugin, options.PLUGIN_CONFIG), "")
+	}
+	pluginConfig, err := utils.ReadPluginConfig(pluginConfigFile)
+	gplog.FatalOnError(err)
+	pluginConfig.SetBackupPluginVersion(backupConfig.Timestamp, backupConfig.PluginVersion)
+	pluginConfig.CopyPluginConfigToAllHosts(globalCluster)
+	defer pluginConfig.DeletePluginConfigWhenEncrypting(globalCluster)
+
+	err = pluginConfig.DeleteBackup(globalCluster, backupConfig.Timestamp)
+	gplog.FatalOnError(err)
+}
+
+/*
+ * Backups taken with a plugin still write their report and config files to
+ * the local backup directories, so those are removed in all cases.  The date
+ * directory above the timestamp directory is removed only if it is empty.
+ */
+func deleteBackupDirectories(backupConfig *history.BackupConfig) {
+	fpInfo := filepath.NewFilePathInfo(globalCluster, backupConfig.BackupDir, backupConfig.Timestamp, segPrefix)
+	remoteOutput := globalCluster.GenerateAndExecuteCommand(
+		fmt.Sprintf("Removing backup directories for timestamp %s", backupConfig.Timestamp),
+		func(contentID int) string {
+			backupDir := fpInfo.GetDirForContent(contentID)
+			return fmt.Sprintf("rm -rf %s && (rmdir %s 2>/dev/null || true)", backupDir, path.Dir(backupDir))
+		}, cluster.ON_SEGMENTS_AND_MASTER)
+	globalCluster.CheckClusterError(remoteOutput, "Unable to remove backup directories", func(contentID int) string {
+		return fmt.Sprintf("Unable to remove backup directory %s", fpInfo.GetDirForContent(contentID))
+	})
+}
@@ -0,0 +1,83 @@
+package catalog_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/catalog"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/testutils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("catalog/delete tests", func() {
+	var (
+		backupHistory   *history.History
+		executor        testutils.TestExecutorMultiple
+		historyFilePath string
+		tempDir         string
+		testCluster     *cluster.Cluster
+	)
+
+	BeforeEach(func() {
+		tempDir, _ = ioutil.TempDir("", "catalog")
+		historyFilePath = filepath.Join(tempDir, "gpbackup_history.yaml")
+		backupHistory = &history.History{BackupConfigs: []history.BackupConfig{
+			{Timestamp: "20190102010101", DatabaseName: "testdb", BackupDir: "/backups"},
+			{Timestamp: "20190101010101", DatabaseName: "testdb"},
+			{Timestamp: "20181231010101", DatabaseName: "testdb", Plugin: "gpbackup_s3_plugin"},
+			{Timestamp: "20181230010101", DatabaseName: "testdb", DateDeleted: "20190101000000"},
+		}}
+		executor = testutils.TestExecutorMultiple{
+			ClusterOutputs: []*cluster.RemoteOutput{{}},
+		}
+		testCluster = testutils.SetDefaultSegmentConfiguration()
+		testCluster.Executor = &executor
+		catalog.SetCluster(testCluster)
+		catalog.SetSegPrefix("gpseg")
+	})
+	AfterEach(func() {
+		_ = os.RemoveAll(tempDir)
+	})
+
+	Describe("DeleteBackup", func() {
+		It("removes the backup directories on all hosts and records the deletion date", func() {
+			catalog.DeleteBackup(backupHistory, historyFilePath, "20190101010101")
+
+			Expect(executor.NumRemoteExecutions).To(Equal(1))
+			cc := executor.ClusterCommands[0]
+			Expect(cc[-1][2]).To(Equal("rm -rf gpseg-1/backups/20190101/20190101010101 && (rmdir gpseg-1/backups/20190101 2>/dev/null || true)"))
+			Expect(cc[0][4]).To(Equal("rm -rf gpseg0/backups/20190101/20190101010101 && (rmdir gpseg0/backups/20190101 2>/dev/null || true)"))
+			Expect(cc[1][4]).To(Equal("rm -rf gpseg1/backups/20190101/20190101010101 && (rmdir gpseg1/backups/20190101 2>/dev/null || true)"))
+
+			resultHistory, err := history.NewHistory(historyFilePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resultHistory.FindBackupConfig("20190101010101").DateDeleted).ToNot(Equal(""))
+			Expect(resultHistory.FindBackupConfig("20190102010101").DateDeleted).To(Equal(""))
+		})
+		It("removes backup directories under a user-specified backup directory", func() {
+			catalog.DeleteBackup(backupHistory, historyFilePath, "20190102010101")
+
+			cc := executor.ClusterCommands[0]
+			Expect(cc[0][4]).To(Equal("rm -rf /backups/gpseg0/backups/20190102/20190102010101 && (rmdir /backups/gpseg0/backups/20190102 2>/dev/null || true)"))
+		})
+		It("fails if the backup was taken with a plugin and no plugin config is given", func() {
+			defer testhelper.ShouldPanicWithMessage(fmt.Sprintf("Backup 20181231010101 was taken with plugin gpbackup_s3_plugin; --plugin-config must be specified to delete it"))
+			catalog.DeleteBackup(backupHistory, historyFilePath, "20181231010101")
+		})
+		It("fails if the backup was already deleted", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20181230010101 was already deleted on 20190101000000")
+			catalog.DeleteBackup(backupHistory, historyFilePath, "20181230010101")
+		})
+		It("fails if the backup is not in the history", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20000101010101 was not found in the backup history file")
+			catalog.DeleteBackup(backupHistory, historyFilePath, "20000101010101")
+		})
+	})
+})
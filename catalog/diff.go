@@ -0,0 +1,270 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+/*
+ * This file contains functions for comparing the metadata and table row
+ * counts of two backups.
+ */
+
+const (
+	CHANGE_ADDED   = "added"
+	CHANGE_REMOVED = "removed"
+	CHANGE_CHANGED = "changed"
+)
+
+var diffSections = []string{"global", "predata", "postdata"}
+
+type MetadataChange struct {
+	Change          string `json:"change"`
+	Section         string `json:"section"`
+	ObjectType      string `json:"object_type"`
+	Schema          string `json:"schema,omitempty"`
+	Name            string `json:"name"`
+	ReferenceObject string `json:"reference_object,omitempty"`
+}
+
+type RowCountChange struct {
+	Change  string `json:"change"`
+	Table   string `json:"table"`
+	OldRows int64  `json:"old_rows"`
+	NewRows int64  `json:"new_rows"`
+}
+
+type BackupDiff struct {
+	OldTimestamp    string           `json:"old_timestamp"`
+	NewTimestamp    string           `json:"new_timestamp"`
+	MetadataChanges []MetadataChange `json:"metadata_changes"`
+	RowCountChanges []RowCountChange `json:"row_count_changes"`
+}
+
+// The metadata and data of a backup, as read from its master backup files
+type backupContents struct {
+	toc         *toc.TOC
+	metadata    []byte
+	dataEntries []toc.MasterDataEntry
+}
+
+type metadataObject struct {
+	objectType      string
+	schema          string
+	name            string
+	referenceObject string
+}
+
+func DiffBackups(backupHistory *history.History, oldTimestamp string, newTimestamp string, format string) {
+	if format != "text" && format != "json" {
+		gplog.Fatal(errors.Errorf("Invalid format %s.  Valid formats are: text, json", format), "")
+	}
+	oldConfig := findDiffBackupConfig(backupHistory, oldTimestamp)
+	newConfig := findDiffBackupConfig(backupHistory, newTimestamp)
+	if oldConfig.Encrypted || newConfig.Encrypted {
+		initializeEncryption(oldConfig, newConfig)
+	}
+
+	oldContents := readBackupContents(oldConfig)
+	newContents := readBackupContents(newConfig)
+	diff := BackupDiff{
+		OldTimestamp:    oldTimestamp,
+		NewTimestamp:    newTimestamp,
+		MetadataChanges: GetMetadataChanges(oldContents.toc, oldContents.metadata, newContents.toc, newContents.metadata),
+		RowCountChanges: GetRowCountChanges(oldContents.dataEntries, newContents.dataEntries),
+	}
+	PrintBackupDiff(diff, format)
+}
+
+/*
+ * The backup files of a plugin backup are only at the plugin destination, so
+ * only backups stored on the cluster can be compared.
+ */
+func findDiffBackupConfig(backupHistory *history.History, timestamp string) *history.BackupConfig {
+	backupConfig := backupHistory.FindBackupConfig(timestamp)
+	if backupConfig == nil {
+		gplog.Fatal(errors.Errorf("Backup %s was not found in the backup history file", timestamp), "")
+	}
+	if backupConfig.DateDeleted != "" {
+		gplog.Fatal(errors.Errorf("Backup %s was deleted on %s", timestamp, backupConfig.DateDeleted), "")
+	}
+	if backupConfig.Plugin != "" {
+		gplog.Fatal(errors.Errorf("Backup %s was taken with plugin %s, so its files are not available to compare", timestamp, backupConfig.Plugin), "")
+	}
+	return backupConfig
+}
+
+func readBackupContents(backupConfig *history.BackupConfig) backupContents {
+	fpInfo := filepath.NewFilePathInfo(globalCluster, backupConfig.BackupDir, backupConfig.Timestamp, segPrefix)
+	contents := backupContents{toc: toc.NewTOC(fpInfo.GetTOCFilePath())}
+	var err error
+	contents.metadata, err = utils.ReadBackupFile(fpInfo.GetMetadataFilePath())
+	gplog.FatalOnError(err)
+
+	for _, planDataEntries := range readRestorePlanDataEntries(backupConfig, contents.toc) {
+		contents.dataEntries = append(contents.dataEntries, planDataEntries.dataEntries...)
+	}
+	return contents
+}
+
+/*
+ * Objects are identified by their section, type, schema, and name, and by the
+ * object they belong to, such as the table of a trigger, whose name need only
+ * be unique for that object.  An object may have several entries, such as for
+ * its definition and its owner, so its statements are compared together.
+ */
+func GetMetadataChanges(oldTOC *toc.TOC, oldMetadata []byte, newTOC *toc.TOC, newMetadata []byte) []MetadataChange {
+	changes := make([]MetadataChange, 0)
+	for _, section := range diffSections {
+		oldStatements := getObjectStatements(getSectionEntries(oldTOC, section), oldMetadata)
+		newStatements := getObjectStatements(getSectionEntries(newTOC, section), newMetadata)
+		for object, newStatement := range newStatements {
+			oldStatement, ok := oldStatements[object]
+			if !ok {
+				changes = append(changes, newMetadataChange(CHANGE_ADDED, section, object))
+			} else if oldStatement != newStatement {
+				changes = append(changes, newMetadataChange(CHANGE_CHANGED, section, object))
+			}
+		}
+		for object := range oldStatements {
+			if _, ok := newStatements[object]; !ok {
+				changes = append(changes, newMetadataChange(CHANGE_REMOVED, section, object))
+			}
+		}
+	}
+	sectionOrder := make(map[string]int, len(diffSections))
+	for i, section := range diffSections {
+		sectionOrder[section] = i
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Section != changes[j].Section {
+			return sectionOrder[changes[i].Section] < sectionOrder[changes[j].Section]
+		}
+		if changes[i].Schema != changes[j].Schema {
+			return changes[i].Schema < changes[j].Schema
+		}
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		if changes[i].ObjectType != changes[j].ObjectType {
+			return changes[i].ObjectType < changes[j].ObjectType
+		}
+		return changes[i].ReferenceObject < changes[j].ReferenceObject
+	})
+	return changes
+}
+
+func newMetadataChange(change string, section string, object metadataObject) MetadataChange {
+	return MetadataChange{Change: change, Section: section, ObjectType: object.objectType, Schema: object.schema, Name: object.name, ReferenceObject: object.referenceObject}
+}
+
+func getSectionEntries(backupTOC *toc.TOC, section string) []toc.MetadataEntry {
+	switch section {
+	case "global":
+		return backupTOC.GlobalEntries
+	case "predata":
+		return backupTOC.PredataEntries
+	default:
+		return backupTOC.PostdataEntries
+	}
+}
+
+func getObjectStatements(entries []toc.MetadataEntry, metadata []byte) map[metadataObject]string {
+	statements := make(map[metadataObject]string, len(entries))
+	for _, entry := range entries {
+		object := metadataObject{objectType: entry.ObjectType, schema: entry.Schema, name: entry.Name, referenceObject: entry.ReferenceObject}
+		statement := ""
+		if entry.StartByte <= entry.EndByte && entry.EndByte <= uint64(len(metadata)) {
+			statement = string(metadata[entry.StartByte:entry.EndByte])
+		}
+		statements[object] += statement
+	}
+	return statements
+}
+
+func GetRowCountChanges(oldEntries []toc.MasterDataEntry, newEntries []toc.MasterDataEntry) []RowCountChange {
+	oldRows := make(map[string]int64, len(oldEntries))
+	for _, entry := range oldEntries {
+		oldRows[utils.MakeFQN(entry.Schema, entry.Name)] = entry.RowsCopied
+	}
+	newRows := make(map[string]int64, len(newEntries))
+	for _, entry := range newEntries {
+		newRows[utils.MakeFQN(entry.Schema, entry.Name)] = entry.RowsCopied
+	}
+
+	changes := make([]RowCountChange, 0)
+	for table, rows := range newRows {
+		if previousRows, ok := oldRows[table]; !ok {
+			changes = append(changes, RowCountChange{Change: CHANGE_ADDED, Table: table, NewRows: rows})
+		} else if previousRows != rows {
+			changes = append(changes, RowCountChange{Change: CHANGE_CHANGED, Table: table, OldRows: previousRows, NewRows: rows})
+		}
+	}
+	for table, rows := range oldRows {
+		if _, ok := newRows[table]; !ok {
+			changes = append(changes, RowCountChange{Change: CHANGE_REMOVED, Table: table, OldRows: rows})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Table < changes[j].Table
+	})
+	return changes
+}
+
+func PrintBackupDiff(diff BackupDiff, format string) {
+	if format == "json" {
+		contents, err := json.MarshalIndent(diff, "", "  ")
+		gplog.FatalOnError(err)
+		fmt.Fprintf(operating.System.Stdout, "%s\n", contents)
+		return
+	}
+
+	fmt.Fprintf(operating.System.Stdout, "Metadata changes from backup %s to backup %s:\n", diff.OldTimestamp, diff.NewTimestamp)
+	if len(diff.MetadataChanges) == 0 {
+		fmt.Fprintln(operating.System.Stdout, "  none")
+	} else {
+		writer := tabwriter.NewWriter(operating.System.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "CHANGE\tSECTION\tTYPE\tOBJECT")
+		for _, change := range diff.MetadataChanges {
+			objectName := change.Name
+			if change.Schema != "" {
+				objectName = utils.MakeFQN(change.Schema, change.Name)
+			}
+			if change.ReferenceObject != "" {
+				objectName = fmt.Sprintf("%s ON %s", objectName, change.ReferenceObject)
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", change.Change, change.Section, change.ObjectType, objectName)
+		}
+		_ = writer.Flush()
+	}
+
+	fmt.Fprintf(operating.System.Stdout, "\nRow count changes from backup %s to backup %s:\n", diff.OldTimestamp, diff.NewTimestamp)
+	if len(diff.RowCountChanges) == 0 {
+		fmt.Fprintln(operating.System.Stdout, "  none")
+	} else {
+		writer := tabwriter.NewWriter(operating.System.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "CHANGE\tTABLE\tOLD ROWS\tNEW ROWS")
+		for _, change := range diff.RowCountChanges {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", change.Change, change.Table,
+				rowCountOrDash(change.OldRows, change.Change == CHANGE_ADDED), rowCountOrDash(change.NewRows, change.Change == CHANGE_REMOVED))
+		}
+		_ = writer.Flush()
+	}
+}
+
+func rowCountOrDash(rows int64, isMissing bool) string {
+	if isMissing {
+		return "-"
+	}
+	return fmt.Sprintf("%d", rows)
+}
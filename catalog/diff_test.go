@@ -0,0 +1,149 @@
+package catalog_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/catalog"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("catalog/diff tests", func() {
+	Describe("GetMetadataChanges", func() {
+		It("reports added, removed, and changed objects in each section", func() {
+			oldMetadata := "CREATE ROLE a;CREATE TABLE public.foo (i int);CREATE TABLE public.bar (i int);ALTER TABLE public.bar OWNER TO a;"
+			oldTOC := &toc.TOC{
+				GlobalEntries: []toc.MetadataEntry{{Name: "a", ObjectType: "ROLE", StartByte: 0, EndByte: 14}},
+				PredataEntries: []toc.MetadataEntry{
+					{Schema: "public", Name: "foo", ObjectType: "TABLE", StartByte: 14, EndByte: 46},
+					{Schema: "public", Name: "bar", ObjectType: "TABLE", StartByte: 46, EndByte: 78},
+					{Schema: "public", Name: "bar", ObjectType: "TABLE", StartByte: 78, EndByte: 112},
+				},
+			}
+			newMetadata := "CREATE ROLE a;CREATE TABLE public.foo (i int);CREATE TABLE public.bar (i int);ALTER TABLE public.bar OWNER TO b;CREATE INDEX foo_idx ON public.foo (i);"
+			newTOC := &toc.TOC{
+				GlobalEntries: []toc.MetadataEntry{{Name: "a", ObjectType: "ROLE", StartByte: 0, EndByte: 14}},
+				PredataEntries: []toc.MetadataEntry{
+					{Schema: "public", Name: "bar", ObjectType: "TABLE", StartByte: 46, EndByte: 78},
+					{Schema: "public", Name: "bar", ObjectType: "TABLE", StartByte: 78, EndByte: 112},
+				},
+				PostdataEntries: []toc.MetadataEntry{
+					{Schema: "public", Name: "foo_idx", ObjectType: "INDEX", StartByte: 112, EndByte: 151},
+				},
+			}
+
+			changes := catalog.GetMetadataChanges(oldTOC, []byte(oldMetadata), newTOC, []byte(newMetadata))
+
+			Expect(changes).To(Equal([]catalog.MetadataChange{
+				{Change: "changed", Section: "predata", ObjectType: "TABLE", Schema: "public", Name: "bar"},
+				{Change: "removed", Section: "predata", ObjectType: "TABLE", Schema: "public", Name: "foo"},
+				{Change: "added", Section: "postdata", ObjectType: "INDEX", Schema: "public", Name: "foo_idx"},
+			}))
+		})
+		It("distinguishes objects with the same name that belong to different objects", func() {
+			oldMetadata := "CREATE TRIGGER trg ON public.foo;CREATE TRIGGER trg ON public.bar;"
+			oldTOC := &toc.TOC{PostdataEntries: []toc.MetadataEntry{
+				{Schema: "public", Name: "trg", ObjectType: "TRIGGER", ReferenceObject: "public.foo", StartByte: 0, EndByte: 33},
+				{Schema: "public", Name: "trg", ObjectType: "TRIGGER", ReferenceObject: "public.bar", StartByte: 33, EndByte: 66},
+			}}
+			newMetadata := "CREATE TRIGGER trg ON public.foo;"
+			newTOC := &toc.TOC{PostdataEntries: []toc.MetadataEntry{
+				{Schema: "public", Name: "trg", ObjectType: "TRIGGER", ReferenceObject: "public.foo", StartByte: 0, EndByte: 33},
+			}}
+
+			changes := catalog.GetMetadataChanges(oldTOC, []byte(oldMetadata), newTOC, []byte(newMetadata))
+
+			Expect(changes).To(Equal([]catalog.MetadataChange{
+				{Change: "removed", Section: "postdata", ObjectType: "TRIGGER", Schema: "public", Name: "trg", ReferenceObject: "public.bar"},
+			}))
+		})
+		It("reports no changes for identical backups", func() {
+			metadata := "CREATE TABLE public.foo (i int);"
+			backupTOC := &toc.TOC{PredataEntries: []toc.MetadataEntry{{Schema: "public", Name: "foo", ObjectType: "TABLE", StartByte: 0, EndByte: 32}}}
+
+			Expect(catalog.GetMetadataChanges(backupTOC, []byte(metadata), backupTOC, []byte(metadata))).To(BeEmpty())
+		})
+	})
+	Describe("GetRowCountChanges", func() {
+		It("reports tables whose row counts changed or that were added or removed", func() {
+			oldEntries := []toc.MasterDataEntry{
+				{Schema: "public", Name: "foo", RowsCopied: 10},
+				{Schema: "public", Name: "bar", RowsCopied: 5},
+				{Schema: "public", Name: "same", RowsCopied: 1},
+			}
+			newEntries := []toc.MasterDataEntry{
+				{Schema: "public", Name: "foo", RowsCopied: 12},
+				{Schema: "public", Name: "baz", RowsCopied: 3},
+				{Schema: "public", Name: "same", RowsCopied: 1},
+			}
+
+			Expect(catalog.GetRowCountChanges(oldEntries, newEntries)).To(Equal([]catalog.RowCountChange{
+				{Change: "removed", Table: "public.bar", OldRows: 5},
+				{Change: "added", Table: "public.baz", NewRows: 3},
+				{Change: "changed", Table: "public.foo", OldRows: 10, NewRows: 12},
+			}))
+		})
+	})
+	Describe("PrintBackupDiff", func() {
+		diff := catalog.BackupDiff{
+			OldTimestamp: "20190101010101",
+			NewTimestamp: "20190102010101",
+			MetadataChanges: []catalog.MetadataChange{
+				{Change: "added", Section: "predata", ObjectType: "FUNCTION", Schema: "public", Name: "add(integer)"},
+				{Change: "removed", Section: "global", ObjectType: "ROLE", Name: "olduser"},
+				{Change: "changed", Section: "postdata", ObjectType: "TRIGGER", Schema: "public", Name: "trg", ReferenceObject: "public.foo"},
+			},
+			RowCountChanges: []catalog.RowCountChange{
+				{Change: "changed", Table: "public.foo", OldRows: 10, NewRows: 12},
+				{Change: "added", Table: "public.baz", NewRows: 3},
+			},
+		}
+		It("prints the changes as text", func() {
+			catalog.PrintBackupDiff(diff, "text")
+
+			Expect(buffer).To(Say("Metadata changes from backup 20190101010101 to backup 20190102010101:"))
+			Expect(buffer).To(Say(`CHANGE\s+SECTION\s+TYPE\s+OBJECT`))
+			Expect(buffer).To(Say(`added\s+predata\s+FUNCTION\s+public\.add\(integer\)`))
+			Expect(buffer).To(Say(`removed\s+global\s+ROLE\s+olduser`))
+			Expect(buffer).To(Say(`changed\s+postdata\s+TRIGGER\s+public\.trg ON public\.foo`))
+			Expect(buffer).To(Say("Row count changes from backup 20190101010101 to backup 20190102010101:"))
+			Expect(buffer).To(Say(`CHANGE\s+TABLE\s+OLD ROWS\s+NEW ROWS`))
+			Expect(buffer).To(Say(`changed\s+public\.foo\s+10\s+12`))
+			Expect(buffer).To(Say(`added\s+public\.baz\s+-\s+3`))
+		})
+		It("prints none when there are no changes", func() {
+			catalog.PrintBackupDiff(catalog.BackupDiff{OldTimestamp: "20190101010101", NewTimestamp: "20190102010101"}, "text")
+
+			Expect(buffer).To(Say(`Metadata changes from backup 20190101010101 to backup 20190102010101:\n  none`))
+			Expect(buffer).To(Say(`Row count changes from backup 20190101010101 to backup 20190102010101:\n  none`))
+		})
+		It("prints the changes as JSON", func() {
+			catalog.PrintBackupDiff(diff, "json")
+
+			Expect(buffer).To(Say(`"old_timestamp": "20190101010101"`))
+			Expect(buffer).To(Say(`"change": "added",\s+"section": "predata",\s+"object_type": "FUNCTION",\s+"schema": "public",\s+"name": "add\(integer\)"`))
+			Expect(buffer).To(Say(`"change": "changed",\s+"table": "public.foo",\s+"old_rows": 10,\s+"new_rows": 12`))
+		})
+	})
+	Describe("DiffBackups", func() {
+		backupHistory := &history.History{BackupConfigs: []history.BackupConfig{
+			{Timestamp: "20190102010101", DatabaseName: "testdb", Plugin: "gpbackup_s3_plugin"},
+			{Timestamp: "20190101010101", DatabaseName: "testdb"},
+		}}
+		It("fails if the format is invalid", func() {
+			defer testhelper.ShouldPanicWithMessage("Invalid format yaml.  Valid formats are: text, json")
+			catalog.DiffBackups(backupHistory, "20190101010101", "20190102010101", "yaml")
+		})
+		It("fails if a timestamp is not in the history", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20000101010101 was not found in the backup history file")
+			catalog.DiffBackups(backupHistory, "20000101010101", "20190101010101", "text")
+		})
+		It("fails if a backup was taken with a plugin", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20190102010101 was taken with plugin gpbackup_s3_plugin, so its files are not available to compare")
+			catalog.DiffBackups(backupHistory, "20190101010101", "20190102010101", "text")
+		})
+	})
+})
@@ -0,0 +1,67 @@
+package catalog
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/spf13/pflag"
+)
+
+/*
+ * This file contains global variables and setter functions for those variables
+ * used in testing.
+ */
+
+/*
+ * Non-flag variables
+ */
+var (
+	connectionPool *dbconn.DBConn
+	globalCluster  *cluster.Cluster
+	segPrefix      string
+	version        string
+)
+
+/*
+ * Command-line flags
+ */
+var cmdFlags *pflag.FlagSet
+
+/*
+ * Setter functions
+ */
+
+func SetCmdFlags(flagSet *pflag.FlagSet) {
+	cmdFlags = flagSet
+	options.SetCatalogFlagDefaults(cmdFlags)
+}
+
+func SetConnection(conn *dbconn.DBConn) {
+	connectionPool = conn
+}
+
+func SetCluster(cluster *cluster.Cluster) {
+	globalCluster = cluster
+}
+
+func SetSegPrefix(prefix string) {
+	segPrefix = prefix
+}
+
+func SetVersion(v string) {
+	version = v
+}
+
+// Util functions to enable ease of access to global flag values
+
+func MustGetFlagString(flagName string) string {
+	return options.MustGetFlagString(cmdFlags, flagName)
+}
+
+func MustGetFlagInt(flagName string) int {
+	return options.MustGetFlagInt(cmdFlags, flagName)
+}
+
+func MustGetFlagBool(flagName string) bool {
+	return options.MustGetFlagBool(cmdFlags, flagName)
+}
@@ -0,0 +1,69 @@
+package catalog
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+ * This file contains functions for displaying the contents of the backup
+ * history file.
+ */
+
+func GetBackupType(backupConfig *history.BackupConfig) string {
+	switch {
+	case backupConfig.MetadataOnly:
+		return "metadata-only"
+	case backupConfig.DataOnly:
+		return "data-only"
+	case backupConfig.Incremental:
+		return "incremental"
+	default:
+		return "full"
+	}
+}
+
+func ListBackups(backupHistory *history.History, dbname string, showDeleted bool) {
+	writer := tabwriter.NewWriter(operating.System.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIMESTAMP\tDATABASE\tTYPE\tPLUGIN\tDATE DELETED")
+	for i := range backupHistory.BackupConfigs {
+		backupConfig := &backupHistory.BackupConfigs[i]
+		if dbname != "" && backupConfig.DatabaseName != dbname {
+			continue
+		}
+		if backupConfig.DateDeleted != "" && !showDeleted {
+			continue
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", backupConfig.Timestamp, backupConfig.DatabaseName,
+			GetBackupType(backupConfig), valueOrDash(backupConfig.Plugin), valueOrDash(backupConfig.DateDeleted))
+	}
+	_ = writer.Flush()
+}
+
+func ShowBackup(backupHistory *history.History, timestamp string) {
+	backupConfig := backupHistory.FindBackupConfig(timestamp)
+	if backupConfig == nil {
+		gplog.Fatal(errors.Errorf("Backup %s was not found in the backup history file", timestamp), "")
+	}
+	configContents, err := yaml.Marshal(backupConfig)
+	gplog.FatalOnError(err)
+	fmt.Fprintf(operating.System.Stdout, "%s\n", configContents)
+
+	fmt.Fprintln(operating.System.Stdout, "Restore plan:")
+	for _, entry := range backupConfig.RestorePlan {
+		fmt.Fprintf(operating.System.Stdout, "  %s: %d table(s)\n", entry.Timestamp, len(entry.TableFQNs))
+	}
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
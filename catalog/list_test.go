@@ -0,0 +1,74 @@
+package catalog_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/catalog"
+	"github.com/greenplum-db/gpbackup/history"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("catalog/list tests", func() {
+	var backupHistory *history.History
+
+	BeforeEach(func() {
+		backupHistory = &history.History{BackupConfigs: []history.BackupConfig{
+			{Timestamp: "20190102010101", DatabaseName: "testdb", Incremental: true, Plugin: "gpbackup_s3_plugin",
+				RestorePlan: []history.RestorePlanEntry{
+					{Timestamp: "20190101010101", TableFQNs: []string{"public.foo", "public.bar"}},
+					{Timestamp: "20190102010101", TableFQNs: []string{"public.baz"}},
+				}},
+			{Timestamp: "20190101010101", DatabaseName: "testdb"},
+			{Timestamp: "20181231010101", DatabaseName: "otherdb", MetadataOnly: true},
+			{Timestamp: "20181230010101", DatabaseName: "testdb", DateDeleted: "20190101000000"},
+		}}
+	})
+
+	Describe("GetBackupType", func() {
+		It("distinguishes metadata-only, data-only, incremental and full backups", func() {
+			Expect(catalog.GetBackupType(&history.BackupConfig{MetadataOnly: true})).To(Equal("metadata-only"))
+			Expect(catalog.GetBackupType(&history.BackupConfig{DataOnly: true})).To(Equal("data-only"))
+			Expect(catalog.GetBackupType(&history.BackupConfig{Incremental: true})).To(Equal("incremental"))
+			Expect(catalog.GetBackupType(&history.BackupConfig{})).To(Equal("full"))
+		})
+	})
+	Describe("ListBackups", func() {
+		It("lists all backups that have not been deleted", func() {
+			catalog.ListBackups(backupHistory, "", false)
+
+			Expect(buffer).To(Say(`TIMESTAMP\s+DATABASE\s+TYPE\s+PLUGIN\s+DATE DELETED`))
+			Expect(buffer).To(Say(`20190102010101\s+testdb\s+incremental\s+gpbackup_s3_plugin\s+-`))
+			Expect(buffer).To(Say(`20190101010101\s+testdb\s+full\s+-\s+-`))
+			Expect(buffer).To(Say(`20181231010101\s+otherdb\s+metadata-only\s+-\s+-`))
+			Expect(string(buffer.Contents())).ToNot(ContainSubstring("20181230010101"))
+		})
+		It("lists only backups of the given database", func() {
+			catalog.ListBackups(backupHistory, "otherdb", false)
+
+			Expect(string(buffer.Contents())).To(ContainSubstring("20181231010101"))
+			Expect(string(buffer.Contents())).ToNot(ContainSubstring("testdb"))
+		})
+		It("includes deleted backups when requested", func() {
+			catalog.ListBackups(backupHistory, "", true)
+
+			Expect(buffer).To(Say(`20181230010101\s+testdb\s+full\s+-\s+20190101000000`))
+		})
+	})
+	Describe("ShowBackup", func() {
+		It("prints the backup config and a summary of its restore plan", func() {
+			catalog.ShowBackup(backupHistory, "20190102010101")
+
+			Expect(buffer).To(Say("databasename: testdb"))
+			Expect(buffer).To(Say("incremental: true"))
+			Expect(buffer).To(Say("Restore plan:"))
+			Expect(buffer).To(Say(`20190101010101: 2 table\(s\)`))
+			Expect(buffer).To(Say(`20190102010101: 1 table\(s\)`))
+		})
+		It("fails if the timestamp is not in the history", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20000101010101 was not found in the backup history file")
+			catalog.ShowBackup(backupHistory, "20000101010101")
+		})
+	})
+})
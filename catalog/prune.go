@@ -0,0 +1,163 @@
+package catalog
+
+import (
+	"sort"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/pkg/errors"
+)
+
+/*
+ * This file contains functions for selecting and deleting backups that have
+ * expired under a retention policy.
+ */
+
+type RetentionPolicy struct {
+	KeepLast int
+	KeepDays int
+	KeepFull int
+}
+
+func (policy RetentionPolicy) IsEmpty() bool {
+	return policy.KeepLast == 0 && policy.KeepDays == 0 && policy.KeepFull == 0
+}
+
+func NewRetentionPolicyFromFlags() RetentionPolicy {
+	policy := RetentionPolicy{
+		KeepLast: MustGetFlagInt(options.KEEP_LAST),
+		KeepDays: MustGetFlagInt(options.KEEP_DAYS),
+		KeepFull: MustGetFlagInt(options.KEEP_FULL),
+	}
+	if policy.KeepLast < 0 || policy.KeepDays < 0 || policy.KeepFull < 0 {
+		gplog.Fatal(errors.Errorf("--%s, --%s, and --%s must not be negative", options.KEEP_LAST, options.KEEP_DAYS, options.KEEP_FULL), "")
+	}
+	if policy.IsEmpty() {
+		gplog.Fatal(errors.Errorf("At least one of --%s, --%s, or --%s must be specified", options.KEEP_LAST, options.KEEP_DAYS, options.KEEP_FULL), "")
+	}
+	return policy
+}
+
+/*
+ * Returns the newest backup that has not been deleted and whose restore plan
+ * still needs the data of the given backup, or nil if there is none.
+ */
+func GetDependentBackup(backupHistory *history.History, timestamp string) *history.BackupConfig {
+	var dependent *history.BackupConfig
+	for i := range backupHistory.BackupConfigs {
+		backupConfig := &backupHistory.BackupConfigs[i]
+		if backupConfig.Timestamp == timestamp || backupConfig.DateDeleted != "" {
+			continue
+		}
+		for _, entry := range backupConfig.RestorePlan {
+			if entry.Timestamp == timestamp && (dependent == nil || backupConfig.Timestamp > dependent.Timestamp) {
+				dependent = backupConfig
+			}
+		}
+	}
+	return dependent
+}
+
+/*
+ * A backup is kept if any rule of the policy keeps it, and every backup that
+ * appears in the restore plan of a kept backup is kept as well, so a retained
+ * incremental backup never loses its base.  Expired timestamps are returned
+ * newest first, so that each incremental backup is deleted before the backups
+ * it depends on.  The returned map records, for each backup that would have
+ * expired but is still needed, the kept backup that depends on it.
+ */
+func SelectExpiredBackups(backupHistory *history.History, dbname string, policy RetentionPolicy, now time.Time) ([]string, map[string]string) {
+	backupsByDatabase := make(map[string][]*history.BackupConfig)
+	for i := range backupHistory.BackupConfigs {
+		backupConfig := &backupHistory.BackupConfigs[i]
+		if backupConfig.DateDeleted != "" || (dbname != "" && backupConfig.DatabaseName != dbname) {
+			continue
+		}
+		backupsByDatabase[backupConfig.DatabaseName] = append(backupsByDatabase[backupConfig.DatabaseName], backupConfig)
+	}
+
+	keep := make(map[string]bool)
+	expired := make(map[string]bool)
+	for _, backups := range backupsByDatabase {
+		sort.Slice(backups, func(i, j int) bool {
+			return backups[i].Timestamp > backups[j].Timestamp
+		})
+		numFull := 0
+		for i, backupConfig := range backups {
+			keepBackup := i < policy.KeepLast
+			if policy.KeepDays > 0 && isWithinDays(backupConfig.Timestamp, policy.KeepDays, now) {
+				keepBackup = true
+			}
+			if GetBackupType(backupConfig) == "full" {
+				if numFull < policy.KeepFull {
+					keepBackup = true
+				}
+				numFull++
+			}
+			if keepBackup {
+				keep[backupConfig.Timestamp] = true
+			} else {
+				expired[backupConfig.Timestamp] = true
+			}
+		}
+	}
+
+	protected := make(map[string]string)
+	for _, backups := range backupsByDatabase {
+		for _, backupConfig := range backups {
+			if !keep[backupConfig.Timestamp] {
+				continue
+			}
+			for _, entry := range backupConfig.RestorePlan {
+				if expired[entry.Timestamp] {
+					delete(expired, entry.Timestamp)
+					protected[entry.Timestamp] = backupConfig.Timestamp
+				}
+			}
+		}
+	}
+
+	expiredTimestamps := make([]string, 0)
+	for timestamp := range expired {
+		expiredTimestamps = append(expiredTimestamps, timestamp)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(expiredTimestamps)))
+	return expiredTimestamps, protected
+}
+
+func isWithinDays(timestamp string, days int, now time.Time) bool {
+	backupTime, err := time.ParseInLocation("20060102150405", timestamp, operating.System.Local)
+	if err != nil {
+		// Keep any backup whose age cannot be determined
+		return true
+	}
+	return now.Sub(backupTime) < time.Duration(days)*24*time.Hour
+}
+
+func PruneBackups(backupHistory *history.History, historyFilePath string, dbname string, policy RetentionPolicy, dryRun bool) {
+	expired, protected := SelectExpiredBackups(backupHistory, dbname, policy, operating.System.Now())
+
+	protectedTimestamps := make([]string, 0)
+	for timestamp := range protected {
+		protectedTimestamps = append(protectedTimestamps, timestamp)
+	}
+	sort.Strings(protectedTimestamps)
+	for _, timestamp := range protectedTimestamps {
+		gplog.Info("Keeping expired backup %s because backup %s depends on it", timestamp, protected[timestamp])
+	}
+
+	if len(expired) == 0 {
+		gplog.Info("No backups to prune")
+		return
+	}
+	for _, timestamp := range expired {
+		if dryRun {
+			gplog.Info("Backup %s would be deleted", timestamp)
+			continue
+		}
+		DeleteBackup(backupHistory, historyFilePath, timestamp)
+	}
+}
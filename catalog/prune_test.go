@@ -0,0 +1,78 @@
+package catalog_test
+
+import (
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/catalog"
+	"github.com/greenplum-db/gpbackup/history"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("catalog/prune tests", func() {
+	var (
+		backupHistory *history.History
+		now           time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2019, time.January, 10, 12, 0, 0, 0, time.Local)
+		backupHistory = &history.History{BackupConfigs: []history.BackupConfig{
+			{Timestamp: "20190109010101", DatabaseName: "testdb", Incremental: true,
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20190105010101"}, {Timestamp: "20190108010101"}, {Timestamp: "20190109010101"}}},
+			{Timestamp: "20190108010101", DatabaseName: "testdb", Incremental: true,
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20190105010101"}, {Timestamp: "20190108010101"}}},
+			{Timestamp: "20190107010101", DatabaseName: "otherdb"},
+			{Timestamp: "20190105010101", DatabaseName: "testdb",
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20190105010101"}}},
+			{Timestamp: "20190103010101", DatabaseName: "testdb", MetadataOnly: true},
+			{Timestamp: "20190101010101", DatabaseName: "testdb",
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20190101010101"}}},
+			{Timestamp: "20181201010101", DatabaseName: "testdb", DateDeleted: "20190101000000"},
+		}}
+	})
+
+	Describe("SelectExpiredBackups", func() {
+		It("keeps the most recent backups of each database and the bases they depend on", func() {
+			expired, protected := catalog.SelectExpiredBackups(backupHistory, "", catalog.RetentionPolicy{KeepLast: 1}, now)
+
+			Expect(expired).To(Equal([]string{"20190103010101", "20190101010101"}))
+			Expect(protected).To(Equal(map[string]string{"20190105010101": "20190109010101", "20190108010101": "20190109010101"}))
+		})
+		It("keeps backups taken within the given number of days", func() {
+			expired, _ := catalog.SelectExpiredBackups(backupHistory, "", catalog.RetentionPolicy{KeepDays: 4}, now)
+
+			Expect(expired).To(Equal([]string{"20190103010101", "20190101010101"}))
+		})
+		It("keeps the most recent full backups, ignoring incremental and metadata-only backups", func() {
+			expired, protected := catalog.SelectExpiredBackups(backupHistory, "testdb", catalog.RetentionPolicy{KeepFull: 2}, now)
+
+			Expect(expired).To(Equal([]string{"20190109010101", "20190108010101", "20190103010101"}))
+			Expect(protected).To(BeEmpty())
+		})
+		It("only considers backups of the given database", func() {
+			expired, _ := catalog.SelectExpiredBackups(backupHistory, "otherdb", catalog.RetentionPolicy{KeepLast: 1}, now)
+
+			Expect(expired).To(BeEmpty())
+		})
+	})
+	Describe("GetDependentBackup", func() {
+		It("returns the newest backup whose restore plan uses the given backup", func() {
+			Expect(catalog.GetDependentBackup(backupHistory, "20190105010101").Timestamp).To(Equal("20190109010101"))
+		})
+		It("ignores the backup itself and deleted backups", func() {
+			backupHistory.BackupConfigs[0].DateDeleted = "20190110000000"
+
+			Expect(catalog.GetDependentBackup(backupHistory, "20190108010101")).To(BeNil())
+			Expect(catalog.GetDependentBackup(backupHistory, "20190101010101")).To(BeNil())
+		})
+	})
+	Describe("DeleteBackup", func() {
+		It("refuses to delete a backup that a newer incremental backup depends on", func() {
+			defer testhelper.ShouldPanicWithMessage("Backup 20190105010101 cannot be deleted because incremental backup 20190109010101 depends on it")
+			catalog.DeleteBackup(backupHistory, "/tmp/unused_history_file.yaml", "20190105010101")
+		})
+	})
+})
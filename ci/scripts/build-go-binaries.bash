@@ -47,6 +47,7 @@ fi
 
 cp ${GOPATH}/bin/gpbackup go_components/
 cp ${GOPATH}/bin/gpbackup_helper go_components/
+cp ${GOPATH}/bin/gpbackup_catalog go_components/
 cp ${GOPATH}/bin/gprestore go_components/
 cp ${GOPATH}/bin/gpbackup_s3_plugin go_components/
 cp ${GOPATH}/bin/gpbackup_manager go_components/
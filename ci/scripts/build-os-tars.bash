@@ -12,7 +12,7 @@ pushd gpbackup_tar
   cp gpbackup_version version
 
   mkdir -p bin lib
-  cp gpbackup gpbackup_helper gprestore gpbackup_s3_plugin gpbackup_manager bin/
+  cp gpbackup gpbackup_helper gpbackup_catalog gprestore gpbackup_s3_plugin gpbackup_manager bin/
   cp ../ddboost_components/gpbackup_ddboost_plugin bin/
   cp ../ddboost_components/libDDBoost.so lib/
   tar -czvf bin_gpbackup.tar.gz bin/ lib/
@@ -89,11 +89,19 @@ func (backupFPInfo *FilePathInfo) GetTableBackupFilePathForCopyCommand(tableOid
 	}
 
 	backupFilePath += extension
+	return path.Join(backupFPInfo.getDirForCopyCommand(), backupFilePath)
+}
+
+func (backupFPInfo *FilePathInfo) GetSegmentTOCFilePathForCopyCommand() string {
+	return path.Join(backupFPInfo.getDirForCopyCommand(), fmt.Sprintf("gpbackup_<SEGID>_%s_toc.yaml", backupFPInfo.Timestamp))
+}
+
+func (backupFPInfo *FilePathInfo) getDirForCopyCommand() string {
 	baseDir := "<SEG_DATA_DIR>"
 	if backupFPInfo.IsUserSpecifiedBackupDir() {
 		baseDir = path.Join(backupFPInfo.UserSpecifiedBackupDir, fmt.Sprintf("%s<SEGID>", backupFPInfo.UserSpecifiedSegPrefix))
 	}
-	return path.Join(baseDir, "backups", backupFPInfo.Timestamp[0:8], backupFPInfo.Timestamp, backupFilePath)
+	return path.Join(baseDir, "backups", backupFPInfo.Timestamp[0:8], backupFPInfo.Timestamp)
 }
 
 var metadataFilenameMap = map[string]string{
@@ -101,10 +109,13 @@ var metadataFilenameMap = map[string]string{
 	"metadata":              "metadata.sql",
 	"statistics":            "statistics.sql",
 	"table of contents":     "toc.yaml",
+	"checksums":             "checksums.yaml",
 	"report":                "report",
+	"json_report":           "report.json",
 	"plugin_config":         "plugin_config.yaml",
 	"error_tables_metadata": "error_tables_metadata",
 	"error_tables_data":     "error_tables_data",
+	"journal":               "journal",
 }
 
 func (backupFPInfo *FilePathInfo) GetBackupFilePath(filetype string) string {
@@ -128,10 +139,18 @@ func (backupFPInfo *FilePathInfo) GetTOCFilePath() string {
 	return backupFPInfo.GetBackupFilePath("table of contents")
 }
 
+func (backupFPInfo *FilePathInfo) GetChecksumManifestFilePath() string {
+	return backupFPInfo.GetBackupFilePath("checksums")
+}
+
 func (backupFPInfo *FilePathInfo) GetBackupReportFilePath() string {
 	return backupFPInfo.GetBackupFilePath("report")
 }
 
+func (backupFPInfo *FilePathInfo) GetBackupJSONReportFilePath() string {
+	return backupFPInfo.GetBackupFilePath("json_report")
+}
+
 func (backupFPInfo *FilePathInfo) GetRestoreFilePath(restoreTimestamp string, filetype string) string {
 	return path.Join(backupFPInfo.GetDirForContent(-1), fmt.Sprintf("gprestore_%s_%s_%s", backupFPInfo.Timestamp, restoreTimestamp, metadataFilenameMap[filetype]))
 }
@@ -140,6 +159,10 @@ func (backupFPInfo *FilePathInfo) GetRestoreReportFilePath(restoreTimestamp stri
 	return backupFPInfo.GetRestoreFilePath(restoreTimestamp, "report")
 }
 
+func (backupFPInfo *FilePathInfo) GetRestoreJSONReportFilePath(restoreTimestamp string) string {
+	return backupFPInfo.GetRestoreFilePath(restoreTimestamp, "json_report")
+}
+
 func (backupFPInfo *FilePathInfo) GetErrorTablesMetadataFilePath(restoreTimestamp string) string {
 	return backupFPInfo.GetRestoreFilePath(restoreTimestamp, "error_tables_metadata")
 }
@@ -148,6 +171,10 @@ func (backupFPInfo *FilePathInfo) GetErrorTablesDataFilePath(restoreTimestamp st
 	return backupFPInfo.GetRestoreFilePath(restoreTimestamp, "error_tables_data")
 }
 
+func (backupFPInfo *FilePathInfo) GetRestoreJournalFilePath(restoreTimestamp string) string {
+	return backupFPInfo.GetRestoreFilePath(restoreTimestamp, "journal")
+}
+
 func (backupFPInfo *FilePathInfo) GetConfigFilePath() string {
 	return backupFPInfo.GetBackupFilePath("config")
 }
@@ -192,12 +219,22 @@ func GetSegPrefix(connectionPool *dbconn.DBConn) string {
 func ParseSegPrefix(backupDir string, timestamp string) string {
 	segPrefix := ""
 	if len(backupDir) > 0 {
-		backupDirForTimestamp, err := operating.System.Glob(fmt.Sprintf("%s/*-1/backups/*/%s", backupDir, timestamp))
-		if err != nil || len(backupDirForTimestamp) == 0 {
-			gplog.Fatal(err, "Master backup directory in %s missing or inaccessible", backupDir)
+		var found bool
+		segPrefix, found = FindSegPrefix(backupDir, timestamp)
+		if !found {
+			gplog.Fatal(nil, "Master backup directory in %s missing or inaccessible", backupDir)
 		}
-		indexOfBackupsSubstr := strings.LastIndex(backupDirForTimestamp[0], "-1/backups/")
-		_, segPrefix = path.Split(backupDirForTimestamp[0][:indexOfBackupsSubstr])
 	}
 	return segPrefix
 }
+
+// Returns false if there is no master backup directory for the timestamp in backupDir
+func FindSegPrefix(backupDir string, timestamp string) (string, bool) {
+	backupDirForTimestamp, err := operating.System.Glob(fmt.Sprintf("%s/*-1/backups/*/%s", backupDir, timestamp))
+	if err != nil || len(backupDirForTimestamp) == 0 {
+		return "", false
+	}
+	indexOfBackupsSubstr := strings.LastIndex(backupDirForTimestamp[0], "-1/backups/")
+	_, segPrefix := path.Split(backupDirForTimestamp[0][:indexOfBackupsSubstr])
+	return segPrefix, true
+}
@@ -93,6 +93,16 @@ var _ = Describe("filepath tests", func() {
 			Expect(fpInfo.GetTableBackupFilePathForCopyCommand(1234, ".gzip", true)).To(Equal("/foo/bar/gpseg<SEGID>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101.gzip"))
 		})
 	})
+	Describe("GetSegmentTOCFilePathForCopyCommand()", func() {
+		It("returns segment TOC file path for copy command", func() {
+			fpInfo := NewFilePathInfo(c, "", "20170101010101", "gpseg")
+			Expect(fpInfo.GetSegmentTOCFilePathForCopyCommand()).To(Equal("<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_toc.yaml"))
+		})
+		It("returns segment TOC file path for copy command based on user specified path", func() {
+			fpInfo := NewFilePathInfo(c, "/foo/bar", "20170101010101", "gpseg")
+			Expect(fpInfo.GetSegmentTOCFilePathForCopyCommand()).To(Equal("/foo/bar/gpseg<SEGID>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_toc.yaml"))
+		})
+	})
 	Describe("GetReportFilePath", func() {
 		It("returns report file path", func() {
 			fpInfo := NewFilePathInfo(c, "", "20170101010101", "gpseg")
@@ -102,6 +112,11 @@ var _ = Describe("filepath tests", func() {
 			fpInfo := NewFilePathInfo(c, "/foo/bar", "20170101010101", "gpseg")
 			Expect(fpInfo.GetBackupReportFilePath()).To(Equal("/foo/bar/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_report"))
 		})
+		It("returns JSON report file paths", func() {
+			fpInfo := NewFilePathInfo(c, "", "20170101010101", "gpseg")
+			Expect(fpInfo.GetBackupJSONReportFilePath()).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_report.json"))
+			Expect(fpInfo.GetRestoreJSONReportFilePath("20170102010101")).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gprestore_20170101010101_20170102010101_report.json"))
+		})
 	})
 	Describe("GetTableBackupFilePath", func() {
 		It("returns table file path", func() {
@@ -147,6 +162,23 @@ var _ = Describe("filepath tests", func() {
 			defer testhelper.ShouldPanicWithMessage("Master backup directory in /tmp/foo missing or inaccessible")
 			Expect(ParseSegPrefix("/tmp/foo", "timestamp1")).To(Equal("gpseg"))
 		})
+		Describe("FindSegPrefix", func() {
+			It("returns the segment prefix if the master backup directory exists", func() {
+				operating.System.Glob = func(pattern string) (matches []string, err error) {
+					return []string{"/tmp/foo/gpseg-1/backups/datestamp1/timestamp1"}, nil
+				}
+
+				segPrefix, found := FindSegPrefix("/tmp/foo", "timestamp1")
+				Expect(found).To(BeTrue())
+				Expect(segPrefix).To(Equal("gpseg"))
+			})
+			It("returns false if the master backup directory does not exist", func() {
+				operating.System.Glob = func(pattern string) (matches []string, err error) { return []string{}, nil }
+
+				_, found := FindSegPrefix("/tmp/foo", "timestamp1")
+				Expect(found).To(BeFalse())
+			})
+		})
 		Describe("IsValidTimestamp", func() {
 			It("allows a valid timestamp", func() {
 				timestamp := "20170101010101"
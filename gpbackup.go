@@ -16,6 +16,9 @@ func main() {
 		Short:   "gpbackup is the parallel backup utility for Greenplum",
 		Args:    cobra.NoArgs,
 		Version: GetVersion(),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return options.ApplyConfigFile(cmd.Flags())
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			defer DoTeardown()
 			DoFlagValidation(cmd)
@@ -0,0 +1,25 @@
+// +build gpbackup_catalog
+
+package main
+
+import (
+	"os"
+
+	. "github.com/greenplum-db/gpbackup/catalog"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	var rootCmd = &cobra.Command{
+		Use:     "gpbackup_catalog",
+		Short:   "gpbackup_catalog lists, displays, deletes, and prunes backups recorded in the backup history",
+		Args:    cobra.NoArgs,
+		Version: GetVersion(),
+	}
+	rootCmd.SetArgs(options.HandleSingleDashes(os.Args[1:]))
+	DoInit(rootCmd)
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(2)
+	}
+}
@@ -2,8 +2,12 @@ package helper
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"os/exec"
@@ -21,11 +25,13 @@ import (
 func doBackupAgent() error {
 	var lastRead uint64
 	var (
-		finalWriter io.Writer
-		gzipWriter  *gzip.Writer
-		bufIoWriter *bufio.Writer
-		writeHandle io.WriteCloser
-		writeCmd    *exec.Cmd
+		finalWriter    io.Writer
+		compressWriter io.WriteCloser
+		encryptWriter  io.WriteCloser
+		bufIoWriter    *bufio.Writer
+		writeHandle    io.WriteCloser
+		writeCmd       *exec.Cmd
+		fileHash       hash.Hash
 	)
 	tocfile := &toc.SegmentTOC{}
 	tocfile.DataEntries = make(map[uint]toc.SegmentDataEntry)
@@ -60,21 +66,32 @@ func doBackupAgent() error {
 			return err
 		}
 		if i == 0 {
-			finalWriter, gzipWriter, bufIoWriter, writeHandle, writeCmd, err = getBackupPipeWriter(*compressionLevel)
+			finalWriter, compressWriter, encryptWriter, bufIoWriter, writeHandle, writeCmd, fileHash, err = getBackupPipeWriter(*compressionLevel)
 			if err != nil {
 				return err
 			}
 		}
 
 		log(fmt.Sprintf("Backing up table with oid %d\n", oid))
-		numBytes, err := io.Copy(finalWriter, reader)
+		tableHash := sha256.New()
+		numBytes, err := io.Copy(io.MultiWriter(finalWriter, tableHash), reader)
 		if err != nil {
 			return errors.Wrap(err, strings.Trim(errBuf.String(), "\x00"))
 		}
 		log(fmt.Sprintf("Read %d bytes\n", numBytes))
 
 		lastProcessed := lastRead + uint64(numBytes)
-		tocfile.AddSegmentDataEntry(uint(oid), lastRead, lastProcessed)
+		/*
+		 * The segment TOC is not encrypted, so a digest of an encrypted
+		 * table's plaintext would let anyone who can read the backup confirm
+		 * a guess at its contents.  Only the checksum of the encrypted data
+		 * file is recorded for encrypted backups.
+		 */
+		checksum := ""
+		if encryptWriter == nil {
+			checksum = hex.EncodeToString(tableHash.Sum(nil))
+		}
+		tocfile.AddSegmentDataEntry(uint(oid), lastRead, lastProcessed, checksum)
 		lastRead = lastProcessed
 
 		lastPipe = currentPipe
@@ -90,8 +107,17 @@ func doBackupAgent() error {
 	 * The order for flushing and closing the writers below is very specific
 	 * to ensure all data is written to the file and file handles are not leaked.
 	 */
-	if gzipWriter != nil {
-		_ = gzipWriter.Close()
+	if compressWriter != nil {
+		err = compressWriter.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if encryptWriter != nil {
+		err = encryptWriter.Close()
+		if err != nil {
+			return err
+		}
 	}
 	_ = bufIoWriter.Flush()
 	_ = writeHandle.Close()
@@ -109,6 +135,15 @@ func doBackupAgent() error {
 			return errors.Wrap(err, strings.Trim(errBuf.String(), "\x00"))
 		}
 	}
+	/*
+	 * The checksum of the data file is computed as it is written, so that
+	 * gpbackup does not need to read the file again and so that a checksum
+	 * is available even if the file was sent to a plugin.
+	 */
+	err = writeChecksumFile(*dataFile, fileHash)
+	if err != nil {
+		return err
+	}
 	err = tocfile.WriteToFileAndMakeReadOnly(*tocFile)
 	if err != nil {
 		return err
@@ -129,7 +164,11 @@ func getBackupPipeReader(currentPipe string) (io.Reader, io.ReadCloser, error) {
 	return reader, readHandle, nil
 }
 
-func getBackupPipeWriter(compressLevel int) (io.Writer, *gzip.Writer, *bufio.Writer, io.WriteCloser, *exec.Cmd, error) {
+/*
+ * Data is compressed before it is encrypted, since encrypted data does not
+ * compress, and the checksum of the file is computed on the data as stored.
+ */
+func getBackupPipeWriter(compressLevel int) (io.Writer, io.WriteCloser, io.WriteCloser, *bufio.Writer, io.WriteCloser, *exec.Cmd, hash.Hash, error) {
 	var writeHandle io.WriteCloser
 	var err error
 	var writeCmd *exec.Cmd
@@ -139,21 +178,90 @@ func getBackupPipeWriter(compressLevel int) (io.Writer, *gzip.Writer, *bufio.Wri
 		writeHandle, err = os.Create(*dataFile)
 	}
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	var finalWriter io.Writer
-	var gzipWriter *gzip.Writer
-	bufIoWriter := bufio.NewWriter(writeHandle)
+	var compressWriter io.WriteCloser
+	var encryptWriter io.WriteCloser
+	fileHash := sha256.New()
+	bufIoWriter := bufio.NewWriter(io.MultiWriter(writeHandle, fileHash))
 	finalWriter = bufIoWriter
+	if *encryptionKeyFile != "" {
+		key, err := getEncryptionKey()
+		if err != nil {
+			return nil, nil, nil, nil, nil, nil, nil, err
+		}
+		encryptWriter, err = utils.NewEncryptWriter(bufIoWriter, key)
+		if err != nil {
+			return nil, nil, nil, nil, nil, nil, nil, err
+		}
+		finalWriter = encryptWriter
+	}
 	if compressLevel > 0 {
-		gzipWriter, err = gzip.NewWriterLevel(bufIoWriter, compressLevel)
+		compressWriter, err = getCompressWriter(finalWriter, *compressionType, compressLevel)
 		if err != nil {
-			return nil, nil, nil, nil, nil, err
+			return nil, nil, nil, nil, nil, nil, nil, err
 		}
-		finalWriter = gzipWriter
+		finalWriter = compressWriter
+	}
+	return finalWriter, compressWriter, encryptWriter, bufIoWriter, writeHandle, writeCmd, fileHash, nil
+}
+
+/*
+ * Data is compressed with gzip in process, while other codecs are applied by
+ * piping the data through their command-line tools.
+ */
+func getCompressWriter(writer io.Writer, compressType string, compressLevel int) (io.WriteCloser, error) {
+	codec, err := utils.GetCompressionCodec(compressType)
+	if err != nil {
+		return nil, err
+	}
+	if codec.Name == "gzip" {
+		return gzip.NewWriterLevel(writer, compressLevel)
 	}
-	return finalWriter, gzipWriter, bufIoWriter, writeHandle, writeCmd, nil
+	args := strings.Fields(fmt.Sprintf(codec.OutputCommand, compressLevel))
+	compressCmd := exec.Command(args[0], args[1:]...)
+	compressCmd.Stdout = writer
+	cmdWriter := &commandWriter{cmd: compressCmd}
+	compressCmd.Stderr = &cmdWriter.errBuf
+	cmdWriter.stdin, err = compressCmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = compressCmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return cmdWriter, nil
+}
+
+/*
+ * Closing a commandWriter closes the command's input and waits for the
+ * command to finish writing its output, like closing a gzip.Writer.
+ */
+type commandWriter struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	errBuf bytes.Buffer
+}
+
+func (writer *commandWriter) Write(p []byte) (int, error) {
+	return writer.stdin.Write(p)
+}
+
+func (writer *commandWriter) Close() error {
+	_ = writer.stdin.Close()
+	err := writer.cmd.Wait()
+	if err != nil {
+		return errors.Wrap(err, strings.TrimSpace(writer.errBuf.String()))
+	}
+	return nil
+}
+
+func writeChecksumFile(filename string, fileHash hash.Hash) error {
+	contents := fmt.Sprintf("%s  %s\n", hex.EncodeToString(fileHash.Sum(nil)), filename)
+	return utils.WriteToFileAndMakeReadOnly(filename+utils.ChecksumFileSuffix, []byte(contents))
 }
 
 func startBackupPluginCommand() (*exec.Cmd, io.WriteCloser, error) {
@@ -0,0 +1,64 @@
+package helper
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Encryption specific functions
+ */
+
+/*
+ * The encrypt and decrypt agents filter stdin to stdout, so that data files
+ * written and read by COPY can be encrypted by adding the helper to the
+ * command that the data is piped through.
+ */
+func doEncryptAgent() error {
+	key, err := getEncryptionKey()
+	if err != nil {
+		return err
+	}
+	stdoutWriter := bufio.NewWriter(os.Stdout)
+	encryptWriter, err := utils.NewEncryptWriter(stdoutWriter, key)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(encryptWriter, bufio.NewReader(os.Stdin))
+	if err != nil {
+		return err
+	}
+	err = encryptWriter.Close()
+	if err != nil {
+		return err
+	}
+	return stdoutWriter.Flush()
+}
+
+func doDecryptAgent() error {
+	key, err := getEncryptionKey()
+	if err != nil {
+		return err
+	}
+	decryptReader, err := utils.NewDecryptReader(bufio.NewReader(os.Stdin), key)
+	if err != nil {
+		return err
+	}
+	stdoutWriter := bufio.NewWriter(os.Stdout)
+	_, err = io.Copy(stdoutWriter, decryptReader)
+	if err != nil {
+		return err
+	}
+	return stdoutWriter.Flush()
+}
+
+func getEncryptionKey() ([]byte, error) {
+	if *encryptionKeyFile == "" {
+		return nil, errors.New("--encryption-key-file must be specified to encrypt or decrypt data")
+	}
+	return utils.ReadEncryptionKey(*encryptionKeyFile)
+}
@@ -0,0 +1,65 @@
+package helper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Extract specific functions
+ */
+
+/*
+ * Writes the uncompressed data of a single table to stdout, so that a backup
+ * taken on a cluster with a different number of segments can be read by any
+ * segment of the restore cluster instead of through a pipe on the segment
+ * with the same content ID.
+ */
+func doExtractAgent() error {
+	entry, ok := toc.NewSegmentTOC(*tocFile).DataEntries[uint(*oid)]
+	if !ok {
+		return errors.Errorf("Table with oid %d not found in table of contents %s", *oid, *tocFile)
+	}
+
+	var readHandle io.Reader
+	var err error
+	if *pluginConfigFile != "" {
+		readHandle, err = startRestorePluginCommand()
+	} else {
+		readHandle, err = os.Open(*dataFile)
+	}
+	if err != nil {
+		return err
+	}
+	decompressReader, err := getDecompressReader(readHandle)
+	if err != nil {
+		return err
+	}
+	dataReader := bufio.NewReader(decompressReader)
+
+	log(fmt.Sprintf("Extracting table with oid %d", *oid))
+	_, err = dataReader.Discard(int(entry.StartByte))
+	if err != nil {
+		return errors.Wrapf(err, "Could not read data for table with oid %d", *oid)
+	}
+	stdoutWriter := bufio.NewWriter(os.Stdout)
+	_, err = io.CopyN(stdoutWriter, dataReader, int64(entry.EndByte-entry.StartByte))
+	if err != nil {
+		return errors.Wrapf(err, "Could not read data for table with oid %d", *oid)
+	}
+	err = stdoutWriter.Flush()
+	if err != nil {
+		return err
+	}
+	errMsg := strings.Trim(errBuf.String(), "\x00")
+	if len(errMsg) != 0 {
+		return errors.New(errMsg)
+	}
+	return nil
+}
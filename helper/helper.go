@@ -3,9 +3,12 @@ package helper
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"os/signal"
 	"runtime/debug"
 	"sort"
@@ -17,6 +20,8 @@ import (
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gp-common-go-libs/iohelper"
 	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
 )
 
 /*
@@ -39,17 +44,24 @@ var (
  * Command-line flags
  */
 var (
-	backupAgent      *bool
-	compressionLevel *int
-	content          *int
-	dataFile         *string
-	oidFile          *string
-	onErrorContinue  *bool
-	pipeFile         *string
-	pluginConfigFile *string
-	printVersion     *bool
-	restoreAgent     *bool
-	tocFile          *string
+	backupAgent       *bool
+	compressionLevel  *int
+	compressionType   *string
+	content           *int
+	dataFile          *string
+	decryptAgent      *bool
+	encryptAgent      *bool
+	encryptionKeyFile *string
+	extractAgent      *bool
+	oid               *int
+	oidFile           *string
+	onErrorContinue   *bool
+	pipeFile          *string
+	pluginConfigFile  *string
+	printVersion      *bool
+	restoreAgent      *bool
+	tocFile           *string
+	verifyAgent       *bool
 )
 
 func DoHelper() {
@@ -81,11 +93,22 @@ func DoHelper() {
 		err = doBackupAgent()
 	} else if *restoreAgent {
 		err = doRestoreAgent()
+	} else if *verifyAgent {
+		err = doVerifyAgent()
+	} else if *extractAgent {
+		err = doExtractAgent()
+	} else if *encryptAgent {
+		err = doEncryptAgent()
+	} else if *decryptAgent {
+		err = doDecryptAgent()
 	}
 	if err != nil {
 		gplog.Error(fmt.Sprintf("%v: %s", err, debug.Stack()))
-		handle, _ := iohelper.OpenFileForWriting(fmt.Sprintf("%s_error", *pipeFile))
-		_ = handle.Close()
+		// These agents run in the foreground, so their exit code is enough to report an error
+		if !*verifyAgent && !*extractAgent && !*encryptAgent && !*decryptAgent {
+			handle, _ := iohelper.OpenFileForWriting(fmt.Sprintf("%s_error", *pipeFile))
+			_ = handle.Close()
+		}
 	}
 }
 
@@ -96,8 +119,14 @@ func InitializeGlobals() {
 
 	backupAgent = flag.Bool("backup-agent", false, "Use gpbackup_helper as an agent for backup")
 	content = flag.Int("content", -2, "Content ID of the corresponding segment")
-	compressionLevel = flag.Int("compression-level", 0, "The level of compression to use with gzip. O indicates no compression.")
+	compressionLevel = flag.Int("compression-level", 0, "The level of compression to use. O indicates no compression.")
+	compressionType = flag.String("compression-type", "gzip", "The type of compression to use. Valid values are gzip, zstd, and lz4.")
 	dataFile = flag.String("data-file", "", "Absolute path to the data file")
+	decryptAgent = flag.Bool("decrypt-agent", false, "Use gpbackup_helper as an agent to decrypt stdin to stdout")
+	encryptAgent = flag.Bool("encrypt-agent", false, "Use gpbackup_helper as an agent to encrypt stdin to stdout")
+	encryptionKeyFile = flag.String("encryption-key-file", "", "Absolute path to the file containing the encryption key for the data")
+	extractAgent = flag.Bool("extract-agent", false, "Use gpbackup_helper as an agent to write the data for a single table to stdout")
+	oid = flag.Int("oid", 0, "Oid of the table to extract")
 	oidFile = flag.String("oid-file", "", "Absolute path to the file containing a list of oids to restore")
 	onErrorContinue = flag.Bool("on-error-continue", false, "Continue restore even when encountering an error")
 	pipeFile = flag.String("pipe-file", "", "Absolute path to the pipe file")
@@ -105,6 +134,7 @@ func InitializeGlobals() {
 	printVersion = flag.Bool("version", false, "Print version number and exit")
 	restoreAgent = flag.Bool("restore-agent", false, "Use gpbackup_helper as an agent for restore")
 	tocFile = flag.String("toc-file", "", "Absolute path to the table of contents file")
+	verifyAgent = flag.Bool("verify-agent", false, "Use gpbackup_helper as an agent to verify a backup")
 
 	if *onErrorContinue && !*restoreAgent {
 		fmt.Printf("--on-error-continue flag can only be used with --restore-agent flag")
@@ -143,6 +173,68 @@ func getOidListFromFile() ([]int, error) {
 	return oidList, nil
 }
 
+/*
+ * The codec is determined by the data file's extension, so that backups taken
+ * with any compression type are restored without any extra configuration.
+ * Encrypted data is decrypted before it is decompressed.
+ */
+func getDecompressReader(reader io.Reader) (io.Reader, error) {
+	if *encryptionKeyFile != "" {
+		key, err := getEncryptionKey()
+		if err != nil {
+			return nil, err
+		}
+		reader, err = utils.NewDecryptReader(reader, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	codec, ok := utils.GetCompressionCodecForFile(*dataFile)
+	if !ok {
+		return reader, nil
+	}
+	if codec.Name == "gzip" {
+		return gzip.NewReader(reader)
+	}
+	args := strings.Fields(codec.InputCommand)
+	decompressCmd := exec.Command(args[0], args[1:]...)
+	decompressCmd.Stdin = reader
+	stderr := &bytes.Buffer{}
+	decompressCmd.Stderr = stderr
+	decompressReader, err := decompressCmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = decompressCmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &commandReader{reader: decompressReader, cmd: decompressCmd, stderr: stderr}, nil
+}
+
+/*
+ * A decompression program that fails, as on a truncated or corrupt file, only
+ * closes its output, so the program is waited for at the end of its output
+ * and its failure is returned in place of io.EOF.
+ */
+type commandReader struct {
+	reader io.Reader
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+	done   bool
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err == io.EOF && !r.done {
+		r.done = true
+		if waitErr := r.cmd.Wait(); waitErr != nil {
+			return n, errors.Errorf("%s failed: %v: %s", r.cmd.Args[0], waitErr, strings.TrimSpace(r.stderr.String()))
+		}
+	}
+	return n, err
+}
+
 func flushAndCloseRestoreWriter() error {
 	if writer != nil {
 		err := writer.Flush()
@@ -2,7 +2,6 @@ package helper
 
 import (
 	"bufio"
-	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
@@ -132,16 +131,11 @@ func getRestoreDataReader() (*bufio.Reader, error) {
 		return nil, err
 	}
 
-	var bufIoReader *bufio.Reader
-	if strings.HasSuffix(*dataFile, ".gz") {
-		gzipReader, err := gzip.NewReader(readHandle)
-		if err != nil {
-			return nil, err
-		}
-		bufIoReader = bufio.NewReader(gzipReader)
-	} else {
-		bufIoReader = bufio.NewReader(readHandle)
+	decompressReader, err := getDecompressReader(readHandle)
+	if err != nil {
+		return nil, err
 	}
+	bufIoReader := bufio.NewReader(decompressReader)
 	// Check that no error has occurred in plugin command
 	errMsg := strings.Trim(errBuf.String(), "\x00")
 	if len(errMsg) != 0 {
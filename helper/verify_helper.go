@@ -0,0 +1,110 @@
+package helper
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Verify specific functions
+ */
+
+/*
+ * Reads the entire data file once, computing the checksum of the file as
+ * stored and the checksum of each table's byte range in the uncompressed data,
+ * and prints one line per table followed by one line for the file:
+ *
+ *   table <oid> <status>
+ *   file <checksum>
+ *
+ * Tables are checked in file order so that the data never needs to be read
+ * more than once, which matters when it is streamed from a plugin.
+ */
+func doVerifyAgent() error {
+	tocEntries := toc.NewSegmentTOC(*tocFile).DataEntries
+	oids := make([]uint, 0, len(tocEntries))
+	for oid := range tocEntries {
+		oids = append(oids, oid)
+	}
+	sort.Slice(oids, func(i, j int) bool {
+		return tocEntries[oids[i]].StartByte < tocEntries[oids[j]].StartByte
+	})
+
+	var readHandle io.Reader
+	var err error
+	if *pluginConfigFile != "" {
+		readHandle, err = startRestorePluginCommand()
+	} else {
+		readHandle, err = os.Open(*dataFile)
+	}
+	if err != nil {
+		return err
+	}
+	fileHash := sha256.New()
+	rawReader := io.TeeReader(readHandle, fileHash)
+	decompressReader, err := getDecompressReader(rawReader)
+	if err != nil {
+		return err
+	}
+	dataReader := bufio.NewReader(decompressReader)
+
+	var lastByte uint64
+	truncated := false
+	for _, oid := range oids {
+		entry := tocEntries[oid]
+		if truncated {
+			fmt.Printf("table %d %s\n", oid, utils.VerifyStatusTruncated)
+			continue
+		}
+		log(fmt.Sprintf("Verifying table with oid %d", oid))
+		_, err = dataReader.Discard(int(entry.StartByte - lastByte))
+		if err != nil {
+			truncated = true
+			fmt.Printf("table %d %s\n", oid, utils.VerifyStatusTruncated)
+			continue
+		}
+		tableHash := sha256.New()
+		bytesRead, err := io.CopyN(tableHash, dataReader, int64(entry.EndByte-entry.StartByte))
+		lastByte = entry.StartByte + uint64(bytesRead)
+		if err != nil {
+			truncated = true
+			fmt.Printf("table %d %s\n", oid, utils.VerifyStatusTruncated)
+			continue
+		}
+		fmt.Printf("table %d %s\n", oid, getTableVerifyStatus(entry.Checksum, hex.EncodeToString(tableHash.Sum(nil))))
+	}
+
+	// Read whatever is left so that the checksum covers the entire file
+	_, _ = io.Copy(ioutil.Discard, dataReader)
+	_, err = io.Copy(ioutil.Discard, rawReader)
+	if err != nil {
+		return err
+	}
+	errMsg := strings.Trim(errBuf.String(), "\x00")
+	if len(errMsg) != 0 {
+		return errors.New(errMsg)
+	}
+	fmt.Printf("file %s\n", hex.EncodeToString(fileHash.Sum(nil)))
+	return nil
+}
+
+func getTableVerifyStatus(expected string, actual string) string {
+	if expected == "" {
+		// Encrypted backups, and backups taken before checksums were recorded, have nothing to compare against
+		return utils.VerifyStatusUnchecked
+	} else if expected != actual {
+		return utils.VerifyStatusMismatch
+	}
+	return utils.VerifyStatusOK
+}
@@ -0,0 +1,209 @@
+package history
+
+/*
+ * This file contains functions for checking that every backup in the restore
+ * plan of an incremental backup is still available and was taken with the
+ * same settings, so that a broken chain of incremental backups is reported
+ * before a backup or restore starts rather than partway through it.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+)
+
+type ChainProblem struct {
+	Timestamp string
+	ContentID int
+	Message   string
+}
+
+func (problem ChainProblem) String() string {
+	location := "on master"
+	if problem.ContentID != -1 {
+		location = fmt.Sprintf("on segment %d", problem.ContentID)
+	}
+	return fmt.Sprintf("Backup %s %s: %s", problem.Timestamp, location, problem.Message)
+}
+
+/*
+ * The table and schema filters of a restore, so that only the data files of
+ * the tables that will be restored need to be on the segments.  The zero
+ * value matches every table.
+ */
+type TableFilters struct {
+	IncludeSchemas   []string
+	ExcludeSchemas   []string
+	IncludeRelations []string
+	ExcludeRelations []string
+}
+
+/*
+ * The master files of each of the given restore plan entries are restored
+ * through the plugin, if any, when they are not already on the master.  The
+ * plugin API has no way to check for a file without restoring it, so the
+ * segment data files are only checked for backups on the cluster.  An empty
+ * segPrefix is found from the master backup directory of each backup in
+ * backupDir.
+ */
+func ValidateIncrementalChain(c *cluster.Cluster, plugin *utils.PluginConfig, backupConfig *BackupConfig, restorePlan []RestorePlanEntry,
+	filters TableFilters, backupDir string, segPrefix string, checkSegmentFiles bool) []ChainProblem {
+	problems := make([]ChainProblem, 0)
+	for _, entry := range restorePlan {
+		entrySegPrefix := segPrefix
+		if backupDir != "" && entrySegPrefix == "" {
+			var found bool
+			entrySegPrefix, found = filepath.FindSegPrefix(backupDir, entry.Timestamp)
+			if !found {
+				problems = append(problems, ChainProblem{entry.Timestamp, -1, fmt.Sprintf("no master backup directory found in %s", backupDir)})
+				continue
+			}
+		}
+		fpInfo := filepath.NewFilePathInfo(c, backupDir, entry.Timestamp, entrySegPrefix)
+
+		var entryConfig *BackupConfig
+		err := restoreFileIfMissing(plugin, fpInfo.GetConfigFilePath())
+		if err == nil {
+			entryConfig, err = readConfigFile(fpInfo.GetConfigFilePath())
+		}
+		if err != nil {
+			problems = append(problems, ChainProblem{entry.Timestamp, -1, fmt.Sprintf("unable to read config file: %v", err)})
+			continue
+		}
+		if entry.Timestamp != backupConfig.Timestamp {
+			for _, message := range GetChainIncompatibilities(backupConfig, entryConfig) {
+				problems = append(problems, ChainProblem{entry.Timestamp, -1, message})
+			}
+		}
+		var tocfile *toc.TOC
+		err = restoreFileIfMissing(plugin, fpInfo.GetTOCFilePath())
+		if err == nil {
+			tocfile, err = toc.ReadTOC(fpInfo.GetTOCFilePath())
+		}
+		if err != nil {
+			problems = append(problems, ChainProblem{entry.Timestamp, -1, fmt.Sprintf("unable to read table of contents file: %v", err)})
+			continue
+		}
+
+		if checkSegmentFiles && plugin == nil {
+			dataEntries := tocfile.GetDataEntriesMatching(filters.IncludeSchemas, filters.ExcludeSchemas,
+				filters.IncludeRelations, filters.ExcludeRelations, entry.TableFQNs)
+			problems = append(problems, validateSegmentFiles(c, fpInfo, entryConfig, dataEntries)...)
+		}
+	}
+	return problems
+}
+
+func restoreFileIfMissing(plugin *utils.PluginConfig, filename string) error {
+	if plugin == nil || iohelper.FileExistsAndIsReadable(filename) {
+		return nil
+	}
+	return plugin.RestoreFile(filename)
+}
+
+/*
+ * These are the settings that must match for incremental backups to be
+ * based on one another, as in backup's matchesIncrementalFlags.
+ */
+func GetChainIncompatibilities(backupConfig *BackupConfig, entryConfig *BackupConfig) []string {
+	incompatibilities := make([]string, 0)
+	compare := func(setting string, value string, expected string) {
+		if value != expected {
+			incompatibilities = append(incompatibilities, fmt.Sprintf("%s is %q, but is %q in backup %s", setting, value, expected, backupConfig.Timestamp))
+		}
+	}
+	compare("database", entryConfig.DatabaseName, backupConfig.DatabaseName)
+	compare("plugin", entryConfig.Plugin, backupConfig.Plugin)
+	compare("single data file", strconv.FormatBool(entryConfig.SingleDataFile), strconv.FormatBool(backupConfig.SingleDataFile))
+	compare("leaf partition data", strconv.FormatBool(entryConfig.LeafPartitionData), strconv.FormatBool(backupConfig.LeafPartitionData))
+	compare("compression", entryConfig.GetCompressionType(), backupConfig.GetCompressionType())
+	compare("encryption key fingerprint", entryConfig.EncryptionKeyFingerprint, backupConfig.EncryptionKeyFingerprint)
+	compare("masked", strconv.FormatBool(entryConfig.Masked), strconv.FormatBool(backupConfig.Masked))
+	compare("table predicates", strconv.FormatBool(entryConfig.Predicated), strconv.FormatBool(backupConfig.Predicated))
+	compare("include schemas", formatFilterList(entryConfig.IncludeSchemas), formatFilterList(backupConfig.IncludeSchemas))
+	compare("exclude schemas", formatFilterList(entryConfig.ExcludeSchemas), formatFilterList(backupConfig.ExcludeSchemas))
+	compare("include relations", formatFilterList(entryConfig.IncludeRelations), formatFilterList(backupConfig.IncludeRelations))
+	compare("exclude relations", formatFilterList(entryConfig.ExcludeRelations), formatFilterList(backupConfig.ExcludeRelations))
+	if entryConfig.SegmentCount != 0 && backupConfig.SegmentCount != 0 {
+		compare("segment count", strconv.Itoa(entryConfig.SegmentCount), strconv.Itoa(backupConfig.SegmentCount))
+	}
+	if entryConfig.MetadataOnly {
+		incompatibilities = append(incompatibilities, "backup is metadata-only, so it has no table data")
+	}
+	return incompatibilities
+}
+
+func formatFilterList(list []string) string {
+	sortedList := make([]string, len(list))
+	copy(sortedList, list)
+	sort.Strings(sortedList)
+	return strings.Join(sortedList, ", ")
+}
+
+/*
+ * Rather than one problem for each missing file, which for a missing backup
+ * directory would be one for every table, one problem is reported for each
+ * segment with missing files.
+ */
+func validateSegmentFiles(c *cluster.Cluster, fpInfo filepath.FilePathInfo, entryConfig *BackupConfig, dataEntries []toc.MasterDataEntry) []ChainProblem {
+	problems := make([]ChainProblem, 0)
+	if len(dataEntries) == 0 {
+		return problems
+	}
+	extension := ""
+	if entryConfig.Compressed {
+		codec, err := utils.GetCompressionCodec(entryConfig.CompressionType)
+		if err != nil {
+			return append(problems, ChainProblem{fpInfo.Timestamp, -1, err.Error()})
+		}
+		extension = codec.Extension
+	}
+	oids := make([]string, 0, len(dataEntries))
+	for _, entry := range dataEntries {
+		oids = append(oids, strconv.FormatUint(uint64(entry.Oid), 10))
+	}
+
+	remoteOutput := c.GenerateAndExecuteCommand(fmt.Sprintf("Checking data files of backup %s", fpInfo.Timestamp),
+		func(contentID int) string {
+			return GetMissingSegmentFilesCommand(fpInfo, contentID, oids, extension, entryConfig.SingleDataFile)
+		}, cluster.ON_SEGMENTS)
+	contentIDs := make([]int, 0, len(remoteOutput.Stdouts))
+	for contentID := range remoteOutput.Stdouts {
+		contentIDs = append(contentIDs, contentID)
+	}
+	sort.Ints(contentIDs)
+	for _, contentID := range contentIDs {
+		if err := remoteOutput.Errors[contentID]; err != nil {
+			problems = append(problems, ChainProblem{fpInfo.Timestamp, contentID,
+				fmt.Sprintf("unable to check data files: %s", strings.TrimSpace(remoteOutput.Stderrs[contentID]))})
+			continue
+		}
+		missingFiles := strings.Fields(remoteOutput.Stdouts[contentID])
+		if len(missingFiles) == 1 {
+			problems = append(problems, ChainProblem{fpInfo.Timestamp, contentID, fmt.Sprintf("data file %s is missing", missingFiles[0])})
+		} else if len(missingFiles) > 1 {
+			problems = append(problems, ChainProblem{fpInfo.Timestamp, contentID,
+				fmt.Sprintf("%d data files are missing from %s, including %s", len(missingFiles), fpInfo.GetDirForContent(contentID), missingFiles[0])})
+		}
+	}
+	return problems
+}
+
+// Prints the path of each data file of the backup that does not exist on the segment
+func GetMissingSegmentFilesCommand(fpInfo filepath.FilePathInfo, contentID int, oids []string, extension string, singleDataFile bool) string {
+	backupDir := fpInfo.GetDirForContent(contentID)
+	if singleDataFile {
+		return fmt.Sprintf(`for file in gpbackup_%d_%s%s gpbackup_%d_%s_toc.yaml; do [ -f "%s/$file" ] || echo "%s/$file"; done`,
+			contentID, fpInfo.Timestamp, extension, contentID, fpInfo.Timestamp, backupDir, backupDir)
+	}
+	filename := fmt.Sprintf("%s/gpbackup_%d_%s_${oid}%s", backupDir, contentID, fpInfo.Timestamp, extension)
+	return fmt.Sprintf(`for oid in %s; do [ -f "%s" ] || echo "%s"; done`, strings.Join(oids, " "), filename, filename)
+}
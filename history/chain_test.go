@@ -0,0 +1,199 @@
+package history_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/greenplum-db/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backup/history chain tests", func() {
+	var (
+		fullConfig        history.BackupConfig
+		incrementalConfig history.BackupConfig
+		executor          testutils.TestExecutorMultiple
+		testCluster       *cluster.Cluster
+		tempDir           string
+	)
+
+	writeBackup := func(config history.BackupConfig, dataEntries []toc.MasterDataEntry) {
+		fpInfo := filepath.NewFilePathInfo(testCluster, tempDir, config.Timestamp, "gpseg")
+		Expect(os.MkdirAll(fpInfo.GetDirForContent(-1), 0755)).To(Succeed())
+		history.WriteConfigFile(&config, fpInfo.GetConfigFilePath())
+		backupTOC := &toc.TOC{DataEntries: dataEntries}
+		backupTOC.WriteToFileAndMakeReadOnly(fpInfo.GetTOCFilePath())
+	}
+
+	BeforeEach(func() {
+		tempDir, _ = ioutil.TempDir("", "history")
+		fullConfig = history.BackupConfig{Timestamp: "20190101010101", DatabaseName: "testdb", Compressed: true,
+			RestorePlan: []history.RestorePlanEntry{{Timestamp: "20190101010101", TableFQNs: []string{"public.foo", "public.bar"}}}}
+		incrementalConfig = history.BackupConfig{Timestamp: "20190102010101", DatabaseName: "testdb", Compressed: true, Incremental: true,
+			RestorePlan: []history.RestorePlanEntry{
+				{Timestamp: "20190101010101", TableFQNs: []string{"public.foo"}},
+				{Timestamp: "20190102010101", TableFQNs: []string{"public.bar"}},
+			}}
+		executor = testutils.TestExecutorMultiple{
+			ClusterOutputs: []*cluster.RemoteOutput{{Stdouts: map[int]string{0: "", 1: ""}}},
+		}
+		testCluster = testutils.SetDefaultSegmentConfiguration()
+		testCluster.Executor = &executor
+	})
+	AfterEach(func() {
+		_ = os.RemoveAll(tempDir)
+	})
+
+	Describe("ValidateIncrementalChain", func() {
+		BeforeEach(func() {
+			writeBackup(fullConfig, []toc.MasterDataEntry{{Schema: "public", Name: "foo", Oid: 1}, {Schema: "public", Name: "bar", Oid: 2}})
+			writeBackup(incrementalConfig, []toc.MasterDataEntry{{Schema: "public", Name: "foo", Oid: 1}, {Schema: "public", Name: "bar", Oid: 2}})
+		})
+		It("finds no problems when every backup in the restore plan is available", func() {
+			problems := history.ValidateIncrementalChain(testCluster, nil, &incrementalConfig, incrementalConfig.RestorePlan, history.TableFilters{}, tempDir, "gpseg", true)
+
+			Expect(problems).To(BeEmpty())
+			Expect(executor.NumRemoteExecutions).To(Equal(2))
+			Expect(executor.ClusterCommands[0][0][4]).To(Equal(`for oid in 1; do [ -f "` + tempDir + `/gpseg0/backups/20190101/20190101010101/gpbackup_0_20190101010101_${oid}.gz" ] || echo "` +
+				tempDir + `/gpseg0/backups/20190101/20190101010101/gpbackup_0_20190101010101_${oid}.gz"; done`))
+			Expect(executor.ClusterCommands[1][1][4]).To(ContainSubstring(`for oid in 2; do`))
+		})
+		It("finds the segment prefix of each backup in the backup directory", func() {
+			problems := history.ValidateIncrementalChain(testCluster, nil, &incrementalConfig, incrementalConfig.RestorePlan, history.TableFilters{}, tempDir, "", true)
+
+			Expect(problems).To(BeEmpty())
+			Expect(executor.ClusterCommands[0][0][4]).To(ContainSubstring(tempDir + "/gpseg0/backups/20190101/20190101010101/"))
+		})
+		It("reports a backup in the restore plan whose master backup directory is missing", func() {
+			Expect(os.RemoveAll(tempDir + "/gpseg-1/backups/20190101")).To(Succeed())
+
+			problems := history.ValidateIncrementalChain(testCluster, nil, &incrementalConfig, incrementalConfig.RestorePlan, history.TableFilters{}, tempDir, "", true)
+
+			Expect(problems).To(HaveLen(1))
+			Expect(problems[0].String()).To(Equal("Backup 20190101010101 on master: no master backup directory found in " + tempDir))
+		})
+		It("reports a backup in the restore plan whose config file is missing", func() {
+			Expect(os.RemoveAll(tempDir + "/gpseg-1/backups/20190101")).To(Succeed())
+
+			problems := history.ValidateIncrementalChain(testCluster, nil, &incrementalConfig, incrementalConfig.RestorePlan, history.TableFilters{}, tempDir, "gpseg", true)
+
+			Expect(problems).To(HaveLen(1))
+			Expect(problems[0].Timestamp).To(Equal("20190101010101"))
+			Expect(problems[0].ContentID).To(Equal(-1))
+			Expect(problems[0].Message).To(HavePrefix("unable to read config file: "))
+			Expect(executor.NumRemoteExecutions).To(Equal(1))
+		})
+		It("reports a backup in the restore plan taken with different settings", func() {
+			fullConfig.IncludeSchemas = []string{"public"}
+			Expect(os.RemoveAll(tempDir + "/gpseg-1/backups/20190101")).To(Succeed())
+			writeBackup(fullConfig, []toc.MasterDataEntry{{Schema: "public", Name: "foo", Oid: 1}})
+
+			problems := history.ValidateIncrementalChain(testCluster, nil, &incrementalConfig, incrementalConfig.RestorePlan, history.TableFilters{}, tempDir, "gpseg", true)
+
+			Expect(problems).To(HaveLen(1))
+			Expect(problems[0].String()).To(Equal(`Backup 20190101010101 on master: include schemas is "public", but is "" in backup 20190102010101`))
+		})
+		It("reports missing data files once for each segment", func() {
+			executor.ClusterOutputs = []*cluster.RemoteOutput{
+				{Stdouts: map[int]string{0: "/a/gpbackup_0_20190101010101_1.gz\n", 1: "/b/gpbackup_1_20190101010101_1.gz\n/b/gpbackup_1_20190101010101_3.gz\n"}},
+				{Stdouts: map[int]string{0: "", 1: ""}},
+			}
+
+			problems := history.ValidateIncrementalChain(testCluster, nil, &incrementalConfig, incrementalConfig.RestorePlan, history.TableFilters{}, tempDir, "gpseg", true)
+
+			Expect(problems).To(Equal([]history.ChainProblem{
+				{Timestamp: "20190101010101", ContentID: 0, Message: "data file /a/gpbackup_0_20190101010101_1.gz is missing"},
+				{Timestamp: "20190101010101", ContentID: 1, Message: "2 data files are missing from " + tempDir +
+					"/gpseg1/backups/20190101/20190101010101, including /b/gpbackup_1_20190101010101_1.gz"},
+			}))
+		})
+		It("reports segments on which the data files could not be checked", func() {
+			executor.ClusterOutputs = []*cluster.RemoteOutput{
+				{NumErrors: 1, Stdouts: map[int]string{0: "", 1: ""}, Stderrs: map[int]string{0: "", 1: "Permission denied\n"}, Errors: map[int]error{1: os.ErrPermission}},
+				{Stdouts: map[int]string{0: "", 1: ""}},
+			}
+
+			problems := history.ValidateIncrementalChain(testCluster, nil, &incrementalConfig, incrementalConfig.RestorePlan, history.TableFilters{}, tempDir, "gpseg", true)
+
+			Expect(problems).To(Equal([]history.ChainProblem{
+				{Timestamp: "20190101010101", ContentID: 1, Message: "unable to check data files: Permission denied"},
+			}))
+		})
+		It("only checks the data files of tables that pass the filters", func() {
+			filters := history.TableFilters{ExcludeRelations: []string{"public.foo"}}
+
+			problems := history.ValidateIncrementalChain(testCluster, nil, &incrementalConfig, incrementalConfig.RestorePlan, filters, tempDir, "gpseg", true)
+
+			Expect(problems).To(BeEmpty())
+			Expect(executor.NumRemoteExecutions).To(Equal(1))
+			Expect(executor.ClusterCommands[0][0][4]).To(ContainSubstring(`/gpbackup_0_20190102010101_${oid}.gz`))
+			Expect(executor.ClusterCommands[0][0][4]).To(ContainSubstring(`for oid in 2; do`))
+		})
+		It("only checks the given restore plan entries", func() {
+			Expect(os.RemoveAll(tempDir + "/gpseg-1/backups/20190101")).To(Succeed())
+
+			problems := history.ValidateIncrementalChain(testCluster, nil, &incrementalConfig, incrementalConfig.RestorePlan[1:], history.TableFilters{}, tempDir, "gpseg", true)
+
+			Expect(problems).To(BeEmpty())
+			Expect(executor.NumRemoteExecutions).To(Equal(1))
+		})
+		It("does not check data files if told not to", func() {
+			problems := history.ValidateIncrementalChain(testCluster, nil, &incrementalConfig, incrementalConfig.RestorePlan, history.TableFilters{}, tempDir, "gpseg", false)
+
+			Expect(problems).To(BeEmpty())
+			Expect(executor.NumRemoteExecutions).To(Equal(0))
+		})
+	})
+	Describe("GetChainIncompatibilities", func() {
+		It("finds no incompatibilities between backups taken with the same settings", func() {
+			fullConfig.IncludeRelations = []string{"public.foo", "public.bar"}
+			incrementalConfig.IncludeRelations = []string{"public.bar", "public.foo"}
+
+			Expect(history.GetChainIncompatibilities(&incrementalConfig, &fullConfig)).To(BeEmpty())
+		})
+		It("treats backups compressed before the compression type was recorded as compressed with gzip", func() {
+			incrementalConfig.CompressionType = "gzip"
+
+			Expect(history.GetChainIncompatibilities(&incrementalConfig, &fullConfig)).To(BeEmpty())
+		})
+		It("reports each setting that differs", func() {
+			fullConfig.Plugin = "gpbackup_s3_plugin"
+			fullConfig.SingleDataFile = true
+			fullConfig.Compressed = false
+			fullConfig.Masked = true
+			fullConfig.Predicated = true
+			fullConfig.ExcludeRelations = []string{"public.baz"}
+			fullConfig.SegmentCount = 2
+			incrementalConfig.SegmentCount = 4
+
+			Expect(history.GetChainIncompatibilities(&incrementalConfig, &fullConfig)).To(Equal([]string{
+				`plugin is "gpbackup_s3_plugin", but is "" in backup 20190102010101`,
+				`single data file is "true", but is "false" in backup 20190102010101`,
+				`compression is "none", but is "gzip" in backup 20190102010101`,
+				`masked is "true", but is "false" in backup 20190102010101`,
+				`table predicates is "true", but is "false" in backup 20190102010101`,
+				`exclude relations is "public.baz", but is "" in backup 20190102010101`,
+				`segment count is "2", but is "4" in backup 20190102010101`,
+			}))
+		})
+		It("reports a metadata-only backup", func() {
+			fullConfig.MetadataOnly = true
+
+			Expect(history.GetChainIncompatibilities(&incrementalConfig, &fullConfig)).To(Equal([]string{"backup is metadata-only, so it has no table data"}))
+		})
+	})
+	Describe("GetMissingSegmentFilesCommand", func() {
+		It("checks for the data file and segment table of contents of a single data file backup", func() {
+			fpInfo := filepath.NewFilePathInfo(testCluster, "/backups", "20190101010101", "gpseg")
+
+			Expect(history.GetMissingSegmentFilesCommand(fpInfo, 1, []string{"1", "2"}, ".zst", true)).To(Equal(
+				`for file in gpbackup_1_20190101010101.zst gpbackup_1_20190101010101_toc.yaml; do [ -f "/backups/gpseg1/backups/20190101/20190101010101/$file" ] || echo "/backups/gpseg1/backups/20190101/20190101010101/$file"; done`))
+		})
+	})
+})
@@ -17,41 +17,61 @@ type RestorePlanEntry struct {
 }
 
 type BackupConfig struct {
-	BackupDir             string
-	BackupVersion         string
-	Compressed            bool
-	DatabaseName          string
-	DatabaseVersion       string
-	DataOnly              bool
-	DateDeleted           string
-	ExcludeRelations      []string
-	ExcludeSchemaFiltered bool
-	ExcludeSchemas        []string
-	ExcludeTableFiltered  bool
-	IncludeRelations      []string
-	IncludeSchemaFiltered bool
-	IncludeSchemas        []string
-	IncludeTableFiltered  bool
-	Incremental           bool
-	LeafPartitionData     bool
-	MetadataOnly          bool
-	Plugin                string
-	PluginVersion         string
-	RestorePlan           []RestorePlanEntry
-	SingleDataFile        bool
-	Timestamp             string
-	EndTime               string
-	WithoutGlobals        bool
-	WithStatistics        bool
+	BackupDir                string
+	BackupVersion            string
+	Compressed               bool
+	CompressionType          string `yaml:",omitempty"`
+	ConsolidatedFrom         string `yaml:",omitempty"`
+	DatabaseName             string
+	DatabaseVersion          string
+	DataOnly                 bool
+	DateDeleted              string
+	Encrypted                bool     `yaml:",omitempty"`
+	EncryptionKeyFingerprint string   `yaml:",omitempty"`
+	ExcludeObjectTypes       []string `yaml:",omitempty"`
+	ExcludeRelations         []string
+	ExcludeSchemaFiltered    bool
+	ExcludeSchemas           []string
+	ExcludeTableFiltered     bool
+	IncludeObjectTypes       []string `yaml:",omitempty"`
+	IncludeRelations         []string
+	IncludeSchemaFiltered    bool
+	IncludeSchemas           []string
+	IncludeTableFiltered     bool
+	Incremental              bool
+	LeafPartitionData        bool
+	Masked                   bool `yaml:",omitempty"`
+	MetadataOnly             bool
+	Plugin                   string
+	PluginVersion            string
+	Predicated               bool `yaml:",omitempty"`
+	RestorePlan              []RestorePlanEntry
+	Resumed                  bool `yaml:",omitempty"`
+	SegmentCount             int  `yaml:",omitempty"`
+	SingleDataFile           bool
+	Timestamp                string
+	EndTime                  string
+	WithoutGlobals           bool
+	WithStatistics           bool
 }
 
 func ReadConfigFile(filename string) *BackupConfig {
+	config, err := readConfigFile(filename)
+	gplog.FatalOnError(err)
+	return config
+}
+
+func readConfigFile(filename string) (*BackupConfig, error) {
 	config := &BackupConfig{}
 	contents, err := operating.System.ReadFile(filename)
-	gplog.FatalOnError(err)
+	if err != nil {
+		return nil, err
+	}
 	err = yaml.Unmarshal(contents, config)
-	gplog.FatalOnError(err)
-	return config
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
 func WriteConfigFile(config *BackupConfig, configFilename string) {
@@ -65,6 +85,20 @@ func WriteConfigFile(config *BackupConfig, configFilename string) {
 	gplog.FatalOnError(err)
 }
 
+/*
+ * Returns "none" for an uncompressed backup, whatever compression type it
+ * recorded.  Backups taken before the compression type was recorded always
+ * used gzip.
+ */
+func (backupConfig *BackupConfig) GetCompressionType() string {
+	if !backupConfig.Compressed {
+		return "none"
+	} else if backupConfig.CompressionType == "" {
+		return "gzip"
+	}
+	return backupConfig.CompressionType
+}
+
 type History struct {
 	BackupConfigs []BackupConfig
 }
@@ -95,7 +129,7 @@ func CurrentTimestamp() string {
 }
 
 func WriteBackupHistory(historyFilePath string, currentBackupConfig *BackupConfig) error {
-	lock := lockHistoryFile()
+	lock := LockHistoryFile()
 	defer func() {
 		_ = lock.Unlock()
 	}()
@@ -122,7 +156,7 @@ func WriteBackupHistory(historyFilePath string, currentBackupConfig *BackupConfi
 }
 
 func (history *History) RewriteHistoryFile(historyFilePath string) error {
-	lock := lockHistoryFile()
+	lock := LockHistoryFile()
 	defer func() {
 		_ = lock.Unlock()
 	}()
@@ -131,7 +165,12 @@ func (history *History) RewriteHistoryFile(historyFilePath string) error {
 	return err
 }
 
-func lockHistoryFile() lockfile.Lockfile {
+/*
+ * Callers that read the history file, update it, and write it back with
+ * WriteToFileAndMakeReadOnly hold the lock for the whole update, so that a
+ * backup recorded in the meantime is not lost when the file is rewritten.
+ */
+func LockHistoryFile() lockfile.Lockfile {
 	lock, err := lockfile.New("/tmp/gpbackup_history.yaml.lck")
 	gplog.FatalOnError(err)
 	err = lock.TryLock()
@@ -173,9 +212,9 @@ func (history *History) WriteToFileAndMakeReadOnly(filename string) error {
 }
 
 func (history *History) FindBackupConfig(timestamp string) *BackupConfig {
-	for _, backupConfig := range history.BackupConfigs {
-		if backupConfig.Timestamp == timestamp {
-			return &backupConfig
+	for i := range history.BackupConfigs {
+		if history.BackupConfigs[i].Timestamp == timestamp {
+			return &history.BackupConfigs[i]
 		}
 	}
 	return nil
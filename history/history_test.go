@@ -258,4 +258,18 @@ var _ = Describe("backup/history tests", func() {
 			Expect(foundConfig).To(BeNil())
 		})
 	})
+	Describe("GetCompressionType", func() {
+		It("returns the recorded compression type of a compressed backup", func() {
+			backupConfig := history.BackupConfig{Compressed: true, CompressionType: "zstd"}
+			Expect(backupConfig.GetCompressionType()).To(Equal("zstd"))
+		})
+		It("returns gzip for a compressed backup without a recorded compression type", func() {
+			backupConfig := history.BackupConfig{Compressed: true}
+			Expect(backupConfig.GetCompressionType()).To(Equal("gzip"))
+		})
+		It("returns none for an uncompressed backup", func() {
+			backupConfig := history.BackupConfig{Compressed: false, CompressionType: "gzip"}
+			Expect(backupConfig.GetCompressionType()).To(Equal("none"))
+		})
+	})
 })
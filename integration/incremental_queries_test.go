@@ -263,4 +263,39 @@ var _ = Describe("backup integration tests", func() {
 			})
 		})
 	})
+	Describe("GetHeapIncrementalMetadata", func() {
+		var heapTableFQN = "public.heap_foo"
+		BeforeEach(func() {
+			testhelper.AssertQueryRuns(connectionPool, fmt.Sprintf("CREATE TABLE %s (i int) DISTRIBUTED BY (i)", heapTableFQN))
+		})
+		AfterEach(func() {
+			testhelper.AssertQueryRuns(connectionPool, fmt.Sprintf(dropTableSQL, heapTableFQN))
+		})
+		It("retrieves metadata only for heap tables", func() {
+			heapIncrementalMetadata := backup.GetHeapIncrementalMetadata(connectionPool)
+
+			Expect(heapIncrementalMetadata).To(HaveKey(heapTableFQN))
+			Expect(heapIncrementalMetadata).To(Not(HaveKey(aoTableFQN)))
+			Expect(heapIncrementalMetadata[heapTableFQN].Relfilenode).To(Not(BeZero()))
+			Expect(heapIncrementalMetadata[heapTableFQN].LastDDLTimestamp).To(Not(BeEmpty()))
+		})
+		It("has a changed relfilenode after a truncate", func() {
+			initialHeapIncrementalMetadata := backup.GetHeapIncrementalMetadata(connectionPool)
+
+			testhelper.AssertQueryRuns(connectionPool, fmt.Sprintf("TRUNCATE TABLE %s", heapTableFQN))
+
+			heapIncrementalMetadata := backup.GetHeapIncrementalMetadata(connectionPool)
+			Expect(heapIncrementalMetadata[heapTableFQN].Relfilenode).
+				To(Not(Equal(initialHeapIncrementalMetadata[heapTableFQN].Relfilenode)))
+		})
+		It("has a changed last DDL timestamp after a column add", func() {
+			initialHeapIncrementalMetadata := backup.GetHeapIncrementalMetadata(connectionPool)
+
+			testhelper.AssertQueryRuns(connectionPool, fmt.Sprintf(addColumnSQL, heapTableFQN))
+
+			heapIncrementalMetadata := backup.GetHeapIncrementalMetadata(connectionPool)
+			Expect(heapIncrementalMetadata[heapTableFQN].LastDDLTimestamp).
+				To(Not(Equal(initialHeapIncrementalMetadata[heapTableFQN].LastDDLTimestamp)))
+		})
+	})
 })
@@ -0,0 +1,140 @@
+package options
+
+/*
+ * This file contains functions related to filling flags from a YAML
+ * configuration file with --config.
+ */
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+ * The top-level keys of a configuration file are flag names and apply to
+ * every run, and each profile under the "profiles" key holds flags that
+ * override them when it is selected with --profile.  A flag given a list is
+ * set once for each value in the list.
+ */
+type ConfigFile struct {
+	Flags    map[string]interface{}            `yaml:",inline"`
+	Profiles map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+/*
+ * Sets each flag in the configuration file and selected profile that was not
+ * set on the command line, so that flags on the command line override the
+ * file.  Flags set from the file count as set, so they are validated the same
+ * way as flags on the command line.
+ */
+func ApplyConfigFile(flags *pflag.FlagSet) error {
+	configFilename, err := flags.GetString(CONFIG)
+	if err != nil {
+		return err
+	}
+	profile, err := flags.GetString(PROFILE)
+	if err != nil {
+		return err
+	}
+	if configFilename == "" {
+		if profile != "" {
+			return errors.Errorf("--%s must be specified with --%s", PROFILE, CONFIG)
+		}
+		return nil
+	}
+	contents, err := operating.System.ReadFile(configFilename)
+	if err != nil {
+		return err
+	}
+	configFile := ConfigFile{}
+	err = yaml.Unmarshal(contents, &configFile)
+	if err != nil {
+		return errors.Errorf("Unable to parse configuration file %s: %v", configFilename, err)
+	}
+
+	configFlags := make(map[string]interface{}, len(configFile.Flags))
+	for name, value := range configFile.Flags {
+		configFlags[name] = value
+	}
+	if profile != "" {
+		profileFlags, ok := configFile.Profiles[profile]
+		if !ok {
+			return errors.Errorf("Profile %s not found in configuration file %s", profile, configFilename)
+		}
+		for name, value := range profileFlags {
+			configFlags[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(configFlags))
+	for name := range configFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == CONFIG || name == PROFILE || name == "help" || name == "version" {
+			return errors.Errorf("Flag %s cannot be set in configuration file %s", name, configFilename)
+		}
+		flag := flags.Lookup(name)
+		if flag == nil {
+			return errors.Errorf("Unrecognized flag %s in configuration file %s", name, configFilename)
+		}
+		if flag.Changed {
+			continue
+		}
+		err = setFlagFromConfig(flags, flag, configFlags[name])
+		if err != nil {
+			return errors.Errorf("Invalid value for flag %s in configuration file %s: %v", name, configFilename, err)
+		}
+	}
+	return nil
+}
+
+/*
+ * A value equal to the flag's default is not set, so that a file that spells
+ * out defaults, such as "no-compression: false", does not conflict with flags
+ * that cannot be set together with them.
+ */
+func setFlagFromConfig(flags *pflag.FlagSet, flag *pflag.Flag, value interface{}) error {
+	values, isList := value.([]interface{})
+	if !isList {
+		if value == nil || fmt.Sprint(value) == flag.DefValue {
+			return nil
+		}
+		values = []interface{}{value}
+	} else if _, isSlice := flag.Value.(pflag.SliceValue); !isSlice {
+		return errors.New("flag does not accept a list")
+	}
+	for _, value := range values {
+		err := flags.Set(flag.Name, fmt.Sprint(value))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * Returns the flags that are set, whether on the command line or from a
+ * configuration file, in the form they would be passed on the command line.
+ */
+func GetEffectiveFlags(flags *pflag.FlagSet) []string {
+	effectiveFlags := make([]string, 0)
+	flags.Visit(func(flag *pflag.Flag) {
+		if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range sliceValue.GetSlice() {
+				effectiveFlags = append(effectiveFlags, fmt.Sprintf("--%s %s", flag.Name, value))
+			}
+		} else if flag.Value.Type() == "bool" && flag.Value.String() == "true" {
+			effectiveFlags = append(effectiveFlags, fmt.Sprintf("--%s", flag.Name))
+		} else {
+			effectiveFlags = append(effectiveFlags, fmt.Sprintf("--%s %s", flag.Name, flag.Value.String()))
+		}
+	})
+	return effectiveFlags
+}
@@ -0,0 +1,106 @@
+package options_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/spf13/pflag"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("options/config tests", func() {
+	var (
+		flagSet            *pflag.FlagSet
+		configFileContents string
+	)
+	defaultConfigFileContents := `jobs: 4
+compression-type: gzip
+backup-dir: /data/backups
+profiles:
+  nightly:
+    jobs: 8
+    include-schema: [schema1, schema2]
+    with-stats: true
+  weekly-full:
+    leaf-partition-data: true
+`
+	BeforeEach(func() {
+		flagSet = pflag.NewFlagSet("gpbackup", pflag.ContinueOnError)
+		options.SetBackupFlagDefaults(flagSet)
+		configFileContents = defaultConfigFileContents
+		operating.System.ReadFile = func(filename string) ([]byte, error) { return []byte(configFileContents), nil }
+	})
+	AfterEach(func() {
+		operating.InitializeSystemFunctions()
+	})
+	Describe("ApplyConfigFile", func() {
+		It("does nothing without --config", func() {
+			Expect(flagSet.Parse([]string{"--jobs", "2"})).To(Succeed())
+
+			Expect(options.ApplyConfigFile(flagSet)).To(Succeed())
+			Expect(options.GetEffectiveFlags(flagSet)).To(Equal([]string{"--jobs 2"}))
+		})
+		It("sets the top-level flags in the file", func() {
+			Expect(flagSet.Parse([]string{"--config", "gpbackup.yaml", "--dbname", "testdb"})).To(Succeed())
+
+			Expect(options.ApplyConfigFile(flagSet)).To(Succeed())
+			Expect(options.MustGetFlagInt(flagSet, options.JOBS)).To(Equal(4))
+			Expect(options.MustGetFlagString(flagSet, options.BACKUP_DIR)).To(Equal("/data/backups"))
+			Expect(flagSet.Changed(options.COMPRESSION_TYPE)).To(BeFalse())
+			Expect(flagSet.Changed(options.INCLUDE_SCHEMA)).To(BeFalse())
+		})
+		It("overrides the top-level flags with the flags of the profile", func() {
+			Expect(flagSet.Parse([]string{"--config", "gpbackup.yaml", "--profile", "nightly", "--dbname", "testdb"})).To(Succeed())
+
+			Expect(options.ApplyConfigFile(flagSet)).To(Succeed())
+			Expect(options.GetEffectiveFlags(flagSet)).To(Equal([]string{
+				"--backup-dir /data/backups",
+				"--config gpbackup.yaml",
+				"--dbname testdb",
+				"--include-schema schema1",
+				"--include-schema schema2",
+				"--jobs 8",
+				"--profile nightly",
+				"--with-stats",
+			}))
+		})
+		It("does not override flags on the command line", func() {
+			Expect(flagSet.Parse([]string{"--config", "gpbackup.yaml", "--profile", "nightly", "--jobs", "2", "--include-schema", "schema3"})).To(Succeed())
+
+			Expect(options.ApplyConfigFile(flagSet)).To(Succeed())
+			Expect(options.MustGetFlagInt(flagSet, options.JOBS)).To(Equal(2))
+			Expect(options.MustGetFlagStringArray(flagSet, options.INCLUDE_SCHEMA)).To(Equal([]string{"schema3"}))
+		})
+		It("returns an error for --profile without --config", func() {
+			Expect(flagSet.Parse([]string{"--profile", "nightly"})).To(Succeed())
+
+			Expect(options.ApplyConfigFile(flagSet)).To(MatchError("--profile must be specified with --config"))
+		})
+		It("returns an error for a profile that is not in the file", func() {
+			Expect(flagSet.Parse([]string{"--config", "gpbackup.yaml", "--profile", "monthly"})).To(Succeed())
+
+			Expect(options.ApplyConfigFile(flagSet)).To(MatchError("Profile monthly not found in configuration file gpbackup.yaml"))
+		})
+		It("returns an error for a flag that does not exist", func() {
+			configFileContents = "timestamp: 20170101010101\n"
+			Expect(flagSet.Parse([]string{"--config", "gpbackup.yaml"})).To(Succeed())
+
+			Expect(options.ApplyConfigFile(flagSet)).To(MatchError("Unrecognized flag timestamp in configuration file gpbackup.yaml"))
+		})
+		It("returns an error for a list given to a flag that takes a single value", func() {
+			configFileContents = "jobs: [1, 2]\n"
+			Expect(flagSet.Parse([]string{"--config", "gpbackup.yaml"})).To(Succeed())
+
+			Expect(options.ApplyConfigFile(flagSet)).To(MatchError("Invalid value for flag jobs in configuration file gpbackup.yaml: flag does not accept a list"))
+		})
+		It("returns an error for a value of the wrong type", func() {
+			configFileContents = "jobs: many\n"
+			Expect(flagSet.Parse([]string{"--config", "gpbackup.yaml"})).To(Succeed())
+
+			err := options.ApplyConfigFile(flagSet)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Invalid value for flag jobs in configuration file gpbackup.yaml"))
+		})
+	})
+})
@@ -16,14 +16,18 @@ import (
 const (
 	BACKUP_DIR            = "backup-dir"
 	COMPRESSION_LEVEL     = "compression-level"
+	COMPRESSION_TYPE      = "compression-type"
+	CONFIG                = "config"
 	DATA_ONLY             = "data-only"
 	DBNAME                = "dbname"
 	DEBUG                 = "debug"
+	EXCLUDE_OBJECT_TYPE   = "exclude-object-type"
 	EXCLUDE_RELATION      = "exclude-table"
 	EXCLUDE_RELATION_FILE = "exclude-table-file"
 	EXCLUDE_SCHEMA        = "exclude-schema"
 	EXCLUDE_SCHEMA_FILE   = "exclude-schema-file"
 	FROM_TIMESTAMP        = "from-timestamp"
+	INCLUDE_OBJECT_TYPE   = "include-object-type"
 	INCLUDE_RELATION      = "include-table"
 	INCLUDE_RELATION_FILE = "include-table-file"
 	INCLUDE_SCHEMA        = "include-schema"
@@ -32,6 +36,7 @@ const (
 	JOBS                  = "jobs"
 	LEAF_PARTITION_DATA   = "leaf-partition-data"
 	METADATA_ONLY         = "metadata-only"
+	METRICS_DIR           = "metrics-dir"
 	NO_COMPRESSION        = "no-compression"
 	PLUGIN_CONFIG         = "plugin-config"
 	QUIET                 = "quiet"
@@ -44,35 +49,67 @@ const (
 	TIMESTAMP             = "timestamp"
 	WITH_GLOBALS          = "with-globals"
 	REDIRECT_SCHEMA       = "redirect-schema"
+	REDIRECT_TABLE_FILE   = "redirect-table-file"
 	TRUNCATE_TABLE        = "truncate-table"
 	WITHOUT_GLOBALS       = "without-globals"
+	SHOW_DELETED          = "show-deleted"
+	KEEP_LAST             = "keep-last"
+	KEEP_DAYS             = "keep-days"
+	KEEP_FULL             = "keep-full"
+	DRY_RUN               = "dry-run"
+	VERIFY_ONLY           = "verify-only"
+	RESIZE_CLUSTER        = "resize-cluster"
+	RESUME                = "resume"
+	ENCRYPT               = "encrypt"
+	ENCRYPTION_KEY_FILE   = "encryption-key-file"
+	TABLE_PREDICATE_FILE  = "table-predicate-file"
+	MASKING_POLICY_FILE   = "masking-policy-file"
+	PROFILE               = "profile"
+	PRINT_SQL             = "print-sql"
+	PRINT_SQL_FILE        = "print-sql-file"
+	FORMAT                = "format"
+	DEPENDENCY_GRAPH      = "dependency-graph"
+	INCREMENTAL_HEAP      = "incremental-heap"
 )
 
 func SetBackupFlagDefaults(flagSet *pflag.FlagSet) {
 	flagSet.String(BACKUP_DIR, "", "The absolute path of the directory to which all backup files will be written")
-	flagSet.Int(COMPRESSION_LEVEL, 1, "Level of compression to use during data backup. Valid values are between 1 and 9.")
+	flagSet.Int(COMPRESSION_LEVEL, 1, "Level of compression to use during data backup. Valid values are between 1 and 9 for gzip, 1 and 19 for zstd, and 1 and 12 for lz4.")
+	flagSet.String(COMPRESSION_TYPE, "gzip", "Type of compression to use during data backup. Valid values are gzip, zstd, and lz4.")
+	flagSet.String(CONFIG, "", "A YAML file of flag values to use for any flags not specified on the command line")
 	flagSet.Bool(DATA_ONLY, false, "Only back up data, do not back up metadata")
 	flagSet.String(DBNAME, "", "The database to be backed up")
 	flagSet.Bool(DEBUG, false, "Print verbose and debug log messages")
+	flagSet.String(DEPENDENCY_GRAPH, "", "The path, without a file extension, at which to write the dependency graph of the backed up objects as <path>.dot and <path>.json, and a report of the dependency cycles that were broken as <path>_cycles.txt")
+	flagSet.Bool(ENCRYPT, false, "Encrypt the data and metadata files with AES-256-GCM, using the key in --encryption-key-file or the GPBACKUP_ENCRYPTION_KEY environment variable")
+	flagSet.String(ENCRYPTION_KEY_FILE, "", "A file containing the encryption key as 64 hexadecimal characters.  Implies --encrypt.")
+	flagSet.StringArray(EXCLUDE_OBJECT_TYPE, []string{}, "Back up all metadata except objects of the specified type(s), such as TRIGGER or \"EVENT TRIGGER\". --exclude-object-type can be specified multiple times.")
 	flagSet.StringArray(EXCLUDE_SCHEMA, []string{}, "Back up all metadata except objects in the specified schema(s). --exclude-schema can be specified multiple times.")
 	flagSet.String(EXCLUDE_SCHEMA_FILE, "", "A file containing a list of schemas to be excluded from the backup")
 	flagSet.StringArray(EXCLUDE_RELATION, []string{}, "Back up all metadata except the specified table(s). --exclude-table can be specified multiple times.")
 	flagSet.String(EXCLUDE_RELATION_FILE, "", "A file containing a list of fully-qualified tables to be excluded from the backup")
 	flagSet.String(FROM_TIMESTAMP, "", "A timestamp to use to base the current incremental backup off")
 	flagSet.Bool("help", false, "Help for gpbackup")
+	flagSet.StringArray(INCLUDE_OBJECT_TYPE, []string{}, "Back up only metadata of the specified object type(s), such as FUNCTION or VIEW, along with all tables. --include-object-type can be specified multiple times.")
 	flagSet.StringArray(INCLUDE_SCHEMA, []string{}, "Back up only the specified schema(s). --include-schema can be specified multiple times.")
 	flagSet.String(INCLUDE_SCHEMA_FILE, "", "A file containing a list of schema(s) to be included in the backup")
 	flagSet.StringArray(INCLUDE_RELATION, []string{}, "Back up only the specified table(s). --include-table can be specified multiple times.")
 	flagSet.String(INCLUDE_RELATION_FILE, "", "A file containing a list of fully-qualified tables to be included in the backup")
 	flagSet.Bool(INCREMENTAL, false, "Only back up data for AO tables that have been modified since the last backup")
+	flagSet.Bool(INCREMENTAL_HEAP, false, "Record change counters for heap tables, and with --incremental, only back up data for heap tables whose counters changed since the last backup")
 	flagSet.Int(JOBS, 1, "The number of parallel connections to use when backing up data")
 	flagSet.Bool(LEAF_PARTITION_DATA, false, "For partition tables, create one data file per leaf partition instead of one data file for the whole table")
+	flagSet.String(MASKING_POLICY_FILE, "", "A file of lines in the form \"schema.table.column: transform\", each masking the data of a column with the transform null, constant <value>, hash, redact [n], or fake")
 	flagSet.Bool(METADATA_ONLY, false, "Only back up metadata, do not back up data")
+	flagSet.String(METRICS_DIR, "", "The directory in which to write a Prometheus metrics file for the node_exporter textfile collector")
 	flagSet.Bool(NO_COMPRESSION, false, "Disable compression of data files")
 	flagSet.String(PLUGIN_CONFIG, "", "The configuration file to use for a plugin")
+	flagSet.String(PROFILE, "", "The profile in the --config file whose flag values override the file's top-level flag values")
 	flagSet.Bool("version", false, "Print version number and exit")
 	flagSet.Bool(QUIET, false, "Suppress non-warning, non-error log messages")
+	flagSet.String(RESUME, "", "The timestamp of a failed or interrupted backup to resume, in the format YYYYMMDDHHMMSS.  Only the data of tables that were not completed is backed up.")
 	flagSet.Bool(SINGLE_DATA_FILE, false, "Back up all data to a single file instead of one per table")
+	flagSet.String(TABLE_PREDICATE_FILE, "", "A file of lines in the form \"schema.table: predicate\", each backing up only the rows of the table that match the predicate")
 	flagSet.Bool(VERBOSE, false, "Print verbose log messages")
 	flagSet.Bool(WITH_STATS, false, "Back up query plan statistics")
 	flagSet.Bool(WITHOUT_GLOBALS, false, "Disable backup of global metadata")
@@ -80,36 +117,63 @@ func SetBackupFlagDefaults(flagSet *pflag.FlagSet) {
 
 func SetRestoreFlagDefaults(flagSet *pflag.FlagSet) {
 	flagSet.String(BACKUP_DIR, "", "The absolute path of the directory in which the backup files to be restored are located")
+	flagSet.String(CONFIG, "", "A YAML file of flag values to use for any flags not specified on the command line")
 	flagSet.Bool(CREATE_DB, false, "Create the database before metadata restore")
 	flagSet.Bool(DATA_ONLY, false, "Only restore data, do not restore metadata")
 	flagSet.Bool(DEBUG, false, "Print verbose and debug log messages")
+	flagSet.String(ENCRYPTION_KEY_FILE, "", "A file containing the key of an encrypted backup.  If not specified, the key is read from the GPBACKUP_ENCRYPTION_KEY environment variable.")
+	flagSet.StringArray(EXCLUDE_OBJECT_TYPE, []string{}, "Restore all metadata except objects of the specified type(s), such as TRIGGER or \"EVENT TRIGGER\". --exclude-object-type can be specified multiple times.")
 	flagSet.StringArray(EXCLUDE_SCHEMA, []string{}, "Restore all metadata except objects in the specified schema(s). --exclude-schema can be specified multiple times.")
 	flagSet.String(EXCLUDE_SCHEMA_FILE, "", "A file containing a list of schemas that will not be restored")
 	flagSet.StringArray(EXCLUDE_RELATION, []string{}, "Restore all metadata except the specified relation(s). --exclude-table can be specified multiple times.")
 	flagSet.String(EXCLUDE_RELATION_FILE, "", "A file containing a list of fully-qualified relation(s) that will not be restored")
 	flagSet.Bool("help", false, "Help for gprestore")
+	flagSet.StringArray(INCLUDE_OBJECT_TYPE, []string{}, "Restore only metadata of the specified object type(s), such as FUNCTION or VIEW. --include-object-type can be specified multiple times.")
 	flagSet.StringArray(INCLUDE_SCHEMA, []string{}, "Restore only the specified schema(s). --include-schema can be specified multiple times.")
 	flagSet.String(INCLUDE_SCHEMA_FILE, "", "A file containing a list of schemas that will be restored")
 	flagSet.StringArray(INCLUDE_RELATION, []string{}, "Restore only the specified relation(s). --include-table can be specified multiple times.")
 	flagSet.String(INCLUDE_RELATION_FILE, "", "A file containing a list of fully-qualified relation(s) that will be restored")
 	flagSet.Bool(INCREMENTAL, false, "BETA FEATURE: Only restore data for all heap tables and only AO tables that have been modified since the last backup")
 	flagSet.Bool(METADATA_ONLY, false, "Only restore metadata, do not restore data")
-	flagSet.Int(JOBS, 1, "Number of parallel connections to use when restoring table data and post-data")
+	flagSet.String(METRICS_DIR, "", "The directory in which to write a Prometheus metrics file for the node_exporter textfile collector")
+	flagSet.Int(JOBS, 1, "Number of parallel connections to use when restoring pre-data, table data, and post-data")
 	flagSet.Bool(ON_ERROR_CONTINUE, false, "Log errors and continue restore, instead of exiting on first error")
 	flagSet.String(PLUGIN_CONFIG, "", "The configuration file to use for a plugin")
+	flagSet.Bool(PRINT_SQL, false, "Print the SQL that the restore would run to stdout instead of restoring, without connecting to the database")
+	flagSet.String(PRINT_SQL_FILE, "", "Write the SQL that the restore would run to the specified file instead of restoring.  Implies --print-sql.")
+	flagSet.String(PROFILE, "", "The profile in the --config file whose flag values override the file's top-level flag values")
 	flagSet.Bool("version", false, "Print version number and exit")
 	flagSet.Bool(QUIET, false, "Suppress non-warning, non-error log messages")
 	flagSet.String(REDIRECT_DB, "", "Restore to the specified database instead of the database that was backed up")
 	flagSet.String(REDIRECT_SCHEMA, "", "Restore to the specified schema instead of the schema that was backed up")
+	flagSet.String(REDIRECT_TABLE_FILE, "", "A file of lines in the form \"schema.table -> new_schema.new_table\", each restoring a table and the objects that depend on it under a new name")
+	flagSet.Bool(RESIZE_CLUSTER, false, "Restore data to a cluster with a different number of segments than the backup cluster")
+	flagSet.Bool(RESUME, false, "Resume a failed or interrupted restore, skipping the tables and statements its restore journal records as completed")
 	flagSet.Bool(WITH_GLOBALS, false, "Restore global metadata")
 	flagSet.String(TIMESTAMP, "", "The timestamp to be restored, in the format YYYYMMDDHHMMSS")
 	flagSet.Bool(TRUNCATE_TABLE, false, "Removes data of the tables getting restored")
 	flagSet.Bool(VERBOSE, false, "Print verbose log messages")
+	flagSet.Bool(VERIFY_ONLY, false, "Verify the backup files against the checksums recorded at backup time, without restoring anything")
 	flagSet.Bool(WITH_STATS, false, "Restore query plan statistics")
 	flagSet.Bool(LEAF_PARTITION_DATA, false, "For partition tables, create one data file per leaf partition instead of one data file for the whole table")
 	_ = flagSet.MarkHidden(LEAF_PARTITION_DATA)
 }
 
+func SetCatalogFlagDefaults(flagSet *pflag.FlagSet) {
+	flagSet.String(DBNAME, "", "Only operate on backups of the specified database")
+	flagSet.Bool(DEBUG, false, "Print verbose and debug log messages")
+	flagSet.Bool(DRY_RUN, false, "Print the backups that would be pruned without deleting them")
+	flagSet.String(ENCRYPTION_KEY_FILE, "", "When comparing or consolidating encrypted backups, a file containing their key.  If not specified, the key is read from the GPBACKUP_ENCRYPTION_KEY environment variable.")
+	flagSet.String(FORMAT, "text", "The output format of diff, either text or json")
+	flagSet.Int(KEEP_DAYS, 0, "When pruning, keep all backups taken within the specified number of days")
+	flagSet.Int(KEEP_FULL, 0, "When pruning, keep the specified number of most recent full backups of each database")
+	flagSet.Int(KEEP_LAST, 0, "When pruning, keep the specified number of most recent backups of each database")
+	flagSet.String(PLUGIN_CONFIG, "", "The configuration file to use for a plugin")
+	flagSet.Bool(QUIET, false, "Suppress non-warning, non-error log messages")
+	flagSet.Bool(SHOW_DELETED, false, "Include backups that have already been deleted")
+	flagSet.Bool(VERBOSE, false, "Print verbose log messages")
+}
+
 /*
  * Functions for validating whether flags are set and in what combination
  */
@@ -0,0 +1,107 @@
+package options
+
+/*
+ * This file contains functions related to filtering backups and restores by
+ * the type of object, using the object types recorded in the table of contents.
+ */
+
+import (
+	"strings"
+
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+var FilterableObjectTypes = []string{
+	"AGGREGATE",
+	"CAST",
+	"COLLATION",
+	"CONSTRAINT",
+	"CONVERSION",
+	"DEFAULT PRIVILEGES",
+	"DOMAIN",
+	"EVENT TRIGGER",
+	"EXTENSION",
+	"FOREIGN DATA WRAPPER",
+	"FOREIGN SERVER",
+	"FOREIGN TABLE",
+	"FUNCTION",
+	"INDEX",
+	"LANGUAGE",
+	"MATERIALIZED VIEW",
+	"OPERATOR",
+	"OPERATOR CLASS",
+	"OPERATOR FAMILY",
+	"PROTOCOL",
+	"RESOURCE GROUP",
+	"RESOURCE QUEUE",
+	"ROLE",
+	"ROLE GRANT",
+	"ROLE GUCS",
+	"RULE",
+	"SCHEMA",
+	"SEQUENCE",
+	"TABLE",
+	"TABLESPACE",
+	"TEXT SEARCH CONFIGURATION",
+	"TEXT SEARCH DICTIONARY",
+	"TEXT SEARCH PARSER",
+	"TEXT SEARCH TEMPLATE",
+	"TRIGGER",
+	"TYPE",
+	"USER MAPPING",
+	"VIEW",
+}
+
+// Statements of these types are filtered along with the object they modify
+var parentObjectTypes = map[string]string{
+	"EXCHANGE PARTITION": "TABLE",
+	"SEQUENCE OWNER":     "SEQUENCE",
+}
+
+/*
+ * Object types are case-insensitive and may be given with any amount of
+ * whitespace between words, so "event  trigger" is the same as "EVENT TRIGGER".
+ */
+func getObjectTypesFromFlag(initialFlags *pflag.FlagSet, filterFlag string) ([]string, error) {
+	if initialFlags.Lookup(filterFlag) == nil {
+		return []string{}, nil
+	}
+	objectTypes, err := initialFlags.GetStringArray(filterFlag)
+	if err != nil {
+		return nil, err
+	}
+	normalizedTypes := make([]string, 0, len(objectTypes))
+	for _, objectType := range objectTypes {
+		normalizedType := strings.Join(strings.Fields(strings.ToUpper(objectType)), " ")
+		if !utils.Exists(FilterableObjectTypes, normalizedType) {
+			return nil, errors.Errorf("Invalid object type %s for --%s.  Valid object types are: %s", objectType, filterFlag, strings.Join(FilterableObjectTypes, ", "))
+		}
+		if !utils.Exists(normalizedTypes, normalizedType) {
+			normalizedTypes = append(normalizedTypes, normalizedType)
+		}
+	}
+	return normalizedTypes, nil
+}
+
+/*
+ * Objects whose type cannot be filtered on, such as the database itself and
+ * session GUCs, always match the filter.
+ */
+func ObjectTypeMatchesFilter(includedTypes []string, excludedTypes []string, objectType string) bool {
+	if parentType, ok := parentObjectTypes[objectType]; ok {
+		objectType = parentType
+	}
+	if !utils.Exists(FilterableObjectTypes, objectType) {
+		return true
+	}
+	if len(includedTypes) > 0 {
+		return utils.Exists(includedTypes, objectType)
+	}
+	return !utils.Exists(excludedTypes, objectType)
+}
+
+func (o Options) ShouldIncludeObjectType(objectType string) bool {
+	return ObjectTypeMatchesFilter(o.IncludedObjectTypes, o.ExcludedObjectTypes, objectType)
+}
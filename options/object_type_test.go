@@ -0,0 +1,57 @@
+package options_test
+
+import (
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/spf13/pflag"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("options/object_type tests", func() {
+	var flagSet *pflag.FlagSet
+	BeforeEach(func() {
+		flagSet = pflag.NewFlagSet("gprestore", pflag.ContinueOnError)
+		options.SetRestoreFlagDefaults(flagSet)
+	})
+	Describe("NewOptions", func() {
+		It("normalizes the case and whitespace of object types and removes duplicates", func() {
+			Expect(flagSet.Parse([]string{"--exclude-object-type", "trigger", "--exclude-object-type", "Event  Trigger", "--exclude-object-type", "TRIGGER"})).To(Succeed())
+
+			opts, err := options.NewOptions(flagSet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opts.ExcludedObjectTypes).To(Equal([]string{"TRIGGER", "EVENT TRIGGER"}))
+			Expect(opts.IncludedObjectTypes).To(BeEmpty())
+		})
+		It("returns an error for an object type that cannot be filtered on", func() {
+			Expect(flagSet.Parse([]string{"--include-object-type", "SESSION GUCS"})).To(Succeed())
+
+			_, err := options.NewOptions(flagSet)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Invalid object type SESSION GUCS for --include-object-type.  Valid object types are: AGGREGATE, CAST,"))
+		})
+	})
+	Describe("ObjectTypeMatchesFilter", func() {
+		It("matches only the included object types", func() {
+			included := []string{"FUNCTION", "VIEW"}
+			Expect(options.ObjectTypeMatchesFilter(included, []string{}, "FUNCTION")).To(BeTrue())
+			Expect(options.ObjectTypeMatchesFilter(included, []string{}, "TRIGGER")).To(BeFalse())
+		})
+		It("matches all but the excluded object types", func() {
+			excluded := []string{"TRIGGER", "RULE"}
+			Expect(options.ObjectTypeMatchesFilter([]string{}, excluded, "TRIGGER")).To(BeFalse())
+			Expect(options.ObjectTypeMatchesFilter([]string{}, excluded, "INDEX")).To(BeTrue())
+		})
+		It("matches everything when there is no filter", func() {
+			Expect(options.ObjectTypeMatchesFilter([]string{}, []string{}, "TRIGGER")).To(BeTrue())
+		})
+		It("always matches object types that cannot be filtered on", func() {
+			Expect(options.ObjectTypeMatchesFilter([]string{"FUNCTION"}, []string{}, "SESSION GUCS")).To(BeTrue())
+			Expect(options.ObjectTypeMatchesFilter([]string{"FUNCTION"}, []string{}, "DATABASE")).To(BeTrue())
+		})
+		It("filters statements that modify an object along with the object", func() {
+			Expect(options.ObjectTypeMatchesFilter([]string{}, []string{"SEQUENCE"}, "SEQUENCE OWNER")).To(BeFalse())
+			Expect(options.ObjectTypeMatchesFilter([]string{"TABLE"}, []string{}, "EXCHANGE PARTITION")).To(BeTrue())
+		})
+	})
+})
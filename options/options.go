@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gp-common-go-libs/iohelper"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/pkg/errors"
@@ -20,8 +21,12 @@ type Options struct {
 	isLeafPartitionData       bool
 	ExcludedSchemas           []string
 	IncludedSchemas           []string
+	IncludedObjectTypes       []string
+	ExcludedObjectTypes       []string
 	originalIncludedRelations []string
 	RedirectSchema            string
+	RedirectTables            map[string]string
+	TablePredicates           map[string]string
 }
 
 func NewOptions(initialFlags *pflag.FlagSet) (*Options, error) {
@@ -33,6 +38,10 @@ func NewOptions(initialFlags *pflag.FlagSet) (*Options, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = utils.ValidateFilterPatterns(includedRelations)
+	if err != nil {
+		return nil, err
+	}
 
 	excludedRelations, err := setFiltersFromFile(initialFlags, EXCLUDE_RELATION, EXCLUDE_RELATION_FILE)
 	if err != nil {
@@ -42,16 +51,38 @@ func NewOptions(initialFlags *pflag.FlagSet) (*Options, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = utils.ValidateFilterPatterns(excludedRelations)
+	if err != nil {
+		return nil, err
+	}
 
 	includedSchemas, err := setFiltersFromFile(initialFlags, INCLUDE_SCHEMA, INCLUDE_SCHEMA_FILE)
 	if err != nil {
 		return nil, err
 	}
+	err = utils.ValidateFilterPatterns(includedSchemas)
+	if err != nil {
+		return nil, err
+	}
 
 	excludedSchemas, err := setFiltersFromFile(initialFlags, EXCLUDE_SCHEMA, EXCLUDE_SCHEMA_FILE)
 	if err != nil {
 		return nil, err
 	}
+	err = utils.ValidateFilterPatterns(excludedSchemas)
+	if err != nil {
+		return nil, err
+	}
+
+	includedObjectTypes, err := getObjectTypesFromFlag(initialFlags, INCLUDE_OBJECT_TYPE)
+	if err != nil {
+		return nil, err
+	}
+
+	excludedObjectTypes, err := getObjectTypesFromFlag(initialFlags, EXCLUDE_OBJECT_TYPE)
+	if err != nil {
+		return nil, err
+	}
 
 	leafPartitionData, err := initialFlags.GetBool(LEAF_PARTITION_DATA)
 	if err != nil {
@@ -66,17 +97,114 @@ func NewOptions(initialFlags *pflag.FlagSet) (*Options, error) {
 		}
 	}
 
+	redirectTables := make(map[string]string)
+	if initialFlags.Lookup(REDIRECT_TABLE_FILE) != nil {
+		redirectTables, err = setRedirectTablesFromFile(initialFlags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tablePredicates := make(map[string]string)
+	if initialFlags.Lookup(TABLE_PREDICATE_FILE) != nil {
+		tablePredicates, err = setTablePredicatesFromFile(initialFlags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &Options{
 		IncludedRelations:         includedRelations,
 		ExcludedRelations:         excludedRelations,
 		IncludedSchemas:           includedSchemas,
 		ExcludedSchemas:           excludedSchemas,
+		IncludedObjectTypes:       includedObjectTypes,
+		ExcludedObjectTypes:       excludedObjectTypes,
 		isLeafPartitionData:       leafPartitionData,
 		originalIncludedRelations: includedRelations,
 		RedirectSchema:            redirectSchema,
+		RedirectTables:            redirectTables,
+		TablePredicates:           tablePredicates,
 	}, nil
 }
 
+/*
+ * Each line of the redirect table file maps a table in the backup to the name
+ * it is restored under, in the form "schema.table -> new_schema.new_table".
+ */
+func setRedirectTablesFromFile(initialFlags *pflag.FlagSet) (map[string]string, error) {
+	redirectTables := make(map[string]string)
+	filename, err := initialFlags.GetString(REDIRECT_TABLE_FILE)
+	if err != nil || filename == "" {
+		return redirectTables, err
+	}
+	lines, err := iohelper.ReadLinesFromFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	targetTables := make(map[string]bool)
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.Split(line, "->")
+		if len(parts) != 2 {
+			return nil, errors.Errorf(`Invalid line in redirect table file %s: %s.  Lines must be in the form "schema.table -> new_schema.new_table".`, filename, line)
+		}
+		sourceTable := strings.TrimSpace(parts[0])
+		targetTable := strings.TrimSpace(parts[1])
+		_, err = SeparateSchemaAndTable([]string{sourceTable, targetTable})
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := redirectTables[sourceTable]; ok {
+			return nil, errors.Errorf("Table %s is redirected more than once in redirect table file %s", sourceTable, filename)
+		}
+		if targetTables[targetTable] {
+			return nil, errors.Errorf("More than one table is redirected to %s in redirect table file %s", targetTable, filename)
+		}
+		redirectTables[sourceTable] = targetTable
+		targetTables[targetTable] = true
+	}
+	return redirectTables, nil
+}
+
+/*
+ * Each line of the table predicate file attaches a predicate to a table, in
+ * the form "schema.table: predicate", so that only the rows of the table that
+ * match the predicate are backed up.
+ */
+func setTablePredicatesFromFile(initialFlags *pflag.FlagSet) (map[string]string, error) {
+	tablePredicates := make(map[string]string)
+	filename, err := initialFlags.GetString(TABLE_PREDICATE_FILE)
+	if err != nil || filename == "" {
+		return tablePredicates, err
+	}
+	lines, err := iohelper.ReadLinesFromFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		table := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, errors.Errorf(`Invalid line in table predicate file %s: %s.  Lines must be in the form "schema.table: predicate".`, filename, line)
+		}
+		_, err = SeparateSchemaAndTable([]string{table})
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := tablePredicates[table]; ok {
+			return nil, errors.Errorf("Table %s has more than one predicate in table predicate file %s", table, filename)
+		}
+		tablePredicates[table] = strings.TrimSpace(parts[1])
+	}
+	return tablePredicates, nil
+}
+
 func setFiltersFromFile(initialFlags *pflag.FlagSet, filterFlag string, filterFileFlag string) ([]string, error) {
 	filters, err := initialFlags.GetStringArray(filterFlag)
 	if err != nil {
@@ -246,12 +374,117 @@ func (o *Options) ExpandIncludesForPartitions(conn *dbconn.DBConn, flags *pflag.
 	return nil
 }
 
+// Patterns are left as they are, to be expanded to quoted names later
 func (o *Options) QuoteIncludeRelations(conn *dbconn.DBConn) error {
+	names := make([]string, 0, len(o.IncludedRelations))
+	for _, relation := range o.IncludedRelations {
+		if !utils.IsFilterPattern(relation) {
+			names = append(names, relation)
+		}
+	}
+	quotedNames, err := QuoteTableNames(conn, names)
+	if err != nil {
+		return err
+	}
+	quotedRelations := make([]string, 0, len(o.IncludedRelations))
+	for _, relation := range o.IncludedRelations {
+		if utils.IsFilterPattern(relation) {
+			quotedRelations = append(quotedRelations, relation)
+		} else {
+			quotedRelations = append(quotedRelations, quotedNames[0])
+			quotedNames = quotedNames[1:]
+		}
+	}
+	o.IncludedRelations = quotedRelations
+
+	return nil
+}
+
+func (o Options) HasFilterPatterns() bool {
+	for _, filterList := range [][]string{o.IncludedRelations, o.ExcludedRelations, o.IncludedSchemas, o.ExcludedSchemas} {
+		for _, filter := range filterList {
+			if utils.IsFilterPattern(filter) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+ * Replaces the glob and regular expression patterns in the table and schema
+ * filters with the names of the tables and schemas they match, and sets the
+ * filter flags to the expanded filters.  The candidates map the unquoted name
+ * of each table or schema that a pattern may match to the name it is filtered
+ * by.  An include pattern that matches nothing is an error, while an exclude
+ * pattern that matches nothing only raises a warning, as with exact names.
+ */
+func (o *Options) ExpandFilterPatterns(flags *pflag.FlagSet, relations map[string]string, schemas map[string]string) error {
 	var err error
-	o.IncludedRelations, err = QuoteTableNames(conn, o.GetIncludedTables())
+	o.IncludedRelations, err = expandFilterList(flags, INCLUDE_RELATION, o.IncludedRelations, relations, "tables")
+	if err != nil {
+		return err
+	}
+	o.originalIncludedRelations = o.IncludedRelations
+	o.ExcludedRelations, err = expandFilterList(flags, EXCLUDE_RELATION, o.ExcludedRelations, relations, "tables")
+	if err != nil {
+		return err
+	}
+	o.IncludedSchemas, err = expandFilterList(flags, INCLUDE_SCHEMA, o.IncludedSchemas, schemas, "schemas")
 	if err != nil {
 		return err
 	}
+	o.ExcludedSchemas, err = expandFilterList(flags, EXCLUDE_SCHEMA, o.ExcludedSchemas, schemas, "schemas")
+	return err
+}
+
+func expandFilterList(flags *pflag.FlagSet, filterFlag string, filterList []string, candidates map[string]string, objectKind string) ([]string, error) {
+	expanded, unmatched, err := utils.ExpandFilterPatterns(filterList, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(unmatched) > 0 {
+		if filterFlag == INCLUDE_RELATION || filterFlag == INCLUDE_SCHEMA {
+			return nil, errors.Errorf("No %s match the following --%s pattern(s): %s", objectKind, filterFlag, strings.Join(unmatched, ", "))
+		}
+		gplog.Warn("No %s match the following --%s pattern(s): %s", objectKind, filterFlag, strings.Join(unmatched, ", "))
+	}
+	if flags != nil && flags.Lookup(filterFlag) != nil {
+		err = flags.Lookup(filterFlag).Value.(pflag.SliceValue).Replace(expanded)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(expanded) > 0 {
+		gplog.Verbose("Expanded --%s to %s", filterFlag, strings.Join(expanded, ", "))
+	}
+	return expanded, nil
+}
+
+func (o *Options) QuoteRedirectTables(conn *dbconn.DBConn) error {
+	quotedRedirectTables := make(map[string]string, len(o.RedirectTables))
+	for sourceTable, targetTable := range o.RedirectTables {
+		quotedTables, err := QuoteTableNames(conn, []string{sourceTable, targetTable})
+		if err != nil {
+			return err
+		}
+		quotedRedirectTables[quotedTables[0]] = quotedTables[1]
+	}
+	o.RedirectTables = quotedRedirectTables
+
+	return nil
+}
+
+func (o *Options) QuoteTablePredicates(conn *dbconn.DBConn) error {
+	quotedTablePredicates := make(map[string]string, len(o.TablePredicates))
+	for table, predicate := range o.TablePredicates {
+		quotedTables, err := QuoteTableNames(conn, []string{table})
+		if err != nil {
+			return err
+		}
+		quotedTablePredicates[quotedTables[0]] = predicate
+	}
+	o.TablePredicates = quotedTablePredicates
 
 	return nil
 }
@@ -136,6 +136,20 @@ var _ = Describe("options", func() {
 			_, err = options.NewOptions(myflags)
 			Expect(err).To(HaveOccurred())
 		})
+		It("returns an error for an invalid pattern", func() {
+			err := myflags.Set(options.EXCLUDE_SCHEMA, "re:tmp_(")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = options.NewOptions(myflags)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Invalid regular expression in filter re:tmp_("))
+		})
+		It("accepts a regular expression containing dots as a table filter", func() {
+			err := myflags.Set(options.INCLUDE_RELATION, `re:staging\.tmp_.*`)
+			Expect(err).ToNot(HaveOccurred())
+			subject, err := options.NewOptions(myflags)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subject.HasFilterPatterns()).To(BeTrue())
+		})
 		Describe("AddIncludeRelation", func() {
 			It("it adds a relation", func() {
 				subject, err := options.NewOptions(myflags)
@@ -146,6 +160,50 @@ var _ = Describe("options", func() {
 			})
 		})
 	})
+	Describe("ExpandFilterPatterns", func() {
+		relations := map[string]string{"staging.tmp_a": "staging.tmp_a", "staging.tmp_b": "staging.tmp_b", "public.foo": "public.foo"}
+		schemas := map[string]string{"staging": "staging", "public": "public", "scratch_1": "scratch_1"}
+		It("expands the patterns in the filters and their flags", func() {
+			Expect(myflags.Set(options.INCLUDE_RELATION, "glob:staging.tmp_*")).To(Succeed())
+			Expect(myflags.Set(options.INCLUDE_RELATION, "public.foo")).To(Succeed())
+			subject, err := options.NewOptions(myflags)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = subject.ExpandFilterPatterns(myflags, relations, schemas)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subject.GetIncludedTables()).To(Equal([]string{"staging.tmp_a", "staging.tmp_b", "public.foo"}))
+			Expect(subject.GetOriginalIncludedTables()).To(Equal([]string{"staging.tmp_a", "staging.tmp_b", "public.foo"}))
+			Expect(options.MustGetFlagStringArray(myflags, options.INCLUDE_RELATION)).To(Equal([]string{"staging.tmp_a", "staging.tmp_b", "public.foo"}))
+		})
+		It("expands schema patterns", func() {
+			Expect(myflags.Set(options.EXCLUDE_SCHEMA, "re:scratch_[0-9]+")).To(Succeed())
+			subject, err := options.NewOptions(myflags)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = subject.ExpandFilterPatterns(myflags, relations, schemas)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subject.GetExcludedSchemas()).To(Equal([]string{"scratch_1"}))
+			Expect(options.MustGetFlagStringArray(myflags, options.EXCLUDE_SCHEMA)).To(Equal([]string{"scratch_1"}))
+		})
+		It("returns an error for an include pattern that matches nothing", func() {
+			Expect(myflags.Set(options.INCLUDE_RELATION, "glob:scratch.*")).To(Succeed())
+			subject, err := options.NewOptions(myflags)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = subject.ExpandFilterPatterns(myflags, relations, schemas)
+			Expect(err).To(MatchError("No tables match the following --include-table pattern(s): glob:scratch.*"))
+		})
+		It("removes an exclude pattern that matches nothing", func() {
+			Expect(myflags.Set(options.EXCLUDE_RELATION, "glob:scratch.*")).To(Succeed())
+			subject, err := options.NewOptions(myflags)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = subject.ExpandFilterPatterns(myflags, relations, schemas)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subject.GetExcludedTables()).To(BeEmpty())
+			Expect(options.MustGetFlagStringArray(myflags, options.EXCLUDE_RELATION)).To(BeEmpty())
+		})
+	})
 	Describe("SeparateSchemaAndTable", func() {
 		It("properly splits the strings", func() {
 			tableList := []string{"foo.Bar", "FOO.Bar", "FO!@#.BAR"}
@@ -184,6 +242,116 @@ var _ = Describe("options", func() {
 			Expect(err.Error()).To(ContainSubstring("foobar.baz.bam"))
 		})
 	})
+	Describe("Redirect table file", func() {
+		var (
+			restoreFlags *pflag.FlagSet
+			filename     string
+		)
+		BeforeEach(func() {
+			restoreFlags = &pflag.FlagSet{}
+			options.SetRestoreFlagDefaults(restoreFlags)
+		})
+		AfterEach(func() {
+			_ = os.Remove(filename)
+		})
+		writeRedirectTableFile := func(contents string) {
+			file, err := ioutil.TempFile("/tmp", "gpbackup_test_options*.txt")
+			Expect(err).To(Not(HaveOccurred()))
+			filename = file.Name()
+			_, err = file.WriteString(contents)
+			Expect(err).To(Not(HaveOccurred()))
+			err = file.Close()
+			Expect(err).To(Not(HaveOccurred()))
+			err = restoreFlags.Set(options.REDIRECT_TABLE_FILE, filename)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		It("returns no redirected tables when no file is specified", func() {
+			subject, err := options.NewOptions(restoreFlags)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(subject.RedirectTables).To(BeEmpty())
+		})
+		It("returns the redirected tables in the file", func() {
+			writeRedirectTableFile("public.foo -> scratch.foo_restored\n\npublic.bar->public.bar_copy\n")
+
+			subject, err := options.NewOptions(restoreFlags)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(subject.RedirectTables).To(Equal(map[string]string{
+				"public.foo": "scratch.foo_restored",
+				"public.bar": "public.bar_copy",
+			}))
+		})
+		It("returns an error for a line without a target table", func() {
+			writeRedirectTableFile("public.foo scratch.foo_restored\n")
+
+			_, err := options.NewOptions(restoreFlags)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid line in redirect table file"))
+		})
+		It("returns an error for a table that is not fully qualified", func() {
+			writeRedirectTableFile("public.foo -> foo_restored\n")
+
+			_, err := options.NewOptions(restoreFlags)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("foo_restored"))
+		})
+		It("returns an error for a table that is redirected more than once", func() {
+			writeRedirectTableFile("public.foo -> scratch.foo1\npublic.foo -> scratch.foo2\n")
+
+			_, err := options.NewOptions(restoreFlags)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Table public.foo is redirected more than once"))
+		})
+		It("returns an error for tables that are redirected to the same table", func() {
+			writeRedirectTableFile("public.foo -> scratch.foo\npublic.bar -> scratch.foo\n")
+
+			_, err := options.NewOptions(restoreFlags)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("More than one table is redirected to scratch.foo"))
+		})
+	})
+	Describe("Table predicate file", func() {
+		var filename string
+		AfterEach(func() {
+			_ = os.Remove(filename)
+		})
+		writeTablePredicateFile := func(contents string) {
+			file, err := ioutil.TempFile("/tmp", "gpbackup_test_options*.txt")
+			Expect(err).To(Not(HaveOccurred()))
+			filename = file.Name()
+			_, err = file.WriteString(contents)
+			Expect(err).To(Not(HaveOccurred()))
+			err = file.Close()
+			Expect(err).To(Not(HaveOccurred()))
+			err = myflags.Set(options.TABLE_PREDICATE_FILE, filename)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		It("returns the table predicates in the file", func() {
+			writeTablePredicateFile("sales.events: event_date >= '2024-01-01'::date\n\nsales.orders:region = 'EU'\n")
+
+			subject, err := options.NewOptions(myflags)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(subject.TablePredicates).To(Equal(map[string]string{
+				"sales.events": "event_date >= '2024-01-01'::date",
+				"sales.orders": "region = 'EU'",
+			}))
+		})
+		It("returns an error for a line without a predicate", func() {
+			writeTablePredicateFile("sales.events:\n")
+
+			_, err := options.NewOptions(myflags)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid line in table predicate file"))
+		})
+		It("returns an error for a table with more than one predicate", func() {
+			writeTablePredicateFile("sales.events: id > 1\nsales.events: id < 10\n")
+
+			_, err := options.NewOptions(myflags)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Table sales.events has more than one predicate"))
+		})
+	})
 	Describe("QuoteTableNames", func() {
 		var (
 			conn   *dbconn.DBConn
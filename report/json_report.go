@@ -0,0 +1,199 @@
+package report
+
+/*
+ * This file contains structs and functions related to writing the JSON
+ * backup and restore reports, which hold the same information as the text
+ * reports in a form that monitoring tools can parse.
+ */
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/history"
+)
+
+const (
+	StatusSuccess           = "success"
+	StatusSuccessWithErrors = "success_with_errors"
+	StatusFailure           = "failure"
+)
+
+type TableResult struct {
+	Name            string  `json:"name"`
+	Rows            int64   `json:"rows"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+/*
+ * Tables are backed up and restored by several connections at once, so
+ * their results are collected under a lock.
+ */
+type TableResults struct {
+	lock          sync.Mutex
+	results       []TableResult
+	skippedTables []string
+}
+
+func (tableResults *TableResults) Add(name string, rows int64, duration time.Duration, err error) {
+	result := TableResult{Name: name, Rows: rows, DurationSeconds: duration.Seconds()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	tableResults.lock.Lock()
+	defer tableResults.lock.Unlock()
+	tableResults.results = append(tableResults.results, result)
+}
+
+// Skipped tables are those whose data is not copied, such as external tables
+func (tableResults *TableResults) AddSkipped(name string) {
+	tableResults.lock.Lock()
+	defer tableResults.lock.Unlock()
+	tableResults.skippedTables = append(tableResults.skippedTables, name)
+}
+
+func (tableResults *TableResults) Counts() (completed int, failed int, skipped int) {
+	tableResults.lock.Lock()
+	defer tableResults.lock.Unlock()
+	for _, result := range tableResults.results {
+		if result.Error == "" {
+			completed++
+		} else {
+			failed++
+		}
+	}
+	return completed, failed, len(tableResults.skippedTables)
+}
+
+func (tableResults *TableResults) Sorted() []TableResult {
+	tableResults.lock.Lock()
+	defer tableResults.lock.Unlock()
+	sorted := make([]TableResult, len(tableResults.results))
+	copy(sorted, tableResults.results)
+	sort.Slice(sorted, func(i int, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+/*
+ * Times are written in RFC 3339 format and durations in seconds, and the
+ * backup parameters are the fields of the backup's config file.
+ */
+type JSONReport struct {
+	Utility         string                `json:"utility"`
+	Version         string                `json:"version"`
+	Timestamp       string                `json:"timestamp"`
+	DatabaseName    string                `json:"database_name"`
+	DatabaseVersion string                `json:"database_version"`
+	CommandLine     string                `json:"command_line"`
+	EffectiveFlags  string                `json:"effective_flags,omitempty"`
+	StartTime       string                `json:"start_time"`
+	EndTime         string                `json:"end_time"`
+	DurationSeconds float64               `json:"duration_seconds"`
+	Status          string                `json:"status"`
+	Error           string                `json:"error,omitempty"`
+	PluginVersion   string                `json:"plugin_version,omitempty"`
+	DatabaseSize    string                `json:"database_size,omitempty"`
+	BackupParams    *history.BackupConfig `json:"backup_params,omitempty"`
+	ObjectCounts    map[string]int        `json:"object_counts,omitempty"`
+	PartialTables   []string              `json:"partial_tables,omitempty"`
+	Tables          []TableResult         `json:"tables"`
+}
+
+func (report *Report) WriteBackupJSONReportFile(reportFilename string, timestamp string, endtime time.Time, objectCounts map[string]int, tableResults []TableResult, errMsg string) {
+	start, end, duration := getJSONDurationInfo(timestamp, endtime)
+	status := StatusSuccess
+	if errMsg != "" {
+		status = StatusFailure
+	}
+	backupParams := report.BackupConfig
+	jsonReport := JSONReport{
+		Utility:         "gpbackup",
+		Version:         report.BackupVersion,
+		Timestamp:       timestamp,
+		DatabaseName:    report.DatabaseName,
+		DatabaseVersion: report.DatabaseVersion,
+		CommandLine:     strings.Join(os.Args, " "),
+		EffectiveFlags:  report.EffectiveFlags,
+		StartTime:       start,
+		EndTime:         end,
+		DurationSeconds: duration,
+		Status:          status,
+		Error:           errMsg,
+		PluginVersion:   report.PluginVersion,
+		DatabaseSize:    strings.ToUpper(report.DatabaseSize),
+		BackupParams:    &backupParams,
+		ObjectCounts:    objectCounts,
+		Tables:          tableResults,
+	}
+	writeJSONReportFile(reportFilename, jsonReport)
+}
+
+func WriteRestoreJSONReportFile(reportFilename string, backupTimestamp string, startTimestamp string, connectionPool *dbconn.DBConn, restoreVersion string, backupConfig *history.BackupConfig, tableResults []TableResult, partialTables []string, errMsg string) {
+	start, end, duration := getJSONDurationInfo(startTimestamp, operating.System.Now())
+	status := StatusSuccess
+	if gplog.GetErrorCode() == 1 {
+		status = StatusSuccessWithErrors
+	} else if errMsg != "" {
+		status = StatusFailure
+	}
+	jsonReport := JSONReport{
+		Utility:         "gprestore",
+		Version:         restoreVersion,
+		Timestamp:       backupTimestamp,
+		DatabaseName:    connectionPool.DBName,
+		DatabaseVersion: connectionPool.Version.VersionString,
+		CommandLine:     strings.Join(os.Args, " "),
+		StartTime:       start,
+		EndTime:         end,
+		DurationSeconds: duration,
+		Status:          status,
+		Error:           errMsg,
+		BackupParams:    backupConfig,
+		PartialTables:   partialTables,
+		Tables:          tableResults,
+	}
+	if backupConfig != nil {
+		jsonReport.PluginVersion = backupConfig.PluginVersion
+	}
+	writeJSONReportFile(reportFilename, jsonReport)
+}
+
+func writeJSONReportFile(reportFilename string, jsonReport JSONReport) {
+	if jsonReport.Tables == nil {
+		jsonReport.Tables = []TableResult{}
+	}
+	contents, err := json.MarshalIndent(jsonReport, "", "  ")
+	if err != nil {
+		gplog.Error("Unable to write JSON report file %s: %v", reportFilename, err)
+		return
+	}
+	reportFile, err := iohelper.OpenFileForWriting(reportFilename)
+	if err != nil {
+		gplog.Error("Unable to open JSON report file %s", reportFilename)
+		return
+	}
+	_, err = reportFile.Write(append(contents, '\n'))
+	if err != nil {
+		gplog.Error("Unable to write JSON report file %s", reportFilename)
+		return
+	}
+	err = reportFile.Close()
+	gplog.FatalOnError(err)
+	_ = operating.System.Chmod(reportFilename, 0444)
+}
+
+func getJSONDurationInfo(timestamp string, endTime time.Time) (string, string, float64) {
+	startTime, _ := time.ParseInLocation("20060102150405", timestamp, operating.System.Local)
+	return startTime.Format(time.RFC3339), endTime.Format(time.RFC3339), endTime.Sub(startTime).Truncate(time.Second).Seconds()
+}
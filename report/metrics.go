@@ -0,0 +1,170 @@
+package report
+
+/*
+ * This file contains structs and functions related to writing a Prometheus
+ * metrics file after a backup or restore, for the node_exporter textfile
+ * collector to export.
+ */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+)
+
+var (
+	metricsFilenameRegex = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+	labelValueReplacer   = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+/*
+ * Phases run one after another, so their timings are recorded without a
+ * lock.  A phase that is not run has no timing.
+ */
+type PhaseTimings struct {
+	durations map[string]float64
+}
+
+func (timings *PhaseTimings) Record(phase string, start time.Time) {
+	if timings.durations == nil {
+		timings.durations = make(map[string]float64)
+	}
+	timings.durations[phase] = time.Since(start).Seconds()
+}
+
+func (timings *PhaseTimings) Durations() map[string]float64 {
+	return timings.durations
+}
+
+type Metrics struct {
+	Utility         string
+	Database        string
+	Success         bool
+	StartTime       time.Time
+	EndTime         time.Time
+	TablesCompleted int
+	TablesFailed    int
+	TablesSkipped   int
+	SegmentBytes    map[int]uint64
+	PhaseDurations  map[string]float64
+}
+
+/*
+ * Each database has its own metrics file, so that the last successful backup
+ * or restore of one database is not overwritten by that of another.
+ */
+func GetMetricsFilePath(metricsDir string, utility string, database string) string {
+	return path.Join(metricsDir, fmt.Sprintf("%s_%s.prom", utility, metricsFilenameRegex.ReplaceAllString(database, "_")))
+}
+
+/*
+ * The file is written under a temporary name and renamed, so that the
+ * collector never reads a partially written file.  When the backup or restore
+ * fails, the time of the last successful one is carried over from the
+ * previous file.
+ */
+func WriteMetricsFile(metricsDir string, metrics Metrics) error {
+	filename := GetMetricsFilePath(metricsDir, metrics.Utility, metrics.Database)
+	lastSuccess := int64(0)
+	if metrics.Success {
+		lastSuccess = metrics.EndTime.Unix()
+	} else {
+		lastSuccess = readLastSuccessTimestamp(filename, metrics.Utility)
+	}
+	tempFilename := fmt.Sprintf("%s.%d.tmp", filename, operating.System.Getpid())
+	err := ioutil.WriteFile(tempFilename, []byte(metrics.Format(lastSuccess)), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFilename, filename)
+}
+
+func readLastSuccessTimestamp(filename string, utility string) int64 {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return 0
+	}
+	prefix := fmt.Sprintf("%s_last_success_timestamp_seconds{", utility)
+	for _, line := range strings.Split(string(contents), "\n") {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		fields := strings.Fields(line)
+		lastSuccess, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+		if err == nil {
+			return lastSuccess
+		}
+	}
+	return 0
+}
+
+func (metrics Metrics) Format(lastSuccess int64) string {
+	var builder strings.Builder
+	databaseLabel := fmt.Sprintf(`database="%s"`, labelValueReplacer.Replace(metrics.Database))
+	writeMetric := func(name string, help string, labels []string, values []string) {
+		fullName := fmt.Sprintf("%s_%s", metrics.Utility, name)
+		builder.WriteString(fmt.Sprintf("# HELP %s %s\n# TYPE %s gauge\n", fullName, help, fullName))
+		for i, label := range labels {
+			builder.WriteString(fmt.Sprintf("%s{%s} %s\n", fullName, label, values[i]))
+		}
+	}
+	writeGauge := func(name string, help string, value string) {
+		writeMetric(name, help, []string{databaseLabel}, []string{value})
+	}
+
+	success := "0"
+	if metrics.Success {
+		success = "1"
+	}
+	writeGauge("success", fmt.Sprintf("Whether the last %s of the database succeeded.", metrics.Utility), success)
+	writeGauge("start_timestamp_seconds", fmt.Sprintf("Start time of the last %s of the database.", metrics.Utility), strconv.FormatInt(metrics.StartTime.Unix(), 10))
+	writeGauge("end_timestamp_seconds", fmt.Sprintf("End time of the last %s of the database.", metrics.Utility), strconv.FormatInt(metrics.EndTime.Unix(), 10))
+	writeGauge("duration_seconds", fmt.Sprintf("Duration of the last %s of the database.", metrics.Utility), formatSeconds(metrics.EndTime.Sub(metrics.StartTime).Seconds()))
+	writeGauge("last_success_timestamp_seconds", fmt.Sprintf("End time of the last successful %s of the database.", metrics.Utility), strconv.FormatInt(lastSuccess, 10))
+	writeMetric("tables", fmt.Sprintf("Number of tables by status in the last %s of the database.", metrics.Utility),
+		[]string{databaseLabel + `,status="completed"`, databaseLabel + `,status="failed"`, databaseLabel + `,status="skipped"`},
+		[]string{strconv.Itoa(metrics.TablesCompleted), strconv.Itoa(metrics.TablesFailed), strconv.Itoa(metrics.TablesSkipped)})
+
+	if len(metrics.SegmentBytes) > 0 {
+		contentIDs := make([]int, 0, len(metrics.SegmentBytes))
+		for contentID := range metrics.SegmentBytes {
+			contentIDs = append(contentIDs, contentID)
+		}
+		sort.Ints(contentIDs)
+		labels := make([]string, len(contentIDs))
+		values := make([]string, len(contentIDs))
+		for i, contentID := range contentIDs {
+			labels[i] = fmt.Sprintf(`%s,segment="%d"`, databaseLabel, contentID)
+			values[i] = strconv.FormatUint(metrics.SegmentBytes[contentID], 10)
+		}
+		writeMetric("segment_bytes", fmt.Sprintf("Bytes of data written by each segment in the last %s of the database.", metrics.Utility), labels, values)
+	}
+
+	if len(metrics.PhaseDurations) > 0 {
+		phases := make([]string, 0, len(metrics.PhaseDurations))
+		for phase := range metrics.PhaseDurations {
+			phases = append(phases, phase)
+		}
+		sort.Strings(phases)
+		labels := make([]string, len(phases))
+		values := make([]string, len(phases))
+		for i, phase := range phases {
+			labels[i] = fmt.Sprintf(`%s,phase="%s"`, databaseLabel, phase)
+			values[i] = formatSeconds(metrics.PhaseDurations[phase])
+		}
+		writeMetric("phase_duration_seconds", fmt.Sprintf("Duration of each phase of the last %s of the database.", metrics.Utility), labels, values)
+	}
+	return builder.String()
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
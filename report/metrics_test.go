@@ -0,0 +1,108 @@
+package report_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+
+	. "github.com/greenplum-db/gpbackup/report"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("report/metrics tests", func() {
+	startTime := time.Unix(1483232461, 0)
+	endTime := time.Unix(1483232581, 500000000)
+	var metrics Metrics
+	BeforeEach(func() {
+		metrics = Metrics{
+			Utility:         "gpbackup",
+			Database:        `test"db`,
+			Success:         true,
+			StartTime:       startTime,
+			EndTime:         endTime,
+			TablesCompleted: 10,
+			TablesFailed:    1,
+			TablesSkipped:   2,
+			SegmentBytes:    map[int]uint64{1: 2048, 0: 1024},
+			PhaseDurations:  map[string]float64{"predata": 1.5, "data": 100.25},
+		}
+	})
+	Describe("Format", func() {
+		It("formats the metrics in the Prometheus text format", func() {
+			Expect(metrics.Format(1483232581)).To(Equal(`# HELP gpbackup_success Whether the last gpbackup of the database succeeded.
+# TYPE gpbackup_success gauge
+gpbackup_success{database="test\"db"} 1
+# HELP gpbackup_start_timestamp_seconds Start time of the last gpbackup of the database.
+# TYPE gpbackup_start_timestamp_seconds gauge
+gpbackup_start_timestamp_seconds{database="test\"db"} 1483232461
+# HELP gpbackup_end_timestamp_seconds End time of the last gpbackup of the database.
+# TYPE gpbackup_end_timestamp_seconds gauge
+gpbackup_end_timestamp_seconds{database="test\"db"} 1483232581
+# HELP gpbackup_duration_seconds Duration of the last gpbackup of the database.
+# TYPE gpbackup_duration_seconds gauge
+gpbackup_duration_seconds{database="test\"db"} 120.500
+# HELP gpbackup_last_success_timestamp_seconds End time of the last successful gpbackup of the database.
+# TYPE gpbackup_last_success_timestamp_seconds gauge
+gpbackup_last_success_timestamp_seconds{database="test\"db"} 1483232581
+# HELP gpbackup_tables Number of tables by status in the last gpbackup of the database.
+# TYPE gpbackup_tables gauge
+gpbackup_tables{database="test\"db",status="completed"} 10
+gpbackup_tables{database="test\"db",status="failed"} 1
+gpbackup_tables{database="test\"db",status="skipped"} 2
+# HELP gpbackup_segment_bytes Bytes of data written by each segment in the last gpbackup of the database.
+# TYPE gpbackup_segment_bytes gauge
+gpbackup_segment_bytes{database="test\"db",segment="0"} 1024
+gpbackup_segment_bytes{database="test\"db",segment="1"} 2048
+# HELP gpbackup_phase_duration_seconds Duration of each phase of the last gpbackup of the database.
+# TYPE gpbackup_phase_duration_seconds gauge
+gpbackup_phase_duration_seconds{database="test\"db",phase="data"} 100.250
+gpbackup_phase_duration_seconds{database="test\"db",phase="predata"} 1.500
+`))
+		})
+	})
+	Describe("WriteMetricsFile", func() {
+		var metricsDir string
+		BeforeEach(func() {
+			metricsDir, _ = ioutil.TempDir("", "metrics")
+			operating.System = operating.InitializeSystemFunctions()
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(metricsDir)
+			operating.System = operating.InitializeSystemFunctions()
+		})
+		It("writes a metrics file for each database", func() {
+			err := WriteMetricsFile(metricsDir, metrics)
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, err := ioutil.ReadFile(path.Join(metricsDir, "gpbackup_test_db.prom"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(ContainSubstring(`gpbackup_last_success_timestamp_seconds{database="test\"db"} 1483232581`))
+			files, _ := ioutil.ReadDir(metricsDir)
+			Expect(files).To(HaveLen(1))
+		})
+		It("keeps the time of the last successful backup when a backup fails", func() {
+			_ = WriteMetricsFile(metricsDir, metrics)
+			metrics.Success = false
+			metrics.EndTime = endTime.Add(time.Hour)
+
+			err := WriteMetricsFile(metricsDir, metrics)
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, _ := ioutil.ReadFile(path.Join(metricsDir, "gpbackup_test_db.prom"))
+			Expect(string(contents)).To(ContainSubstring(`gpbackup_success{database="test\"db"} 0`))
+			Expect(string(contents)).To(ContainSubstring(`gpbackup_last_success_timestamp_seconds{database="test\"db"} 1483232581`))
+		})
+		It("reports no successful backup when the first backup fails", func() {
+			metrics.Success = false
+
+			_ = WriteMetricsFile(metricsDir, metrics)
+
+			contents, _ := ioutil.ReadFile(path.Join(metricsDir, "gpbackup_test_db.prom"))
+			Expect(string(contents)).To(ContainSubstring(`gpbackup_last_success_timestamp_seconds{database="test\"db"} 0`))
+		})
+	})
+})
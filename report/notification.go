@@ -0,0 +1,330 @@
+package report
+
+/*
+ * This file contains structs and functions related to sending notifications
+ * of a completed backup or restore by email and to webhooks.
+ */
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	SMTP_STARTTLS = "starttls"
+	SMTP_TLS      = "tls"
+	SMTP_NONE     = "none"
+
+	notificationTimeout = 30 * time.Second
+)
+
+type ContactFile struct {
+	Contacts map[string][]EmailContact
+}
+
+type EmailContact struct {
+	Address string
+	Status  map[string]bool
+}
+
+/*
+ * The notification config is read from gp_email_contacts.yaml, which holds
+ * the email contacts of each utility and, optionally, the SMTP server to send
+ * email through and the webhooks of each utility.  Email is sent with
+ * sendmail on the master when no SMTP server is configured.
+ */
+type NotificationConfig struct {
+	Contacts map[string][]EmailContact `yaml:"contacts"`
+	SMTP     *SMTPConfig               `yaml:"smtp"`
+	Webhooks map[string][]Webhook      `yaml:"webhooks"`
+}
+
+type SMTPConfig struct {
+	Host        string `yaml:"host"`
+	Port        int    `yaml:"port"`
+	From        string `yaml:"from"`
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password"`
+	PasswordEnv string `yaml:"password_env"`
+	TLS         string `yaml:"tls"`
+}
+
+type Webhook struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Status  map[string]bool   `yaml:"status"`
+}
+
+type WebhookPayload struct {
+	Utility   string `json:"utility"`
+	Timestamp string `json:"timestamp"`
+	Hostname  string `json:"hostname"`
+	Status    string `json:"status"`
+	Text      string `json:"text"`
+	Report    string `json:"report"`
+}
+
+func ReadNotificationConfig(filename string) (*NotificationConfig, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseNotificationConfig(contents, filename)
+}
+
+func parseNotificationConfig(contents []byte, filename string) (*NotificationConfig, error) {
+	config := &NotificationConfig{}
+	err := yaml.Unmarshal(contents, config)
+	if err != nil {
+		return nil, errors.Errorf("Unable to parse %s.  Please ensure that it is in valid YAML format.", filename)
+	}
+	if config.SMTP != nil {
+		if config.SMTP.Host == "" || config.SMTP.From == "" {
+			return nil, errors.Errorf("The smtp section of %s requires a host and a from address", filename)
+		}
+		switch config.SMTP.TLS {
+		case "":
+			config.SMTP.TLS = SMTP_STARTTLS
+		case SMTP_STARTTLS, SMTP_TLS, SMTP_NONE:
+		default:
+			return nil, errors.Errorf("Invalid smtp tls mode %s in %s.  Valid modes are starttls, tls, and none.", config.SMTP.TLS, filename)
+		}
+		if config.SMTP.Port == 0 {
+			config.SMTP.Port = 587
+			if config.SMTP.TLS == SMTP_TLS {
+				config.SMTP.Port = 465
+			}
+		}
+		if config.SMTP.PasswordEnv != "" {
+			config.SMTP.Password = operating.System.Getenv(config.SMTP.PasswordEnv)
+		}
+	}
+	return config, nil
+}
+
+// Returns the status used to route notifications, based on the exit code of the utility
+func getNotificationStatus() string {
+	switch gplog.GetErrorCode() {
+	case 1:
+		return "success_with_errors"
+	case 2:
+		return "failure"
+	}
+	return "success"
+}
+
+func getContactAddresses(contacts []EmailContact, status string) []string {
+	addresses := make([]string, 0)
+	for _, contact := range contacts {
+		if contact.Status[status] {
+			addresses = append(addresses, contact.Address)
+		}
+	}
+	return addresses
+}
+
+func GetContacts(filename string, utility string) string {
+	contents, err := operating.System.ReadFile(filename)
+	gplog.FatalOnError(err)
+	config, err := parseNotificationConfig(contents, filename)
+	if err != nil {
+		gplog.Warn("Unable to send email report: Error reading email contacts file.")
+		gplog.Warn("Please ensure that the email contacts file is in valid YAML format.")
+		return ""
+	}
+	return strings.Join(getContactAddresses(config.Contacts[utility], getNotificationStatus()), " ")
+}
+
+func ConstructEmailMessage(timestamp string, contactList string, reportFilePath string, utility string) string {
+	hostname, _ := operating.System.Hostname()
+	emailHeader := fmt.Sprintf(`To: %s
+Subject: %s %s on %s completed
+Content-Type: text/html
+Content-Disposition: inline
+<html>
+<body>
+<pre style=\"font: monospace\">
+`, contactList, utility, timestamp, hostname)
+	emailFooter := `
+</pre>
+</body>
+</html>`
+	fileContents := strings.Join(iohelper.MustReadLinesFromFile(reportFilePath), "\n")
+	return emailHeader + fileContents + emailFooter
+}
+
+/*
+ * Sends the report to the email contacts and webhooks configured for the
+ * utility and its exit status in gp_email_contacts.yaml, which is read from
+ * $HOME or, if it is not there, from $GPHOME/bin.
+ */
+func SendReportNotifications(c *cluster.Cluster, timestamp string, reportFilePath string, utility string) {
+	contactsFilename := "gp_email_contacts.yaml"
+	gphomeFile := fmt.Sprintf("%s/bin/%s", operating.System.Getenv("GPHOME"), contactsFilename)
+	homeFile := fmt.Sprintf("%s/%s", operating.System.Getenv("HOME"), contactsFilename)
+	_, homeErr := c.ExecuteLocalCommand(fmt.Sprintf("test -f %s", homeFile))
+	if homeErr != nil {
+		_, gphomeErr := c.ExecuteLocalCommand(fmt.Sprintf("test -f %s", gphomeFile))
+		if gphomeErr != nil {
+			gplog.Info("Found neither %s nor %s", gphomeFile, homeFile)
+			gplog.Info("Email containing %s report %s will not be sent", utility, reportFilePath)
+			return
+		}
+		contactsFilename = gphomeFile
+	} else {
+		contactsFilename = homeFile
+	}
+	gplog.Info("%s list found, %s will be sent", contactsFilename, reportFilePath)
+	config, err := ReadNotificationConfig(contactsFilename)
+	if err != nil {
+		gplog.Warn("Unable to send report notifications: %v", err)
+		return
+	}
+	status := getNotificationStatus()
+	addresses := getContactAddresses(config.Contacts[utility], status)
+	if len(addresses) > 0 {
+		err = sendEmailReport(c, config.SMTP, addresses, timestamp, reportFilePath, utility)
+		if err != nil {
+			gplog.Warn("Unable to send email report: %v", err)
+		}
+	}
+	for _, webhook := range config.Webhooks[utility] {
+		if !webhook.Status[status] {
+			continue
+		}
+		err = sendWebhook(webhook, timestamp, reportFilePath, utility, status)
+		if err != nil {
+			gplog.Warn("Unable to send report to webhook %s: %v", webhook.URL, err)
+		}
+	}
+}
+
+/*
+ * The message is base64-encoded for sendmail, so that quotes and backticks in
+ * the report are not interpreted by the shell.
+ */
+func sendEmailReport(c *cluster.Cluster, smtpConfig *SMTPConfig, addresses []string, timestamp string, reportFilePath string, utility string) error {
+	gplog.Verbose("Sending email report to the following addresses: %s", strings.Join(addresses, " "))
+	if smtpConfig != nil {
+		message := fmt.Sprintf("From: %s\n%s", smtpConfig.From, ConstructEmailMessage(timestamp, strings.Join(addresses, ", "), reportFilePath, utility))
+		return sendSMTPMail(smtpConfig, addresses, message)
+	}
+	message := ConstructEmailMessage(timestamp, strings.Join(addresses, " "), reportFilePath, utility)
+	output, err := c.ExecuteLocalCommand(fmt.Sprintf("echo %s | base64 -d | sendmail -t", base64.StdEncoding.EncodeToString([]byte(message))))
+	if err != nil {
+		return errors.New(output)
+	}
+	return nil
+}
+
+func sendSMTPMail(config *SMTPConfig, addresses []string, message string) error {
+	address := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	tlsConfig := &tls.Config{ServerName: config.Host}
+	var conn net.Conn
+	var err error
+	if config.TLS == SMTP_TLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: notificationTimeout}, "tcp", address, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", address, notificationTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(notificationTimeout))
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+	if config.TLS == SMTP_STARTTLS {
+		err = client.StartTLS(tlsConfig)
+		if err != nil {
+			return err
+		}
+	}
+	if config.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", config.Username, config.Password, config.Host))
+		if err != nil {
+			return err
+		}
+	}
+	err = client.Mail(config.From)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range addresses {
+		err = client.Rcpt(recipient)
+		if err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write([]byte(message))
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+/*
+ * The payload's text field summarizes the result for chat services such as
+ * Slack, and its report field holds the contents of the report file.
+ */
+func sendWebhook(webhook Webhook, timestamp string, reportFilePath string, utility string, status string) error {
+	hostname, _ := operating.System.Hostname()
+	payload := WebhookPayload{
+		Utility:   utility,
+		Timestamp: timestamp,
+		Hostname:  hostname,
+		Status:    status,
+		Text:      fmt.Sprintf("%s %s on %s completed with status %s", utility, timestamp, hostname, status),
+		Report:    strings.Join(iohelper.MustReadLinesFromFile(reportFilePath), "\n"),
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range webhook.Headers {
+		request.Header.Set(key, value)
+	}
+	gplog.Verbose("Sending %s report to webhook %s", utility, webhook.URL)
+	client := &http.Client{Timeout: notificationTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errors.Errorf("Webhook returned status %s", response.Status)
+	}
+	return nil
+}
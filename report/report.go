@@ -10,7 +10,6 @@ import (
 	"time"
 
 	"github.com/blang/semver"
-	"github.com/greenplum-db/gp-common-go-libs/cluster"
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gp-common-go-libs/iohelper"
@@ -18,7 +17,6 @@ import (
 	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
 /*
@@ -29,6 +27,7 @@ import (
 type Report struct {
 	BackupParamsString string
 	DatabaseSize       string
+	EffectiveFlags     string
 	history.BackupConfig
 }
 
@@ -99,6 +98,19 @@ data file format: %s
 %s`
 	report.BackupParamsString = fmt.Sprintf(backupParamsTemplate, compressStr, pluginStr, sectionStr, filterStr,
 		statsStr, filesStr, report.constructIncrementalSection())
+	if report.Encrypted {
+		report.BackupParamsString += "\nencrypted: True"
+	}
+	if report.Masked {
+		report.BackupParamsString += "\nmasked: True"
+	}
+	if report.Predicated {
+		report.BackupParamsString += "\ntable predicates: True"
+	}
+	if report.Resumed {
+		// Tables backed up before the backup was resumed were read under a different snapshot
+		report.BackupParamsString += "\nresumed: True"
+	}
 }
 
 func (report *Report) constructIncrementalSection() string {
@@ -132,6 +144,10 @@ func (report *Report) WriteBackupReportFile(reportFilename string, timestamp str
 		LineInfo{Key: "database name:", Value: report.DatabaseName},
 		LineInfo{Key: "command line:", Value: gpbackupCommandLine},
 	)
+	if report.EffectiveFlags != "" {
+		// Flags set from a --config file do not appear on the command line
+		reportInfo = append(reportInfo, LineInfo{Key: "effective flags:", Value: report.EffectiveFlags})
+	}
 
 	AppendBackupParams(&reportInfo, report.BackupParamsString)
 
@@ -172,7 +188,7 @@ func (report *Report) WriteBackupReportFile(reportFilename string, timestamp str
 	_ = operating.System.Chmod(reportFilename, 0444)
 }
 
-func WriteRestoreReportFile(reportFilename string, backupTimestamp string, startTimestamp string, connectionPool *dbconn.DBConn, restoreVersion string, errMsg string) {
+func WriteRestoreReportFile(reportFilename string, backupTimestamp string, startTimestamp string, connectionPool *dbconn.DBConn, restoreVersion string, partialTables []string, errMsg string) {
 	reportFile, err := iohelper.OpenFileForWriting(reportFilename)
 	if err != nil {
 		gplog.Error("Unable to open restore report file %s", reportFilename)
@@ -215,6 +231,12 @@ func WriteRestoreReportFile(reportFilename string, backupTimestamp string, start
 	}
 
 	logOutputReport(reportFile, reportInfo)
+	if len(partialTables) > 0 {
+		utils.MustPrintf(reportFile, "\nThe following tables were backed up with a predicate and contain only the rows that matched it:\n")
+		for _, partialTable := range partialTables {
+			utils.MustPrintf(reportFile, "%s\n", partialTable)
+		}
+	}
 
 	err = reportFile.Close()
 	gplog.FatalOnError(err)
@@ -309,90 +331,6 @@ func EnsureDatabaseVersionCompatibility(backupGPDBVersion string, restoreGPDBVer
 	}
 }
 
-type ContactFile struct {
-	Contacts map[string][]EmailContact
-}
-
-type EmailContact struct {
-	Address string
-	Status  map[string]bool
-}
-
-func GetContacts(filename string, utility string) string {
-	contactFile := &ContactFile{}
-	contents, err := operating.System.ReadFile(filename)
-	gplog.FatalOnError(err)
-	err = yaml.Unmarshal(contents, contactFile)
-	if err != nil {
-		gplog.Warn("Unable to send email report: Error reading email contacts file.")
-		gplog.Warn("Please ensure that the email contacts file is in valid YAML format.")
-		return ""
-	}
-
-	errorCode := gplog.GetErrorCode()
-	exitStatus := "success"
-	if errorCode == 1 {
-		exitStatus = "success_with_errors"
-	} else if errorCode == 2 {
-		exitStatus = "failure"
-	}
-
-	contactList := make([]string, 0)
-	for _, contact := range contactFile.Contacts[utility] {
-		if contact.Status[exitStatus] {
-			contactList = append(contactList, contact.Address)
-		}
-	}
-	return strings.Join(contactList, " ")
-}
-
-func ConstructEmailMessage(timestamp string, contactList string, reportFilePath string, utility string) string {
-	hostname, _ := operating.System.Hostname()
-	emailHeader := fmt.Sprintf(`To: %s
-Subject: %s %s on %s completed
-Content-Type: text/html
-Content-Disposition: inline
-<html>
-<body>
-<pre style=\"font: monospace\">
-`, contactList, utility, timestamp, hostname)
-	emailFooter := `
-</pre>
-</body>
-</html>`
-	fileContents := strings.Join(iohelper.MustReadLinesFromFile(reportFilePath), "\n")
-	return emailHeader + fileContents + emailFooter
-}
-
-func EmailReport(c *cluster.Cluster, timestamp string, reportFilePath string, utility string) {
-	contactsFilename := "gp_email_contacts.yaml"
-	gphomeFile := fmt.Sprintf("%s/bin/%s", operating.System.Getenv("GPHOME"), contactsFilename)
-	homeFile := fmt.Sprintf("%s/%s", operating.System.Getenv("HOME"), contactsFilename)
-	_, homeErr := c.ExecuteLocalCommand(fmt.Sprintf("test -f %s", homeFile))
-	if homeErr != nil {
-		_, gphomeErr := c.ExecuteLocalCommand(fmt.Sprintf("test -f %s", gphomeFile))
-		if gphomeErr != nil {
-			gplog.Info("Found neither %s nor %s", gphomeFile, homeFile)
-			gplog.Info("Email containing %s report %s will not be sent", utility, reportFilePath)
-			return
-		}
-		contactsFilename = gphomeFile
-	} else {
-		contactsFilename = homeFile
-	}
-	gplog.Info("%s list found, %s will be sent", contactsFilename, reportFilePath)
-	contactList := GetContacts(contactsFilename, utility)
-	if contactList == "" {
-		return
-	}
-	message := ConstructEmailMessage(timestamp, contactList, reportFilePath, utility)
-	gplog.Verbose("Sending email report to the following addresses: %s", contactList)
-	output, sendErr := c.ExecuteLocalCommand(fmt.Sprintf(`echo "%s" | sendmail -t`, message))
-	if sendErr != nil {
-		gplog.Warn("Unable to send email report: %s", output)
-	}
-}
-
 func AppendBackupParams(infoArr *[]LineInfo, paramsStr string) {
 	paramsStr = strings.Trim(paramsStr, "\n")
 	params := strings.Split(paramsStr, "\n")
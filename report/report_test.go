@@ -1,9 +1,15 @@
 package report_test
 
 import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -119,6 +125,14 @@ count of database objects in backup:
 sequences   1
 tables      42
 types       1000`))
+		})
+		It("writes the effective flags of a backup that used a configuration file", func() {
+			backupReport.EffectiveFlags = "--config gpbackup.yaml --dbname testdb --jobs 4"
+			backupReport.WriteBackupReportFile("filename", timestamp, endtime, objectCounts, "")
+			Expect(buffer).To(Say(`database name:         testdb
+command line:          .*
+effective flags:       --config gpbackup.yaml --dbname testdb --jobs 4
+compression:           gzip`))
 		})
 		It("writes a report for a failed backup", func() {
 			backupReport.WriteBackupReportFile("filename", timestamp, endtime, objectCounts, "Cannot access /tmp/backups: Permission denied")
@@ -179,6 +193,105 @@ tables      42
 types       1000`))
 		})
 	})
+	Describe("WriteBackupJSONReportFile", func() {
+		timestamp := "20170101010101"
+		endtime := time.Date(2017, 1, 1, 5, 4, 3, 2, time.Local)
+		backupReport := &Report{}
+		BeforeEach(func() {
+			backupReport = &Report{
+				DatabaseSize: "42 mb",
+				BackupConfig: history.BackupConfig{
+					BackupVersion:   "0.1.0",
+					DatabaseName:    "testdb",
+					DatabaseVersion: "5.0.0 build test",
+					PluginVersion:   "1.2.3",
+					Compressed:      true,
+				},
+			}
+			operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				return buffer, nil
+			}
+			operating.System.Chmod = func(name string, mode os.FileMode) error {
+				return nil
+			}
+		})
+		It("writes a JSON report for a successful backup", func() {
+			tableResults := []TableResult{{Name: "public.foo", Rows: 10, DurationSeconds: 1.5}}
+			backupReport.WriteBackupJSONReportFile("filename", timestamp, endtime, map[string]int{"tables": 1}, tableResults, "")
+
+			var jsonReport JSONReport
+			Expect(json.Unmarshal(buffer.Contents(), &jsonReport)).To(Succeed())
+			Expect(jsonReport.Utility).To(Equal("gpbackup"))
+			Expect(jsonReport.Version).To(Equal("0.1.0"))
+			Expect(jsonReport.Timestamp).To(Equal(timestamp))
+			Expect(jsonReport.DatabaseName).To(Equal("testdb"))
+			Expect(jsonReport.DurationSeconds).To(Equal(float64(4*60*60 + 3*60 + 2)))
+			Expect(jsonReport.Status).To(Equal(StatusSuccess))
+			Expect(jsonReport.Error).To(Equal(""))
+			Expect(jsonReport.PluginVersion).To(Equal("1.2.3"))
+			Expect(jsonReport.DatabaseSize).To(Equal("42 MB"))
+			Expect(jsonReport.BackupParams.Compressed).To(BeTrue())
+			Expect(jsonReport.ObjectCounts).To(Equal(map[string]int{"tables": 1}))
+			Expect(jsonReport.Tables).To(Equal(tableResults))
+		})
+		It("writes a JSON report for a failed backup", func() {
+			backupReport.WriteBackupJSONReportFile("filename", timestamp, endtime, nil, nil, "Cannot access /tmp/backups: Permission denied")
+
+			Expect(string(buffer.Contents())).To(ContainSubstring(`"status": "failure"`))
+			Expect(string(buffer.Contents())).To(ContainSubstring(`"error": "Cannot access /tmp/backups: Permission denied"`))
+			Expect(string(buffer.Contents())).To(ContainSubstring(`"tables": []`))
+		})
+	})
+	Describe("WriteRestoreJSONReportFile", func() {
+		connectionPool := &dbconn.DBConn{
+			DBName: "testdb",
+			Version: dbconn.GPDBVersion{
+				VersionString: "5.0.0 build test",
+			},
+		}
+		BeforeEach(func() {
+			operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				return buffer, nil
+			}
+			operating.System.Now = func() time.Time {
+				return time.Date(2017, 1, 1, 5, 4, 3, 2, time.Local)
+			}
+			operating.System.Chmod = func(name string, mode os.FileMode) error {
+				return nil
+			}
+		})
+		AfterEach(func() {
+			gplog.SetErrorCode(0)
+		})
+		It("writes a JSON report for a restore with errors", func() {
+			gplog.SetErrorCode(1)
+			backupConfig := &history.BackupConfig{PluginVersion: "1.2.3"}
+			tableResults := []TableResult{{Name: "public.bar", Error: "Expected to restore 2 rows to table public.bar, but restored 1 instead"}, {Name: "public.foo", Rows: 10}}
+			WriteRestoreJSONReportFile("filename", "20170101010101", "20170101010102", connectionPool, "0.1.0", backupConfig, tableResults, []string{"public.foo: id > 10"}, "")
+
+			var jsonReport JSONReport
+			Expect(json.Unmarshal(buffer.Contents(), &jsonReport)).To(Succeed())
+			Expect(jsonReport.Utility).To(Equal("gprestore"))
+			Expect(jsonReport.DatabaseVersion).To(Equal("5.0.0 build test"))
+			Expect(jsonReport.DurationSeconds).To(Equal(float64(4*60*60 + 3*60 + 1)))
+			Expect(jsonReport.Status).To(Equal(StatusSuccessWithErrors))
+			Expect(jsonReport.PluginVersion).To(Equal("1.2.3"))
+			Expect(jsonReport.PartialTables).To(Equal([]string{"public.foo: id > 10"}))
+			Expect(jsonReport.Tables).To(Equal(tableResults))
+		})
+	})
+	Describe("TableResults", func() {
+		It("returns the results sorted by table name", func() {
+			var tableResults TableResults
+			tableResults.Add("public.foo", 10, 2*time.Second, nil)
+			tableResults.Add("public.bar", 0, time.Second, errors.New("COPY failed"))
+
+			Expect(tableResults.Sorted()).To(Equal([]TableResult{
+				{Name: "public.bar", DurationSeconds: 1, Error: "COPY failed"},
+				{Name: "public.foo", Rows: 10, DurationSeconds: 2},
+			}))
+		})
+	})
 	Describe("AppendBackupParams", func() {
 		It("correctly parses the string and appends to the LineInfo array", func() {
 			testParamsStr := `compression: exampleStr
@@ -239,7 +352,7 @@ incremental backup set:
 
 		It("writes a report for a failed restore", func() {
 			gplog.SetErrorCode(2)
-			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "Cannot access /tmp/backups: Permission denied")
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, nil, "Cannot access /tmp/backups: Permission denied")
 			Expect(buffer).To(Say(`Greenplum Database Restore Report
 
 timestamp key:       20170101010101
@@ -258,7 +371,7 @@ restore error:       Cannot access /tmp/backups: Permission denied`))
 		})
 		It("writes a report for a successful restore", func() {
 			gplog.SetErrorCode(0)
-			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "")
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, nil, "")
 			Expect(buffer).To(Say(`Greenplum Database Restore Report
 
 timestamp key:       20170101010101
@@ -276,7 +389,7 @@ restore status:      Success`))
 		})
 		It("writes a report for a successful restore with errors", func() {
 			gplog.SetErrorCode(1)
-			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "")
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, nil, "")
 			Expect(buffer).To(Say(`Greenplum Database Restore Report
 
 timestamp key:       20170101010101
@@ -292,13 +405,21 @@ duration:            4:03:01
 
 restore status:      Success but non-fatal errors occurred. See log file .+ for details.`))
 		})
+		It("writes a report for a restore of tables that were backed up with a predicate", func() {
+			gplog.SetErrorCode(0)
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, []string{"sales.events: event_date >= '2024-01-01'"}, "")
+			Expect(buffer).To(Say(`restore status:      Success
+
+The following tables were backed up with a predicate and contain only the rows that matched it:
+sales\.events: event_date >= '2024-01-01'`))
+		})
 	})
 	Describe("SetBackupParamFromFlags", func() {
 		AfterEach(func() {
-			utils.InitializePipeThroughParameters(false, 0)
+			utils.InitializePipeThroughParameters(false, "", 0)
 		})
 		It("configures the Report struct correctly", func() {
-			utils.InitializePipeThroughParameters(true, 0)
+			utils.InitializePipeThroughParameters(true, "gzip", 0)
 			backupCmdFlags := pflag.NewFlagSet("gpbackup", pflag.ExitOnError)
 			backup.SetCmdFlags(backupCmdFlags)
 			err := backupCmdFlags.Set(options.INCLUDE_RELATION, "public.foobar")
@@ -315,12 +436,15 @@ restore status:      Success but non-fatal errors occurred. See log file .+ for
 			structmatcher.ExpectStructsToMatch(history.BackupConfig{
 				BackupVersion:        "0.1.0",
 				Compressed:           true,
+				CompressionType:      "gzip",
 				DatabaseName:         "testdb",
 				DatabaseVersion:      "5.0.0 build test",
 				IncludeSchemas:       []string{},
 				IncludeRelations:     []string{"public.foobar"},
+				IncludeObjectTypes:   []string{},
 				ExcludeSchemas:       []string{},
 				ExcludeRelations:     []string{},
+				ExcludeObjectTypes:   []string{},
 				Plugin:               "/tmp/plugin.sh",
 				Timestamp:            "timestamp1",
 				IncludeTableFiltered: true,
@@ -530,11 +654,36 @@ Timestamp Key: 20170101010101
 				Expect(message).To(Equal(expectedMessage))
 			})
 		})
-		Context("EmailReport", func() {
+		Context("ReadNotificationConfig", func() {
+			It("sets the default TLS mode and port of the SMTP server", func() {
+				_, _ = w.Write([]byte("smtp:\n  host: mail.example.com\n  from: gpadmin@example.com\n  password_env: SMTP_PASSWORD\n"))
+				_ = w.Close()
+				operating.System.Getenv = func(key string) string { return "secret" }
+
+				config, err := ReadNotificationConfig("gp_email_contacts.yaml")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(*config.SMTP).To(Equal(SMTPConfig{Host: "mail.example.com", Port: 587, From: "gpadmin@example.com", Password: "secret", PasswordEnv: "SMTP_PASSWORD", TLS: SMTP_STARTTLS}))
+			})
+			It("returns an error for an invalid TLS mode", func() {
+				_, _ = w.Write([]byte("smtp:\n  host: mail.example.com\n  from: gpadmin@example.com\n  tls: ssl\n"))
+				_ = w.Close()
+
+				_, err := ReadNotificationConfig("gp_email_contacts.yaml")
+				Expect(err).To(MatchError("Invalid smtp tls mode ssl in gp_email_contacts.yaml.  Valid modes are starttls, tls, and none."))
+			})
+			It("returns an error for an SMTP server without a host", func() {
+				_, _ = w.Write([]byte("smtp:\n  from: gpadmin@example.com\n"))
+				_ = w.Close()
+
+				_, err := ReadNotificationConfig("gp_email_contacts.yaml")
+				Expect(err).To(MatchError("The smtp section of gp_email_contacts.yaml requires a host and a from address"))
+			})
+		})
+		Context("SendReportNotifications", func() {
 			var (
 				expectedHomeCmd   = "test -f home/gp_email_contacts.yaml"
 				expectedGpHomeCmd = "test -f gphome/bin/gp_email_contacts.yaml"
-				expectedMessage   = `echo "To: contact1@example.com
+				expectedMessage   = fmt.Sprintf("echo %s | base64 -d | sendmail -t", base64.StdEncoding.EncodeToString([]byte(`To: contact1@example.com
 Subject: gpbackup 20170101010101 on localhost completed
 Content-Type: text/html
 Content-Disposition: inline
@@ -544,7 +693,7 @@ Content-Disposition: inline
 
 </pre>
 </body>
-</html>" | sendmail -t`
+</html>`)))
 			)
 			It("sends no email and raises a warning if no gp_email_contacts.yaml file is found", func() {
 				_, _ = w.Write(contactsFileContents)
@@ -552,7 +701,7 @@ Content-Disposition: inline
 
 				testExecutor.LocalError = errors.Errorf("exit status 2")
 
-				EmailReport(testCluster, testFPInfo.Timestamp, "report_file", "gpbackup")
+				SendReportNotifications(testCluster, testFPInfo.Timestamp, "report_file", "gpbackup")
 				Expect(testExecutor.NumExecutions).To(Equal(2))
 				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd, expectedGpHomeCmd}))
 				Expect(stdout).To(Say("Found neither gphome/bin/gp_email_contacts.yaml nor home/gp_email_contacts.yaml"))
@@ -564,7 +713,7 @@ Content-Disposition: inline
 				testExecutor.ErrorOnExecNum = 2 // Shouldn't hit this case, as it shouldn't be executed a second time
 				testExecutor.LocalError = errors.Errorf("exit status 2")
 
-				EmailReport(testCluster, testFPInfo.Timestamp, "report_file", "gpbackup")
+				SendReportNotifications(testCluster, testFPInfo.Timestamp, "report_file", "gpbackup")
 				Expect(testExecutor.NumExecutions).To(Equal(2))
 				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd, expectedMessage}))
 				Expect(logfile).To(Say("Sending email report to the following addresses: contact1@example.com"))
@@ -576,7 +725,7 @@ Content-Disposition: inline
 				testExecutor.ErrorOnExecNum = 1
 				testExecutor.LocalError = errors.Errorf("exit status 2")
 
-				EmailReport(testCluster, testFPInfo.Timestamp, "report_file", "gpbackup")
+				SendReportNotifications(testCluster, testFPInfo.Timestamp, "report_file", "gpbackup")
 				Expect(testExecutor.NumExecutions).To(Equal(3))
 				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd, expectedGpHomeCmd, expectedMessage}))
 				Expect(logfile).To(Say("Sending email report to the following addresses: contact1@example.com"))
@@ -585,11 +734,139 @@ Content-Disposition: inline
 				_, _ = w.Write(contactsFileContents)
 				_ = w.Close()
 
-				EmailReport(testCluster, testFPInfo.Timestamp, "report_file", "gpbackup")
+				SendReportNotifications(testCluster, testFPInfo.Timestamp, "report_file", "gpbackup")
 				Expect(testExecutor.NumExecutions).To(Equal(2))
 				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd, expectedMessage}))
 				Expect(logfile).To(Say("Sending email report to the following addresses: contact1@example.com"))
 			})
+			It("sends the report to webhooks configured for the exit status", func() {
+				payloads := make(chan WebhookPayload, 2)
+				headers := make(chan string, 2)
+				server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+					payload := WebhookPayload{}
+					_ = json.NewDecoder(request.Body).Decode(&payload)
+					payloads <- payload
+					headers <- request.Header.Get("Authorization")
+				}))
+				defer server.Close()
+				_, _ = w.Write([]byte(fmt.Sprintf(`webhooks:
+  gpbackup:
+  - url: %[1]s/success
+    headers:
+      Authorization: Token abc
+    status:
+      success: true
+  - url: %[1]s/failure
+    status:
+      failure: true
+`, server.URL)))
+				_ = w.Close()
+
+				SendReportNotifications(testCluster, testFPInfo.Timestamp, "report_file", "gpbackup")
+				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd}))
+				Expect(payloads).To(HaveLen(1))
+				Expect(<-payloads).To(Equal(WebhookPayload{
+					Utility:   "gpbackup",
+					Timestamp: "20170101010101",
+					Hostname:  "localhost",
+					Status:    "success",
+					Text:      "gpbackup 20170101010101 on localhost completed with status success",
+				}))
+				Expect(<-headers).To(Equal("Token abc"))
+			})
+			It("raises a warning if a webhook returns an error", func() {
+				server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+					response.WriteHeader(http.StatusInternalServerError)
+				}))
+				defer server.Close()
+				_, _ = w.Write([]byte(fmt.Sprintf("webhooks:\n  gpbackup:\n  - url: %s\n    status:\n      success: true\n", server.URL)))
+				_ = w.Close()
+
+				SendReportNotifications(testCluster, testFPInfo.Timestamp, "report_file", "gpbackup")
+				Expect(stdout).To(Say(fmt.Sprintf("Unable to send report to webhook %s: Webhook returned status 500 Internal Server Error", server.URL)))
+			})
+			It("sends an email through the configured SMTP server", func() {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).ToNot(HaveOccurred())
+				defer listener.Close()
+				received := make(chan []string, 1)
+				go serveSMTP(listener, received)
+				host, port, _ := net.SplitHostPort(listener.Addr().String())
+				_, _ = w.Write([]byte(fmt.Sprintf(`smtp:
+  host: %s
+  port: %s
+  from: gpadmin@example.com
+  tls: none
+contacts:
+  gpbackup:
+  - address: contact1@example.com
+    status:
+      success: true
+  - address: contact2@example.org
+    status:
+      success: true
+`, host, port)))
+				_ = w.Close()
+
+				SendReportNotifications(testCluster, testFPInfo.Timestamp, "report_file", "gpbackup")
+				Expect(testExecutor.LocalCommands).To(Equal([]string{expectedHomeCmd}))
+				Eventually(received).Should(Receive(Equal([]string{
+					"MAIL FROM:<gpadmin@example.com>",
+					"RCPT TO:<contact1@example.com>",
+					"RCPT TO:<contact2@example.org>",
+					"From: gpadmin@example.com",
+					"To: contact1@example.com, contact2@example.org",
+					"Subject: gpbackup 20170101010101 on localhost completed",
+				})))
+			})
 		})
 	})
 })
+
+/*
+ * Serves a single SMTP session, sending the envelope commands and the message
+ * headers it receives.
+ */
+func serveSMTP(listener net.Listener, received chan []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	lines := make([]string, 0)
+	_, _ = conn.Write([]byte("220 localhost\r\n"))
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if inData {
+			if line == "." {
+				inData = false
+				_, _ = conn.Write([]byte("250 OK\r\n"))
+			} else if strings.HasPrefix(line, "From:") || strings.HasPrefix(line, "To:") || strings.HasPrefix(line, "Subject:") {
+				lines = append(lines, line)
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+			_, _ = conn.Write([]byte("250 localhost\r\n"))
+		case strings.HasPrefix(line, "MAIL"), strings.HasPrefix(line, "RCPT"):
+			lines = append(lines, line)
+			_, _ = conn.Write([]byte("250 OK\r\n"))
+		case line == "DATA":
+			inData = true
+			_, _ = conn.Write([]byte("354 Go ahead\r\n"))
+		case line == "QUIT":
+			_, _ = conn.Write([]byte("221 Bye\r\n"))
+			received <- lines
+			return
+		default:
+			_, _ = conn.Write([]byte("250 OK\r\n"))
+		}
+	}
+}
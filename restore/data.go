@@ -6,11 +6,14 @@ package restore
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
 	"github.com/greenplum-db/gpbackup/filepath"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/toc"
@@ -26,7 +29,24 @@ var (
 
 func CopyTableIn(connectionPool *dbconn.DBConn, tableName string, tableAttributes string, destinationToRead string, singleDataFile bool, whichConn int) (int64, error) {
 	whichConn = connectionPool.ValidateConnNum(whichConn)
-	copyCommand := ""
+	query := GetCopyTableInQuery(tableName, tableAttributes, destinationToRead, singleDataFile)
+	gplog.Verbose(query)
+	result, err := connectionPool.Exec(query, whichConn)
+	if err != nil {
+		errStr := fmt.Sprintf("Error loading data into table %s", tableName)
+
+		// The COPY ON SEGMENT error might contain useful CONTEXT output
+		if err.(pgx.PgError).Where != "" {
+			errStr = fmt.Sprintf("%s: %s", errStr, err.(pgx.PgError).Where)
+		}
+
+		return 0, errors.Wrap(err, errStr)
+	}
+	numRows, _ := result.RowsAffected()
+	return numRows, err
+}
+
+func GetCopyTableInQuery(tableName string, tableAttributes string, destinationToRead string, singleDataFile bool) string {
 	readFromDestinationCommand := "cat"
 	customPipeThroughCommand := utils.GetPipeThroughProgram().InputCommand
 
@@ -37,33 +57,140 @@ func CopyTableIn(connectionPool *dbconn.DBConn, tableName string, tableAttribute
 		readFromDestinationCommand = fmt.Sprintf("%s restore_data %s", pluginConfig.ExecutablePath, pluginConfig.ConfigPath)
 	}
 
-	copyCommand = fmt.Sprintf("PROGRAM '%s %s | %s'", readFromDestinationCommand, destinationToRead, customPipeThroughCommand)
+	copyCommand := fmt.Sprintf("PROGRAM '%s %s | %s'", readFromDestinationCommand, destinationToRead, customPipeThroughCommand)
 
-	query := fmt.Sprintf("COPY %s%s FROM %s WITH CSV DELIMITER '%s' ON SEGMENT;", tableName, tableAttributes, copyCommand, tableDelim)
-	gplog.Verbose(query)
-	result, err := connectionPool.Exec(query, whichConn)
+	return fmt.Sprintf("COPY %s%s FROM %s WITH CSV DELIMITER '%s' ON SEGMENT;", tableName, tableAttributes, copyCommand, tableDelim)
+}
+
+/*
+ * COPY ON SEGMENT cannot be used when the restore cluster has a different
+ * number of segments than the backup cluster, as rows must be sent to the
+ * segments chosen by the table's distribution policy in the restore cluster.
+ * Instead, the segments read the backup files through an external web table
+ * and the rows are redistributed by inserting them into the table.
+ */
+func CopyTableInResized(connectionPool *dbconn.DBConn, tableName string, externalTableName string, tableAttributes string, readCommand string, whichConn int) (int64, error) {
+	whichConn = connectionPool.ValidateConnNum(whichConn)
+	columnDefs, err := getExternalTableColumnDefs(connectionPool, tableName, tableAttributes, whichConn)
 	if err != nil {
-		errStr := fmt.Sprintf("Error loading data into table %s", tableName)
+		return 0, err
+	}
+	dropQuery := fmt.Sprintf("DROP EXTERNAL TABLE IF EXISTS %s;", externalTableName)
+	_, err = connectionPool.Exec(dropQuery, whichConn)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Error dropping external table %s", externalTableName)
+	}
+	createQuery := fmt.Sprintf("CREATE EXTERNAL WEB TABLE %s (%s) EXECUTE '%s' ON ALL FORMAT 'csv' (DELIMITER '%s');", externalTableName, columnDefs, utils.EscapeSingleQuotes(readCommand), tableDelim)
+	gplog.Verbose(createQuery)
+	_, err = connectionPool.Exec(createQuery, whichConn)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Error creating external table %s", externalTableName)
+	}
+	insertQuery := fmt.Sprintf("INSERT INTO %s%s SELECT * FROM %s;", tableName, tableAttributes, externalTableName)
+	gplog.Verbose(insertQuery)
+	result, err := connectionPool.Exec(insertQuery, whichConn)
+	_, dropErr := connectionPool.Exec(dropQuery, whichConn)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("Error loading data into table %s", tableName))
+	}
+	if dropErr != nil {
+		return 0, errors.Wrapf(dropErr, "Error dropping external table %s", externalTableName)
+	}
+	numRows, _ := result.RowsAffected()
+	return numRows, nil
+}
 
-		// The COPY ON SEGMENT error might contain useful CONTEXT output
-		if err.(pgx.PgError).Where != "" {
-			errStr = fmt.Sprintf("%s: %s", errStr, err.(pgx.PgError).Where)
+/*
+ * The external table's columns are listed in the order in which they were
+ * backed up, which may differ from their order in the restore table.
+ */
+func getExternalTableColumnDefs(connectionPool *dbconn.DBConn, tableName string, tableAttributes string, whichConn int) (string, error) {
+	query := fmt.Sprintf(`
+	SELECT quote_ident(attname) AS name,
+		pg_catalog.format_type(atttypid, atttypmod) AS type
+	FROM pg_catalog.pg_attribute
+	WHERE attrelid = '%s'::regclass
+		AND attnum > 0
+		AND NOT attisdropped`, utils.EscapeSingleQuotes(tableName))
+	results := make([]struct {
+		Name string
+		Type string
+	}, 0)
+	err := connectionPool.Select(&results, query, whichConn)
+	if err != nil {
+		return "", errors.Wrapf(err, "Error getting column types of table %s", tableName)
+	}
+	columnTypes := make(map[string]string, len(results))
+	for _, column := range results {
+		columnTypes[column.Name] = column.Type
+	}
+	columnDefs := make([]string, 0)
+	for _, columnName := range SplitTableAttributes(tableAttributes) {
+		columnType, ok := columnTypes[columnName]
+		if !ok {
+			return "", errors.Errorf("Column %s of table %s does not exist", columnName, tableName)
 		}
+		columnDefs = append(columnDefs, fmt.Sprintf("%s %s", columnName, columnType))
+	}
+	return strings.Join(columnDefs, ", "), nil
+}
 
-		return 0, errors.Wrap(err, errStr)
+/*
+ * Splits an attribute list such as (a,"b,c") into its column names, which
+ * remain quoted as they were in the table of contents.
+ */
+func SplitTableAttributes(tableAttributes string) []string {
+	attributes := strings.TrimSuffix(strings.TrimPrefix(tableAttributes, "("), ")")
+	columnNames := make([]string, 0)
+	if attributes == "" {
+		return columnNames
 	}
-	numRows, _ := result.RowsAffected()
-	return numRows, err
+	inQuotes := false
+	start := 0
+	for i, char := range attributes {
+		if char == '"' {
+			inQuotes = !inQuotes
+		} else if char == ',' && !inQuotes {
+			columnNames = append(columnNames, attributes[start:i])
+			start = i + 1
+		}
+	}
+	return append(columnNames, attributes[start:])
+}
+
+/*
+ * Each segment of the restore cluster reads the files of every backup segment
+ * whose content ID is congruent to its own modulo the number of segments in
+ * the restore cluster, so that each backup file is read exactly once.
+ */
+func GetResizeReadCommand(fpInfo filepath.FilePathInfo, oid uint32, backupSegmentCount int, restoreSegmentCount int, singleDataFile bool) string {
+	extension := utils.GetPipeThroughProgram().Extension
+	dataFile := fpInfo.GetTableBackupFilePathForCopyCommand(oid, extension, singleDataFile)
+	readCommand := ""
+	if singleDataFile {
+		// The helper finds the table's byte range in the segment TOC and decompresses the data file as needed
+		readCommand = fmt.Sprintf("%s/bin/gpbackup_helper --extract-agent --oid %d --toc-file %s --data-file %s%s",
+			operating.System.Getenv("GPHOME"), oid, fpInfo.GetSegmentTOCFilePathForCopyCommand(), dataFile, getHelperEncryptionOption())
+	} else {
+		// The redirection comes first so that it applies to the first command when the input command is a pipeline
+		readCommand = fmt.Sprintf("< %s %s", dataFile, utils.GetPipeThroughProgram().InputCommand)
+	}
+	readCommand = strings.Replace(readCommand, "<SEGID>", "${SEGID}", -1)
+	return fmt.Sprintf("for SEGID in $(seq $GP_SEGMENT_ID %d %d); do %s || exit 1; done", restoreSegmentCount, backupSegmentCount-1, readCommand)
 }
 
 func restoreSingleTableData(fpInfo *filepath.FilePathInfo, entry toc.MasterDataEntry, tableName string, whichConn int) error {
-	destinationToRead := ""
-	if backupConfig.SingleDataFile {
-		destinationToRead = fmt.Sprintf("%s_%d", fpInfo.GetSegmentPipePathForCopyCommand(), entry.Oid)
+	var numRowsRestored int64
+	var err error
+	if MustGetFlagBool(options.RESIZE_CLUSTER) {
+		schema := getRedirectedTableSchema(entry.Schema, entry.Name)
+		externalTableName := utils.MakeFQN(schema, fmt.Sprintf("gprestore_resize_%d", entry.Oid))
+		readCommand := GetResizeReadCommand(*fpInfo, entry.Oid, backupConfig.SegmentCount, utils.GetSegmentCount(globalCluster), backupConfig.SingleDataFile)
+		numRowsRestored, err = CopyTableInResized(connectionPool, tableName, externalTableName, entry.AttributeString, readCommand, whichConn)
 	} else {
-		destinationToRead = fpInfo.GetTableBackupFilePathForCopyCommand(entry.Oid, utils.GetPipeThroughProgram().Extension, backupConfig.SingleDataFile)
+		destinationToRead := getDestinationToRead(fpInfo, entry)
+		numRowsRestored, err = CopyTableIn(connectionPool, tableName, entry.AttributeString, destinationToRead, backupConfig.SingleDataFile, whichConn)
 	}
-	numRowsRestored, err := CopyTableIn(connectionPool, tableName, entry.AttributeString, destinationToRead, backupConfig.SingleDataFile, whichConn)
 	if err != nil {
 		return err
 	}
@@ -75,6 +202,14 @@ func restoreSingleTableData(fpInfo *filepath.FilePathInfo, entry toc.MasterDataE
 	return nil
 }
 
+// Data in a single data file is fed by gpbackup_helper to a pipe for each table
+func getDestinationToRead(fpInfo *filepath.FilePathInfo, entry toc.MasterDataEntry) string {
+	if backupConfig.SingleDataFile {
+		return fmt.Sprintf("%s_%d", fpInfo.GetSegmentPipePathForCopyCommand(), entry.Oid)
+	}
+	return fpInfo.GetTableBackupFilePathForCopyCommand(entry.Oid, utils.GetPipeThroughProgram().Extension, backupConfig.SingleDataFile)
+}
+
 func CheckRowsRestored(rowsRestored int64, rowsBackedUp int64, tableName string) error {
 	if rowsRestored != rowsBackedUp {
 		rowsErrMsg := fmt.Sprintf("Expected to restore %d rows to table %s, but restored %d instead", rowsBackedUp, tableName, rowsRestored)
@@ -91,7 +226,9 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Ma
 		return
 	}
 
-	if backupConfig.SingleDataFile {
+	// When resizing, each table's data is read by its own helper process instead of by agents feeding pipes
+	useHelperAgents := backupConfig.SingleDataFile && !MustGetFlagBool(options.RESIZE_CLUSTER)
+	if useHelperAgents {
 		gplog.Verbose("Initializing pipes and gpbackup_helper on segments for single data file restore")
 		utils.VerifyHelperVersionOnSegments(version, globalCluster)
 		filteredOids := make([]string, totalTables)
@@ -104,7 +241,7 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Ma
 		if wasTerminated {
 			return
 		}
-		utils.StartGpbackupHelpers(globalCluster, fpInfo, "--restore-agent", MustGetFlagString(options.PLUGIN_CONFIG), "", MustGetFlagBool(options.ON_ERROR_CONTINUE))
+		utils.StartGpbackupHelpers(globalCluster, fpInfo, "--restore-agent", MustGetFlagString(options.PLUGIN_CONFIG), getHelperEncryptionOption(), MustGetFlagBool(options.ON_ERROR_CONTINUE))
 	}
 	/*
 	 * We break when an interrupt is received and rely on
@@ -127,11 +264,14 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Ma
 					dataProgressBar.(*pb.ProgressBar).NotPrint = true
 					return
 				}
-				tableName := utils.MakeFQN(entry.Schema, entry.Name)
-				if opts.RedirectSchema != "" {
-					tableName = utils.MakeFQN(opts.RedirectSchema, entry.Name)
-				}
+				tableName := getRedirectedTableFQN(entry.Schema, entry.Name)
+				start := time.Now()
 				err := restoreSingleTableData(&fpInfo, entry, tableName, whichConn)
+				var rowsRestored int64
+				if err == nil {
+					rowsRestored = entry.RowsCopied
+				}
+				tableResults.Add(tableName, rowsRestored, time.Since(start), err)
 
 				atomic.AddInt64(&tableNum, 1)
 				if gplog.GetVerbosity() > gplog.LOGINFO {
@@ -151,9 +291,11 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Ma
 					mutex.Lock()
 					errorTablesData[tableName] = Empty{}
 					mutex.Unlock()
+				} else {
+					restoreJournal.RecordTable(fpInfo.Timestamp, entry.Oid)
 				}
 
-				if backupConfig.SingleDataFile {
+				if useHelperAgents {
 					agentErr := utils.CheckAgentErrorsOnSegments(globalCluster, globalFPInfo)
 					if agentErr != nil {
 						gplog.Error(agentErr.Error())
@@ -1,10 +1,13 @@
 package restore_test
 
 import (
+	"os"
 	"regexp"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
 	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/filepath"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/restore"
 	"github.com/greenplum-db/gpbackup/utils"
@@ -89,6 +92,71 @@ var _ = Describe("restore/data tests", func() {
 				"ERROR: value of distribution key doesn't belong to segment with ID 0, it belongs to segment with ID 1 (SQLSTATE 22P04)"))
 		})
 	})
+	Describe("GetCopyTableInQuery", func() {
+		It("returns the COPY command that restores a table from its own file", func() {
+			utils.SetPipeThroughProgram(utils.PipeThroughProgram{Name: "gzip", OutputCommand: "gzip -c -1", InputCommand: "gzip -d -c", Extension: ".gz"})
+			_ = cmdFlags.Set(options.PLUGIN_CONFIG, "")
+			filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz"
+
+			query := restore.GetCopyTableInQuery("public.foo", "(i,j)", filename, false)
+
+			Expect(query).To(Equal("COPY public.foo(i,j) FROM PROGRAM 'cat <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz | gzip -d -c' WITH CSV DELIMITER ',' ON SEGMENT;"))
+		})
+	})
+	Describe("CopyTableInResized", func() {
+		It("loads the table through an external web table", func() {
+			columns := sqlmock.NewRows([]string{"name", "type"}).AddRow("j", "text").AddRow("i", "integer")
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(columns)
+			mock.ExpectExec(regexp.QuoteMeta("DROP EXTERNAL TABLE IF EXISTS public.gprestore_resize_3456;")).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(regexp.QuoteMeta("CREATE EXTERNAL WEB TABLE public.gprestore_resize_3456 (i integer, j text) EXECUTE 'cat - < /tmp/file''s' ON ALL FORMAT 'csv' (DELIMITER ',');")).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO public.foo(i,j) SELECT * FROM public.gprestore_resize_3456;")).WillReturnResult(sqlmock.NewResult(0, 10))
+			mock.ExpectExec(regexp.QuoteMeta("DROP EXTERNAL TABLE IF EXISTS public.gprestore_resize_3456;")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+			numRows, err := restore.CopyTableInResized(connectionPool, "public.foo", "public.gprestore_resize_3456", "(i,j)", "cat - < /tmp/file's", 0)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(numRows).To(Equal(int64(10)))
+		})
+		It("returns an error if a backed up column does not exist in the table", func() {
+			columns := sqlmock.NewRows([]string{"name", "type"}).AddRow("i", "integer")
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(columns)
+
+			_, err := restore.CopyTableInResized(connectionPool, "public.foo", "public.gprestore_resize_3456", "(i,j)", "cat - < /tmp/file", 0)
+
+			Expect(err).To(MatchError("Column j of table public.foo does not exist"))
+		})
+	})
+	Describe("SplitTableAttributes", func() {
+		It("splits an attribute list on commas outside of quotes", func() {
+			Expect(restore.SplitTableAttributes(`(i,"j,k",l)`)).To(Equal([]string{"i", `"j,k"`, "l"}))
+		})
+		It("returns no columns for an empty attribute list", func() {
+			Expect(restore.SplitTableAttributes("")).To(BeEmpty())
+		})
+	})
+	Describe("GetResizeReadCommand", func() {
+		var fpInfo filepath.FilePathInfo
+		BeforeEach(func() {
+			utils.SetPipeThroughProgram(utils.PipeThroughProgram{Name: "gzip", OutputCommand: "gzip -c -1", InputCommand: "gzip -d -c", Extension: ".gz"})
+			fpInfo = filepath.FilePathInfo{Timestamp: "20170101010101", UserSpecifiedBackupDir: "/backups", UserSpecifiedSegPrefix: "gpseg"}
+			operating.System.Getenv = func(key string) string { return "/usr/local/gpdb" }
+		})
+		AfterEach(func() {
+			operating.System.Getenv = os.Getenv
+		})
+		It("reads the file of each backup segment assigned to the restore segment", func() {
+			command := restore.GetResizeReadCommand(fpInfo, 3456, 4, 2, false)
+
+			Expect(command).To(Equal("for SEGID in $(seq $GP_SEGMENT_ID 2 3); do < /backups/gpseg${SEGID}/backups/20170101/20170101010101/gpbackup_${SEGID}_20170101010101_3456.gz gzip -d -c || exit 1; done"))
+		})
+		It("extracts the table from the single data file of each backup segment", func() {
+			command := restore.GetResizeReadCommand(fpInfo, 3456, 2, 4, true)
+
+			Expect(command).To(Equal("for SEGID in $(seq $GP_SEGMENT_ID 4 1); do /usr/local/gpdb/bin/gpbackup_helper --extract-agent --oid 3456 " +
+				"--toc-file /backups/gpseg${SEGID}/backups/20170101/20170101010101/gpbackup_${SEGID}_20170101010101_toc.yaml " +
+				"--data-file /backups/gpseg${SEGID}/backups/20170101/20170101010101/gpbackup_${SEGID}_20170101010101.gz || exit 1; done"))
+		})
+	})
 	Describe("CheckRowsRestored", func() {
 		var (
 			expectedRows int64 = 10
@@ -2,12 +2,14 @@ package restore
 
 import (
 	"sync"
+	"time"
 
 	"github.com/greenplum-db/gp-common-go-libs/cluster"
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gpbackup/filepath"
 	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/report"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/spf13/pflag"
@@ -40,6 +42,13 @@ var (
 	errorTablesMetadata map[string]Empty
 	errorTablesData     map[string]Empty
 	opts                *options.Options
+	restoreJournal      *RestoreJournal
+	encryptionKeyFile   string
+	// Tables restored from data backed up with a predicate, with their predicates
+	partialTables []string
+	tableResults  report.TableResults
+	phaseTimings  report.PhaseTimings
+	startTime     time.Time
 	/*
 	 * Used for synchronizing DoCleanup.  In DoInit() we increment the group
 	 * and then wait for at least one DoCleanup to finish, either in DoTeardown
@@ -92,6 +101,10 @@ func SetTOC(toc *toc.TOC) {
 	globalTOC = toc
 }
 
+func SetRestoreJournal(journal *RestoreJournal) {
+	restoreJournal = journal
+}
+
 // Util functions to enable ease of access to global flag values
 
 func MustGetFlagString(flagName string) string {
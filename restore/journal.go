@@ -0,0 +1,268 @@
+package restore
+
+/*
+ * This file contains functions related to the restore journal, which records
+ * the work completed by a restore so that a failed or interrupted restore can
+ * be resumed with --resume.
+ */
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Each line of the journal records one completed unit of work:
+ *   database <name>            the database being restored to
+ *   statement <sha256>         a pre-data or post-data statement that succeeded
+ *   table <timestamp> <oid>    a table whose data was restored
+ * Lines are written as soon as the work completes, so the journal is
+ * accurate even if the restore is terminated.
+ */
+type RestoreJournal struct {
+	Database          string
+	statements        map[string]bool
+	pendingStatements map[string]bool
+	tables            map[string]bool
+	writer            io.WriteCloser
+	writeFailed       bool
+	lock              sync.Mutex
+}
+
+func NewRestoreJournal(database string) *RestoreJournal {
+	return &RestoreJournal{
+		Database:          database,
+		statements:        make(map[string]bool),
+		pendingStatements: make(map[string]bool),
+		tables:            make(map[string]bool),
+	}
+}
+
+func ReadRestoreJournal(reader io.Reader) (*RestoreJournal, error) {
+	journal := NewRestoreJournal("")
+	scanner := bufio.NewScanner(reader)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, errors.Errorf("Invalid entry on line %d of restore journal: %s", lineNum, line)
+		}
+		switch fields[0] {
+		case "database":
+			journal.Database = fields[1]
+		case "statement":
+			journal.statements[fields[1]] = true
+		case "table":
+			tableFields := strings.Fields(fields[1])
+			if len(tableFields) != 2 {
+				return nil, errors.Errorf("Invalid entry on line %d of restore journal: %s", lineNum, line)
+			}
+			oid, err := strconv.ParseUint(tableFields[1], 10, 32)
+			if err != nil {
+				return nil, errors.Errorf("Invalid entry on line %d of restore journal: %s", lineNum, line)
+			}
+			journal.tables[getJournalTableKey(tableFields[0], uint32(oid))] = true
+		default:
+			return nil, errors.Errorf("Invalid entry on line %d of restore journal: %s", lineNum, line)
+		}
+	}
+	return journal, scanner.Err()
+}
+
+/*
+ * Writes the entries already in the journal to writer, so that the journal of
+ * a resumed restore covers the work of every earlier attempt, and records all
+ * subsequent entries to it.
+ */
+func (journal *RestoreJournal) Start(writer io.WriteCloser) error {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	lines := []string{fmt.Sprintf("database %s", journal.Database)}
+	statementKeys := make([]string, 0, len(journal.statements))
+	for key := range journal.statements {
+		statementKeys = append(statementKeys, key)
+	}
+	sort.Strings(statementKeys)
+	for _, key := range statementKeys {
+		lines = append(lines, fmt.Sprintf("statement %s", key))
+	}
+	tableKeys := make([]string, 0, len(journal.tables))
+	for key := range journal.tables {
+		tableKeys = append(tableKeys, key)
+	}
+	sort.Strings(tableKeys)
+	for _, key := range tableKeys {
+		lines = append(lines, fmt.Sprintf("table %s", key))
+	}
+	_, err := io.WriteString(writer, strings.Join(lines, "\n")+"\n")
+	if err != nil {
+		return err
+	}
+	journal.writer = writer
+	return nil
+}
+
+/*
+ * The methods below may be called on a nil journal, such as when a function
+ * is run in a test without a restore in progress, in which case nothing is
+ * skipped or recorded.
+ */
+
+/*
+ * Returns the statements that have not already succeeded.  Only statements
+ * passed through this function are recorded when they succeed, so that
+ * statements that must be run on every attempt, such as session GUCs, are
+ * never skipped.
+ */
+func (journal *RestoreJournal) SkipCompletedStatements(statements []toc.StatementWithType) []toc.StatementWithType {
+	if journal == nil {
+		return statements
+	}
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	remainingStatements := make([]toc.StatementWithType, 0, len(statements))
+	for _, statement := range statements {
+		key := getJournalStatementKey(statement)
+		if journal.statements[key] {
+			continue
+		}
+		journal.pendingStatements[key] = true
+		remainingStatements = append(remainingStatements, statement)
+	}
+	if numSkipped := len(statements) - len(remainingStatements); numSkipped > 0 {
+		gplog.Verbose("Skipping %d statement(s) completed by a previous restore attempt", numSkipped)
+	}
+	return remainingStatements
+}
+
+func (journal *RestoreJournal) RecordStatement(statement toc.StatementWithType) {
+	if journal == nil {
+		return
+	}
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	key := getJournalStatementKey(statement)
+	if !journal.pendingStatements[key] {
+		return
+	}
+	delete(journal.pendingStatements, key)
+	journal.statements[key] = true
+	journal.writeLine(fmt.Sprintf("statement %s", key))
+}
+
+func (journal *RestoreJournal) SkipRestoredTables(timestamp string, entries []toc.MasterDataEntry) []toc.MasterDataEntry {
+	if journal == nil {
+		return entries
+	}
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	remainingEntries := make([]toc.MasterDataEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !journal.tables[getJournalTableKey(timestamp, entry.Oid)] {
+			remainingEntries = append(remainingEntries, entry)
+		}
+	}
+	if numSkipped := len(entries) - len(remainingEntries); numSkipped > 0 {
+		gplog.Info("Skipping data for %d table(s) from backup with timestamp %s restored by a previous restore attempt", numSkipped, timestamp)
+	}
+	return remainingEntries
+}
+
+func (journal *RestoreJournal) RecordTable(timestamp string, oid uint32) {
+	if journal == nil {
+		return
+	}
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	key := getJournalTableKey(timestamp, oid)
+	journal.tables[key] = true
+	journal.writeLine(fmt.Sprintf("table %s", key))
+}
+
+func (journal *RestoreJournal) Close() {
+	if journal == nil {
+		return
+	}
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	if journal.writer != nil {
+		_ = journal.writer.Close()
+		journal.writer = nil
+	}
+}
+
+// The journal lock must be held when calling this function
+func (journal *RestoreJournal) writeLine(line string) {
+	if journal.writer == nil || journal.writeFailed {
+		return
+	}
+	_, err := io.WriteString(journal.writer, line+"\n")
+	if err != nil {
+		// A journal that cannot be written only means more work is redone on resume
+		gplog.Warn("Unable to write to restore journal: %v", err)
+		journal.writeFailed = true
+	}
+}
+
+func getJournalStatementKey(statement toc.StatementWithType) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(statement.Statement)))
+}
+
+func getJournalTableKey(timestamp string, oid uint32) string {
+	return fmt.Sprintf("%s %d", timestamp, oid)
+}
+
+func initializeRestoreJournal(database string) {
+	journal := NewRestoreJournal(database)
+	if MustGetFlagBool(options.RESUME) {
+		journal = readPreviousRestoreJournal(database)
+	}
+	journalFilename := globalFPInfo.GetRestoreJournalFilePath(restoreStartTime)
+	journalFile, err := operating.System.OpenFileWrite(journalFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	gplog.FatalOnError(err)
+	err = journal.Start(journalFile)
+	gplog.FatalOnError(err)
+	gplog.Verbose("Recording restore progress in %s", journalFilename)
+	restoreJournal = journal
+}
+
+// Journals are named by restore timestamp, so the last one sorted is the most recent
+func readPreviousRestoreJournal(database string) *RestoreJournal {
+	journalFilenames, err := operating.System.Glob(globalFPInfo.GetRestoreJournalFilePath("*"))
+	gplog.FatalOnError(err)
+	if len(journalFilenames) == 0 {
+		gplog.Fatal(errors.Errorf("Cannot resume restore of backup %s: no restore journal was found in %s.",
+			globalFPInfo.Timestamp, globalFPInfo.GetDirForContent(-1)), "")
+	}
+	sort.Strings(journalFilenames)
+	journalFilename := journalFilenames[len(journalFilenames)-1]
+	journalFile, err := operating.System.OpenFileRead(journalFilename, os.O_RDONLY, 0644)
+	gplog.FatalOnError(err)
+	defer journalFile.Close()
+	journal, err := ReadRestoreJournal(journalFile)
+	gplog.FatalOnError(err, fmt.Sprintf("Unable to read restore journal %s", journalFilename))
+	if journal.Database != database {
+		gplog.Fatal(errors.Errorf("Cannot resume restore: restore journal %s was written for database %s, not %s.",
+			journalFilename, journal.Database, database), "")
+	}
+	gplog.Info("Resuming restore recorded in %s", journalFilename)
+	return journal
+}
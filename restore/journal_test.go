@@ -0,0 +1,95 @@
+package restore_test
+
+import (
+	"strings"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gpbackup/restore"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("restore/journal tests", func() {
+	createTable := toc.StatementWithType{Schema: "public", Name: "foo", ObjectType: "TABLE", Statement: "CREATE TABLE public.foo (i int);"}
+	createView := toc.StatementWithType{Schema: "public", Name: "bar", ObjectType: "VIEW", Statement: "CREATE VIEW public.bar AS SELECT 1;"}
+	fooEntry := toc.MasterDataEntry{Schema: "public", Name: "foo", Oid: 1}
+	bazEntry := toc.MasterDataEntry{Schema: "public", Name: "baz", Oid: 2}
+	var journalBuffer *Buffer
+
+	BeforeEach(func() {
+		journalBuffer = NewBuffer()
+	})
+	Describe("RestoreJournal", func() {
+		It("records only statements that were checked against the journal", func() {
+			journal := restore.NewRestoreJournal("testdb")
+			Expect(journal.Start(journalBuffer)).To(Succeed())
+
+			remaining := journal.SkipCompletedStatements([]toc.StatementWithType{createTable})
+			Expect(remaining).To(Equal([]toc.StatementWithType{createTable}))
+			journal.RecordStatement(createTable)
+			journal.RecordStatement(createView)
+
+			lines := strings.Split(strings.TrimSpace(string(journalBuffer.Contents())), "\n")
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(Equal("database testdb"))
+			Expect(lines[1]).To(HavePrefix("statement "))
+		})
+		It("skips statements and tables read from a previous journal", func() {
+			journal := restore.NewRestoreJournal("testdb")
+			Expect(journal.Start(journalBuffer)).To(Succeed())
+			journal.SkipCompletedStatements([]toc.StatementWithType{createTable})
+			journal.RecordStatement(createTable)
+			journal.RecordTable("20170101010101", 1)
+
+			resumedJournal, err := restore.ReadRestoreJournal(strings.NewReader(string(journalBuffer.Contents())))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resumedJournal.Database).To(Equal("testdb"))
+			Expect(resumedJournal.SkipCompletedStatements([]toc.StatementWithType{createTable, createView})).To(Equal([]toc.StatementWithType{createView}))
+			Expect(resumedJournal.SkipRestoredTables("20170101010101", []toc.MasterDataEntry{fooEntry, bazEntry})).To(Equal([]toc.MasterDataEntry{bazEntry}))
+			Expect(resumedJournal.SkipRestoredTables("20170101010102", []toc.MasterDataEntry{fooEntry, bazEntry})).To(Equal([]toc.MasterDataEntry{fooEntry, bazEntry}))
+		})
+		It("writes the entries of a previous journal when started", func() {
+			previousJournal := "database testdb\ntable 20170101010101 1\n"
+			journal, err := restore.ReadRestoreJournal(strings.NewReader(previousJournal))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(journal.Start(journalBuffer)).To(Succeed())
+			journal.RecordTable("20170101010101", 2)
+
+			Expect(string(journalBuffer.Contents())).To(Equal("database testdb\ntable 20170101010101 1\ntable 20170101010101 2\n"))
+		})
+		It("returns an error for an invalid journal entry", func() {
+			_, err := restore.ReadRestoreJournal(strings.NewReader("database testdb\ntable 20170101010101 foo\n"))
+			Expect(err).To(MatchError("Invalid entry on line 2 of restore journal: table 20170101010101 foo"))
+		})
+		It("does nothing when there is no journal", func() {
+			var journal *restore.RestoreJournal
+			Expect(journal.SkipCompletedStatements([]toc.StatementWithType{createTable})).To(Equal([]toc.StatementWithType{createTable}))
+			Expect(journal.SkipRestoredTables("20170101010101", []toc.MasterDataEntry{fooEntry})).To(Equal([]toc.MasterDataEntry{fooEntry}))
+			journal.RecordStatement(createTable)
+			journal.RecordTable("20170101010101", 1)
+			journal.Close()
+		})
+	})
+	Describe("ExecuteStatements", func() {
+		AfterEach(func() {
+			restore.SetRestoreJournal(nil)
+		})
+		It("records statements that succeed in the restore journal", func() {
+			journal := restore.NewRestoreJournal("testdb")
+			Expect(journal.Start(journalBuffer)).To(Succeed())
+			restore.SetRestoreJournal(journal)
+			statements := journal.SkipCompletedStatements([]toc.StatementWithType{createTable})
+			mock.ExpectExec("CREATE TABLE public.foo").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			restore.ExecuteStatements(statements, utils.NewProgressBar(len(statements), "", utils.PB_NONE), false)
+
+			resumedJournal, err := restore.ReadRestoreJournal(strings.NewReader(string(journalBuffer.Contents())))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resumedJournal.SkipCompletedStatements([]toc.StatementWithType{createTable})).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,59 @@
+package restore
+
+/*
+ * This file contains functions related to writing the Prometheus metrics
+ * file of a restore with --metrics-dir.
+ */
+
+import (
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+)
+
+func writeMetricsFile(restoreFailed bool) {
+	metricsDir := MustGetFlagString(options.METRICS_DIR)
+	if metricsDir == "" {
+		return
+	}
+	database := MustGetFlagString(options.REDIRECT_DB)
+	if database == "" && backupConfig != nil {
+		database = utils.UnquoteIdent(backupConfig.DatabaseName)
+	}
+	completed, failed, skipped := tableResults.Counts()
+	metrics := report.Metrics{
+		Utility:         "gprestore",
+		Database:        database,
+		Success:         !restoreFailed && gplog.GetErrorCode() != 2,
+		StartTime:       startTime,
+		EndTime:         time.Now(),
+		TablesCompleted: completed,
+		TablesFailed:    failed,
+		TablesSkipped:   skipped,
+		PhaseDurations:  phaseTimings.Durations(),
+	}
+	err := report.WriteMetricsFile(metricsDir, metrics)
+	if err != nil {
+		gplog.Warn("Unable to write metrics file to %s: %v", metricsDir, err)
+	}
+}
+
+// Tables are skipped when a previous attempt of a resumed restore restored them
+func recordSkippedTables(entries []toc.MasterDataEntry, remainingEntries []toc.MasterDataEntry) {
+	if len(entries) == len(remainingEntries) {
+		return
+	}
+	remainingOids := make(map[uint32]bool, len(remainingEntries))
+	for _, entry := range remainingEntries {
+		remainingOids[entry.Oid] = true
+	}
+	for _, entry := range entries {
+		if !remainingOids[entry.Oid] {
+			tableResults.AddSkipped(getRedirectedTableFQN(entry.Schema, entry.Name))
+		}
+	}
+}
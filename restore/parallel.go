@@ -25,27 +25,36 @@ func executeStatementsForConn(statements chan toc.StatementWithType, fatalErr *e
 		if wasTerminated || *fatalErr != nil {
 			return
 		}
-		_, err := connectionPool.Exec(statement.Statement, whichConn)
-		if err != nil {
-			gplog.Verbose("Error encountered when executing statement: %s Error was: %s", strings.TrimSpace(statement.Statement), err.Error())
-			if MustGetFlagBool(options.ON_ERROR_CONTINUE) {
-				if executeInParallel {
-					atomic.AddInt32(numErrors, 1)
-					mutex.Lock()
-					errorTablesMetadata[statement.Schema+"."+statement.Name] = Empty{}
-					mutex.Unlock()
-				} else {
-					*numErrors = *numErrors + 1
-					errorTablesMetadata[statement.Schema+"."+statement.Name] = Empty{}
-				}
-			} else {
-				*fatalErr = err
-			}
+		if err := executeStatement(statement, numErrors, whichConn, executeInParallel); err != nil {
+			*fatalErr = err
 		}
 		progressBar.Increment()
 	}
 }
 
+// Returns an error only if the error should stop the restore
+func executeStatement(statement toc.StatementWithType, numErrors *int32, whichConn int, executeInParallel bool) error {
+	_, err := connectionPool.Exec(statement.Statement, whichConn)
+	if err != nil {
+		gplog.Verbose("Error encountered when executing statement: %s Error was: %s", strings.TrimSpace(statement.Statement), err.Error())
+		if !MustGetFlagBool(options.ON_ERROR_CONTINUE) {
+			return err
+		}
+		if executeInParallel {
+			atomic.AddInt32(numErrors, 1)
+			mutex.Lock()
+			errorTablesMetadata[statement.Schema+"."+statement.Name] = Empty{}
+			mutex.Unlock()
+		} else {
+			*numErrors = *numErrors + 1
+			errorTablesMetadata[statement.Schema+"."+statement.Name] = Empty{}
+		}
+	} else {
+		restoreJournal.RecordStatement(statement)
+	}
+	return nil
+}
+
 /*
  * This function creates a worker pool of N goroutines to be able to execute up
  * to N statements in parallel.
@@ -74,6 +83,10 @@ func ExecuteStatements(statements []toc.StatementWithType, progressBar utils.Pro
 		}
 		workerPool.Wait()
 	}
+	handleStatementErrors(fatalErr, numErrors)
+}
+
+func handleStatementErrors(fatalErr error, numErrors int32) {
 	if fatalErr != nil {
 		fmt.Println("")
 		gplog.Fatal(fatalErr, "")
@@ -83,6 +96,113 @@ func ExecuteStatements(statements []toc.StatementWithType, progressBar utils.Pro
 	}
 }
 
+/*
+ * Returns, for each statement, the indices of the statements that must run
+ * after it and the number of statements that must run before it.  A statement
+ * depends on the statements with the IDs it lists that come before it; IDs of
+ * statements that were filtered out or already restored are ignored.  A
+ * statement without an ID, such as one from a backup that did not record
+ * dependencies, depends on every statement before it, and every statement
+ * after it depends on it.
+ */
+func GetStatementDependencies(statements []toc.StatementWithType) ([][]int, []int) {
+	dependents := make([][]int, len(statements))
+	numPrerequisites := make([]int, len(statements))
+	addDependency := func(prerequisite int, dependent int) {
+		dependents[prerequisite] = append(dependents[prerequisite], dependent)
+		numPrerequisites[dependent]++
+	}
+
+	indexForID := make(map[uint64]int)
+	lastBarrier := -1
+	for i, statement := range statements {
+		if statement.ID == 0 {
+			for j := lastBarrier; j < i; j++ {
+				if j >= 0 {
+					addDependency(j, i)
+				}
+			}
+			lastBarrier = i
+			continue
+		}
+		if lastBarrier >= 0 {
+			addDependency(lastBarrier, i)
+		}
+		isPrerequisite := make(map[int]bool, len(statement.DependsOn))
+		for _, id := range statement.DependsOn {
+			if index, ok := indexForID[id]; ok && index > lastBarrier && !isPrerequisite[index] {
+				isPrerequisite[index] = true
+				addDependency(index, i)
+			}
+		}
+		indexForID[statement.ID] = i
+	}
+	return dependents, numPrerequisites
+}
+
+/*
+ * Statements are run on all connections, each as soon as the statements it
+ * depends on have run, so that objects that do not depend on each other are
+ * created in parallel.  With --on-error-continue, a statement whose
+ * prerequisite failed is still run, as it would be when run serially.
+ */
+func ExecuteStatementsByDependency(statements []toc.StatementWithType, progressBar utils.ProgressBar) {
+	if len(statements) == 0 {
+		return
+	}
+	dependents, numPrerequisites := GetStatementDependencies(statements)
+	ready := make(chan int, len(statements))
+	for i, num := range numPrerequisites {
+		if num == 0 {
+			ready <- i
+		}
+	}
+
+	var workerPool sync.WaitGroup
+	var fatalErr error
+	var numErrors int32
+	var lock sync.Mutex
+	numRemaining := len(statements)
+	isStopped := false
+	for i := 0; i < connectionPool.NumConns; i++ {
+		workerPool.Add(1)
+		go func(whichConn int) {
+			defer workerPool.Done()
+			for index := range ready {
+				lock.Lock()
+				skip := isStopped
+				lock.Unlock()
+				if skip {
+					continue
+				}
+				err := executeStatement(statements[index], &numErrors, whichConn, true)
+				progressBar.Increment()
+
+				lock.Lock()
+				numRemaining--
+				if err != nil && fatalErr == nil {
+					fatalErr = err
+				}
+				if !isStopped && (wasTerminated || fatalErr != nil || numRemaining == 0) {
+					isStopped = true
+					close(ready)
+				}
+				if !isStopped {
+					for _, dependent := range dependents[index] {
+						numPrerequisites[dependent]--
+						if numPrerequisites[dependent] == 0 {
+							ready <- dependent
+						}
+					}
+				}
+				lock.Unlock()
+			}
+		}(connectionPool.ValidateConnNum(i))
+	}
+	workerPool.Wait()
+	handleStatementErrors(fatalErr, numErrors)
+}
+
 func ExecuteStatementsAndCreateProgressBar(statements []toc.StatementWithType, objectsTitle string, showProgressBar int, executeInParallel bool, whichConn ...int) {
 	progressBar := utils.NewProgressBar(len(statements), fmt.Sprintf("%s restored: ", objectsTitle), showProgressBar)
 	progressBar.Start()
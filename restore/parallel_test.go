@@ -1,8 +1,10 @@
 package restore_test
 
 import (
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/greenplum-db/gpbackup/restore"
 	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -34,4 +36,60 @@ var _ = Describe("restore/parallel tests", func() {
 		})
 
 	})
+	Describe("GetStatementDependencies", func() {
+		It("makes each statement depend on the earlier statements with the IDs it lists", func() {
+			statements := []toc.StatementWithType{
+				{ID: 1},
+				{ID: 2},
+				{ID: 3, DependsOn: []uint64{1, 2}},
+				{ID: 4, DependsOn: []uint64{3}},
+			}
+
+			dependents, numPrerequisites := restore.GetStatementDependencies(statements)
+
+			Expect(dependents).To(Equal([][]int{{2}, {2}, {3}, nil}))
+			Expect(numPrerequisites).To(Equal([]int{0, 0, 2, 1}))
+		})
+		It("ignores IDs of statements that are not being restored", func() {
+			statements := []toc.StatementWithType{
+				{ID: 2, DependsOn: []uint64{1}},
+				{ID: 3, DependsOn: []uint64{1, 2, 2}},
+			}
+
+			dependents, numPrerequisites := restore.GetStatementDependencies(statements)
+
+			Expect(dependents).To(Equal([][]int{{1}, nil}))
+			Expect(numPrerequisites).To(Equal([]int{0, 1}))
+		})
+		It("runs statements without an ID after every statement before them and before every statement after them", func() {
+			statements := []toc.StatementWithType{
+				{ID: 1},
+				{ID: 2},
+				{},
+				{ID: 3, DependsOn: []uint64{1}},
+				{ID: 4},
+			}
+
+			dependents, numPrerequisites := restore.GetStatementDependencies(statements)
+
+			Expect(dependents).To(Equal([][]int{{2}, {2}, {3, 4}, nil, nil}))
+			Expect(numPrerequisites).To(Equal([]int{0, 0, 2, 1, 1}))
+		})
+	})
+	Describe("ExecuteStatementsByDependency", func() {
+		It("runs each statement after the statements it depends on", func() {
+			statements := []toc.StatementWithType{
+				{Schema: "public", Name: "add(integer, integer)", ObjectType: "FUNCTION", Statement: "CREATE FUNCTION public.add", ID: 1},
+				{Schema: "public", Name: "add_view", ObjectType: "VIEW", Statement: "CREATE VIEW public.add_view", ID: 3, DependsOn: []uint64{1}},
+				{Schema: "public", Name: "foo", ObjectType: "TABLE", Statement: "CREATE TABLE public.foo", ID: 2},
+			}
+			mock.ExpectExec("CREATE FUNCTION public.add").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("CREATE TABLE public.foo").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("CREATE VIEW public.add_view").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			restore.ExecuteStatementsByDependency(statements, utils.NewProgressBar(len(statements), "", utils.PB_NONE))
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
 })
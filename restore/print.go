@@ -0,0 +1,177 @@
+package restore
+
+/*
+ * This file contains functions related to printing the SQL that a restore
+ * would run, without connecting to the database.
+ */
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+func isPrintSQL() bool {
+	return MustGetFlagBool(options.PRINT_SQL) || MustGetFlagString(options.PRINT_SQL_FILE) != ""
+}
+
+/*
+ * Without a connection there is no segment configuration, so only the master
+ * is known, and the backup is found either in --backup-dir or in the data
+ * directory given by MASTER_DATA_DIRECTORY.
+ */
+func setupPrintSQL() {
+	var err error
+	opts, err = options.NewOptions(cmdFlags)
+	gplog.FatalOnError(err)
+
+	backupDir := MustGetFlagString(options.BACKUP_DIR)
+	masterDataDir := operating.System.Getenv("MASTER_DATA_DIRECTORY")
+	if backupDir == "" && masterDataDir == "" {
+		gplog.Fatal(errors.Errorf("Cannot find the backup without a database connection.  Use --backup-dir or set MASTER_DATA_DIRECTORY."), "")
+	}
+	globalCluster = cluster.NewCluster([]cluster.SegConfig{{ContentID: -1, DataDir: masterDataDir}})
+	segPrefix := filepath.ParseSegPrefix(backupDir, MustGetFlagString(options.TIMESTAMP))
+	globalFPInfo = filepath.NewFilePathInfo(globalCluster, backupDir, MustGetFlagString(options.TIMESTAMP), segPrefix)
+
+	backupConfig = history.ReadConfigFile(globalFPInfo.GetConfigFilePath())
+	err = utils.InitializePipeThroughParameters(backupConfig.Compressed, backupConfig.CompressionType, 0)
+	gplog.FatalOnError(err)
+	report.EnsureBackupVersionCompatibility(backupConfig.BackupVersion, version)
+	if backupConfig.Encrypted {
+		initializePrintSQLEncryption()
+	}
+
+	VerifyMetadataFilePaths(MustGetFlagBool(options.WITH_STATS))
+	initializeGlobalTOC()
+	ValidateBackupFlagCombinations()
+
+	quoteIncludeRelationsFromBackupSet()
+	expandFilterPatterns()
+	validateFilterListsInBackupSet()
+}
+
+// Log messages that would go to stdout go to stderr, so that stdout has only SQL
+func redirectLoggingToStderr() {
+	logFile, err := os.OpenFile(gplog.GetLogFilePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	gplog.FatalOnError(err)
+	gplog.SetLogger(gplog.NewLogger(os.Stderr, os.Stderr, logFile, gplog.GetLogFilePath(), gplog.GetVerbosity(), "gprestore", gplog.GetLogFileVerbosity()))
+}
+
+/*
+ * The metadata is decrypted with the key as usual, but the key is not copied
+ * to the segments, so the COPY commands name the file to which a restore
+ * would copy it.
+ */
+func initializePrintSQLEncryption() {
+	key, err := utils.ReadEncryptionKey(MustGetFlagString(options.ENCRYPTION_KEY_FILE))
+	gplog.FatalOnError(err)
+	ValidateEncryptionKey(key)
+	utils.SetEncryptionKey(key)
+	if backupConfig.MetadataOnly {
+		return
+	}
+	utils.AddEncryptionToPipeThroughProgram(fmt.Sprintf("/tmp/gprestore_%s_%s_encryption_key", globalFPInfo.Timestamp, restoreStartTime))
+}
+
+func DoPrintSQL() {
+	writer := io.Writer(os.Stdout)
+	if sqlFilename := MustGetFlagString(options.PRINT_SQL_FILE); sqlFilename != "" {
+		sqlFile, err := os.OpenFile(sqlFilename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		gplog.FatalOnError(err)
+		defer func() {
+			err := sqlFile.Close()
+			gplog.FatalOnError(err)
+		}()
+		writer = sqlFile
+		gplog.Info("Writing restore SQL to %s", sqlFilename)
+	}
+
+	statements := getRestoreSQLStatements()
+	for _, statement := range statements {
+		_, err := fmt.Fprint(writer, statement.Statement)
+		gplog.FatalOnError(err)
+	}
+	_, err := fmt.Fprintln(writer)
+	gplog.FatalOnError(err)
+	gplog.Info("Printed %d SQL statements", len(statements))
+}
+
+/*
+ * Statements are returned in the order that a restore runs them, using the
+ * same functions as the restore to select and edit them.  A restore with
+ * multiple connections runs the pre-data, data, and post-data statements in
+ * parallel, so statements in those sections may run in a different order.
+ */
+func getRestoreSQLStatements() []toc.StatementWithType {
+	metadataFilename := globalFPInfo.GetMetadataFilePath()
+	isDataOnly := backupConfig.DataOnly || MustGetFlagBool(options.DATA_ONLY)
+	isMetadataOnly := backupConfig.MetadataOnly || MustGetFlagBool(options.METADATA_ONLY)
+	quotedRedirectDB := ""
+	if MustGetFlagString(options.REDIRECT_DB) != "" {
+		quotedRedirectDB = utils.QuoteIdentWithoutConnection(MustGetFlagString(options.REDIRECT_DB))
+	}
+
+	statements := make([]toc.StatementWithType, 0)
+	if MustGetFlagBool(options.WITH_GLOBALS) {
+		activeUser := dbconn.NewDBConnFromEnvironment("postgres").User
+		statements = append(statements, getGlobalStatements(metadataFilename, quotedRedirectDB, activeUser)...)
+	} else if MustGetFlagBool(options.CREATE_DB) {
+		statements = append(statements, getCreateDatabaseStatements(metadataFilename, quotedRedirectDB)...)
+	}
+	if !isDataOnly {
+		filters := NewFilters(opts.IncludedSchemas, opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations)
+		schemaStatements, predataStatements := getPredataStatements(metadataFilename, filters)
+		statements = append(statements, schemaStatements...)
+		statements = append(statements, predataStatements...)
+	}
+	if !isMetadataOnly && shouldRestoreTableData() {
+		statements = append(statements, getDataStatements()...)
+	}
+	if !isDataOnly {
+		firstBatch, secondBatch := BatchPostdataStatements(getPostdataStatements(metadataFilename))
+		statements = append(statements, firstBatch...)
+		statements = append(statements, secondBatch...)
+	}
+	if MustGetFlagBool(options.WITH_STATS) && backupConfig.WithStatistics && shouldRestoreTableData() {
+		statements = append(statements, getStatisticsStatements(globalFPInfo.GetStatisticsFilePath())...)
+	}
+	return statements
+}
+
+func getDataStatements() []toc.StatementWithType {
+	objectTypes := []string{"SESSION GUCS"}
+	statements := GetRestoreMetadataStatements("global", globalFPInfo.GetMetadataFilePath(), objectTypes, []string{})
+	for _, planEntry := range backupConfig.RestorePlan {
+		fpInfo := GetBackupFPInfoForTimestamp(planEntry.Timestamp)
+		tocfile := toc.NewTOC(fpInfo.GetTOCFilePath())
+		entries := tocfile.GetDataEntriesMatching(opts.IncludedSchemas, opts.ExcludedSchemas,
+			opts.IncludedRelations, opts.ExcludedRelations, planEntry.TableFQNs)
+		if len(entries) == 0 {
+			continue
+		}
+		if MustGetFlagBool(options.TRUNCATE_TABLE) {
+			statements = append(statements, toc.StatementWithType{ObjectType: "TABLE DATA",
+				Statement: fmt.Sprintf("\n\n%s", getTruncateTablesQuery(entries))})
+		}
+		for _, entry := range entries {
+			query := GetCopyTableInQuery(getRedirectedTableFQN(entry.Schema, entry.Name), entry.AttributeString,
+				getDestinationToRead(&fpInfo, entry), backupConfig.SingleDataFile)
+			statements = append(statements, toc.StatementWithType{Schema: entry.Schema, Name: entry.Name,
+				ObjectType: "TABLE DATA", Statement: fmt.Sprintf("\n\n%s", query)})
+		}
+	}
+	return statements
+}
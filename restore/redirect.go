@@ -0,0 +1,159 @@
+package restore
+
+/*
+ * This file contains functions related to restoring tables under new names
+ * with --redirect-table-file.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+)
+
+var tupleStatisticsTableRegex = regexp.MustCompile(`WHERE relname = '(?:[^']|'')*'\nAND relnamespace = \d+;`)
+
+/*
+ * Renames the tables in redirectTables in the given statements.  Objects that
+ * reference a redirected table, such as its indexes, constraints, triggers,
+ * rules and owned sequences, are moved to the table's new schema, and the old
+ * table name in their names is replaced with the new one so that they do not
+ * collide with the objects of a table of the old name in that schema.  For
+ * example, the index public.foo_pkey of table public.foo becomes
+ * scratch.foo_copy_pkey when public.foo is redirected to scratch.foo_copy.
+ */
+func EditStatementsRedirectTables(statements []toc.StatementWithType, redirectTables map[string]string) {
+	if len(redirectTables) == 0 {
+		return
+	}
+
+	// Names are qualified in most statements, so each renamed object is first replaced by its qualified name
+	renamedObjects := make(map[string]string, len(redirectTables))
+	for sourceTable, targetTable := range redirectTables {
+		renamedObjects[sourceTable] = targetTable
+	}
+	for _, statement := range statements {
+		targetTable, ok := redirectTables[statement.ReferenceObject]
+		if !ok {
+			continue
+		}
+		objectFQN := utils.MakeFQN(statement.Schema, statement.Name)
+		if _, ok := renamedObjects[objectFQN]; ok {
+			continue
+		}
+		renamedObjects[objectFQN] = getRedirectedObjectFQN(statement.Name, statement.ReferenceObject, targetTable)
+	}
+
+	for i, statement := range statements {
+		objectFQN := utils.MakeFQN(statement.Schema, statement.Name)
+		redirectedFQN, isRenamed := renamedObjects[objectFQN]
+		if isRenamed && statement.ObjectType == "STATISTICS" {
+			statement.Statement = tupleStatisticsTableRegex.ReplaceAllLiteralString(statement.Statement,
+				fmt.Sprintf("WHERE oid = '%s'::regclass::oid;", utils.EscapeSingleQuotes(redirectedFQN)))
+		}
+		statement.Statement = replaceIdentifiers(statement.Statement, renamedObjects)
+		if isRenamed {
+			fqn := splitRedirectedFQN(redirectedFQN)
+			statement.Schema = fqn.SchemaName
+			// Objects such as indexes and triggers are named without their schema when they are created
+			if statement.ObjectType != "TABLE" && statement.ObjectType != "SEQUENCE" && statement.ObjectType != "STATISTICS" {
+				statement.Statement = replaceIdentifiers(statement.Statement, map[string]string{statement.Name: fqn.TableName})
+			}
+			statement.Name = fqn.TableName
+		}
+		if targetTable, ok := renamedObjects[statement.ReferenceObject]; ok {
+			statement.ReferenceObject = targetTable
+		}
+		statements[i] = statement
+	}
+}
+
+/*
+ * Returns the name under which an object referencing sourceTable is restored
+ * when that table is redirected to targetTable.
+ */
+func getRedirectedObjectFQN(objectName string, sourceTable string, targetTable string) string {
+	sourceName := utils.UnquoteIdent(splitRedirectedFQN(sourceTable).TableName)
+	target := splitRedirectedFQN(targetTable)
+	targetName := utils.UnquoteIdent(target.TableName)
+	unquotedObjectName := utils.UnquoteIdent(objectName)
+	if !strings.Contains(unquotedObjectName, sourceName) {
+		return utils.MakeFQN(target.SchemaName, objectName)
+	}
+	redirectedName := strings.Replace(unquotedObjectName, sourceName, targetName, 1)
+	return utils.MakeFQN(target.SchemaName, utils.QuoteIdent(connectionPool, redirectedName))
+}
+
+// Names in the redirect table file cannot contain dots, so the first dot separates the schema and table
+func splitRedirectedFQN(fqn string) options.FqnStruct {
+	parts := strings.SplitN(fqn, ".", 2)
+	if len(parts) != 2 {
+		return options.FqnStruct{TableName: fqn}
+	}
+	return options.FqnStruct{SchemaName: parts[0], TableName: parts[1]}
+}
+
+/*
+ * Replaces each identifier in statement that is a key of replacements with its
+ * value.  Identifiers are only replaced where they are not part of a longer
+ * identifier, so that replacing public.foo does not alter public.foobar or
+ * other.public.foo, and all are replaced in a single pass so that tables whose
+ * names are swapped are each renamed once.
+ */
+func replaceIdentifiers(statement string, replacements map[string]string) string {
+	var result strings.Builder
+	for i := 0; i < len(statement); {
+		oldIdentifier := ""
+		if i == 0 || (!isIdentifierByte(statement[i-1]) && statement[i-1] != '.') {
+			for identifier := range replacements {
+				end := i + len(identifier)
+				if len(identifier) > len(oldIdentifier) && strings.HasPrefix(statement[i:], identifier) &&
+					(end == len(statement) || !isIdentifierByte(statement[end])) {
+					oldIdentifier = identifier
+				}
+			}
+		}
+		if oldIdentifier == "" {
+			result.WriteByte(statement[i])
+			i++
+			continue
+		}
+		result.WriteString(replacements[oldIdentifier])
+		i += len(oldIdentifier)
+	}
+	return result.String()
+}
+
+func isIdentifierByte(b byte) bool {
+	return b == '_' || b == '$' || b == '"' || b >= 0x80 ||
+		('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
+
+/*
+ * Returns the name of the table that the data of the given table is restored
+ * to, taking --redirect-schema and --redirect-table-file into account.
+ */
+func getRedirectedTableFQN(schema string, name string) string {
+	tableFQN := utils.MakeFQN(schema, name)
+	if opts.RedirectSchema != "" {
+		return utils.MakeFQN(opts.RedirectSchema, name)
+	}
+	if targetTable, ok := opts.RedirectTables[tableFQN]; ok {
+		return targetTable
+	}
+	return tableFQN
+}
+
+func getRedirectedTableSchema(schema string, name string) string {
+	if opts.RedirectSchema != "" {
+		return opts.RedirectSchema
+	}
+	if targetTable, ok := opts.RedirectTables[utils.MakeFQN(schema, name)]; ok {
+		return splitRedirectedFQN(targetTable).SchemaName
+	}
+	return schema
+}
@@ -0,0 +1,109 @@
+package restore_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gpbackup/restore"
+	"github.com/greenplum-db/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore/redirect tests", func() {
+	Describe("EditStatementsRedirectTables", func() {
+		redirectTables := map[string]string{"public.foo": "scratch.foo_copy"}
+		It("does not alter statements if no tables are redirected", func() {
+			statements := []toc.StatementWithType{
+				{Schema: "public", Name: "foo", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.foo (\n\ti integer\n) DISTRIBUTED BY (i);\n"},
+			}
+			expectedStatements := make([]toc.StatementWithType, len(statements))
+			copy(expectedStatements, statements)
+
+			restore.EditStatementsRedirectTables(statements, map[string]string{})
+
+			Expect(statements).To(Equal(expectedStatements))
+		})
+		It("renames a redirected table and its owned sequence", func() {
+			statements := []toc.StatementWithType{
+				{Schema: "public", Name: "foo_id_seq", ObjectType: "SEQUENCE", Statement: "\n\nCREATE SEQUENCE public.foo_id_seq\n\tINCREMENT BY 1;\n\nSELECT pg_catalog.setval('public.foo_id_seq', 1, false);\n"},
+				{Schema: "public", Name: "foo", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.foo (\n\tid integer DEFAULT nextval('public.foo_id_seq'::regclass)\n) DISTRIBUTED BY (id);\n"},
+				{Schema: "public", Name: "foo", ObjectType: "TABLE", Statement: "\n\nCOMMENT ON TABLE public.foo IS 'public.foo table';\n"},
+				{Schema: "public", Name: "foobar", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.foobar (\n\tfoo integer\n) DISTRIBUTED BY (foo);\n"},
+				{Schema: "public", Name: "foo_id_seq", ObjectType: "SEQUENCE OWNER", ReferenceObject: "public.foo", Statement: "\n\nALTER SEQUENCE public.foo_id_seq OWNED BY public.foo.id;\n"},
+			}
+			mock.ExpectQuery("SELECT quote_ident\\('foo_copy_id_seq'\\)").WillReturnRows(sqlmock.NewRows([]string{"quote_ident"}).AddRow("foo_copy_id_seq"))
+
+			restore.EditStatementsRedirectTables(statements, redirectTables)
+
+			expectedStatements := []toc.StatementWithType{
+				{Schema: "scratch", Name: "foo_copy_id_seq", ObjectType: "SEQUENCE", Statement: "\n\nCREATE SEQUENCE scratch.foo_copy_id_seq\n\tINCREMENT BY 1;\n\nSELECT pg_catalog.setval('scratch.foo_copy_id_seq', 1, false);\n"},
+				{Schema: "scratch", Name: "foo_copy", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE scratch.foo_copy (\n\tid integer DEFAULT nextval('scratch.foo_copy_id_seq'::regclass)\n) DISTRIBUTED BY (id);\n"},
+				{Schema: "scratch", Name: "foo_copy", ObjectType: "TABLE", Statement: "\n\nCOMMENT ON TABLE scratch.foo_copy IS 'scratch.foo_copy table';\n"},
+				{Schema: "public", Name: "foobar", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.foobar (\n\tfoo integer\n) DISTRIBUTED BY (foo);\n"},
+				{Schema: "scratch", Name: "foo_copy_id_seq", ObjectType: "SEQUENCE OWNER", ReferenceObject: "scratch.foo_copy", Statement: "\n\nALTER SEQUENCE scratch.foo_copy_id_seq OWNED BY scratch.foo_copy.id;\n"},
+			}
+			Expect(statements).To(Equal(expectedStatements))
+		})
+		It("renames the objects that reference a redirected table", func() {
+			statements := []toc.StatementWithType{
+				{Schema: "public", Name: "foo_idx", ObjectType: "INDEX", ReferenceObject: "public.foo", Statement: "\n\nCREATE INDEX foo_idx ON public.foo USING btree (id);\n\nALTER INDEX public.foo_idx SET TABLESPACE test_tablespace;\n"},
+				{Schema: "public", Name: "foo_pkey", ObjectType: "CONSTRAINT", ReferenceObject: "public.foo", Statement: "\n\nALTER TABLE ONLY public.foo ADD CONSTRAINT foo_pkey PRIMARY KEY (id);\n"},
+				{Schema: "public", Name: "audit", ObjectType: "TRIGGER", ReferenceObject: "public.foo", Statement: "\n\nCREATE TRIGGER audit AFTER INSERT ON public.foo FOR EACH ROW EXECUTE PROCEDURE public.audit_func();\n"},
+				{Schema: "public", Name: "bar_idx", ObjectType: "INDEX", ReferenceObject: "public.bar", Statement: "\n\nCREATE INDEX bar_idx ON public.bar USING btree (id);\n"},
+			}
+			mock.ExpectQuery("SELECT quote_ident\\('foo_copy_idx'\\)").WillReturnRows(sqlmock.NewRows([]string{"quote_ident"}).AddRow("foo_copy_idx"))
+			mock.ExpectQuery("SELECT quote_ident\\('foo_copy_pkey'\\)").WillReturnRows(sqlmock.NewRows([]string{"quote_ident"}).AddRow("foo_copy_pkey"))
+
+			restore.EditStatementsRedirectTables(statements, redirectTables)
+
+			expectedStatements := []toc.StatementWithType{
+				{Schema: "scratch", Name: "foo_copy_idx", ObjectType: "INDEX", ReferenceObject: "scratch.foo_copy", Statement: "\n\nCREATE INDEX foo_copy_idx ON scratch.foo_copy USING btree (id);\n\nALTER INDEX scratch.foo_copy_idx SET TABLESPACE test_tablespace;\n"},
+				{Schema: "scratch", Name: "foo_copy_pkey", ObjectType: "CONSTRAINT", ReferenceObject: "scratch.foo_copy", Statement: "\n\nALTER TABLE ONLY scratch.foo_copy ADD CONSTRAINT foo_copy_pkey PRIMARY KEY (id);\n"},
+				{Schema: "scratch", Name: "audit", ObjectType: "TRIGGER", ReferenceObject: "scratch.foo_copy", Statement: "\n\nCREATE TRIGGER audit AFTER INSERT ON scratch.foo_copy FOR EACH ROW EXECUTE PROCEDURE public.audit_func();\n"},
+				{Schema: "public", Name: "bar_idx", ObjectType: "INDEX", ReferenceObject: "public.bar", Statement: "\n\nCREATE INDEX bar_idx ON public.bar USING btree (id);\n"},
+			}
+			Expect(statements).To(Equal(expectedStatements))
+		})
+		It("renames each of two tables whose names are swapped once", func() {
+			statements := []toc.StatementWithType{
+				{Schema: "public", Name: "v", ObjectType: "VIEW", Statement: "\n\nCREATE VIEW public.v AS  SELECT foo.i\n   FROM (public.foo\n   JOIN public.bar USING (i));\n"},
+			}
+
+			restore.EditStatementsRedirectTables(statements, map[string]string{"public.foo": "public.bar", "public.bar": "public.foo"})
+
+			Expect(statements[0].Statement).To(Equal("\n\nCREATE VIEW public.v AS  SELECT foo.i\n   FROM (public.bar\n   JOIN public.foo USING (i));\n"))
+		})
+		It("restores statistics to a redirected table", func() {
+			statements := []toc.StatementWithType{
+				{Schema: "public", Name: "foo", ObjectType: "STATISTICS", Statement: `
+
+UPDATE pg_class
+SET
+	relpages = 1::int,
+	reltuples = 1.000000::real
+WHERE relname = 'foo'
+AND relnamespace = 2200;
+
+
+DELETE FROM pg_statistic WHERE starelid = 'public.foo'::regclass::oid AND staattnum = 1;
+`},
+			}
+
+			restore.EditStatementsRedirectTables(statements, redirectTables)
+
+			Expect(statements[0].Schema).To(Equal("scratch"))
+			Expect(statements[0].Name).To(Equal("foo_copy"))
+			Expect(statements[0].Statement).To(Equal(`
+
+UPDATE pg_class
+SET
+	relpages = 1::int,
+	reltuples = 1.000000::real
+WHERE oid = 'scratch.foo_copy'::regclass::oid;
+
+
+DELETE FROM pg_statistic WHERE starelid = 'scratch.foo_copy'::regclass::oid AND staattnum = 1;
+`))
+		})
+	})
+})
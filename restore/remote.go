@@ -2,12 +2,14 @@ package restore
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/greenplum-db/gp-common-go-libs/cluster"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gpbackup/filepath"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/pkg/errors"
 )
@@ -19,7 +21,8 @@ import (
 func VerifyBackupDirectoriesExistOnAllHosts() {
 	_, err := globalCluster.ExecuteLocalCommand(fmt.Sprintf("test -d %s", globalFPInfo.GetDirForContent(-1)))
 	gplog.FatalOnError(err, "Backup directory %s missing or inaccessible", globalFPInfo.GetDirForContent(-1))
-	if MustGetFlagString(options.PLUGIN_CONFIG) == "" || backupConfig.SingleDataFile {
+	// When resizing, segments read the files of other content IDs, so their own directories need not exist
+	if (MustGetFlagString(options.PLUGIN_CONFIG) == "" || backupConfig.SingleDataFile) && !MustGetFlagBool(options.RESIZE_CLUSTER) {
 		remoteOutput := globalCluster.GenerateAndExecuteCommand("Verifying backup directories exist", func(contentID int) string {
 			return fmt.Sprintf("test -d %s", globalFPInfo.GetDirForContent(contentID))
 		}, cluster.ON_SEGMENTS)
@@ -30,24 +33,34 @@ func VerifyBackupDirectoriesExistOnAllHosts() {
 }
 
 func VerifyBackupFileCountOnSegments(fileCount int) {
+	numIncorrect := len(GetSegmentsWithIncorrectBackupFileCount(globalFPInfo, fileCount))
+	if numIncorrect > 0 {
+		cluster.LogFatalClusterError("Found incorrect number of backup files", cluster.ON_SEGMENTS, numIncorrect)
+	}
+}
+
+/*
+ * Returns the content IDs of the segments whose backup directory does not
+ * contain the expected number of files.
+ */
+func GetSegmentsWithIncorrectBackupFileCount(fpInfo filepath.FilePathInfo, fileCount int) []int {
 	remoteOutput := globalCluster.GenerateAndExecuteCommand("Verifying backup file count", func(contentID int) string {
-		return fmt.Sprintf("find %s -type f | wc -l", globalFPInfo.GetDirForContent(contentID))
+		return fmt.Sprintf("find %s -type f | wc -l", fpInfo.GetDirForContent(contentID))
 	}, cluster.ON_SEGMENTS)
 	globalCluster.CheckClusterError(remoteOutput, "Could not verify backup file count", func(contentID int) string {
 		return "Could not verify backup file count"
 	})
 
-	numIncorrect := 0
+	incorrect := make([]int, 0)
 	for contentID := range remoteOutput.Stdouts {
 		numFound, _ := strconv.Atoi(strings.TrimSpace(remoteOutput.Stdouts[contentID]))
 		if numFound != fileCount {
 			gplog.Verbose("Expected to find %d file(s) on segment %d on host %s, but found %d instead.", fileCount, contentID, globalCluster.GetHostForContent(contentID), numFound)
-			numIncorrect++
+			incorrect = append(incorrect, contentID)
 		}
 	}
-	if numIncorrect > 0 {
-		cluster.LogFatalClusterError("Found incorrect number of backup files", cluster.ON_SEGMENTS, numIncorrect)
-	}
+	sort.Ints(incorrect)
+	return incorrect
 }
 
 func VerifyMetadataFilePaths(withStats bool) {
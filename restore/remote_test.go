@@ -79,4 +79,20 @@ var _ = Describe("restore/remote tests", func() {
 			restore.VerifyBackupFileCountOnSegments(2)
 		})
 	})
+	Describe("GetSegmentsWithIncorrectBackupFileCount", func() {
+		It("returns the segments whose file counts do not match without panicking", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{
+				Stdouts: map[int]string{
+					0: "2",
+					1: "1",
+				},
+			}
+			restore.SetCluster(testCluster)
+
+			incorrect := restore.GetSegmentsWithIncorrectBackupFileCount(testFPInfo, 2)
+
+			Expect(incorrect).To(Equal([]int{1}))
+			Expect(testExecutor.ClusterCommands[0][0][4]).To(Equal("find /data/gpseg0/backups/20170101/20170101010101 -type f | wc -l"))
+		})
+	})
 })
@@ -7,6 +7,7 @@ import (
 	"runtime/debug"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/greenplum-db/gp-common-go-libs/cluster"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
@@ -30,6 +31,7 @@ func DoInit(cmd *cobra.Command) {
 	SetCmdFlags(cmd.Flags())
 	_ = cmd.MarkFlagRequired(options.TIMESTAMP)
 	utils.InitializeSignalHandler(DoCleanup, "restore process", &wasTerminated)
+	startTime = time.Now()
 }
 
 /*
@@ -42,6 +44,8 @@ func DoValidation(cmd *cobra.Command) {
 	gplog.FatalOnError(err)
 	err = utils.ValidateFullPath(MustGetFlagString(options.PLUGIN_CONFIG))
 	gplog.FatalOnError(err)
+	err = utils.ValidateFullPath(MustGetFlagString(options.METRICS_DIR))
+	gplog.FatalOnError(err)
 	if !filepath.IsValidTimestamp(MustGetFlagString(options.TIMESTAMP)) {
 		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.", MustGetFlagString(options.TIMESTAMP)), "")
 	}
@@ -50,7 +54,20 @@ func DoValidation(cmd *cobra.Command) {
 // This function handles setup that must be done after parsing flags.
 func DoSetup() {
 	SetLoggerVerbosity()
+	if MustGetFlagBool(options.PRINT_SQL) && MustGetFlagString(options.PRINT_SQL_FILE) == "" {
+		redirectLoggingToStderr()
+	}
 	gplog.Verbose("Restore Command: %s", os.Args)
+	if MustGetFlagString(options.CONFIG) != "" {
+		gplog.Info("Effective flags after applying configuration file %s: %s", MustGetFlagString(options.CONFIG), strings.Join(options.GetEffectiveFlags(cmdFlags), " "))
+	}
+
+	if isPrintSQL() {
+		restoreStartTime = history.CurrentTimestamp()
+		gplog.Info("Restore Key = %s", MustGetFlagString(options.TIMESTAMP))
+		setupPrintSQL()
+		return
+	}
 
 	utils.CheckGpexpandRunning(utils.RestorePreventedByGpexpandMessage)
 	restoreStartTime = history.CurrentTimestamp()
@@ -64,6 +81,8 @@ func DoSetup() {
 
 	err = opts.QuoteIncludeRelations(connectionPool)
 	gplog.FatalOnError(err)
+	err = opts.QuoteRedirectTables(connectionPool)
+	gplog.FatalOnError(err)
 
 	segConfig := cluster.MustGetSegmentConfiguration(connectionPool)
 	globalCluster = cluster.NewCluster(segConfig)
@@ -76,8 +95,27 @@ func DoSetup() {
 	} else {
 		InitializeBackupConfig()
 	}
+	if backupConfig.Encrypted {
+		initializeEncryption()
+	}
+	if backupConfig.Compressed && !backupConfig.MetadataOnly && !MustGetFlagBool(options.METADATA_ONLY) {
+		utils.CheckCompressionProgramOnAllHosts(globalCluster, backupConfig.CompressionType)
+	}
+	if len(backupConfig.RestorePlan) > 1 {
+		ValidateIncrementalChain()
+	}
+	if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
+		RecoverRestorePlanFilesUsingPlugin()
+	}
 
 	BackupConfigurationValidation()
+	if backupConfig.Masked {
+		gplog.Warn("Backup %s was taken with a masking policy, so the data of its masked columns will not match the original data", backupConfig.Timestamp)
+	}
+	if MustGetFlagBool(options.VERIFY_ONLY) {
+		// Verification reads only the backup files, so there is no restore database to validate
+		return
+	}
 	metadataFilename := globalFPInfo.GetMetadataFilePath()
 	if !backupConfig.DataOnly {
 		gplog.Verbose("Metadata will be restored from %s", metadataFilename)
@@ -102,8 +140,9 @@ func DoSetup() {
 	 * should not error out for validation reasons once the restore database exists.
 	 * For on-error-continue, we will see the same errors later when we try to run SQL,
 	 * but since they will not stop the restore, it is not necessary to log them twice.
+	 * A resumed restore expects the relations restored by earlier attempts to exist.
 	 */
-	if !MustGetFlagBool(options.CREATE_DB) && !MustGetFlagBool(options.ON_ERROR_CONTINUE) && !MustGetFlagBool(options.INCREMENTAL) {
+	if !MustGetFlagBool(options.CREATE_DB) && !MustGetFlagBool(options.ON_ERROR_CONTINUE) && !MustGetFlagBool(options.INCREMENTAL) && !MustGetFlagBool(options.RESUME) {
 		relationsToRestore := GenerateRestoreRelationList(*opts)
 		if opts.RedirectSchema != "" {
 			fqns, err := options.SeparateSchemaAndTable(relationsToRestore)
@@ -114,15 +153,35 @@ func DoSetup() {
 			}
 			relationsToRestore = redirectRelationsToRestore
 		}
+		if len(opts.RedirectTables) > 0 {
+			redirectRelationsToRestore := make([]string, 0)
+			for _, relation := range relationsToRestore {
+				if targetTable, ok := opts.RedirectTables[relation]; ok {
+					relation = targetTable
+				}
+				redirectRelationsToRestore = append(redirectRelationsToRestore, relation)
+			}
+			relationsToRestore = redirectRelationsToRestore
+		}
 		ValidateRelationsInRestoreDatabase(connectionPool, relationsToRestore)
 	}
 
 	if opts.RedirectSchema != "" {
 		ValidateRedirectSchema(connectionPool, opts.RedirectSchema)
 	}
+	ValidateRedirectTables(opts.RedirectTables, opts.IncludedRelations)
+	initializeRestoreJournal(unquotedRestoreDatabase)
 }
 
 func DoRestore() {
+	if MustGetFlagBool(options.VERIFY_ONLY) {
+		DoVerify()
+		return
+	}
+	if isPrintSQL() {
+		DoPrintSQL()
+		return
+	}
 	metadataFilename := globalFPInfo.GetMetadataFilePath()
 	isDataOnly := backupConfig.DataOnly || MustGetFlagBool(options.DATA_ONLY)
 	isMetadataOnly := backupConfig.MetadataOnly || MustGetFlagBool(options.METADATA_ONLY)
@@ -131,8 +190,8 @@ func DoRestore() {
 		restorePredata(metadataFilename)
 	}
 
-	if !isMetadataOnly {
-		if MustGetFlagString(options.PLUGIN_CONFIG) == "" {
+	if !isMetadataOnly && shouldRestoreTableData() {
+		if MustGetFlagString(options.PLUGIN_CONFIG) == "" && !MustGetFlagBool(options.RESIZE_CLUSTER) {
 			backupFileCount := 2 // 1 for the actual data file, 1 for the segment TOC file
 			if !backupConfig.SingleDataFile {
 				backupFileCount = len(globalTOC.DataEntries)
@@ -146,42 +205,60 @@ func DoRestore() {
 		restorePostdata(metadataFilename)
 	}
 
-	if MustGetFlagBool(options.WITH_STATS) && backupConfig.WithStatistics {
+	if MustGetFlagBool(options.WITH_STATS) && backupConfig.WithStatistics && shouldRestoreTableData() {
 		restoreStatistics()
 	}
 }
 
 func createDatabase(metadataFilename string) {
-	objectTypes := []string{"SESSION GUCS", "DATABASE GUC", "DATABASE", "DATABASE METADATA"}
 	dbName := backupConfig.DatabaseName
-	gplog.Info("Creating database")
-	statements := GetRestoreMetadataStatements("global", metadataFilename, objectTypes, []string{})
+	quotedRedirectDB := ""
 	if MustGetFlagString(options.REDIRECT_DB) != "" {
-		quotedDBName := utils.QuoteIdent(connectionPool, MustGetFlagString(options.REDIRECT_DB))
-		dbName = quotedDBName
-		statements = toc.SubstituteRedirectDatabaseInStatements(statements, backupConfig.DatabaseName, quotedDBName)
+		quotedRedirectDB = utils.QuoteIdent(connectionPool, MustGetFlagString(options.REDIRECT_DB))
+		dbName = quotedRedirectDB
 	}
+	gplog.Info("Creating database")
+	statements := getCreateDatabaseStatements(metadataFilename, quotedRedirectDB)
 	ExecuteRestoreMetadataStatements(statements, "", nil, utils.PB_NONE, false)
 	gplog.Info("Database creation complete for: %s", dbName)
 }
 
+func getCreateDatabaseStatements(metadataFilename string, quotedRedirectDB string) []toc.StatementWithType {
+	objectTypes := []string{"SESSION GUCS", "DATABASE GUC", "DATABASE", "DATABASE METADATA"}
+	statements := GetRestoreMetadataStatements("global", metadataFilename, objectTypes, []string{})
+	if quotedRedirectDB != "" {
+		statements = toc.SubstituteRedirectDatabaseInStatements(statements, backupConfig.DatabaseName, quotedRedirectDB)
+	}
+	return statements
+}
+
 func restoreGlobal(metadataFilename string) {
+	defer phaseTimings.Record("globals", time.Now())
+	quotedRedirectDB := ""
+	if MustGetFlagString(options.REDIRECT_DB) != "" {
+		quotedRedirectDB = utils.QuoteIdent(connectionPool, MustGetFlagString(options.REDIRECT_DB))
+	}
+	gplog.Info("Restoring global metadata")
+	statements := getGlobalStatements(metadataFilename, quotedRedirectDB, connectionPool.User)
+	ExecuteRestoreMetadataStatements(statements, "Global objects", nil, utils.PB_VERBOSE, false)
+	gplog.Info("Global database metadata restore complete")
+}
+
+func getGlobalStatements(metadataFilename string, quotedRedirectDB string, activeUser string) []toc.StatementWithType {
 	objectTypes := []string{"SESSION GUCS", "DATABASE GUC", "DATABASE METADATA", "RESOURCE QUEUE", "RESOURCE GROUP", "ROLE", "ROLE GUCS", "ROLE GRANT", "TABLESPACE"}
 	if MustGetFlagBool(options.CREATE_DB) {
 		objectTypes = append(objectTypes, "DATABASE")
 	}
-	gplog.Info("Restoring global metadata")
 	statements := GetRestoreMetadataStatements("global", metadataFilename, objectTypes, []string{})
-	if MustGetFlagString(options.REDIRECT_DB) != "" {
-		quotedDBName := utils.QuoteIdent(connectionPool, MustGetFlagString(options.REDIRECT_DB))
-		statements = toc.SubstituteRedirectDatabaseInStatements(statements, backupConfig.DatabaseName, quotedDBName)
+	statements = filterStatementsByObjectType(statements)
+	if quotedRedirectDB != "" {
+		statements = toc.SubstituteRedirectDatabaseInStatements(statements, backupConfig.DatabaseName, quotedRedirectDB)
 	}
-	statements = toc.RemoveActiveRole(connectionPool.User, statements)
-	ExecuteRestoreMetadataStatements(statements, "Global objects", nil, utils.PB_VERBOSE, false)
-	gplog.Info("Global database metadata restore complete")
+	return toc.RemoveActiveRole(activeUser, statements)
 }
 
 func restorePredata(metadataFilename string) {
+	defer phaseTimings.Record("predata", time.Now())
 	if wasTerminated {
 		return
 	}
@@ -252,18 +329,18 @@ func restorePredata(metadataFilename string) {
 	}
 
 	filters := NewFilters(inSchemas, exSchemas, inRelations, exRelations)
-	var schemaStatements []toc.StatementWithType
-	if opts.RedirectSchema == "" {
-		schemaStatements = GetRestoreMetadataStatementsFiltered("predata", metadataFilename, []string{"SCHEMA"}, []string{}, filters)
-	}
-	statements := GetRestoreMetadataStatementsFiltered("predata", metadataFilename, []string{}, []string{"SCHEMA"}, filters)
-
-	editStatementsRedirectSchema(statements, opts.RedirectSchema)
+	schemaStatements, statements := getPredataStatements(metadataFilename, filters)
+	schemaStatements = restoreJournal.SkipCompletedStatements(schemaStatements)
+	statements = restoreJournal.SkipCompletedStatements(statements)
 	progressBar := utils.NewProgressBar(len(schemaStatements)+len(statements), "Pre-data objects restored: ", utils.PB_VERBOSE)
 	progressBar.Start()
 
 	RestoreSchemas(schemaStatements, progressBar)
-	ExecuteRestoreMetadataStatements(statements, "Pre-data objects", progressBar, utils.PB_VERBOSE, false)
+	if connectionPool.NumConns > 1 {
+		ExecuteStatementsByDependency(statements, progressBar)
+	} else {
+		ExecuteRestoreMetadataStatements(statements, "Pre-data objects", progressBar, utils.PB_VERBOSE, false)
+	}
 
 	progressBar.Finish()
 	if wasTerminated {
@@ -273,6 +350,21 @@ func restorePredata(metadataFilename string) {
 	}
 }
 
+// Schemas are returned separately, as they are created before any other objects
+func getPredataStatements(metadataFilename string, filters Filters) ([]toc.StatementWithType, []toc.StatementWithType) {
+	var schemaStatements []toc.StatementWithType
+	if opts.RedirectSchema == "" {
+		schemaStatements = GetRestoreMetadataStatementsFiltered("predata", metadataFilename, []string{"SCHEMA"}, []string{}, filters)
+	}
+	statements := GetRestoreMetadataStatementsFiltered("predata", metadataFilename, []string{}, []string{"SCHEMA"}, filters)
+	schemaStatements = filterStatementsByObjectType(schemaStatements)
+	statements = filterStatementsByObjectType(statements)
+
+	editStatementsRedirectSchema(statements, opts.RedirectSchema)
+	EditStatementsRedirectTables(statements, opts.RedirectTables)
+	return schemaStatements, statements
+}
+
 func editStatementsRedirectSchema(statements []toc.StatementWithType, redirectSchema string) {
 	if redirectSchema == "" {
 		return
@@ -291,6 +383,7 @@ func editStatementsRedirectSchema(statements []toc.StatementWithType, redirectSc
 }
 
 func restoreData() {
+	defer phaseTimings.Record("data", time.Now())
 	if wasTerminated {
 		return
 	}
@@ -313,6 +406,16 @@ func restoreData() {
 		restorePlanTableFQNs := entry.TableFQNs
 		filteredDataEntriesForTimestamp := tocfile.GetDataEntriesMatching(opts.IncludedSchemas,
 			opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations, restorePlanTableFQNs)
+		for _, dataEntry := range filteredDataEntriesForTimestamp {
+			if dataEntry.Predicate != "" {
+				tableFQN := utils.MakeFQN(dataEntry.Schema, dataEntry.Name)
+				gplog.Info("Table %s was backed up with predicate %s, so only the rows that matched it will be restored", tableFQN, dataEntry.Predicate)
+				partialTables = append(partialTables, fmt.Sprintf("%s: %s", tableFQN, dataEntry.Predicate))
+			}
+		}
+		remainingDataEntries := restoreJournal.SkipRestoredTables(entry.Timestamp, filteredDataEntriesForTimestamp)
+		recordSkippedTables(filteredDataEntriesForTimestamp, remainingDataEntries)
+		filteredDataEntriesForTimestamp = remainingDataEntries
 		filteredDataEntries[entry.Timestamp] = filteredDataEntriesForTimestamp
 		totalTables += len(filteredDataEntriesForTimestamp)
 	}
@@ -338,15 +441,14 @@ func restoreData() {
 }
 
 func restorePostdata(metadataFilename string) {
+	defer phaseTimings.Record("postdata", time.Now())
 	if wasTerminated {
 		return
 	}
 	gplog.Info("Restoring post-data metadata")
 
-	filters := NewFilters(opts.IncludedSchemas, opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations)
-
-	statements := GetRestoreMetadataStatementsFiltered("postdata", metadataFilename, []string{}, []string{}, filters)
-	editStatementsRedirectSchema(statements, opts.RedirectSchema)
+	statements := getPostdataStatements(metadataFilename)
+	statements = restoreJournal.SkipCompletedStatements(statements)
 	firstBatch, secondBatch := BatchPostdataStatements(statements)
 	progressBar := utils.NewProgressBar(len(statements), "Post-data objects restored: ", utils.PB_VERBOSE)
 	progressBar.Start()
@@ -360,24 +462,42 @@ func restorePostdata(metadataFilename string) {
 	}
 }
 
+func getPostdataStatements(metadataFilename string) []toc.StatementWithType {
+	filters := NewFilters(opts.IncludedSchemas, opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations)
+
+	statements := GetRestoreMetadataStatementsFiltered("postdata", metadataFilename, []string{}, []string{}, filters)
+	statements = filterStatementsByObjectType(statements)
+	editStatementsRedirectSchema(statements, opts.RedirectSchema)
+	EditStatementsRedirectTables(statements, opts.RedirectTables)
+	return statements
+}
+
 func restoreStatistics() {
+	defer phaseTimings.Record("statistics", time.Now())
 	if wasTerminated {
 		return
 	}
 	statisticsFilename := globalFPInfo.GetStatisticsFilePath()
 	gplog.Info("Restoring query planner statistics from %s", statisticsFilename)
 
+	statements := getStatisticsStatements(statisticsFilename)
+	ExecuteRestoreMetadataStatements(statements, "Table statistics", nil, utils.PB_VERBOSE, false)
+	gplog.Info("Query planner statistics restore complete")
+}
+
+func getStatisticsStatements(statisticsFilename string) []toc.StatementWithType {
 	filters := NewFilters(opts.IncludedSchemas, opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations)
 
 	statements := GetRestoreMetadataStatementsFiltered("statistics", statisticsFilename, []string{}, []string{}, filters)
 	editStatementsRedirectSchema(statements, opts.RedirectSchema)
-	ExecuteRestoreMetadataStatements(statements, "Table statistics", nil, utils.PB_VERBOSE, false)
-	gplog.Info("Query planner statistics restore complete")
+	EditStatementsRedirectTables(statements, opts.RedirectTables)
+	return statements
 }
 
 func DoTeardown() {
 	restoreFailed := false
 	defer func() {
+		writeMetricsFile(restoreFailed)
 		DoCleanup(restoreFailed)
 
 		errorCode := gplog.GetErrorCode()
@@ -411,18 +531,28 @@ func DoTeardown() {
 		return
 	}
 	if errStr != "" {
-		fmt.Println(errStr)
+		if isPrintSQL() {
+			// Only the printed SQL goes to stdout
+			fmt.Fprintln(os.Stderr, errStr)
+		} else {
+			fmt.Println(errStr)
+		}
 	}
 	errMsg := report.ParseErrorMessage(errStr)
 
-	if globalFPInfo.Timestamp != "" {
+	// Printing the SQL restores nothing, so there is nothing to report
+	if globalFPInfo.Timestamp != "" && !isPrintSQL() {
 		_, statErr := os.Stat(globalFPInfo.GetDirForContent(-1))
 		if statErr != nil { // Even if this isn't os.IsNotExist, don't try to write a report file in case of further errors
 			return
 		}
-		reportFilename := globalFPInfo.GetRestoreReportFilePath(restoreStartTime)
-		report.WriteRestoreReportFile(reportFilename, globalFPInfo.Timestamp, restoreStartTime, connectionPool, version, errMsg)
-		report.EmailReport(globalCluster, globalFPInfo.Timestamp, reportFilename, "gprestore")
+		// Verifying a backup restores nothing either, but the plugin must still be cleaned up
+		if !MustGetFlagBool(options.VERIFY_ONLY) {
+			reportFilename := globalFPInfo.GetRestoreReportFilePath(restoreStartTime)
+			report.WriteRestoreReportFile(reportFilename, globalFPInfo.Timestamp, restoreStartTime, connectionPool, version, partialTables, errMsg)
+			report.WriteRestoreJSONReportFile(globalFPInfo.GetRestoreJSONReportFilePath(restoreStartTime), globalFPInfo.Timestamp, restoreStartTime, connectionPool, version, backupConfig, tableResults.Sorted(), partialTables, errMsg)
+			report.SendReportNotifications(globalCluster, globalFPInfo.Timestamp, reportFilename, "gprestore")
+		}
 		if pluginConfig != nil {
 			pluginConfig.CleanupPluginForRestore(globalCluster, globalFPInfo)
 			pluginConfig.DeletePluginConfigWhenEncrypting(globalCluster)
@@ -481,7 +611,7 @@ func DoCleanup(restoreFailed bool) {
 	}()
 
 	gplog.Verbose("Beginning cleanup")
-	if backupConfig != nil && backupConfig.SingleDataFile {
+	if backupConfig != nil && backupConfig.SingleDataFile && !isPrintSQL() {
 		fpInfoList := GetBackupFPInfoListFromRestorePlan()
 		for _, fpInfo := range fpInfoList {
 			if restoreFailed {
@@ -494,6 +624,10 @@ func DoCleanup(restoreFailed bool) {
 		}
 	}
 
+	restoreJournal.Close()
+	if encryptionKeyFile != "" {
+		utils.DeleteEncryptionKeyFromAllHosts(globalCluster, encryptionKeyFile)
+	}
 	if connectionPool != nil {
 		connectionPool.Close()
 	}
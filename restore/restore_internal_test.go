@@ -1,7 +1,11 @@
 package restore
 
 import (
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/spf13/pflag"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -55,4 +59,124 @@ var _ = Describe("restore internal tests", func() {
 			Expect(statements).To(Equal(expectedStatements))
 		})
 	})
+	Describe("expandFilterPatterns", func() {
+		BeforeEach(func() {
+			globalTOC = &toc.TOC{PredataEntries: []toc.MetadataEntry{
+				{Schema: "public", Name: `"Foo"`, ObjectType: "TABLE"},
+				{Schema: "public", Name: "foo_seq", ObjectType: "SEQUENCE"},
+				{Schema: "staging", Name: "tmp_a", ObjectType: "TABLE"},
+				{Schema: "scratch", Name: "func", ObjectType: "FUNCTION"},
+			}}
+			backupConfig = &history.BackupConfig{}
+			cmdFlags = pflag.NewFlagSet("gprestore", pflag.ContinueOnError)
+			options.SetRestoreFlagDefaults(cmdFlags)
+		})
+		It("expands patterns against the unquoted names in the TOC", func() {
+			Expect(cmdFlags.Set(options.INCLUDE_RELATION, "glob:public.Fo*")).To(Succeed())
+			Expect(cmdFlags.Set(options.INCLUDE_RELATION, "re:staging\\..*")).To(Succeed())
+			opts, _ = options.NewOptions(cmdFlags)
+
+			expandFilterPatterns()
+
+			Expect(opts.IncludedRelations).To(Equal([]string{`public."Foo"`, "staging.tmp_a"}))
+		})
+		It("expands schema patterns against the schemas of all objects in the TOC", func() {
+			Expect(cmdFlags.Set(options.EXCLUDE_SCHEMA, "glob:sc*")).To(Succeed())
+			opts, _ = options.NewOptions(cmdFlags)
+
+			expandFilterPatterns()
+
+			Expect(opts.ExcludedSchemas).To(Equal([]string{"scratch"}))
+		})
+	})
+	Describe("quoteIncludeRelationsFromBackupSet", func() {
+		It("quotes included relations as they are quoted in the TOC", func() {
+			globalTOC = &toc.TOC{PredataEntries: []toc.MetadataEntry{
+				{Schema: "public", Name: `"Foo"`, ObjectType: "TABLE"},
+				{Schema: "public", Name: "bar", ObjectType: "TABLE"},
+			}}
+			backupConfig = &history.BackupConfig{}
+			cmdFlags = pflag.NewFlagSet("gprestore", pflag.ContinueOnError)
+			options.SetRestoreFlagDefaults(cmdFlags)
+			Expect(cmdFlags.Set(options.INCLUDE_RELATION, "public.Foo")).To(Succeed())
+			Expect(cmdFlags.Set(options.INCLUDE_RELATION, "public.bar")).To(Succeed())
+			Expect(cmdFlags.Set(options.INCLUDE_RELATION, "public.missing")).To(Succeed())
+			opts, _ = options.NewOptions(cmdFlags)
+
+			quoteIncludeRelationsFromBackupSet()
+
+			Expect(opts.IncludedRelations).To(Equal([]string{`public."Foo"`, "public.bar", "public.missing"}))
+		})
+	})
+	Describe("filterStatementsByObjectType", func() {
+		statements := []toc.StatementWithType{
+			{Schema: "public", Name: "foo", ObjectType: "TABLE"},
+			{Schema: "public", Name: "foo_trigger", ObjectType: "TRIGGER"},
+			{Schema: "", Name: "event_trigger", ObjectType: "EVENT TRIGGER"},
+			{Schema: "public", Name: "foo_idx", ObjectType: "INDEX"},
+		}
+		BeforeEach(func() {
+			cmdFlags = pflag.NewFlagSet("gprestore", pflag.ContinueOnError)
+			options.SetRestoreFlagDefaults(cmdFlags)
+		})
+		It("returns all statements when no object types are filtered", func() {
+			opts, _ = options.NewOptions(cmdFlags)
+
+			Expect(filterStatementsByObjectType(statements)).To(Equal(statements))
+		})
+		It("removes the statements of excluded object types", func() {
+			Expect(cmdFlags.Set(options.EXCLUDE_OBJECT_TYPE, "trigger")).To(Succeed())
+			Expect(cmdFlags.Set(options.EXCLUDE_OBJECT_TYPE, "event trigger")).To(Succeed())
+			opts, _ = options.NewOptions(cmdFlags)
+
+			Expect(filterStatementsByObjectType(statements)).To(Equal([]toc.StatementWithType{statements[0], statements[3]}))
+		})
+		It("keeps only the statements of included object types", func() {
+			Expect(cmdFlags.Set(options.INCLUDE_OBJECT_TYPE, "INDEX")).To(Succeed())
+			opts, _ = options.NewOptions(cmdFlags)
+
+			Expect(filterStatementsByObjectType(statements)).To(Equal([]toc.StatementWithType{statements[3]}))
+		})
+	})
+	Describe("shouldRestoreTableData", func() {
+		BeforeEach(func() {
+			cmdFlags = pflag.NewFlagSet("gprestore", pflag.ContinueOnError)
+			options.SetRestoreFlagDefaults(cmdFlags)
+		})
+		It("restores table data when tables are not filtered out", func() {
+			Expect(cmdFlags.Set(options.EXCLUDE_OBJECT_TYPE, "TRIGGER")).To(Succeed())
+			opts, _ = options.NewOptions(cmdFlags)
+
+			Expect(shouldRestoreTableData()).To(BeTrue())
+		})
+		It("does not restore table data when tables are excluded", func() {
+			Expect(cmdFlags.Set(options.EXCLUDE_OBJECT_TYPE, "TABLE")).To(Succeed())
+			opts, _ = options.NewOptions(cmdFlags)
+
+			Expect(shouldRestoreTableData()).To(BeFalse())
+		})
+		It("does not restore table data when tables are not included", func() {
+			Expect(cmdFlags.Set(options.INCLUDE_OBJECT_TYPE, "VIEW")).To(Succeed())
+			opts, _ = options.NewOptions(cmdFlags)
+
+			Expect(shouldRestoreTableData()).To(BeFalse())
+		})
+	})
+	Describe("compareSegmentChecksum", func() {
+		manifest := &utils.ChecksumManifest{SegmentFiles: map[int]map[string]string{0: {"gpbackup_0_1_16384.gz": "abc"}}}
+		It("finds no problems with a file that matches the manifest", func() {
+			Expect(compareSegmentChecksum("1", 0, "gpbackup_0_1_16384.gz", "abc", manifest)).To(BeEmpty())
+		})
+		It("reports a file that does not match the manifest", func() {
+			problems := compareSegmentChecksum("1", 0, "gpbackup_0_1_16384.gz", "def", manifest)
+			Expect(problems).To(Equal([]VerificationProblem{{"1", 0, "gpbackup_0_1_16384.gz", "contents do not match the checksum recorded at backup time"}}))
+		})
+		It("finds no problems with a file that was read when there is no manifest", func() {
+			Expect(compareSegmentChecksum("1", 0, "gpbackup_0_1_16384.gz", "abc", nil)).To(BeEmpty())
+		})
+		It("reports a file that could not be read when there is no manifest", func() {
+			problems := compareSegmentChecksum("1", 0, "gpbackup_0_1_16384.gz", "", nil)
+			Expect(problems).To(Equal([]VerificationProblem{{"1", 0, "gpbackup_0_1_16384.gz", "file could not be read"}}))
+		})
+	})
 })
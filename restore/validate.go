@@ -7,6 +7,7 @@ import (
 
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/pkg/errors"
@@ -24,6 +25,62 @@ func validateFilterListsInBackupSet() {
 	ValidateExcludeRelationsInBackupSet(opts.ExcludedRelations)
 }
 
+/*
+ * Table and schema patterns are expanded against the TOC, matching the
+ * unquoted names of the relations and schemas in the backup, so that the rest
+ * of the restore sees only the quoted names that they match.
+ */
+func expandFilterPatterns() {
+	if !opts.HasFilterPatterns() {
+		return
+	}
+	relations, schemas := getRelationsAndSchemasInBackupSet()
+	err := opts.ExpandFilterPatterns(cmdFlags, relations, schemas)
+	gplog.FatalOnError(err)
+}
+
+// Both maps are keyed by unquoted names, with the quoted names as values
+func getRelationsAndSchemasInBackupSet() (map[string]string, map[string]string) {
+	relations := make(map[string]string)
+	schemas := make(map[string]string)
+	addRelation := func(schema string, name string) {
+		relations[utils.MakeFQN(utils.UnquoteIdent(schema), utils.UnquoteIdent(name))] = utils.MakeFQN(schema, name)
+	}
+	addSchema := func(schema string) {
+		if schema != "" {
+			schemas[utils.UnquoteIdent(schema)] = schema
+		}
+	}
+	if !backupConfig.DataOnly {
+		for _, entry := range globalTOC.PredataEntries {
+			addSchema(entry.Schema)
+			if entry.ObjectType == "TABLE" || entry.ObjectType == "SEQUENCE" || entry.ObjectType == "VIEW" || entry.ObjectType == "MATERIALIZED VIEW" {
+				addRelation(entry.Schema, entry.Name)
+			}
+		}
+	} else {
+		for _, entry := range globalTOC.DataEntries {
+			addSchema(entry.Schema)
+			addRelation(entry.Schema, entry.Name)
+		}
+	}
+	return relations, schemas
+}
+
+/*
+ * Without a connection to quote the included relations with quote_ident(),
+ * they are quoted as they are in the TOC instead.  Relations that are not in
+ * the backup are left as they are, to be reported by the filter validation.
+ */
+func quoteIncludeRelationsFromBackupSet() {
+	relations, _ := getRelationsAndSchemasInBackupSet()
+	for i, relation := range opts.IncludedRelations {
+		if quotedRelation, ok := relations[relation]; ok && !utils.IsFilterPattern(relation) {
+			opts.IncludedRelations[i] = quotedRelation
+		}
+	}
+}
+
 func ValidateIncludeSchemasInBackupSet(schemaList []string) {
 	if keys := getFilterSchemasInBackupSet(schemaList); len(keys) != 0 {
 		gplog.Fatal(errors.Errorf("Could not find the following schema(s) in the backup set: %s", strings.Join(keys, ", ")), "")
@@ -150,6 +207,24 @@ func ValidateRedirectSchema(connectionPool *dbconn.DBConn, redirectSchema string
 	}
 }
 
+/*
+ * Tables can only be redirected if they are being restored, and the schemas
+ * they are redirected to must already exist.
+ */
+func ValidateRedirectTables(redirectTables map[string]string, includedRelations []string) {
+	targetSchemas := make(map[string]bool)
+	for sourceTable, targetTable := range redirectTables {
+		if !utils.Exists(includedRelations, sourceTable) {
+			gplog.Fatal(errors.Errorf("Table %s in the redirect table file must also be specified with --include-table or --include-table-file", sourceTable), "")
+		}
+		targetSchema := splitRedirectedFQN(targetTable).SchemaName
+		if !targetSchemas[targetSchema] {
+			ValidateRedirectSchema(connectionPool, utils.EscapeSingleQuotes(utils.UnquoteIdent(targetSchema)))
+			targetSchemas[targetSchema] = true
+		}
+	}
+}
+
 func ValidateIncludeRelationsInBackupSet(schemaList []string) {
 	if keys := getFilterRelationsInBackupSet(schemaList); len(keys) != 0 {
 		gplog.Fatal(errors.Errorf("Could not find the following relation(s) in the backup set: %s", strings.Join(keys, ", ")), "")
@@ -240,6 +315,26 @@ func ValidateBackupFlagCombinations() {
 	validateBackupFlagPluginCombinations()
 }
 
+/*
+ * Data is loaded with COPY ON SEGMENT, so each segment of the restore cluster
+ * reads the files written by the segment with the same content ID unless the
+ * data is redistributed with --resize-cluster.
+ */
+func ValidateBackupSegmentCount(numSegments int) {
+	if backupConfig.MetadataOnly || MustGetFlagBool(options.METADATA_ONLY) {
+		return
+	}
+	if backupConfig.SegmentCount == 0 {
+		if MustGetFlagBool(options.RESIZE_CLUSTER) {
+			gplog.Fatal(errors.Errorf("Backup %s does not record the number of segments it was taken on, so it cannot be restored with --resize-cluster.", backupConfig.Timestamp), "")
+		}
+		return
+	}
+	if backupConfig.SegmentCount != numSegments && !MustGetFlagBool(options.RESIZE_CLUSTER) {
+		gplog.Fatal(errors.Errorf("Backup was taken on a cluster with %d segments, but the restore cluster has %d segments.  Use --resize-cluster to restore to a cluster with a different number of segments.", backupConfig.SegmentCount, numSegments), "")
+	}
+}
+
 func validateBackupFlagPluginCombinations() {
 	if backupConfig.Plugin != "" && MustGetFlagString(options.PLUGIN_CONFIG) == "" {
 		gplog.Fatal(errors.Errorf("Backup was taken with plugin %s. The --plugin-config flag must be used to restore.", backupConfig.Plugin), "")
@@ -248,6 +343,43 @@ func validateBackupFlagPluginCombinations() {
 	}
 }
 
+/*
+ * Data files are only checked when table data will be restored to a cluster
+ * with the same segments as the backups, as with --resize-cluster the
+ * segments' backup directories do not correspond to those of the cluster,
+ * and only for the tables that pass the restore's filters.  With
+ * --incremental, only the data of the last backup in the restore plan is
+ * restored, so only that backup is checked.
+ */
+func ValidateIncrementalChain() {
+	checkSegmentFiles := !backupConfig.MetadataOnly && !MustGetFlagBool(options.METADATA_ONLY) && !MustGetFlagBool(options.RESIZE_CLUSTER)
+	restorePlan := backupConfig.RestorePlan
+	if MustGetFlagBool(options.INCREMENTAL) {
+		restorePlan = restorePlan[len(restorePlan)-1:]
+	}
+	filters := history.TableFilters{
+		IncludeSchemas:   opts.IncludedSchemas,
+		ExcludeSchemas:   opts.ExcludedSchemas,
+		IncludeRelations: opts.IncludedRelations,
+		ExcludeRelations: opts.ExcludedRelations,
+	}
+	problems := history.ValidateIncrementalChain(globalCluster, pluginConfig, backupConfig, restorePlan,
+		filters, MustGetFlagString(options.BACKUP_DIR), "", checkSegmentFiles)
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			gplog.Error(problem.String())
+		}
+		gplog.Fatal(errors.Errorf("Found %d problem(s) in the restore plan of backup %s.  "+
+			"Every backup in the restore plan of an incremental backup is needed to restore it.", len(problems), backupConfig.Timestamp), "")
+	}
+}
+
+func ValidateEncryptionKey(key []byte) {
+	if utils.GetEncryptionKeyFingerprint(key) != backupConfig.EncryptionKeyFingerprint {
+		gplog.Fatal(errors.Errorf("The encryption key provided is not the key that was used to encrypt backup %s", backupConfig.Timestamp), "")
+	}
+}
+
 func ValidateFlagCombinations(flags *pflag.FlagSet) {
 	options.CheckExclusiveFlags(flags, options.DATA_ONLY, options.WITH_GLOBALS)
 	options.CheckExclusiveFlags(flags, options.DATA_ONLY, options.CREATE_DB)
@@ -256,6 +388,9 @@ func ValidateFlagCombinations(flags *pflag.FlagSet) {
 		options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE, options.EXCLUDE_RELATION, options.EXCLUDE_RELATION_FILE,
 		options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_FILE, options.INCLUDE_RELATION, options.INCLUDE_RELATION_FILE)
 	options.CheckExclusiveFlags(flags, options.METADATA_ONLY, options.DATA_ONLY)
+	options.CheckExclusiveFlags(flags, options.INCLUDE_OBJECT_TYPE, options.EXCLUDE_OBJECT_TYPE)
+	options.CheckExclusiveFlags(flags, options.INCLUDE_OBJECT_TYPE, options.DATA_ONLY)
+	options.CheckExclusiveFlags(flags, options.EXCLUDE_OBJECT_TYPE, options.DATA_ONLY)
 	options.CheckExclusiveFlags(flags, options.PLUGIN_CONFIG, options.BACKUP_DIR)
 	options.CheckExclusiveFlags(flags,
 		options.TRUNCATE_TABLE, options.REDIRECT_SCHEMA, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE,
@@ -266,6 +401,32 @@ func ValidateFlagCombinations(flags *pflag.FlagSet) {
 	}
 	options.CheckExclusiveFlags(flags,
 		options.TRUNCATE_TABLE, options.METADATA_ONLY, options.INCREMENTAL, options.REDIRECT_SCHEMA)
+	options.CheckExclusiveFlags(flags, options.REDIRECT_TABLE_FILE, options.REDIRECT_SCHEMA)
+	options.CheckExclusiveFlags(flags, options.REDIRECT_TABLE_FILE, options.INCREMENTAL)
+	options.CheckExclusiveFlags(flags, options.REDIRECT_TABLE_FILE, options.TRUNCATE_TABLE)
+	if flags.Changed(options.REDIRECT_TABLE_FILE) &&
+		!(flags.Changed(options.INCLUDE_RELATION) || flags.Changed(options.INCLUDE_RELATION_FILE)) {
+		gplog.Fatal(errors.Errorf("Cannot use --redirect-table-file without --include-table or --include-table-file"), "")
+	}
+	options.CheckExclusiveFlags(flags, options.RESIZE_CLUSTER, options.METADATA_ONLY)
+	options.CheckExclusiveFlags(flags, options.RESUME, options.CREATE_DB)
+	if flags.Changed(options.RESIZE_CLUSTER) && !flags.Changed(options.BACKUP_DIR) {
+		gplog.Fatal(errors.Errorf("Cannot use --resize-cluster without --backup-dir"), "")
+	}
+	if flags.Changed(options.VERIFY_ONLY) {
+		for _, flagName := range []string{options.CREATE_DB, options.DATA_ONLY, options.INCREMENTAL, options.METADATA_ONLY,
+			options.REDIRECT_DB, options.REDIRECT_SCHEMA, options.REDIRECT_TABLE_FILE, options.RESIZE_CLUSTER, options.RESUME, options.TRUNCATE_TABLE, options.WITH_GLOBALS} {
+			options.CheckExclusiveFlags(flags, options.VERIFY_ONLY, flagName)
+		}
+	}
+	for _, printFlag := range []string{options.PRINT_SQL, options.PRINT_SQL_FILE} {
+		if flags.Changed(printFlag) {
+			for _, flagName := range []string{options.INCREMENTAL, options.METRICS_DIR, options.PLUGIN_CONFIG,
+				options.REDIRECT_TABLE_FILE, options.RESIZE_CLUSTER, options.RESUME, options.VERIFY_ONLY} {
+				options.CheckExclusiveFlags(flags, printFlag, flagName)
+			}
+		}
+	}
 	if flags.Changed(options.TRUNCATE_TABLE) &&
 		!(flags.Changed(options.INCLUDE_RELATION) || flags.Changed(options.INCLUDE_RELATION_FILE)) &&
 		!flags.Changed(options.DATA_ONLY) {
@@ -32,10 +32,10 @@ var _ = Describe("restore/validate tests", func() {
 			tocfile, backupfile = testutils.InitializeTestTOC(buffer, "predata")
 			backupfile.ByteCount = table1Len
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "table1", ObjectType: "TABLE"}, 0, backupfile.ByteCount)
-			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "")
+			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "", "")
 			backupfile.ByteCount += table2Len
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema2", Name: "table2", ObjectType: "TABLE"}, table1Len, backupfile.ByteCount)
-			tocfile.AddMasterDataEntry("schema2", "table2", 2, "(j)", 0, "")
+			tocfile.AddMasterDataEntry("schema2", "table2", 2, "(j)", 0, "", "")
 			backupfile.ByteCount += sequenceLen
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema", Name: "somesequence", ObjectType: "SEQUENCE"}, table1Len+table2Len, backupfile.ByteCount)
 			restore.SetTOC(tocfile)
@@ -74,10 +74,10 @@ var _ = Describe("restore/validate tests", func() {
 		var opts *options.Options
 		BeforeEach(func() {
 			tocfile, _ = testutils.InitializeTestTOC(buffer, "metadata")
-			tocfile.AddMasterDataEntry("s1", "table1", 1, "(j)", 0, "")
-			tocfile.AddMasterDataEntry("s1", "table2", 2, "(j)", 0, "")
-			tocfile.AddMasterDataEntry("s2", "table1", 3, "(j)", 0, "")
-			tocfile.AddMasterDataEntry("s2", "table2", 4, "(j)", 0, "")
+			tocfile.AddMasterDataEntry("s1", "table1", 1, "(j)", 0, "", "")
+			tocfile.AddMasterDataEntry("s1", "table2", 2, "(j)", 0, "", "")
+			tocfile.AddMasterDataEntry("s2", "table1", 3, "(j)", 0, "", "")
+			tocfile.AddMasterDataEntry("s2", "table2", 4, "(j)", 0, "", "")
 			restore.SetTOC(tocfile)
 
 			opts = &options.Options{}
@@ -195,10 +195,10 @@ var _ = Describe("restore/validate tests", func() {
 		BeforeEach(func() {
 			tocfile, backupfile = testutils.InitializeTestTOC(buffer, "predata")
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "table1", ObjectType: "TABLE"}, 0, backupfile.ByteCount)
-			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "")
+			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "", "")
 
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema2", Name: "table2", ObjectType: "TABLE"}, 0, backupfile.ByteCount)
-			tocfile.AddMasterDataEntry("schema2", "table2", 2, "(j)", 0, "")
+			tocfile.AddMasterDataEntry("schema2", "table2", 2, "(j)", 0, "", "")
 
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "somesequence", ObjectType: "SEQUENCE"}, 0, backupfile.ByteCount)
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "someview", ObjectType: "VIEW"}, 0, backupfile.ByteCount)
@@ -262,6 +262,39 @@ var _ = Describe("restore/validate tests", func() {
 			restore.ValidateIncludeRelationsInBackupSet(filterList)
 		})
 	})
+	Describe("ValidateBackupSegmentCount", func() {
+		AfterEach(func() {
+			_ = cmdFlags.Set(options.RESIZE_CLUSTER, "false")
+		})
+		It("passes when the backup and restore clusters have the same number of segments", func() {
+			restore.SetBackupConfig(&history.BackupConfig{SegmentCount: 3})
+			restore.ValidateBackupSegmentCount(3)
+		})
+		It("passes when the backup does not record its number of segments", func() {
+			restore.SetBackupConfig(&history.BackupConfig{})
+			restore.ValidateBackupSegmentCount(3)
+		})
+		It("passes when restoring a metadata-only backup to a cluster with a different number of segments", func() {
+			restore.SetBackupConfig(&history.BackupConfig{SegmentCount: 2, MetadataOnly: true})
+			restore.ValidateBackupSegmentCount(3)
+		})
+		It("panics when the restore cluster has a different number of segments", func() {
+			restore.SetBackupConfig(&history.BackupConfig{SegmentCount: 2})
+			defer testhelper.ShouldPanicWithMessage("Backup was taken on a cluster with 2 segments, but the restore cluster has 3 segments.  Use --resize-cluster to restore to a cluster with a different number of segments.")
+			restore.ValidateBackupSegmentCount(3)
+		})
+		It("passes when the restore cluster has a different number of segments and --resize-cluster is passed", func() {
+			_ = cmdFlags.Set(options.RESIZE_CLUSTER, "true")
+			restore.SetBackupConfig(&history.BackupConfig{SegmentCount: 2})
+			restore.ValidateBackupSegmentCount(3)
+		})
+		It("panics when --resize-cluster is passed and the backup does not record its number of segments", func() {
+			_ = cmdFlags.Set(options.RESIZE_CLUSTER, "true")
+			restore.SetBackupConfig(&history.BackupConfig{Timestamp: "20170101010101"})
+			defer testhelper.ShouldPanicWithMessage("Backup 20170101010101 does not record the number of segments it was taken on, so it cannot be restored with --resize-cluster.")
+			restore.ValidateBackupSegmentCount(3)
+		})
+	})
 	Describe("ValidateDatabaseExistence", func() {
 		It("panics if createdb passed when db exists", func() {
 			dbExists := sqlmock.NewRows([]string{"string"}).
@@ -291,4 +324,32 @@ var _ = Describe("restore/validate tests", func() {
 			restore.ValidateDatabaseExistence("testdb", false, false)
 		})
 	})
+	Describe("ValidateEncryptionKey", func() {
+		key := []byte("0123456789abcdef0123456789abcdef")
+		It("does not panic if the key matches the key used for the backup", func() {
+			restore.SetBackupConfig(&history.BackupConfig{Timestamp: "20170101010101", Encrypted: true, EncryptionKeyFingerprint: utils.GetEncryptionKeyFingerprint(key)})
+			restore.ValidateEncryptionKey(key)
+		})
+		It("panics if the key does not match the key used for the backup", func() {
+			restore.SetBackupConfig(&history.BackupConfig{Timestamp: "20170101010101", Encrypted: true, EncryptionKeyFingerprint: "0123"})
+			defer testhelper.ShouldPanicWithMessage("The encryption key provided is not the key that was used to encrypt backup 20170101010101")
+			restore.ValidateEncryptionKey(key)
+		})
+	})
+	Describe("ValidateRedirectTables", func() {
+		redirectTables := map[string]string{"public.foo": "scratch.foo_copy"}
+		It("does not panic if the redirected tables are restored and their schemas exist", func() {
+			mock.ExpectQuery("SELECT quote_ident").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("scratch"))
+			restore.ValidateRedirectTables(redirectTables, []string{"public.foo", "public.bar"})
+		})
+		It("panics if a redirected table is not restored", func() {
+			defer testhelper.ShouldPanicWithMessage("Table public.foo in the redirect table file must also be specified with --include-table or --include-table-file")
+			restore.ValidateRedirectTables(redirectTables, []string{"public.bar"})
+		})
+		It("panics if the schema a table is redirected to does not exist", func() {
+			mock.ExpectQuery("SELECT quote_ident").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+			defer testhelper.ShouldPanicWithMessage("Schema scratch to redirect into does not exist")
+			restore.ValidateRedirectTables(redirectTables, []string{"public.foo"})
+		})
+	})
 })
@@ -0,0 +1,337 @@
+package restore
+
+/*
+ * This file contains functions for verifying a backup against the checksum
+ * manifest written at backup time, without restoring anything.
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+type VerificationProblem struct {
+	Timestamp string
+	ContentID int
+	Object    string
+	Message   string
+}
+
+func (problem VerificationProblem) String() string {
+	location := "on master"
+	if problem.ContentID != -1 {
+		location = fmt.Sprintf("on segment %d", problem.ContentID)
+	}
+	return fmt.Sprintf("Backup %s: %s %s: %s", problem.Timestamp, problem.Object, location, problem.Message)
+}
+
+func DoVerify() {
+	gplog.Info("Verifying backup %s", globalFPInfo.Timestamp)
+	problems := VerifyMetadataFileAgainstTOC(globalFPInfo.Timestamp, globalFPInfo.GetMetadataFilePath(), globalTOC)
+
+	restorePlanEntries := backupConfig.RestorePlan
+	if backupConfig.MetadataOnly {
+		restorePlanEntries = []history.RestorePlanEntry{{Timestamp: globalFPInfo.Timestamp}}
+	}
+	for _, entry := range restorePlanEntries {
+		fpInfo := globalFPInfo
+		if entry.Timestamp != globalFPInfo.Timestamp {
+			fpInfo = GetBackupFPInfoForTimestamp(entry.Timestamp)
+		}
+		manifest := getChecksumManifest(fpInfo)
+		if manifest != nil {
+			problems = append(problems, verifyMasterFiles(fpInfo, manifest)...)
+		}
+		if backupConfig.MetadataOnly {
+			continue
+		}
+		tocfile := toc.NewTOC(fpInfo.GetTOCFilePath())
+		dataEntries := tocfile.GetDataEntriesMatching([]string{}, []string{}, []string{}, []string{}, entry.TableFQNs)
+		problems = append(problems, verifySegmentData(fpInfo, dataEntries, len(tocfile.DataEntries), manifest)...)
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			gplog.Error(problem.String())
+		}
+		gplog.Fatal(errors.Errorf("Verification of backup %s found %d problem(s)", globalFPInfo.Timestamp, len(problems)), "")
+	}
+	gplog.Info("Backup %s verified successfully", globalFPInfo.Timestamp)
+}
+
+/*
+ * Backups taken before checksums were recorded have no manifest, in which
+ * case only the structure of the backup can be verified.
+ */
+func getChecksumManifest(fpInfo filepath.FilePathInfo) *utils.ChecksumManifest {
+	manifestFilename := fpInfo.GetChecksumManifestFilePath()
+	if pluginConfig != nil && !iohelper.FileExistsAndIsReadable(manifestFilename) {
+		err := pluginConfig.RestoreFile(manifestFilename)
+		if err != nil {
+			gplog.Verbose("%v", err)
+		}
+	}
+	if !iohelper.FileExistsAndIsReadable(manifestFilename) {
+		gplog.Warn("No checksum manifest found for backup %s; file contents will not be verified", fpInfo.Timestamp)
+		return nil
+	}
+	manifest, err := utils.ReadChecksumManifest(manifestFilename)
+	gplog.FatalOnError(err)
+	return manifest
+}
+
+func verifyMasterFiles(fpInfo filepath.FilePathInfo, manifest *utils.ChecksumManifest) []VerificationProblem {
+	problems := make([]VerificationProblem, 0)
+	filenames := make([]string, 0, len(manifest.MasterFiles))
+	for filename := range manifest.MasterFiles {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		fullPath := path.Join(fpInfo.GetDirForContent(-1), filename)
+		if pluginConfig != nil && !iohelper.FileExistsAndIsReadable(fullPath) {
+			err := pluginConfig.RestoreFile(fullPath)
+			if err != nil {
+				problems = append(problems, VerificationProblem{fpInfo.Timestamp, -1, filename, err.Error()})
+				continue
+			}
+		}
+		gplog.Verbose("Verifying checksum of %s", fullPath)
+		checksum, err := utils.ComputeFileChecksum(fullPath)
+		if err != nil {
+			problems = append(problems, VerificationProblem{fpInfo.Timestamp, -1, filename, err.Error()})
+		} else if checksum != manifest.MasterFiles[filename] {
+			problems = append(problems, VerificationProblem{fpInfo.Timestamp, -1, filename, "contents do not match the checksum recorded at backup time"})
+		}
+	}
+	return problems
+}
+
+/*
+ * Checks that every byte range in the TOC lies within the metadata file, that
+ * no two ranges overlap, and that each range contains a statement.
+ */
+func VerifyMetadataFileAgainstTOC(timestamp string, metadataFilename string, tocfile *toc.TOC) []VerificationProblem {
+	problems := make([]VerificationProblem, 0)
+	contents, err := utils.ReadBackupFile(metadataFilename)
+	gplog.FatalOnError(err)
+	metadataFile := bytes.NewReader(contents)
+	fileSize := uint64(len(contents))
+
+	sections := [][]toc.MetadataEntry{tocfile.GlobalEntries, tocfile.PredataEntries, tocfile.PostdataEntries}
+	var lastEnd uint64
+	for _, entries := range sections {
+		for _, entry := range entries {
+			object := fmt.Sprintf("%s %s", entry.ObjectType, utils.MakeFQN(entry.Schema, entry.Name))
+			if entry.Schema == "" {
+				object = fmt.Sprintf("%s %s", entry.ObjectType, entry.Name)
+			}
+			if entry.StartByte > entry.EndByte || entry.EndByte > fileSize {
+				problems = append(problems, VerificationProblem{timestamp, -1, object,
+					fmt.Sprintf("byte range %d-%d is outside of metadata file of %d bytes", entry.StartByte, entry.EndByte, fileSize)})
+				continue
+			}
+			if entry.StartByte < lastEnd {
+				problems = append(problems, VerificationProblem{timestamp, -1, object,
+					fmt.Sprintf("byte range %d-%d overlaps the previous statement", entry.StartByte, entry.EndByte)})
+			}
+			lastEnd = entry.EndByte
+			contents := make([]byte, entry.EndByte-entry.StartByte)
+			_, err = metadataFile.ReadAt(contents, int64(entry.StartByte))
+			if err != nil || strings.TrimSpace(string(contents)) == "" {
+				problems = append(problems, VerificationProblem{timestamp, -1, object,
+					fmt.Sprintf("byte range %d-%d does not contain a statement", entry.StartByte, entry.EndByte)})
+			}
+		}
+	}
+	return problems
+}
+
+/*
+ * Every table backed up at a given timestamp has a data file, even if a later
+ * incremental backup supersedes it, so the expected file count comes from the
+ * number of tables in the TOC rather than in the restore plan.
+ */
+func verifySegmentData(fpInfo filepath.FilePathInfo, dataEntries []toc.MasterDataEntry, numTablesInBackup int, manifest *utils.ChecksumManifest) []VerificationProblem {
+	problems := make([]VerificationProblem, 0)
+	if len(dataEntries) == 0 {
+		return problems
+	}
+	if pluginConfig == nil {
+		fileCount := 2 // 1 for the actual data file, 1 for the segment TOC file
+		if !backupConfig.SingleDataFile {
+			fileCount = numTablesInBackup
+		}
+		for _, contentID := range GetSegmentsWithIncorrectBackupFileCount(fpInfo, fileCount) {
+			problems = append(problems, VerificationProblem{fpInfo.Timestamp, contentID, "backup directory",
+				fmt.Sprintf("expected %d file(s) in %s", fileCount, fpInfo.GetDirForContent(contentID))})
+		}
+	}
+
+	if backupConfig.SingleDataFile {
+		problems = append(problems, verifySingleDataFiles(fpInfo, dataEntries, manifest)...)
+	} else {
+		problems = append(problems, verifyTableDataFiles(fpInfo, dataEntries, manifest)...)
+	}
+	return problems
+}
+
+/*
+ * The gpbackup_helper agent reads each segment's data file once, checking
+ * each table's byte range against the checksum in the segment TOC and
+ * reporting the checksum of the whole file.
+ */
+func verifySingleDataFiles(fpInfo filepath.FilePathInfo, dataEntries []toc.MasterDataEntry, manifest *utils.ChecksumManifest) []VerificationProblem {
+	utils.VerifyHelperVersionOnSegments(version, globalCluster)
+	pluginStr := ""
+	if pluginConfig != nil {
+		pluginStr = fmt.Sprintf(" --plugin-config %s", pluginConfig.ConfigPath)
+	}
+	pluginStr += getHelperEncryptionOption()
+	gphome := operating.System.Getenv("GPHOME")
+	extension := utils.GetPipeThroughProgram().Extension
+	remoteOutput := globalCluster.GenerateAndExecuteCommand("Verifying data files with gpbackup_helper", func(contentID int) string {
+		return fmt.Sprintf("source %[1]s/greenplum_path.sh && %[1]s/bin/gpbackup_helper --verify-agent --toc-file %s --data-file %s --content %d%s",
+			gphome, fpInfo.GetSegmentTOCFilePath(contentID), fpInfo.GetTableBackupFilePath(contentID, 0, extension, true), contentID, pluginStr)
+	}, cluster.ON_SEGMENTS)
+	globalCluster.CheckClusterError(remoteOutput, "Unable to verify data files", func(contentID int) string {
+		return "See gpAdminLog for gpbackup_helper on segment host for details: Error occurred while verifying data file"
+	})
+
+	problems := make([]VerificationProblem, 0)
+	for _, contentID := range sortedContentIDs(remoteOutput.Stdouts) {
+		tableStatuses, fileChecksum := ParseVerifyAgentOutput(remoteOutput.Stdouts[contentID])
+		for _, entry := range dataEntries {
+			status, ok := tableStatuses[entry.Oid]
+			if !ok {
+				status = "missing from the segment table of contents"
+			}
+			if message := getTableVerifyMessage(status); message != "" {
+				problems = append(problems, VerificationProblem{fpInfo.Timestamp, contentID, fmt.Sprintf("table %s", utils.MakeFQN(entry.Schema, entry.Name)), message})
+			}
+		}
+		if manifest != nil {
+			filename := path.Base(fpInfo.GetTableBackupFilePath(contentID, 0, extension, true))
+			problems = append(problems, compareSegmentChecksum(fpInfo.Timestamp, contentID, filename, fileChecksum, manifest)...)
+		}
+	}
+	return problems
+}
+
+/*
+ * Parses the "table <oid> <status>" and "file <checksum>" lines printed by
+ * the gpbackup_helper verify agent.
+ */
+func ParseVerifyAgentOutput(output string) (map[uint32]string, string) {
+	tableStatuses := make(map[uint32]string)
+	fileChecksum := ""
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == "table" {
+			oid, err := strconv.ParseUint(fields[1], 10, 32)
+			if err == nil {
+				tableStatuses[uint32(oid)] = fields[2]
+			}
+		} else if len(fields) == 2 && fields[0] == "file" {
+			fileChecksum = fields[1]
+		}
+	}
+	return tableStatuses, fileChecksum
+}
+
+func getTableVerifyMessage(status string) string {
+	switch status {
+	case utils.VerifyStatusOK:
+		return ""
+	case utils.VerifyStatusUnchecked:
+		gplog.Verbose("No checksum was recorded for a table in the segment table of contents")
+		return ""
+	case utils.VerifyStatusMismatch:
+		return "data does not match the checksum recorded at backup time"
+	case utils.VerifyStatusTruncated:
+		return "data file ends before the end of the table's data"
+	default:
+		return status
+	}
+}
+
+/*
+ * Data sent to a plugin is read back with the plugin's restore_data command
+ * and hashed on the segment, while local data files are hashed in place.
+ * Without a checksum manifest, every data file is still read, so that missing
+ * and unreadable files are reported.
+ */
+func verifyTableDataFiles(fpInfo filepath.FilePathInfo, dataEntries []toc.MasterDataEntry, manifest *utils.ChecksumManifest) []VerificationProblem {
+	problems := make([]VerificationProblem, 0)
+	if manifest == nil {
+		gplog.Warn("Checking only that the data files of backup %s can be read, as their contents cannot be checked without a checksum manifest", fpInfo.Timestamp)
+	}
+	oids := make([]string, len(dataEntries))
+	for i, entry := range dataEntries {
+		oids[i] = fmt.Sprintf("%d", entry.Oid)
+	}
+	extension := utils.GetPipeThroughProgram().Extension
+	remoteOutput := globalCluster.GenerateAndExecuteCommand("Computing checksums of data files", func(contentID int) string {
+		dataFilePattern := path.Join(fpInfo.GetDirForContent(contentID), fmt.Sprintf("gpbackup_%d_%s_${oid}%s", contentID, fpInfo.Timestamp, extension))
+		checksumCommand := fmt.Sprintf(`sha256sum "%s" || true`, dataFilePattern)
+		if pluginConfig != nil {
+			checksumCommand = fmt.Sprintf(`checksum=$(set -o pipefail; %s restore_data %s "%s" | sha256sum | cut -c1-64) && echo "$checksum  %s" || true`,
+				pluginConfig.ExecutablePath, pluginConfig.ConfigPath, dataFilePattern, dataFilePattern)
+		}
+		return fmt.Sprintf("source %s/greenplum_path.sh; for oid in %s; do %s; done", operating.System.Getenv("GPHOME"), strings.Join(oids, " "), checksumCommand)
+	}, cluster.ON_SEGMENTS)
+	globalCluster.CheckClusterError(remoteOutput, "Unable to compute checksums of data files", func(contentID int) string {
+		return fmt.Sprintf("Unable to compute checksums of data files in %s", fpInfo.GetDirForContent(contentID))
+	})
+
+	for _, contentID := range sortedContentIDs(remoteOutput.Stdouts) {
+		checksums := utils.ParseChecksumOutput(remoteOutput.Stdouts[contentID])
+		for _, entry := range dataEntries {
+			filename := path.Base(fpInfo.GetTableBackupFilePath(contentID, entry.Oid, extension, false))
+			for _, problem := range compareSegmentChecksum(fpInfo.Timestamp, contentID, filename, checksums[filename], manifest) {
+				problem.Object = fmt.Sprintf("table %s", utils.MakeFQN(entry.Schema, entry.Name))
+				problems = append(problems, problem)
+			}
+		}
+	}
+	return problems
+}
+
+// A file that could not be read has no checksum, whether or not there is a manifest
+func compareSegmentChecksum(timestamp string, contentID int, filename string, checksum string, manifest *utils.ChecksumManifest) []VerificationProblem {
+	if checksum == "" {
+		return []VerificationProblem{{timestamp, contentID, filename, "file could not be read"}}
+	} else if manifest == nil {
+		return []VerificationProblem{}
+	}
+	expected, ok := manifest.SegmentFiles[contentID][filename]
+	if !ok {
+		return []VerificationProblem{{timestamp, contentID, filename, "file is not in the checksum manifest"}}
+	} else if checksum != expected {
+		return []VerificationProblem{{timestamp, contentID, filename, "contents do not match the checksum recorded at backup time"}}
+	}
+	return []VerificationProblem{}
+}
+
+func sortedContentIDs(outputs map[int]string) []int {
+	contentIDs := make([]int, 0, len(outputs))
+	for contentID := range outputs {
+		contentIDs = append(contentIDs, contentID)
+	}
+	sort.Ints(contentIDs)
+	return contentIDs
+}
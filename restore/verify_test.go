@@ -0,0 +1,79 @@
+package restore_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/greenplum-db/gpbackup/restore"
+	"github.com/greenplum-db/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore/verify tests", func() {
+	Describe("VerifyMetadataFileAgainstTOC", func() {
+		var metadataFilename string
+		BeforeEach(func() {
+			metadataFile, _ := ioutil.TempFile("", "gpbackup_metadata")
+			_, _ = metadataFile.WriteString("\n\nCREATE SCHEMA foo;\n\n\nCREATE TABLE foo.bar (i int);\n")
+			_ = metadataFile.Close()
+			metadataFilename = metadataFile.Name()
+		})
+		AfterEach(func() {
+			_ = os.Remove(metadataFilename)
+		})
+		It("finds no problems when every entry is a statement within the file", func() {
+			tocfile := &toc.TOC{PredataEntries: []toc.MetadataEntry{
+				{Schema: "", Name: "foo", ObjectType: "SCHEMA", StartByte: 0, EndByte: 21},
+				{Schema: "foo", Name: "bar", ObjectType: "TABLE", StartByte: 21, EndByte: 53},
+			}}
+
+			problems := restore.VerifyMetadataFileAgainstTOC("20170101010101", metadataFilename, tocfile)
+
+			Expect(problems).To(BeEmpty())
+		})
+		It("reports entries that extend past the end of the file", func() {
+			tocfile := &toc.TOC{PredataEntries: []toc.MetadataEntry{
+				{Schema: "foo", Name: "bar", ObjectType: "TABLE", StartByte: 21, EndByte: 100},
+			}}
+
+			problems := restore.VerifyMetadataFileAgainstTOC("20170101010101", metadataFilename, tocfile)
+
+			Expect(problems).To(HaveLen(1))
+			Expect(problems[0].String()).To(Equal("Backup 20170101010101: TABLE foo.bar on master: byte range 21-100 is outside of metadata file of 53 bytes"))
+		})
+		It("reports entries that overlap or contain no statement", func() {
+			tocfile := &toc.TOC{PredataEntries: []toc.MetadataEntry{
+				{Schema: "", Name: "foo", ObjectType: "SCHEMA", StartByte: 0, EndByte: 21},
+				{Schema: "foo", Name: "bar", ObjectType: "TABLE", StartByte: 10, EndByte: 53},
+			}, PostdataEntries: []toc.MetadataEntry{
+				{Schema: "foo", Name: "baz", ObjectType: "INDEX", StartByte: 53, EndByte: 53},
+			}}
+
+			problems := restore.VerifyMetadataFileAgainstTOC("20170101010101", metadataFilename, tocfile)
+
+			Expect(problems).To(HaveLen(2))
+			Expect(problems[0].Object).To(Equal("TABLE foo.bar"))
+			Expect(problems[0].Message).To(Equal("byte range 10-53 overlaps the previous statement"))
+			Expect(problems[1].Object).To(Equal("INDEX foo.baz"))
+			Expect(problems[1].Message).To(Equal("byte range 53-53 does not contain a statement"))
+		})
+	})
+	Describe("ParseVerifyAgentOutput", func() {
+		It("parses table statuses and the file checksum", func() {
+			output := "table 1234 ok\ntable 2345 mismatch\nfile abc123\n"
+
+			tableStatuses, fileChecksum := restore.ParseVerifyAgentOutput(output)
+
+			Expect(tableStatuses).To(Equal(map[uint32]string{1234: "ok", 2345: "mismatch"}))
+			Expect(fileChecksum).To(Equal("abc123"))
+		})
+		It("returns an empty checksum if the agent did not finish reading the file", func() {
+			tableStatuses, fileChecksum := restore.ParseVerifyAgentOutput("table 1234 truncated\n")
+
+			Expect(tableStatuses).To(Equal(map[uint32]string{1234: "truncated"}))
+			Expect(fileChecksum).To(Equal(""))
+		})
+	})
+})
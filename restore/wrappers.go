@@ -1,7 +1,9 @@
 package restore
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	path "path/filepath"
 	"strconv"
 	"strings"
@@ -130,19 +132,68 @@ func SetMaxCsvLineLengthQuery(connectionPool *dbconn.DBConn) string {
 
 func InitializeBackupConfig() {
 	backupConfig = history.ReadConfigFile(globalFPInfo.GetConfigFilePath())
-	utils.InitializePipeThroughParameters(backupConfig.Compressed, 0)
+	err := utils.InitializePipeThroughParameters(backupConfig.Compressed, backupConfig.CompressionType, 0)
+	gplog.FatalOnError(err)
 	report.EnsureBackupVersionCompatibility(backupConfig.BackupVersion, version)
 	report.EnsureDatabaseVersionCompatibility(backupConfig.DatabaseVersion, connectionPool.Version)
 }
 
+/*
+ * Master files are decrypted by gprestore itself, while data is decrypted on
+ * the segments by gpbackup_helper with a copy of the key that is removed
+ * when the restore finishes.
+ */
+func initializeEncryption() {
+	key, err := utils.ReadEncryptionKey(MustGetFlagString(options.ENCRYPTION_KEY_FILE))
+	gplog.FatalOnError(err)
+	ValidateEncryptionKey(key)
+	utils.SetEncryptionKey(key)
+	if backupConfig.MetadataOnly {
+		return
+	}
+	utils.VerifyHelperVersionOnSegments(version, globalCluster)
+	encryptionKeyFile = utils.NewEncryptionKeyFilePath(fmt.Sprintf("gprestore_%s_%s", globalFPInfo.Timestamp, restoreStartTime))
+	utils.CopyEncryptionKeyToAllHosts(globalCluster, key, encryptionKeyFile)
+	utils.AddEncryptionToPipeThroughProgram(encryptionKeyFile)
+}
+
+// Returns the gpbackup_helper option giving the location of the key on segments, if any
+func getHelperEncryptionOption() string {
+	if encryptionKeyFile == "" {
+		return ""
+	}
+	return fmt.Sprintf(" --encryption-key-file %s", encryptionKeyFile)
+}
+
+/*
+ * Statements are read by their offsets in the unencrypted metadata file, so
+ * an encrypted metadata file is decrypted in memory.
+ */
+func openMetadataFileForReading(filename string) io.ReaderAt {
+	if utils.GetEncryptionKey() == nil {
+		return iohelper.MustOpenFileForReading(filename)
+	}
+	contents, err := utils.ReadBackupFile(filename)
+	gplog.FatalOnError(err)
+	return bytes.NewReader(contents)
+}
+
 func BackupConfigurationValidation() {
+	ValidateBackupSegmentCount(utils.GetSegmentCount(globalCluster))
 	if !backupConfig.MetadataOnly {
 		gplog.Verbose("Gathering information on backup directories")
 		VerifyBackupDirectoriesExistOnAllHosts()
 	}
 
 	VerifyMetadataFilePaths(MustGetFlagBool(options.WITH_STATS))
+	initializeGlobalTOC()
+	ValidateBackupFlagCombinations()
+
+	expandFilterPatterns()
+	validateFilterListsInBackupSet()
+}
 
+func initializeGlobalTOC() {
 	tocFilename := globalFPInfo.GetTOCFilePath()
 	globalTOC = toc.NewTOC(tocFilename)
 	globalTOC.InitializeMetadataEntryMap()
@@ -151,10 +202,6 @@ func BackupConfigurationValidation() {
 	if isLegacyBackup := backupConfig.RestorePlan == nil; isLegacyBackup {
 		SetRestorePlanForLegacyBackup(globalTOC, globalFPInfo.Timestamp, backupConfig)
 	}
-
-	ValidateBackupFlagCombinations()
-
-	validateFilterListsInBackupSet()
 }
 
 func SetRestorePlanForLegacyBackup(toc *toc.TOC, backupTimestamp string, backupConfig *history.BackupConfig) {
@@ -197,7 +244,14 @@ func RecoverMetadataFilesUsingPlugin() {
 	}
 
 	InitializeBackupConfig()
+}
 
+/*
+ * The tables of contents of the backups in the restore plan are restored
+ * once the chain of incremental backups has been validated, as the plan may
+ * refer to backups that are no longer available.
+ */
+func RecoverRestorePlanFilesUsingPlugin() {
 	var fpInfoList []filepath.FilePathInfo
 	if backupConfig.MetadataOnly {
 		fpInfoList = []filepath.FilePathInfo{globalFPInfo}
@@ -242,7 +296,7 @@ func GetRestoreMetadataStatements(section string, filename string, includeObject
 }
 
 func GetRestoreMetadataStatementsFiltered(section string, filename string, includeObjectTypes []string, excludeObjectTypes []string, filters Filters) []toc.StatementWithType {
-	metadataFile := iohelper.MustOpenFileForReading(filename)
+	metadataFile := openMetadataFileForReading(filename)
 	var statements []toc.StatementWithType
 	var inSchemas, exSchemas, inRelations, exRelations []string
 	if !filtersEmpty(filters) {
@@ -273,6 +327,32 @@ func GetRestoreMetadataStatementsFiltered(section string, filename string, inclu
 	return statements
 }
 
+/*
+ * Statements are filtered by object type separately from the filtering in
+ * GetRestoreMetadataStatementsFiltered, as the object types passed to it are
+ * those that each section of the restore needs rather than those the user asked for.
+ */
+func filterStatementsByObjectType(statements []toc.StatementWithType) []toc.StatementWithType {
+	if len(opts.IncludedObjectTypes) == 0 && len(opts.ExcludedObjectTypes) == 0 {
+		return statements
+	}
+	filteredStatements := make([]toc.StatementWithType, 0, len(statements))
+	for _, statement := range statements {
+		if opts.ShouldIncludeObjectType(statement.ObjectType) {
+			filteredStatements = append(filteredStatements, statement)
+		}
+	}
+	return filteredStatements
+}
+
+/*
+ * Table data and statistics are restored into the tables that the TABLE
+ * statements create, so they are skipped when tables are filtered out.
+ */
+func shouldRestoreTableData() bool {
+	return opts.ShouldIncludeObjectType("TABLE")
+}
+
 func ExecuteRestoreMetadataStatements(statements []toc.StatementWithType, objectsTitle string, progressBar utils.ProgressBar, showProgressBar int, executeInParallel bool) {
 	if progressBar == nil {
 		ExecuteStatementsAndCreateProgressBar(statements, objectsTitle, showProgressBar, executeInParallel)
@@ -320,6 +400,7 @@ func RestoreSchemas(schemaStatements []toc.StatementWithType, progressBar utils.
 		if err != nil {
 			if strings.Contains(err.Error(), "already exists") {
 				gplog.Warn("Schema %s already exists", schema.Name)
+				restoreJournal.RecordStatement(schema)
 			} else {
 				errMsg := fmt.Sprintf("Error encountered while creating schema %s", schema.Name)
 				if MustGetFlagBool(options.ON_ERROR_CONTINUE) {
@@ -329,6 +410,8 @@ func RestoreSchemas(schemaStatements []toc.StatementWithType, progressBar utils.
 					gplog.Fatal(err, errMsg)
 				}
 			}
+		} else {
+			restoreJournal.RecordStatement(schema)
 		}
 		progressBar.Increment()
 	}
@@ -367,6 +450,11 @@ func GetExistingSchemas() ([]string, error) {
 }
 
 func TruncateTablesBeforeRestore(entries []toc.MasterDataEntry) error {
+	_, err := connectionPool.Exec(getTruncateTablesQuery(entries))
+	return err
+}
+
+func getTruncateTablesQuery(entries []toc.MasterDataEntry) string {
 	query := `TRUNCATE `
 	tableFQNs := make([]string, 0)
 	for _, entry := range entries {
@@ -375,6 +463,5 @@ func TruncateTablesBeforeRestore(entries []toc.MasterDataEntry) error {
 	}
 	query += strings.Join(tableFQNs, ",")
 	query += ";"
-	_, err := connectionPool.Exec(query)
-	return err
+	return query
 }
@@ -32,6 +32,9 @@ type MetadataEntry struct {
 	ReferenceObject string
 	StartByte       uint64
 	EndByte         uint64
+	// Pre-data entries of dependent objects have an ID and list the IDs of the entries they depend on
+	ID        uint64   `yaml:",omitempty"`
+	DependsOn []uint64 `yaml:",omitempty"`
 }
 
 type MasterDataEntry struct {
@@ -41,15 +44,20 @@ type MasterDataEntry struct {
 	AttributeString string
 	RowsCopied      int64
 	PartitionRoot   string
+	// The predicate, if any, that the rows of a table were filtered by when it was backed up
+	Predicate string `yaml:",omitempty"`
 }
 
 type SegmentDataEntry struct {
 	StartByte uint64
 	EndByte   uint64
+	Checksum  string `yaml:",omitempty"`
 }
 
 type IncrementalEntries struct {
 	AO map[string]AOEntry
+	// Only recorded for backups taken with --incremental-heap
+	Heap map[string]HeapEntry `yaml:",omitempty"`
 }
 
 type AOEntry struct {
@@ -57,15 +65,38 @@ type AOEntry struct {
 	LastDDLTimestamp string
 }
 
+/*
+ * A heap table has no modcount, so it is considered changed if its
+ * relfilenode, its last DDL timestamp, or any of the tuple counters in its
+ * statistics on the segments differ from those in the last backup.
+ */
+type HeapEntry struct {
+	Relfilenode      uint32
+	TuplesInserted   int64
+	TuplesUpdated    int64
+	TuplesDeleted    int64
+	LastDDLTimestamp string
+}
+
 func NewTOC(filename string) *TOC {
-	toc := &TOC{}
-	contents, err := ioutil.ReadFile(filename)
-	gplog.FatalOnError(err)
-	err = yaml.Unmarshal(contents, toc)
+	toc, err := ReadTOC(filename)
 	gplog.FatalOnError(err)
 	return toc
 }
 
+func ReadTOC(filename string) (*TOC, error) {
+	toc := &TOC{}
+	contents, err := utils.ReadBackupFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(contents, toc)
+	if err != nil {
+		return nil, err
+	}
+	return toc, nil
+}
+
 func NewSegmentTOC(filename string) *SegmentTOC {
 	toc := &SegmentTOC{}
 	contents, err := ioutil.ReadFile(filename)
@@ -78,6 +109,10 @@ func NewSegmentTOC(filename string) *SegmentTOC {
 func (toc *TOC) WriteToFileAndMakeReadOnly(filename string) {
 	contents, err := yaml.Marshal(toc)
 	gplog.FatalOnError(err)
+	if key := utils.GetEncryptionKey(); key != nil {
+		contents, err = utils.EncryptBytes(contents, key)
+		gplog.FatalOnError(err)
+	}
 	err = utils.WriteToFileAndMakeReadOnly(filename, contents)
 	gplog.FatalOnError(err)
 }
@@ -96,6 +131,8 @@ type StatementWithType struct {
 	ObjectType      string
 	ReferenceObject string
 	Statement       string
+	ID              uint64
+	DependsOn       []uint64
 }
 
 func GetIncludedPartitionRoots(tocDataEntries []MasterDataEntry, includeRelations []string) []string {
@@ -130,7 +167,7 @@ func (toc *TOC) GetSQLStatementForObjectTypes(section string, metadataFile io.Re
 			contents := make([]byte, entry.EndByte-entry.StartByte)
 			_, err := metadataFile.ReadAt(contents, int64(entry.StartByte))
 			gplog.FatalOnError(err)
-			statements = append(statements, StatementWithType{Schema: entry.Schema, Name: entry.Name, ObjectType: entry.ObjectType, ReferenceObject: entry.ReferenceObject, Statement: string(contents)})
+			statements = append(statements, StatementWithType{Schema: entry.Schema, Name: entry.Name, ObjectType: entry.ObjectType, ReferenceObject: entry.ReferenceObject, Statement: string(contents), ID: entry.ID, DependsOn: entry.DependsOn})
 		}
 	}
 	return statements
@@ -265,11 +302,16 @@ func (toc *TOC) AddMetadataEntry(section string, entry MetadataEntry, start, end
 	*toc.metadataEntryMap[section] = append(*toc.metadataEntryMap[section], entry)
 }
 
-func (toc *TOC) AddMasterDataEntry(schema string, name string, oid uint32, attributeString string, rowsCopied int64, PartitionRoot string) {
-	toc.DataEntries = append(toc.DataEntries, MasterDataEntry{schema, name, oid, attributeString, rowsCopied, PartitionRoot})
+func (toc *TOC) AddMasterDataEntry(schema string, name string, oid uint32, attributeString string, rowsCopied int64, PartitionRoot string, predicate string) {
+	toc.DataEntries = append(toc.DataEntries, MasterDataEntry{schema, name, oid, attributeString, rowsCopied, PartitionRoot, predicate})
 }
 
-func (toc *SegmentTOC) AddSegmentDataEntry(oid uint, startByte uint64, endByte uint64) {
+/*
+ * The checksum is the SHA-256 digest of the uncompressed bytes in the range,
+ * so that the data for a single table can be verified without reading the
+ * rest of the data file.
+ */
+func (toc *SegmentTOC) AddSegmentDataEntry(oid uint, startByte uint64, endByte uint64, checksum string) {
 	// We use uint for oid since the flags package does not have a uint32 flag
-	toc.DataEntries[oid] = SegmentDataEntry{startByte, endByte}
+	toc.DataEntries[oid] = SegmentDataEntry{startByte, endByte, checksum}
 }
@@ -81,6 +81,16 @@ var _ = Describe("utils/toc tests", func() {
 
 			Expect(statements).To(Equal([]toc.StatementWithType{view}))
 		})
+		It("returns the ID and dependencies of an entry with its statement", func() {
+			tocfile.PredataEntries[3].ID = 4
+			tocfile.PredataEntries[3].DependsOn = []uint64{1, 2}
+			statements := tocfile.GetSQLStatementForObjectTypes("predata", metadataFile, []string{"VIEW"}, noExObj, noInSchema, noExSchema, noInRelation, noExRelation)
+
+			expectedView := view
+			expectedView.ID = 4
+			expectedView.DependsOn = []uint64{1, 2}
+			Expect(statements).To(Equal([]toc.StatementWithType{expectedView}))
+		})
 		It("returns statement for multiple object types", func() {
 			statements := tocfile.GetSQLStatementForObjectTypes("predata", metadataFile, []string{"TABLE", "VIEW"}, noExObj, noInSchema, noExSchema, noInRelation, noExRelation)
 
@@ -195,11 +205,11 @@ var _ = Describe("utils/toc tests", func() {
 	})
 	Describe("GetDataEntriesMatching", func() {
 		BeforeEach(func() {
-			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "")
-			tocfile.AddMasterDataEntry("schema2", "table2", 1, "(i)", 0, "")
-			tocfile.AddMasterDataEntry("schema3", "table3", 1, "(i)", 0, "")
-			tocfile.AddMasterDataEntry("schema3", "table3_partition1", 1, "(i)", 0, "table3")
-			tocfile.AddMasterDataEntry("schema3", "table3_partition2", 1, "(i)", 0, "table3")
+			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "", "")
+			tocfile.AddMasterDataEntry("schema2", "table2", 1, "(i)", 0, "", "")
+			tocfile.AddMasterDataEntry("schema3", "table3", 1, "(i)", 0, "", "")
+			tocfile.AddMasterDataEntry("schema3", "table3_partition1", 1, "(i)", 0, "table3", "")
+			tocfile.AddMasterDataEntry("schema3", "table3_partition2", 1, "(i)", 0, "table3", "")
 		})
 		Context("Non-empty restore plan", func() {
 			restorePlanTableFQNs := []string{"schema1.table1", "schema2.table2", "schema3.table3", "schema3.table3_partition1", "schema3.table3_partition2"}
@@ -243,6 +253,17 @@ var _ = Describe("utils/toc tests", func() {
 					},
 				))
 			})
+			It("returns matching entries on include table and schema patterns", func() {
+				matchingEntries := tocfile.GetDataEntriesMatching([]string{"re:schema[23]"}, []string{},
+					[]string{"glob:schema3.table3_*"}, []string{}, restorePlanTableFQNs)
+
+				Expect(matchingEntries).To(Equal(
+					[]toc.MasterDataEntry{
+						{Schema: "schema3", Name: "table3_partition1", Oid: 1, AttributeString: "(i)", PartitionRoot: "table3"},
+						{Schema: "schema3", Name: "table3_partition2", Oid: 1, AttributeString: "(i)", PartitionRoot: "table3"},
+					},
+				))
+			})
 			It("returns matching entry on exclude table", func() {
 				excludeTables := []string{"schema2.table2"}
 
@@ -400,22 +421,22 @@ COMMENT ON DATABASE "db-special-chär$" IS 'this is a database comment';`}
 	})
 	Describe("GetIncludedPartitionRoots", func() {
 		It("does not return anything if relations are not leaf partitions", func() {
-			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "")
+			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "", "")
+			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "", "")
 			roots := toc.GetIncludedPartitionRoots(tocfile.DataEntries, []string{"schema0.name0", "schema1.name1"})
 			Expect(roots).To(BeEmpty())
 		})
 		It("returns root parition of leaf partitions", func() {
-			tocfile.AddMasterDataEntry("schema0", "name0", 2, "attribute0", 1, "root0")
-			tocfile.AddMasterDataEntry("schema1", "name1", 3, "attribute0", 1, "root1")
+			tocfile.AddMasterDataEntry("schema0", "name0", 2, "attribute0", 1, "root0", "")
+			tocfile.AddMasterDataEntry("schema1", "name1", 3, "attribute0", 1, "root1", "")
 			roots := toc.GetIncludedPartitionRoots(tocfile.DataEntries, []string{"schema0.name0", "schema1.name1"})
 			Expect(roots).To(ConsistOf("schema0.root0", "schema1.root1"))
 		})
 		It("only returns root partitions of leaf partitions", func() {
-			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2")
-			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3")
+			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "", "")
+			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "", "")
+			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2", "")
+			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3", "")
 			roots := toc.GetIncludedPartitionRoots(tocfile.DataEntries, []string{"schema2.name2", "schema3.name3"})
 			Expect(roots).To(ConsistOf("schema2.root2", "schema3.root3"))
 		})
@@ -424,18 +445,18 @@ COMMENT ON DATABASE "db-special-chär$" IS 'this is a database comment';`}
 			Expect(roots).To(BeEmpty())
 		})
 		It("returns nothing if relation is not part of TOC data entries", func() {
-			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2")
-			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3")
+			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "", "")
+			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "", "")
+			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2", "")
+			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3", "")
 			roots := toc.GetIncludedPartitionRoots(tocfile.DataEntries, []string{"schema4.name4", "schema5.name5"})
 			Expect(roots).To(BeEmpty())
 		})
 		It("returns empty if no relations are passed in", func() {
-			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2")
-			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3")
+			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "", "")
+			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "", "")
+			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2", "")
+			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3", "")
 			roots := toc.GetIncludedPartitionRoots(tocfile.DataEntries, []string{})
 			Expect(roots).To(BeEmpty())
 		})
@@ -144,6 +144,26 @@ HEREDOC
 	})
 }
 
+/*
+ * The agent writes the segment TOC file once it has finished writing the data
+ * file, or an error file if it fails, so waiting for either of those files
+ * ensures that the agent is done with the backup.
+ */
+func WaitForSegmentTOCs(c *cluster.Cluster, fpInfo filepath.FilePathInfo, verboseMsg string, errMsg string) {
+	var command string
+	remoteOutput := c.GenerateAndExecuteCommand(verboseMsg,
+		func(contentID int) string {
+			tocFile := fpInfo.GetSegmentTOCFilePath(contentID)
+			errorFile := fmt.Sprintf("%s_error", fpInfo.GetSegmentPipeFilePath(contentID))
+			command = fmt.Sprintf(`while [[ ! -f "%s" && ! -f "%s" ]]; do sleep 1; done; ls "%s"`, tocFile, errorFile, tocFile)
+			return command
+		}, cluster.ON_SEGMENTS)
+	gplog.Debug("%s", command)
+	c.CheckClusterError(remoteOutput, "Error occurred in gpbackup_helper", func(contentID int) string {
+		return errMsg
+	})
+}
+
 func CleanUpHelperFilesOnAllHosts(c *cluster.Cluster, fpInfo filepath.FilePathInfo) {
 	remoteOutput := c.GenerateAndExecuteCommand("Removing oid list and helper script files from segment data directories", func(contentID int) string {
 		errorFile := fmt.Sprintf("%s_error", fpInfo.GetSegmentPipeFilePath(contentID))
@@ -0,0 +1,168 @@
+package utils
+
+/*
+ * This file contains structs and functions related to computing and recording
+ * checksums of the files written by a backup.
+ */
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ChecksumAlgorithm = "sha256"
+	/*
+	 * Whatever writes a data file, whether to the segment's disk or to a plugin,
+	 * stores its checksum in a file with this suffix next to where the file is
+	 * or would have been written.
+	 */
+	ChecksumFileSuffix = ".sha256"
+)
+
+// Statuses reported for each table by the gpbackup_helper verify agent
+const (
+	VerifyStatusOK        = "ok"
+	VerifyStatusMismatch  = "mismatch"
+	VerifyStatusTruncated = "truncated"
+	VerifyStatusUnchecked = "unchecked"
+)
+
+/*
+ * Files are keyed by base name, as the directory they are in is determined
+ * by the backup timestamp and the content ID.
+ */
+type ChecksumManifest struct {
+	Algorithm    string
+	MasterFiles  map[string]string
+	SegmentFiles map[int]map[string]string
+}
+
+func NewChecksumManifest() *ChecksumManifest {
+	return &ChecksumManifest{
+		Algorithm:    ChecksumAlgorithm,
+		MasterFiles:  make(map[string]string),
+		SegmentFiles: make(map[int]map[string]string),
+	}
+}
+
+func ReadChecksumManifest(filename string) (*ChecksumManifest, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &ChecksumManifest{}
+	err = yaml.Unmarshal(contents, manifest)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Algorithm != ChecksumAlgorithm {
+		return nil, errors.Errorf("Checksum manifest %s uses unsupported algorithm %s", filename, manifest.Algorithm)
+	}
+	return manifest, nil
+}
+
+func (manifest *ChecksumManifest) WriteToFileAndMakeReadOnly(filename string) error {
+	contents, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return WriteToFileAndMakeReadOnly(filename, contents)
+}
+
+func (manifest *ChecksumManifest) AddMasterFile(filename string) error {
+	checksum, err := ComputeFileChecksum(filename)
+	if err != nil {
+		return err
+	}
+	manifest.MasterFiles[path.Base(filename)] = checksum
+	return nil
+}
+
+func ComputeFileChecksum(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/*
+ * Returns a map from content ID to a map from file name to checksum for the
+ * files in each segment backup directory.  Checksums that were computed while
+ * the file was written are taken from the corresponding checksum files, which
+ * are removed so that they are not mistaken for backup files, and any other
+ * files are read and hashed on the segment.
+ */
+func GetSegmentFileChecksums(c *cluster.Cluster, fpInfo filepath.FilePathInfo) map[int]map[string]string {
+	remoteOutput := c.GenerateAndExecuteCommand("Computing checksums of segment backup files", func(contentID int) string {
+		return fmt.Sprintf(`set -e; cd %s; for f in gpbackup_*; do
+[ -e "$f" ] || continue
+case "$f" in
+*%[2]s) read sum rest < "$f"; echo "$sum  ${f%%%[2]s}"; rm -f "$f";;
+*) [ -f "$f%[2]s" ] || sha256sum "$f";;
+esac
+done`, fpInfo.GetDirForContent(contentID), ChecksumFileSuffix)
+	}, cluster.ON_SEGMENTS)
+	c.CheckClusterError(remoteOutput, "Unable to compute checksums of segment backup files", func(contentID int) string {
+		return fmt.Sprintf("Unable to compute checksums of files in %s", fpInfo.GetDirForContent(contentID))
+	})
+
+	checksums := make(map[int]map[string]string, len(remoteOutput.Stdouts))
+	for contentID, stdout := range remoteOutput.Stdouts {
+		checksums[contentID] = ParseChecksumOutput(stdout)
+	}
+	return checksums
+}
+
+/*
+ * Parses output in the format of sha256sum, one "<checksum>  <filename>" line
+ * per file.
+ */
+func ParseChecksumOutput(output string) map[string]string {
+	checksums := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		checksums[path.Base(strings.TrimPrefix(fields[1], "*"))] = fields[0]
+	}
+	return checksums
+}
+
+/*
+ * Segment backup directories are not created for metadata-only backups, so
+ * segment files are only included when the caller says there may be some.
+ */
+func WriteChecksumManifest(c *cluster.Cluster, fpInfo filepath.FilePathInfo, masterFiles []string, includeSegmentFiles bool) {
+	gplog.Verbose("Writing checksum manifest to %s", fpInfo.GetChecksumManifestFilePath())
+	manifest := NewChecksumManifest()
+	for _, filename := range masterFiles {
+		err := manifest.AddMasterFile(filename)
+		gplog.FatalOnError(err)
+	}
+	if includeSegmentFiles {
+		manifest.SegmentFiles = GetSegmentFileChecksums(c, fpInfo)
+	}
+	err := manifest.WriteToFileAndMakeReadOnly(fpInfo.GetChecksumManifestFilePath())
+	gplog.FatalOnError(err)
+}
@@ -0,0 +1,115 @@
+package utils_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/checksum tests", func() {
+	var tempDir string
+	BeforeEach(func() {
+		tempDir, _ = ioutil.TempDir("", "gpbackup-checksum")
+	})
+	AfterEach(func() {
+		_ = os.RemoveAll(tempDir)
+	})
+	Describe("ComputeFileChecksum", func() {
+		It("returns the SHA-256 digest of the file contents", func() {
+			filename := path.Join(tempDir, "file")
+			_ = ioutil.WriteFile(filename, []byte("abc"), 0644)
+
+			checksum, err := utils.ComputeFileChecksum(filename)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(checksum).To(Equal("ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"))
+		})
+		It("returns an error if the file does not exist", func() {
+			_, err := utils.ComputeFileChecksum(path.Join(tempDir, "missing"))
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Describe("ParseChecksumOutput", func() {
+		It("maps file names to checksums and ignores malformed lines", func() {
+			output := `abc123  gpbackup_0_20170101010101_1234.gz
+def456  /data/gpseg0/gpbackup_0_20170101010101_toc.yaml
+789abc *gpbackup_0_20170101010101_5678
+
+garbage`
+			Expect(utils.ParseChecksumOutput(output)).To(Equal(map[string]string{
+				"gpbackup_0_20170101010101_1234.gz":  "abc123",
+				"gpbackup_0_20170101010101_toc.yaml": "def456",
+				"gpbackup_0_20170101010101_5678":     "789abc",
+			}))
+		})
+	})
+	Describe("ChecksumManifest", func() {
+		It("writes a manifest that can be read back", func() {
+			filename := path.Join(tempDir, "gpbackup_20170101010101_checksums.yaml")
+			manifest := utils.NewChecksumManifest()
+			manifest.MasterFiles["gpbackup_20170101010101_toc.yaml"] = "abc123"
+			manifest.SegmentFiles[0] = map[string]string{"gpbackup_0_20170101010101_1234.gz": "def456"}
+
+			err := manifest.WriteToFileAndMakeReadOnly(filename)
+			Expect(err).ToNot(HaveOccurred())
+			result, err := utils.ReadChecksumManifest(filename)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(manifest))
+		})
+		It("returns an error if the manifest uses an unknown algorithm", func() {
+			filename := path.Join(tempDir, "gpbackup_20170101010101_checksums.yaml")
+			_ = ioutil.WriteFile(filename, []byte("algorithm: md5\n"), 0644)
+
+			_, err := utils.ReadChecksumManifest(filename)
+
+			Expect(err).To(MatchError(ContainSubstring("uses unsupported algorithm md5")))
+		})
+	})
+	Describe("GetSegmentFileChecksums", func() {
+		var (
+			testCluster  *cluster.Cluster
+			testExecutor *testhelper.TestExecutor
+			fpInfo       filepath.FilePathInfo
+		)
+		BeforeEach(func() {
+			testExecutor = &testhelper.TestExecutor{
+				ClusterOutput: &cluster.RemoteOutput{
+					Stdouts: map[int]string{
+						0: "abc123  gpbackup_0_20170101010101_1234.gz\n",
+						1: "def456  gpbackup_1_20170101010101_1234.gz\n",
+					},
+				},
+			}
+			testCluster = cluster.NewCluster([]cluster.SegConfig{
+				{ContentID: -1, Hostname: "localhost", DataDir: "/data/gpseg-1"},
+				{ContentID: 0, Hostname: "localhost", DataDir: "/data/gpseg0"},
+				{ContentID: 1, Hostname: "remotehost1", DataDir: "/data/gpseg1"},
+			})
+			testCluster.Executor = testExecutor
+			fpInfo = filepath.NewFilePathInfo(testCluster, "", "20170101010101", "gpseg")
+		})
+		It("computes checksums in each segment backup directory", func() {
+			checksums := utils.GetSegmentFileChecksums(testCluster, fpInfo)
+
+			Expect(testExecutor.NumExecutions).To(Equal(1))
+			cc := testExecutor.ClusterCommands[0]
+			Expect(cc[0][4]).To(ContainSubstring("cd /data/gpseg0/backups/20170101/20170101010101;"))
+			Expect(cc[1][4]).To(ContainSubstring("cd /data/gpseg1/backups/20170101/20170101010101;"))
+			Expect(cc[0][4]).To(ContainSubstring(`*.sha256) read sum rest < "$f"; echo "$sum  ${f%.sha256}"; rm -f "$f";;`))
+			Expect(checksums).To(Equal(map[int]map[string]string{
+				0: {"gpbackup_0_20170101010101_1234.gz": "abc123"},
+				1: {"gpbackup_1_20170101010101_1234.gz": "def456"},
+			}))
+		})
+	})
+})
@@ -1,6 +1,12 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/pkg/errors"
+)
 
 var (
 	pipeThroughProgram PipeThroughProgram
@@ -13,12 +19,69 @@ type PipeThroughProgram struct {
 	Extension     string
 }
 
-func InitializePipeThroughParameters(compress bool, compressionLevel int) {
-	if compress {
-		pipeThroughProgram = PipeThroughProgram{Name: "gzip", OutputCommand: fmt.Sprintf("gzip -c -%d", compressionLevel), InputCommand: "gzip -d -c", Extension: ".gz"}
-	} else {
+type CompressionCodec struct {
+	Name string
+	// OutputCommand is formatted with the compression level
+	OutputCommand string
+	InputCommand  string
+	Extension     string
+	MinLevel      int
+	MaxLevel      int
+}
+
+var compressionCodecs = []CompressionCodec{
+	{Name: "gzip", OutputCommand: "gzip -c -%d", InputCommand: "gzip -d -c", Extension: ".gz", MinLevel: 1, MaxLevel: 9},
+	{Name: "zstd", OutputCommand: "zstd --compress -%d -c", InputCommand: "zstd --decompress -c", Extension: ".zst", MinLevel: 1, MaxLevel: 19},
+	{Name: "lz4", OutputCommand: "lz4 --compress -%d -c", InputCommand: "lz4 --decompress -c", Extension: ".lz4", MinLevel: 1, MaxLevel: 12},
+}
+
+/*
+ * Backups taken before the compression type was recorded were always
+ * compressed with gzip, so an empty type is treated as gzip.
+ */
+func GetCompressionCodec(compressionType string) (CompressionCodec, error) {
+	if compressionType == "" {
+		compressionType = "gzip"
+	}
+	for _, codec := range compressionCodecs {
+		if codec.Name == compressionType {
+			return codec, nil
+		}
+	}
+	return CompressionCodec{}, errors.Errorf("Unknown compression type %s.  Valid values are gzip, zstd, and lz4.", compressionType)
+}
+
+func GetCompressionCodecForFile(filename string) (CompressionCodec, bool) {
+	for _, codec := range compressionCodecs {
+		if strings.HasSuffix(filename, codec.Extension) {
+			return codec, true
+		}
+	}
+	return CompressionCodec{}, false
+}
+
+func ValidateCompressionTypeAndLevel(compressionType string, compressionLevel int) error {
+	codec, err := GetCompressionCodec(compressionType)
+	if err != nil {
+		return err
+	}
+	if compressionLevel < codec.MinLevel || compressionLevel > codec.MaxLevel {
+		return errors.Errorf("Compression level must be between %d and %d", codec.MinLevel, codec.MaxLevel)
+	}
+	return nil
+}
+
+func InitializePipeThroughParameters(compress bool, compressionType string, compressionLevel int) error {
+	if !compress {
 		pipeThroughProgram = PipeThroughProgram{Name: "cat", OutputCommand: "cat -", InputCommand: "cat -", Extension: ""}
+		return nil
+	}
+	codec, err := GetCompressionCodec(compressionType)
+	if err != nil {
+		return err
 	}
+	pipeThroughProgram = PipeThroughProgram{Name: codec.Name, OutputCommand: fmt.Sprintf(codec.OutputCommand, compressionLevel), InputCommand: codec.InputCommand, Extension: codec.Extension}
+	return nil
 }
 
 func GetPipeThroughProgram() PipeThroughProgram {
@@ -28,3 +91,21 @@ func GetPipeThroughProgram() PipeThroughProgram {
 func SetPipeThroughProgram(compression PipeThroughProgram) {
 	pipeThroughProgram = compression
 }
+
+/*
+ * Data is compressed and decompressed on the segments, so the program of the
+ * compression type must be installed on every host, which is checked before
+ * any data is backed up or restored rather than when the first table fails.
+ */
+func CheckCompressionProgramOnAllHosts(c *cluster.Cluster, compressionType string) {
+	codec, err := GetCompressionCodec(compressionType)
+	if err != nil {
+		return
+	}
+	remoteOutput := c.GenerateAndExecuteCommand(fmt.Sprintf("Checking for %s on all hosts", codec.Name), func(contentID int) string {
+		return fmt.Sprintf("command -v %s", codec.Name)
+	}, cluster.ON_HOSTS_AND_MASTER)
+	c.CheckClusterError(remoteOutput, fmt.Sprintf("Unable to find %s on all hosts", codec.Name), func(contentID int) string {
+		return fmt.Sprintf("Unable to find %s on host %s", codec.Name, c.GetHostForContent(contentID))
+	})
+}
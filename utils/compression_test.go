@@ -8,8 +8,10 @@ import (
 	"github.com/greenplum-db/gp-common-go-libs/structmatcher"
 	"github.com/greenplum-db/gp-common-go-libs/testhelper"
 	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("utils/compression tests", func() {
@@ -39,7 +41,7 @@ var _ = Describe("utils/compression tests", func() {
 				InputCommand:  "cat -",
 				Extension:     "",
 			}
-			utils.InitializePipeThroughParameters(false, 3)
+			utils.InitializePipeThroughParameters(false, "", 3)
 			resultProgram := utils.GetPipeThroughProgram()
 			structmatcher.ExpectStructsToMatch(&expectedProgram, &resultProgram)
 		})
@@ -52,9 +54,88 @@ var _ = Describe("utils/compression tests", func() {
 				InputCommand:  "gzip -d -c",
 				Extension:     ".gz",
 			}
-			utils.InitializePipeThroughParameters(true, 7)
+			utils.InitializePipeThroughParameters(true, "gzip", 7)
 			resultProgram := utils.GetPipeThroughProgram()
 			structmatcher.ExpectStructsToMatch(&expectedProgram, &resultProgram)
 		})
+		It("initializes to use zstd when passed the zstd compression type", func() {
+			originalProgram := utils.GetPipeThroughProgram()
+			defer utils.SetPipeThroughProgram(originalProgram)
+			expectedProgram := utils.PipeThroughProgram{
+				Name:          "zstd",
+				OutputCommand: "zstd --compress -3 -c",
+				InputCommand:  "zstd --decompress -c",
+				Extension:     ".zst",
+			}
+			err := utils.InitializePipeThroughParameters(true, "zstd", 3)
+			Expect(err).ToNot(HaveOccurred())
+			resultProgram := utils.GetPipeThroughProgram()
+			structmatcher.ExpectStructsToMatch(&expectedProgram, &resultProgram)
+		})
+		It("initializes to use lz4 when passed the lz4 compression type", func() {
+			originalProgram := utils.GetPipeThroughProgram()
+			defer utils.SetPipeThroughProgram(originalProgram)
+			expectedProgram := utils.PipeThroughProgram{
+				Name:          "lz4",
+				OutputCommand: "lz4 --compress -1 -c",
+				InputCommand:  "lz4 --decompress -c",
+				Extension:     ".lz4",
+			}
+			err := utils.InitializePipeThroughParameters(true, "lz4", 1)
+			Expect(err).ToNot(HaveOccurred())
+			resultProgram := utils.GetPipeThroughProgram()
+			structmatcher.ExpectStructsToMatch(&expectedProgram, &resultProgram)
+		})
+		It("returns an error when passed an unknown compression type", func() {
+			err := utils.InitializePipeThroughParameters(true, "bzip2", 1)
+			Expect(err).To(MatchError("Unknown compression type bzip2.  Valid values are gzip, zstd, and lz4."))
+		})
+	})
+	Describe("ValidateCompressionTypeAndLevel", func() {
+		It("accepts levels within the range of the compression type", func() {
+			Expect(utils.ValidateCompressionTypeAndLevel("gzip", 9)).To(Succeed())
+			Expect(utils.ValidateCompressionTypeAndLevel("zstd", 19)).To(Succeed())
+			Expect(utils.ValidateCompressionTypeAndLevel("lz4", 12)).To(Succeed())
+		})
+		It("rejects levels outside of the range of the compression type", func() {
+			Expect(utils.ValidateCompressionTypeAndLevel("gzip", 10)).To(MatchError("Compression level must be between 1 and 9"))
+			Expect(utils.ValidateCompressionTypeAndLevel("zstd", 0)).To(MatchError("Compression level must be between 1 and 19"))
+		})
+	})
+	Describe("GetCompressionCodecForFile", func() {
+		It("determines the compression type from the file extension", func() {
+			codec, ok := utils.GetCompressionCodecForFile("gpbackup_0_20170101010101_1234.zst")
+			Expect(ok).To(BeTrue())
+			Expect(codec.Name).To(Equal("zstd"))
+		})
+		It("returns false for uncompressed files", func() {
+			_, ok := utils.GetCompressionCodecForFile("gpbackup_0_20170101010101_1234")
+			Expect(ok).To(BeFalse())
+		})
+	})
+	Describe("CheckCompressionProgramOnAllHosts", func() {
+		It("checks for the program of the compression type on every host", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{}
+
+			utils.CheckCompressionProgramOnAllHosts(testCluster, "zstd")
+
+			Expect(testExecutor.NumExecutions).To(Equal(1))
+			Expect(testExecutor.ClusterCommands[0]).To(HaveLen(2))
+			for _, command := range testExecutor.ClusterCommands[0] {
+				Expect(command[len(command)-1]).To(Equal("command -v zstd"))
+			}
+		})
+		It("panics if the program is missing on a host", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{
+				NumErrors: 1,
+				Scope:     cluster.ON_HOSTS_AND_MASTER,
+				Stderrs:   map[int]string{1: ""},
+				Errors:    map[int]error{1: errors.New("exit status 1")},
+				CmdStrs:   map[int]string{1: "command -v lz4"},
+			}
+
+			Expect(func() { utils.CheckCompressionProgramOnAllHosts(testCluster, "lz4") }).To(Panic())
+			Expect(string(logfile.Contents())).To(ContainSubstring("Unable to find lz4 on host remotehost1"))
+		})
 	})
 })
@@ -0,0 +1,388 @@
+package utils
+
+/*
+ * This file contains functions for encrypting backup files with AES-256-GCM.
+ */
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+const (
+	EncryptionKeyEnvVar = "GPBACKUP_ENCRYPTION_KEY"
+
+	encryptionMagic       = "GPBKENC1"
+	encryptionNoncePrefix = 8
+	encryptionChunkSize   = 64 * 1024
+	// The high bit of a chunk's length marks the last chunk, so that truncated files are detected
+	encryptionFinalChunk = uint32(1) << 31
+)
+
+var (
+	encryptionKey []byte
+)
+
+func SetEncryptionKey(key []byte) {
+	encryptionKey = key
+}
+
+func GetEncryptionKey() []byte {
+	return encryptionKey
+}
+
+/*
+ * A key is 32 bytes written as 64 hexadecimal characters, such as the output
+ * of "openssl rand -hex 32".
+ */
+func ParseEncryptionKey(keyStr string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(keyStr))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("Encryption key must be 64 hexadecimal characters")
+	}
+	return key, nil
+}
+
+/*
+ * Reads the key from keyFile if one is given and from the environment
+ * variable otherwise.
+ */
+func ReadEncryptionKey(keyFile string) ([]byte, error) {
+	if keyFile != "" {
+		contents, err := operating.System.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseEncryptionKey(string(contents))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid encryption key in %s", keyFile)
+		}
+		return key, nil
+	}
+	keyStr := operating.System.Getenv(EncryptionKeyEnvVar)
+	if keyStr == "" {
+		return nil, errors.Errorf("No encryption key was provided.  Use --encryption-key-file or set %s.", EncryptionKeyEnvVar)
+	}
+	key, err := ParseEncryptionKey(keyStr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid encryption key in %s", EncryptionKeyEnvVar)
+	}
+	return key, nil
+}
+
+// The fingerprint identifies a key in the backup config without revealing it
+func GetEncryptionKeyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:16])
+}
+
+func IsEncrypted(contents []byte) bool {
+	return bytes.HasPrefix(contents, []byte(encryptionMagic))
+}
+
+/*
+ * Encrypted data consists of a header holding a random nonce prefix followed
+ * by chunks of at most 64KB of plaintext, each sealed separately under a
+ * nonce made of the prefix and the chunk's index.  Each chunk is preceded by
+ * its length, which is also authenticated.
+ */
+type encryptWriter struct {
+	writer     io.Writer
+	aead       cipher.AEAD
+	nonce      []byte
+	chunkIndex uint32
+	buffer     []byte
+	closed     bool
+}
+
+func NewEncryptWriter(writer io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce[:encryptionNoncePrefix])
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte(encryptionMagic), nonce[:encryptionNoncePrefix]...)
+	_, err = writer.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptWriter{writer: writer, aead: aead, nonce: nonce, buffer: make([]byte, 0, encryptionChunkSize)}, nil
+}
+
+func (writer *encryptWriter) Write(p []byte) (int, error) {
+	if writer.closed {
+		return 0, errors.New("Write to closed encryption stream")
+	}
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is not written until more data arrives, since it may be the last one
+		if len(writer.buffer) == encryptionChunkSize {
+			err := writer.writeChunk(false)
+			if err != nil {
+				return written, err
+			}
+		}
+		n := encryptionChunkSize - len(writer.buffer)
+		if n > len(p) {
+			n = len(p)
+		}
+		writer.buffer = append(writer.buffer, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the last chunk but does not close the underlying writer
+func (writer *encryptWriter) Close() error {
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	return writer.writeChunk(true)
+}
+
+func (writer *encryptWriter) writeChunk(final bool) error {
+	binary.BigEndian.PutUint32(writer.nonce[encryptionNoncePrefix:], writer.chunkIndex)
+	writer.chunkIndex++
+	length := uint32(len(writer.buffer) + writer.aead.Overhead())
+	if final {
+		length |= encryptionFinalChunk
+	}
+	lengthBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBytes, length)
+	sealed := writer.aead.Seal(lengthBytes, writer.nonce, writer.buffer, lengthBytes)
+	writer.buffer = writer.buffer[:0]
+	_, err := writer.writer.Write(sealed)
+	return err
+}
+
+type decryptReader struct {
+	reader     io.Reader
+	aead       cipher.AEAD
+	nonce      []byte
+	chunkIndex uint32
+	plaintext  []byte
+	done       bool
+}
+
+func NewDecryptReader(reader io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(encryptionMagic)+encryptionNoncePrefix)
+	_, err = io.ReadFull(reader, header)
+	if err != nil || !IsEncrypted(header) {
+		return nil, errors.New("Data is not encrypted or is corrupt")
+	}
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, header[len(encryptionMagic):])
+	return &decryptReader{reader: reader, aead: aead, nonce: nonce}, nil
+}
+
+func (reader *decryptReader) Read(p []byte) (int, error) {
+	for len(reader.plaintext) == 0 {
+		if reader.done {
+			return 0, io.EOF
+		}
+		err := reader.readChunk()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, reader.plaintext)
+	reader.plaintext = reader.plaintext[n:]
+	return n, nil
+}
+
+func (reader *decryptReader) readChunk() error {
+	lengthBytes := make([]byte, 4)
+	_, err := io.ReadFull(reader.reader, lengthBytes)
+	if err != nil {
+		return errors.New("Encrypted data is truncated")
+	}
+	length := binary.BigEndian.Uint32(lengthBytes)
+	final := length&encryptionFinalChunk != 0
+	length &^= encryptionFinalChunk
+	if length < uint32(reader.aead.Overhead()) || length > uint32(encryptionChunkSize+reader.aead.Overhead()) {
+		return errors.New("Encrypted data is corrupt")
+	}
+	sealed := make([]byte, length)
+	_, err = io.ReadFull(reader.reader, sealed)
+	if err != nil {
+		return errors.New("Encrypted data is truncated")
+	}
+	binary.BigEndian.PutUint32(reader.nonce[encryptionNoncePrefix:], reader.chunkIndex)
+	reader.chunkIndex++
+	reader.plaintext, err = reader.aead.Open(sealed[:0], reader.nonce, sealed, lengthBytes)
+	if err != nil {
+		return errors.New("Unable to decrypt data: the encryption key is incorrect or the data is corrupt")
+	}
+	reader.done = final
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func EncryptBytes(contents []byte, key []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer, err := NewEncryptWriter(&buffer, key)
+	if err != nil {
+		return nil, err
+	}
+	_, err = writer.Write(contents)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	return buffer.Bytes(), err
+}
+
+func DecryptBytes(contents []byte, key []byte) ([]byte, error) {
+	reader, err := NewDecryptReader(bytes.NewReader(contents), key)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	_, err = io.Copy(&buffer, reader)
+	return buffer.Bytes(), err
+}
+
+/*
+ * Reads a master backup file, decrypting it with the key set by
+ * SetEncryptionKey if it is encrypted.
+ */
+func ReadBackupFile(filename string) ([]byte, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil || !IsEncrypted(contents) {
+		return contents, err
+	}
+	if encryptionKey == nil {
+		return nil, errors.Errorf("File %s is encrypted, but no encryption key was provided", filename)
+	}
+	contents, err = DecryptBytes(contents, encryptionKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read file %s", filename)
+	}
+	return contents, nil
+}
+
+/*
+ * Encrypts a completed master backup file with the key set by
+ * SetEncryptionKey, if any.  Files are encrypted once complete because the
+ * table of contents records offsets into the unencrypted metadata file.
+ */
+func EncryptBackupFile(filename string) error {
+	if encryptionKey == nil {
+		return nil
+	}
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	contents, err = EncryptBytes(contents, encryptionKey)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filename)
+	if err != nil {
+		return err
+	}
+	return WriteToFileAndMakeReadOnly(filename, contents)
+}
+
+/*
+ * The key file is put in a directory with a random name, so that its path
+ * cannot be guessed and the file cannot be created at that path in advance.
+ */
+func NewEncryptionKeyFilePath(prefix string) string {
+	suffix := make([]byte, 16)
+	_, err := rand.Read(suffix)
+	gplog.FatalOnError(err)
+	return fmt.Sprintf("/tmp/%s_encryption_key_%s/key", prefix, hex.EncodeToString(suffix))
+}
+
+/*
+ * Segments read the key from a file copied to every host for the duration of
+ * the backup or restore, so that it does not need to be available there in
+ * advance.  The file is in a directory only its owner can read.  A backup or
+ * restore that is killed before it cleans up leaves the key on every host, so
+ * key files left by earlier runs are reported.
+ */
+func CopyEncryptionKeyToAllHosts(c *cluster.Cluster, key []byte, keyFile string) {
+	remoteOutput := c.GenerateAndExecuteCommand("Creating encryption key directory on all hosts", func(contentID int) string {
+		return fmt.Sprintf("ls -d /tmp/gpbackup_*_encryption_key* /tmp/gprestore_*_encryption_key* 2>/dev/null; mkdir -m 700 %s", path.Dir(keyFile))
+	}, cluster.ON_HOSTS_AND_MASTER)
+	c.CheckClusterError(remoteOutput, "Unable to create encryption key directory", func(contentID int) string {
+		return fmt.Sprintf("Unable to create encryption key directory on host %s", c.GetHostForContent(contentID))
+	})
+	for contentID, stdout := range remoteOutput.Stdouts {
+		if staleFiles := strings.Fields(stdout); len(staleFiles) > 0 {
+			gplog.Warn("Found encryption key file(s) on host %s left by a backup or restore that did not finish: %s.  "+
+				"Remove them once no other backup or restore is running.", c.GetHostForContent(contentID), strings.Join(staleFiles, ", "))
+		}
+	}
+
+	// TempFile creates the file readable only by its owner
+	localKeyFile, err := ioutil.TempFile("", "gpbackup_local_key_")
+	gplog.FatalOnError(err)
+	defer os.Remove(localKeyFile.Name())
+	_, err = localKeyFile.WriteString(hex.EncodeToString(key))
+	if err == nil {
+		err = localKeyFile.Close()
+	}
+	gplog.FatalOnError(err)
+	remoteOutput = c.GenerateAndExecuteCommand("Copying encryption key to all hosts", func(contentID int) string {
+		return fmt.Sprintf("scp %s %s:%s", localKeyFile.Name(), c.GetHostForContent(contentID), keyFile)
+	}, cluster.ON_MASTER_TO_HOSTS_AND_MASTER)
+	c.CheckClusterError(remoteOutput, "Unable to copy encryption key", func(contentID int) string {
+		return fmt.Sprintf("Unable to copy encryption key to host %s", c.GetHostForContent(contentID))
+	})
+}
+
+// The directory is removed with rmdir, so that nothing but the key file is ever removed with it
+func DeleteEncryptionKeyFromAllHosts(c *cluster.Cluster, keyFile string) {
+	remoteOutput := c.GenerateAndExecuteCommand("Removing encryption key from all hosts", func(contentID int) string {
+		return fmt.Sprintf("rm -f %s && (rmdir %s 2>/dev/null || true)", keyFile, path.Dir(keyFile))
+	}, cluster.ON_HOSTS_AND_MASTER)
+	c.CheckClusterError(remoteOutput, "Unable to remove encryption key", func(contentID int) string {
+		return fmt.Sprintf("Unable to remove encryption key from host %s", c.GetHostForContent(contentID))
+	}, true)
+}
+
+/*
+ * Data files written by COPY are encrypted and decrypted by piping them
+ * through gpbackup_helper, after compression and before decompression.
+ */
+func AddEncryptionToPipeThroughProgram(keyFile string) {
+	helper := fmt.Sprintf("%s/bin/gpbackup_helper", operating.System.Getenv("GPHOME"))
+	pipeThroughProgram.OutputCommand = fmt.Sprintf("%s | %s --encrypt-agent --encryption-key-file %s", pipeThroughProgram.OutputCommand, helper, keyFile)
+	pipeThroughProgram.InputCommand = fmt.Sprintf("%s --decrypt-agent --encryption-key-file %s | %s", helper, keyFile, pipeThroughProgram.InputCommand)
+}
@@ -0,0 +1,200 @@
+package utils_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/encryption tests", func() {
+	keyStr := strings.Repeat("0123456789abcdef", 4)
+	otherKeyStr := strings.Repeat("fedcba9876543210", 4)
+	var key, otherKey []byte
+
+	BeforeEach(func() {
+		key, _ = utils.ParseEncryptionKey(keyStr)
+		otherKey, _ = utils.ParseEncryptionKey(otherKeyStr)
+	})
+	Describe("ParseEncryptionKey", func() {
+		It("parses a key of 64 hexadecimal characters", func() {
+			parsedKey, err := utils.ParseEncryptionKey(keyStr + "\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsedKey).To(HaveLen(32))
+		})
+		It("returns an error for a key of the wrong length", func() {
+			_, err := utils.ParseEncryptionKey("0123456789abcdef")
+			Expect(err).To(MatchError("Encryption key must be 64 hexadecimal characters"))
+		})
+		It("returns an error for a key that is not hexadecimal", func() {
+			_, err := utils.ParseEncryptionKey(strings.Repeat("z", 64))
+			Expect(err).To(MatchError("Encryption key must be 64 hexadecimal characters"))
+		})
+	})
+	Describe("ReadEncryptionKey", func() {
+		AfterEach(func() {
+			operating.System.Getenv = os.Getenv
+			operating.System.ReadFile = ioutil.ReadFile
+		})
+		It("reads the key from the key file", func() {
+			operating.System.ReadFile = func(filename string) ([]byte, error) { return []byte(keyStr), nil }
+			operating.System.Getenv = func(key string) string { return otherKeyStr }
+			readKey, err := utils.ReadEncryptionKey("/tmp/keyfile")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readKey).To(Equal(key))
+		})
+		It("reads the key from the environment when there is no key file", func() {
+			operating.System.Getenv = func(key string) string { return otherKeyStr }
+			readKey, err := utils.ReadEncryptionKey("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readKey).To(Equal(otherKey))
+		})
+		It("returns an error when no key is provided", func() {
+			operating.System.Getenv = func(key string) string { return "" }
+			_, err := utils.ReadEncryptionKey("")
+			Expect(err).To(MatchError("No encryption key was provided.  Use --encryption-key-file or set GPBACKUP_ENCRYPTION_KEY."))
+		})
+	})
+	Describe("GetEncryptionKeyFingerprint", func() {
+		It("returns different fingerprints for different keys", func() {
+			fingerprint := utils.GetEncryptionKeyFingerprint(key)
+			Expect(fingerprint).To(HaveLen(32))
+			Expect(fingerprint).To(Equal(utils.GetEncryptionKeyFingerprint(key)))
+			Expect(fingerprint).ToNot(Equal(utils.GetEncryptionKeyFingerprint(otherKey)))
+			Expect(fingerprint).ToNot(ContainSubstring(keyStr[:32]))
+		})
+	})
+	Describe("EncryptBytes and DecryptBytes", func() {
+		It("decrypts empty data", func() {
+			encrypted, err := utils.EncryptBytes([]byte{}, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(utils.IsEncrypted(encrypted)).To(BeTrue())
+			decrypted, err := utils.DecryptBytes(encrypted, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(BeEmpty())
+		})
+		It("decrypts data spanning multiple chunks", func() {
+			plaintext := bytes.Repeat([]byte("table data,"), 20000)
+			encrypted, err := utils.EncryptBytes(plaintext, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bytes.Contains(encrypted, []byte("table data,"))).To(BeFalse())
+			decrypted, err := utils.DecryptBytes(encrypted, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal(plaintext))
+		})
+		It("returns an error when decrypting with the wrong key", func() {
+			encrypted, _ := utils.EncryptBytes([]byte("table data"), key)
+			_, err := utils.DecryptBytes(encrypted, otherKey)
+			Expect(err).To(MatchError("Unable to decrypt data: the encryption key is incorrect or the data is corrupt"))
+		})
+		It("returns an error when the data has been modified", func() {
+			encrypted, _ := utils.EncryptBytes([]byte("table data"), key)
+			encrypted[len(encrypted)-1] ^= 1
+			_, err := utils.DecryptBytes(encrypted, key)
+			Expect(err).To(MatchError("Unable to decrypt data: the encryption key is incorrect or the data is corrupt"))
+		})
+		It("returns an error when the data has been truncated at a chunk boundary", func() {
+			plaintext := bytes.Repeat([]byte("x"), 200000)
+			encrypted, _ := utils.EncryptBytes(plaintext, key)
+			// The header, length and tag of the first chunk followed by its 64KB of data
+			firstChunkEnd := 16 + 4 + 65536 + 16
+			_, err := utils.DecryptBytes(encrypted[:firstChunkEnd], key)
+			Expect(err).To(MatchError("Encrypted data is truncated"))
+		})
+		It("returns an error when the data is not encrypted", func() {
+			_, err := utils.DecryptBytes([]byte("SET search_path = public;"), key)
+			Expect(err).To(MatchError("Data is not encrypted or is corrupt"))
+		})
+	})
+	Describe("ReadBackupFile", func() {
+		AfterEach(func() {
+			operating.System.ReadFile = ioutil.ReadFile
+			utils.SetEncryptionKey(nil)
+		})
+		It("returns the contents of an unencrypted file", func() {
+			operating.System.ReadFile = func(filename string) ([]byte, error) { return []byte("contents"), nil }
+			contents, err := utils.ReadBackupFile("/tmp/file")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("contents"))
+		})
+		It("decrypts an encrypted file", func() {
+			encrypted, _ := utils.EncryptBytes([]byte("contents"), key)
+			operating.System.ReadFile = func(filename string) ([]byte, error) { return encrypted, nil }
+			utils.SetEncryptionKey(key)
+			contents, err := utils.ReadBackupFile("/tmp/file")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("contents"))
+		})
+		It("returns an error for an encrypted file when there is no key", func() {
+			encrypted, _ := utils.EncryptBytes([]byte("contents"), key)
+			operating.System.ReadFile = func(filename string) ([]byte, error) { return encrypted, nil }
+			_, err := utils.ReadBackupFile("/tmp/file")
+			Expect(err).To(MatchError("File /tmp/file is encrypted, but no encryption key was provided"))
+		})
+	})
+	Describe("AddEncryptionToPipeThroughProgram", func() {
+		It("encrypts after compressing and decrypts before decompressing", func() {
+			originalProgram := utils.GetPipeThroughProgram()
+			defer utils.SetPipeThroughProgram(originalProgram)
+			operating.System.Getenv = func(key string) string { return "/usr/local/gpdb" }
+			defer func() { operating.System.Getenv = os.Getenv }()
+			utils.SetPipeThroughProgram(utils.PipeThroughProgram{Name: "gzip", OutputCommand: "gzip -c -1", InputCommand: "gzip -d -c", Extension: ".gz"})
+
+			utils.AddEncryptionToPipeThroughProgram("/tmp/key")
+
+			program := utils.GetPipeThroughProgram()
+			Expect(program.OutputCommand).To(Equal("gzip -c -1 | /usr/local/gpdb/bin/gpbackup_helper --encrypt-agent --encryption-key-file /tmp/key"))
+			Expect(program.InputCommand).To(Equal("/usr/local/gpdb/bin/gpbackup_helper --decrypt-agent --encryption-key-file /tmp/key | gzip -d -c"))
+			Expect(program.Extension).To(Equal(".gz"))
+		})
+	})
+	Describe("NewEncryptionKeyFilePath", func() {
+		It("returns a different key file path under /tmp each time", func() {
+			keyFile := utils.NewEncryptionKeyFilePath("gpbackup_20170101010101")
+
+			Expect(keyFile).To(MatchRegexp(`^/tmp/gpbackup_20170101010101_encryption_key_[0-9a-f]{32}/key$`))
+			Expect(utils.NewEncryptionKeyFilePath("gpbackup_20170101010101")).ToNot(Equal(keyFile))
+		})
+	})
+	Describe("CopyEncryptionKeyToAllHosts", func() {
+		var (
+			testCluster  *cluster.Cluster
+			testExecutor *testhelper.TestExecutor
+		)
+		BeforeEach(func() {
+			testExecutor = &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{}}
+			testCluster = cluster.NewCluster([]cluster.SegConfig{
+				{ContentID: -1, Hostname: "masterhost", DataDir: "/data/gpseg-1"},
+				{ContentID: 0, Hostname: "remotehost1", DataDir: "/data/gpseg0"},
+			})
+			testCluster.Executor = testExecutor
+		})
+		It("creates a private directory for the key on every host and copies the key into it", func() {
+			utils.CopyEncryptionKeyToAllHosts(testCluster, key, "/tmp/gpbackup_1_encryption_key_abc/key")
+
+			Expect(testExecutor.NumExecutions).To(Equal(2))
+			for _, command := range testExecutor.ClusterCommands[0] {
+				Expect(command[len(command)-1]).To(HaveSuffix("; mkdir -m 700 /tmp/gpbackup_1_encryption_key_abc"))
+			}
+			for _, command := range testExecutor.ClusterCommands[1] {
+				Expect(command[len(command)-1]).To(MatchRegexp(`^scp \S+ \w+:/tmp/gpbackup_1_encryption_key_abc/key$`))
+			}
+			Expect(string(logfile.Contents())).ToNot(ContainSubstring("left by a backup or restore that did not finish"))
+		})
+		It("warns about key files left on a host by earlier runs", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{Stdouts: map[int]string{0: "/tmp/gpbackup_20170101010101_encryption_key\n"}}
+
+			utils.CopyEncryptionKeyToAllHosts(testCluster, key, "/tmp/gpbackup_1_encryption_key_abc/key")
+
+			Expect(string(logfile.Contents())).To(ContainSubstring("Found encryption key file(s) on host remotehost1 left by a backup or restore that did not finish: /tmp/gpbackup_20170101010101_encryption_key."))
+		})
+	})
+})
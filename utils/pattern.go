@@ -0,0 +1,130 @@
+package utils
+
+/*
+ * This file contains functions related to glob and regular expression
+ * patterns in table and schema filters.
+ */
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	FILTER_GLOB_PREFIX  = "glob:"
+	FILTER_REGEX_PREFIX = "re:"
+)
+
+/*
+ * A filter is a pattern only if it is marked by the "glob:" or "re:" prefix;
+ * any other filter is an exact name, so that names containing *, ?, or [ are
+ * never mistaken for patterns.  In a glob, * matches any characters, ? matches
+ * one character, and [...] matches one of a set of characters.
+ */
+func IsFilterPattern(filter string) bool {
+	return strings.HasPrefix(filter, FILTER_GLOB_PREFIX) || strings.HasPrefix(filter, FILTER_REGEX_PREFIX)
+}
+
+// Both kinds of pattern are anchored, so they must match the whole name
+func CompileFilterPattern(filter string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(filter, FILTER_REGEX_PREFIX) {
+		pattern, err := regexp.Compile("^(?:" + strings.TrimPrefix(filter, FILTER_REGEX_PREFIX) + ")$")
+		if err != nil {
+			return nil, errors.Errorf("Invalid regular expression in filter %s: %v", filter, err)
+		}
+		return pattern, nil
+	}
+	return compileGlob(filter)
+}
+
+func compileGlob(filter string) (*regexp.Regexp, error) {
+	glob := strings.TrimPrefix(filter, FILTER_GLOB_PREFIX)
+	var builder strings.Builder
+	builder.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 1 {
+				return nil, errors.Errorf("Invalid glob pattern in filter %s: unterminated [", filter)
+			}
+			class := glob[i+1 : i+1+end]
+			if class[0] == '!' {
+				class = "^" + class[1:]
+			}
+			builder.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			builder.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	builder.WriteString("$")
+	pattern, err := regexp.Compile(builder.String())
+	if err != nil {
+		return nil, errors.Errorf("Invalid glob pattern in filter %s: %v", filter, err)
+	}
+	return pattern, nil
+}
+
+func ValidateFilterPatterns(filterList []string) error {
+	for _, filter := range filterList {
+		if IsFilterPattern(filter) {
+			if _, err := CompileFilterPattern(filter); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ * Returns the filters with each pattern replaced by the names it matches, in
+ * sorted order and without duplicates, along with the patterns that match no
+ * names.  The candidates map the unquoted name that a pattern is matched
+ * against to the name that replaces the pattern.
+ */
+func ExpandFilterPatterns(filterList []string, candidates map[string]string) ([]string, []string, error) {
+	matchNames := make([]string, 0, len(candidates))
+	for matchName := range candidates {
+		matchNames = append(matchNames, matchName)
+	}
+	sort.Strings(matchNames)
+
+	expanded := make([]string, 0, len(filterList))
+	unmatched := make([]string, 0)
+	seen := make(map[string]bool, len(filterList))
+	addName := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			expanded = append(expanded, name)
+		}
+	}
+	for _, filter := range filterList {
+		if !IsFilterPattern(filter) {
+			addName(filter)
+			continue
+		}
+		pattern, err := CompileFilterPattern(filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		numMatches := 0
+		for _, matchName := range matchNames {
+			if pattern.MatchString(matchName) {
+				addName(candidates[matchName])
+				numMatches++
+			}
+		}
+		if numMatches == 0 {
+			unmatched = append(unmatched, filter)
+		}
+	}
+	return expanded, unmatched, nil
+}
@@ -0,0 +1,81 @@
+package utils_test
+
+import (
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/pattern tests", func() {
+	Describe("IsFilterPattern", func() {
+		It("recognizes globs and regular expressions by their prefixes", func() {
+			Expect(utils.IsFilterPattern("glob:staging.tmp_*")).To(BeTrue())
+			Expect(utils.IsFilterPattern(`re:staging\.tmp_\d+`)).To(BeTrue())
+			Expect(utils.IsFilterPattern("staging.tmp_1")).To(BeFalse())
+		})
+		It("treats a filter without a prefix as an exact name", func() {
+			Expect(utils.IsFilterPattern("staging.tmp_*")).To(BeFalse())
+			Expect(utils.IsFilterPattern("staging.tmp_?")).To(BeFalse())
+			Expect(utils.IsFilterPattern("staging.tmp_[0-9]")).To(BeFalse())
+		})
+	})
+	Describe("CompileFilterPattern", func() {
+		It("anchors a glob and matches its wildcards", func() {
+			pattern, err := utils.CompileFilterPattern("glob:staging.tmp_*")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pattern.MatchString("staging.tmp_orders")).To(BeTrue())
+			Expect(pattern.MatchString("staging.tmp_")).To(BeTrue())
+			Expect(pattern.MatchString("stagingxtmp_orders")).To(BeFalse())
+			Expect(pattern.MatchString("old_staging.tmp_orders")).To(BeFalse())
+		})
+		It("matches a single character and character classes in a glob", func() {
+			pattern, err := utils.CompileFilterPattern("glob:sales.q?_[0-9][!a]")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pattern.MatchString("sales.q1_2b")).To(BeTrue())
+			Expect(pattern.MatchString("sales.q1_2a")).To(BeFalse())
+			Expect(pattern.MatchString("sales.q12_2b")).To(BeFalse())
+		})
+		It("anchors a regular expression", func() {
+			pattern, err := utils.CompileFilterPattern(`re:staging\.tmp_\d+|scratch\..*`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pattern.MatchString("staging.tmp_42")).To(BeTrue())
+			Expect(pattern.MatchString("scratch.anything")).To(BeTrue())
+			Expect(pattern.MatchString("staging.tmp_42_old")).To(BeFalse())
+		})
+		It("returns an error for an invalid regular expression", func() {
+			_, err := utils.CompileFilterPattern("re:staging.tmp_(")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Invalid regular expression in filter re:staging.tmp_("))
+		})
+		It("returns an error for an unterminated character class", func() {
+			_, err := utils.CompileFilterPattern("glob:staging.tmp_[0-9")
+			Expect(err).To(MatchError("Invalid glob pattern in filter glob:staging.tmp_[0-9: unterminated ["))
+		})
+	})
+	Describe("ExpandFilterPatterns", func() {
+		candidates := map[string]string{
+			"staging.tmp_b": `staging."tmp_b"`,
+			"staging.tmp_a": "staging.tmp_a",
+			"staging.keep":  "staging.keep",
+		}
+		It("replaces each pattern with the names it matches", func() {
+			expanded, unmatched, err := utils.ExpandFilterPatterns([]string{"public.foo", "glob:staging.tmp_*", "staging.tmp_a"}, candidates)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(expanded).To(Equal([]string{"public.foo", "staging.tmp_a", `staging."tmp_b"`}))
+			Expect(unmatched).To(BeEmpty())
+		})
+		It("returns the patterns that match nothing", func() {
+			expanded, unmatched, err := utils.ExpandFilterPatterns([]string{"re:scratch\\..*", "staging.keep"}, candidates)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(expanded).To(Equal([]string{"staging.keep"}))
+			Expect(unmatched).To(Equal([]string{"re:scratch\\..*"}))
+		})
+		It("leaves a name containing glob characters as it is", func() {
+			expanded, unmatched, err := utils.ExpandFilterPatterns([]string{"staging.tmp_*"}, candidates)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(expanded).To(Equal([]string{"staging.tmp_*"}))
+			Expect(unmatched).To(BeEmpty())
+		})
+	})
+})
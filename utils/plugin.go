@@ -76,14 +76,34 @@ func (plugin *PluginConfig) MustBackupFile(filenamePath string) {
 	gplog.FatalOnError(err)
 }
 
-func (plugin *PluginConfig) MustRestoreFile(filenamePath string) {
+func (plugin *PluginConfig) RestoreFile(filenamePath string) error {
 	directory, _ := path.Split(filenamePath)
 	err := operating.System.MkdirAll(directory, 0755)
-	gplog.FatalOnError(err)
+	if err != nil {
+		return err
+	}
 	command := fmt.Sprintf("%s restore_file %s %s", plugin.ExecutablePath, plugin.ConfigPath, filenamePath)
 	gplog.Debug("%s", command)
 	output, err := exec.Command("bash", "-c", command).CombinedOutput()
-	gplog.FatalOnError(err, string(output))
+	if err != nil {
+		return fmt.Errorf("ERROR: Plugin failed to process %s. %s", filenamePath, string(output))
+	}
+	return nil
+}
+
+func (plugin *PluginConfig) MustRestoreFile(filenamePath string) {
+	err := plugin.RestoreFile(filenamePath)
+	gplog.FatalOnError(err)
+}
+
+func (plugin *PluginConfig) DeleteBackup(c *cluster.Cluster, timestamp string) error {
+	command := fmt.Sprintf("%s delete_backup %s %s", plugin.ExecutablePath, plugin.ConfigPath, timestamp)
+	gplog.Debug("%s", command)
+	output, err := c.ExecuteLocalCommand(command)
+	if err != nil {
+		return fmt.Errorf("ERROR: Plugin failed to delete backup %s. %s", timestamp, output)
+	}
+	return nil
 }
 
 func (plugin *PluginConfig) CheckPluginExistsOnAllHosts(c *cluster.Cluster) string {
@@ -395,20 +415,10 @@ func GetSecretKey(pluginName string, mdd string) (string, error) {
 }
 
 func (plugin *PluginConfig) BackupSegmentTOCs(c *cluster.Cluster, fpInfo filepath.FilePathInfo) {
-	var command string
-	remoteOutput := c.GenerateAndExecuteCommand("Waiting for remaining data to be uploaded to plugin destination",
-		func(contentID int) string {
-			tocFile := fpInfo.GetSegmentTOCFilePath(contentID)
-			errorFile := fmt.Sprintf("%s_error", fpInfo.GetSegmentPipeFilePath(contentID))
-			command = fmt.Sprintf(`while [[ ! -f "%s" && ! -f "%s" ]]; do sleep 1; done; ls "%s"`, tocFile, errorFile, tocFile)
-			return command
-		}, cluster.ON_SEGMENTS)
-	gplog.Debug("%s", command)
-	c.CheckClusterError(remoteOutput, "Error occurred in gpbackup_helper", func(contentID int) string {
-		return "See gpAdminLog for gpbackup_helper on segment host for details: Error occurred with plugin"
-	})
+	WaitForSegmentTOCs(c, fpInfo, "Waiting for remaining data to be uploaded to plugin destination",
+		"See gpAdminLog for gpbackup_helper on segment host for details: Error occurred with plugin")
 
-	remoteOutput = c.GenerateAndExecuteCommand("Processing segment TOC files with plugin",
+	remoteOutput := c.GenerateAndExecuteCommand("Processing segment TOC files with plugin",
 		func(contentID int) string {
 			tocFile := fpInfo.GetSegmentTOCFilePath(contentID)
 			return fmt.Sprintf("source %s/greenplum_path.sh && %s backup_file %s %s && " +
@@ -316,6 +316,22 @@ options:
 			})
 		})
 	})
+	Describe("DeleteBackup", func() {
+		It("calls the plugin's delete_backup command with the config and timestamp", func() {
+			err := subject.DeleteBackup(testCluster, "20170101010101")
+
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(executor.LocalCommands[0]).To(Equal("/a/b/myPlugin delete_backup /tmp/my_plugin_config.yaml 20170101010101"))
+		})
+		It("returns an error including the plugin output when the plugin fails", func() {
+			executor.LocalOutput = "backup not found"
+			executor.LocalError = errors.New("exit status 1")
+			err := subject.DeleteBackup(testCluster, "20170101010101")
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("ERROR: Plugin failed to delete backup 20170101010101. backup not found"))
+		})
+	})
 	Describe("GetPluginName", func() {
 		It("make the correct plugin call, parses out plugin name correctly, and returns it", func() {
 			executor.LocalOutput = "gpbackup_fake_plugin version 1.0.1+dev.28.g00c877e"
@@ -5,6 +5,10 @@ package utils
  * for use in filtering lists.
  */
 
+import (
+	"regexp"
+)
+
 /*
  * This set implementation can be used in one of two ways.  An "include" set
  * returns true if an item is in the map and false otherwise, while an "exclude"
@@ -15,11 +19,16 @@ package utils
  * if an empty list is passed, so that it doesn't attempt to filter on anything
  * The isExclude variable controls whether a set is an include set or an exclude
  * set.
+ *
+ * The items of include and exclude sets are filters given by the user, so
+ * those that are glob or regular expression patterns also match any item that
+ * the pattern matches.
  */
 type FilterSet struct {
 	Set                 map[string]bool
 	IsExclude           bool
 	AlwaysMatchesFilter bool
+	patterns            []*regexp.Regexp
 }
 
 func NewSet(list []string) *FilterSet {
@@ -31,9 +40,21 @@ func NewSet(list []string) *FilterSet {
 	return &newSet
 }
 
+func (s *FilterSet) compilePatterns() {
+	for item := range s.Set {
+		if IsFilterPattern(item) {
+			// Patterns are validated when the filters are parsed
+			if pattern, err := CompileFilterPattern(item); err == nil {
+				s.patterns = append(s.patterns, pattern)
+			}
+		}
+	}
+}
+
 func NewIncludeSet(list []string) *FilterSet {
 	newSet := NewSet(list)
 	(*newSet).AlwaysMatchesFilter = len(list) == 0
+	newSet.compilePatterns()
 	return newSet
 }
 
@@ -41,6 +62,7 @@ func NewExcludeSet(list []string) *FilterSet {
 	newSet := NewSet(list)
 	(*newSet).AlwaysMatchesFilter = len(list) == 0
 	(*newSet).IsExclude = true
+	newSet.compilePatterns()
 	return newSet
 }
 
@@ -49,6 +71,9 @@ func (s *FilterSet) MatchesFilter(item string) bool {
 		return true
 	}
 	_, matches := s.Set[item]
+	for i := 0; !matches && i < len(s.patterns); i++ {
+		matches = s.patterns[i].MatchString(item)
+	}
 	if s.IsExclude {
 		return !matches
 	}
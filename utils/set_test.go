@@ -120,4 +120,31 @@ var _ = Describe("utils/set tests", func() {
 			Expect(utils.NewIncludeSet([]string{}).Equals(utils.NewIncludeSet([]string{}))).To(BeTrue())
 		})
 	})
+	Describe("MatchesFilter with patterns", func() {
+		It("matches items that a pattern in an include set matches", func() {
+			includeSet := utils.NewIncludeSet([]string{"public.foo", "glob:staging.tmp_*"})
+
+			Expect(includeSet.MatchesFilter("public.foo")).To(BeTrue())
+			Expect(includeSet.MatchesFilter("staging.tmp_orders")).To(BeTrue())
+			Expect(includeSet.MatchesFilter("staging.orders")).To(BeFalse())
+		})
+		It("does not match items that a pattern in an exclude set matches", func() {
+			excludeSet := utils.NewExcludeSet([]string{`re:staging\.tmp_[0-9]+`})
+
+			Expect(excludeSet.MatchesFilter("staging.tmp_1")).To(BeFalse())
+			Expect(excludeSet.MatchesFilter("staging.tmp_x")).To(BeTrue())
+		})
+		It("treats an item without a pattern prefix as an exact name", func() {
+			includeSet := utils.NewIncludeSet([]string{"staging.tmp_*"})
+
+			Expect(includeSet.MatchesFilter("staging.tmp_orders")).To(BeFalse())
+			Expect(includeSet.MatchesFilter("staging.tmp_*")).To(BeTrue())
+		})
+		It("does not treat the items of a plain set as patterns", func() {
+			plainSet := utils.NewSet([]string{"glob:staging.tmp_*"})
+
+			Expect(plainSet.MatchesFilter("staging.tmp_orders")).To(BeFalse())
+			Expect(plainSet.MatchesFilter("glob:staging.tmp_*")).To(BeTrue())
+		})
+	})
 })
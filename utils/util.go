@@ -14,6 +14,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gpbackup/filepath"
@@ -79,6 +80,10 @@ func MakeFQN(schema string, object string) string {
 func ValidateFQNs(tableList []string) error {
 	validFormat := regexp.MustCompile(`^[^.]+\.[^.]+$`)
 	for _, fqn := range tableList {
+		// A regular expression may contain dots, and is matched against the whole name
+		if strings.HasPrefix(fqn, FILTER_REGEX_PREFIX) {
+			continue
+		}
 		if !validFormat.Match([]byte(fqn)) {
 			return errors.Errorf(`Table "%s" is not correctly fully-qualified.  Please ensure table is in the format "schema.table" and both the schema and table does not contain a dot (.).`, fqn)
 		}
@@ -94,13 +99,6 @@ func ValidateFullPath(path string) error {
 	return nil
 }
 
-func ValidateCompressionLevel(compressionLevel int) error {
-	if compressionLevel < 1 || compressionLevel > 9 {
-		return errors.Errorf("Compression level must be between 1 and 9")
-	}
-	return nil
-}
-
 func InitializeSignalHandler(cleanupFunc func(bool), procDesc string, termFlag *bool) {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
@@ -180,6 +178,16 @@ func QuoteIdent(connectionPool *dbconn.DBConn, ident string) string {
 	return dbconn.MustSelectString(connectionPool, fmt.Sprintf(`SELECT quote_ident('%s')`, EscapeSingleQuotes(ident)))
 }
 
+/*
+ * Quotes an identifier when there is no database connection to call
+ * quote_ident() with.  Identifiers are always quoted, as without the server's
+ * keyword list there is no way to tell whether a lowercase name such as user
+ * or order needs quoting.
+ */
+func QuoteIdentWithoutConnection(ident string) string {
+	return fmt.Sprintf(`"%s"`, strings.Replace(ident, `"`, `""`, -1))
+}
+
 func SliceToQuotedString(slice []string) string {
 	quotedStrings := make([]string, len(slice))
 	for i, str := range slice {
@@ -191,3 +199,14 @@ func SliceToQuotedString(slice []string) string {
 func EscapeSingleQuotes(str string) string {
 	return strings.Replace(str, "'", "''", -1)
 }
+
+// The master is not counted, as it does not store any table data
+func GetSegmentCount(c *cluster.Cluster) int {
+	numSegments := 0
+	for _, contentID := range c.ContentIDs {
+		if contentID >= 0 {
+			numSegments++
+		}
+	}
+	return numSegments
+}
@@ -1,6 +1,7 @@
 package utils_test
 
 import (
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
 	"github.com/greenplum-db/gp-common-go-libs/testhelper"
 	"github.com/greenplum-db/gpbackup/utils"
 
@@ -103,23 +104,6 @@ var _ = Describe("utils/util tests", func() {
 			utils.ValidateGPDBVersionCompatibility(connectionPool)
 		})
 	})
-	Describe("ValidateCompressionLevel", func() {
-		It("validates a compression level between 1 and 9", func() {
-			compressLevel := 5
-			err := utils.ValidateCompressionLevel(compressLevel)
-			Expect(err).To(Not(HaveOccurred()))
-		})
-		It("panics if given a compression level < 1", func() {
-			compressLevel := 0
-			err := utils.ValidateCompressionLevel(compressLevel)
-			Expect(err).To(MatchError("Compression level must be between 1 and 9"))
-		})
-		It("panics if given a compression level > 9", func() {
-			compressLevel := 11
-			err := utils.ValidateCompressionLevel(compressLevel)
-			Expect(err).To(MatchError("Compression level must be between 1 and 9"))
-		})
-	})
 	Describe("UnquoteIdent", func() {
 		It("returns unchanged ident when passed a single char", func() {
 			dbname := `a`
@@ -152,6 +136,21 @@ var _ = Describe("utils/util tests", func() {
 			Expect(resultString).To(Equal(`"test`))
 		})
 	})
+	Describe("QuoteIdentWithoutConnection", func() {
+		It("quotes an identifier that is a valid unquoted name", func() {
+			Expect(utils.QuoteIdentWithoutConnection("test_db1")).To(Equal(`"test_db1"`))
+		})
+		It("quotes an identifier that is a keyword", func() {
+			Expect(utils.QuoteIdentWithoutConnection("user")).To(Equal(`"user"`))
+			Expect(utils.QuoteIdentWithoutConnection("order")).To(Equal(`"order"`))
+		})
+		It("quotes an identifier with uppercase letters or special characters", func() {
+			Expect(utils.QuoteIdentWithoutConnection("Test-DB")).To(Equal(`"Test-DB"`))
+		})
+		It("escapes double quotes in a quoted identifier", func() {
+			Expect(utils.QuoteIdentWithoutConnection(`a"b`)).To(Equal(`"a""b"`))
+		})
+	})
 	Describe("SliceToQuotedString", func() {
 		It("quotes and joins a slice of strings into a single string", func() {
 			inputStrings := []string{"string1", "string2", "string3"}
@@ -165,4 +164,14 @@ var _ = Describe("utils/util tests", func() {
 			Expect(resultString).To(Equal(""))
 		})
 	})
+	Describe("GetSegmentCount", func() {
+		It("counts the segments of the cluster without the master", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{ContentID: -1, Hostname: "localhost", DataDir: "/data/gpseg-1"},
+				{ContentID: 0, Hostname: "localhost", DataDir: "/data/gpseg0"},
+				{ContentID: 1, Hostname: "remotehost1", DataDir: "/data/gpseg1"},
+			})
+			Expect(utils.GetSegmentCount(testCluster)).To(Equal(2))
+		})
+	})
 })